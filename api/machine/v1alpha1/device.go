@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import "fmt"
+
+// MachineTransport is the transport over which the virtio devices of a
+// machine are exposed to its kernel.
+type MachineTransport string
+
+const (
+	// MachineTransportPCI exposes virtio devices on the PCI bus of the machine.
+	MachineTransportPCI = MachineTransport("pci")
+
+	// MachineTransportMMIO exposes virtio devices as memory-mapped devices, which
+	// does not require the machine to emulate a PCI bus.
+	MachineTransportMMIO = MachineTransport("mmio")
+)
+
+// ParseMachineTransport returns the transport with the provided name, where an
+// empty name leaves the choice to the platform.
+func ParseMachineTransport(s string) (MachineTransport, error) {
+	switch transport := MachineTransport(s); transport {
+	case "", MachineTransportPCI, MachineTransportMMIO:
+		return transport, nil
+	default:
+		return "", fmt.Errorf("unknown transport: %s (choice of %s or %s)", s, MachineTransportPCI, MachineTransportMMIO)
+	}
+}
+
+// MachineNetworkModel is the model of the network devices of a machine.
+type MachineNetworkModel string
+
+const (
+	MachineNetworkModelVirtio  = MachineNetworkModel("virtio")
+	MachineNetworkModelE1000   = MachineNetworkModel("e1000")
+	MachineNetworkModelRtl8139 = MachineNetworkModel("rtl8139")
+	MachineNetworkModelVmxnet3 = MachineNetworkModel("vmxnet3")
+)
+
+// ParseMachineNetworkModel returns the network model with the provided name,
+// where an empty name leaves the choice to the platform.
+func ParseMachineNetworkModel(s string) (MachineNetworkModel, error) {
+	switch model := MachineNetworkModel(s); model {
+	case "",
+		MachineNetworkModelVirtio,
+		MachineNetworkModelE1000,
+		MachineNetworkModelRtl8139,
+		MachineNetworkModelVmxnet3:
+		return model, nil
+	default:
+		return "", fmt.Errorf("unknown network model: %s", s)
+	}
+}
+
+// IsVirtio returns whether devices of the network model are virtio devices,
+// which is the case for the default model.
+func (model MachineNetworkModel) IsVirtio() bool {
+	return model == "" || model == MachineNetworkModelVirtio
+}
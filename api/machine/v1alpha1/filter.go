@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ParseLabels parses labels in the format KEY=VALUE, or KEY for a label with
+// an empty value.  Keys and values are validated in the same way as the labels
+// of any other object.
+func ParseLabels(kvs []string) (map[string]string, error) {
+	ret := make(map[string]string, len(kvs))
+
+	for _, kv := range kvs {
+		key, value, _ := strings.Cut(kv, "=")
+
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid label key: %s: %s", key, strings.Join(errs, "; "))
+		}
+
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid label value: %s: %s", value, strings.Join(errs, "; "))
+		}
+
+		ret[key] = value
+	}
+
+	return ret, nil
+}
+
+// ParseAnnotations parses annotations in the format KEY=VALUE.  Unlike the
+// value of a label, the value of an annotation is not restricted.
+func ParseAnnotations(kvs []string) (map[string]string, error) {
+	ret := make(map[string]string, len(kvs))
+
+	for _, kv := range kvs {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid annotation: %s (expected KEY=VALUE)", kv)
+		}
+
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid annotation key: %s: %s", key, strings.Join(errs, "; "))
+		}
+
+		ret[key] = value
+	}
+
+	return ret, nil
+}
+
+// MachineFilter selects machines by their labels, name or UID, state and
+// platform.  All of its conditions must be met for a machine to be selected,
+// whereas a machine only needs to match one of several names, states or
+// platforms.
+type MachineFilter struct {
+	// Labels selects machines by their labels.
+	Labels labels.Selector
+
+	// Names contains the names or UIDs of the machines which are selected.
+	Names []string
+
+	// States contains the states of the machines which are selected.
+	States []MachineState
+
+	// Platforms contains the platforms of the machines which are selected.
+	Platforms []string
+}
+
+// ParseMachineFilter parses comma-separated filters in the format
+// label=KEY[=VALUE], name=NAME, id=UID, state=STATE or plat=PLATFORM.
+func ParseMachineFilter(filters []string) (*MachineFilter, error) {
+	filter := MachineFilter{
+		Labels: labels.Everything(),
+	}
+
+	for _, term := range filters {
+		for _, term := range strings.Split(term, ",") {
+			if term == "" {
+				continue
+			}
+
+			kind, value, ok := strings.Cut(term, "=")
+			if !ok || value == "" {
+				return nil, fmt.Errorf("invalid filter: %s (expected label=KEY[=VALUE], name=NAME, id=UID, state=STATE or plat=PLATFORM)", term)
+			}
+
+			switch kind {
+			case "label":
+				key, value, hasValue := strings.Cut(value, "=")
+
+				op := selection.Exists
+				values := []string{}
+				if hasValue {
+					op = selection.Equals
+					values = []string{value}
+				}
+
+				req, err := labels.NewRequirement(key, op, values)
+				if err != nil {
+					return nil, fmt.Errorf("invalid label filter: %w", err)
+				}
+
+				filter.Labels = filter.Labels.Add(*req)
+
+			case "name", "id":
+				filter.Names = append(filter.Names, value)
+
+			case "state":
+				filter.States = append(filter.States, MachineState(value))
+
+			case "plat":
+				filter.Platforms = append(filter.Platforms, value)
+
+			default:
+				return nil, fmt.Errorf("unknown filter: %s (choice of label, name, id, state or plat)", kind)
+			}
+		}
+	}
+
+	return &filter, nil
+}
+
+// Matches returns whether the provided machine is selected by the filter.
+func (filter *MachineFilter) Matches(machine *Machine) bool {
+	if filter.Labels != nil && !filter.Labels.Matches(labels.Set(machine.Labels)) {
+		return false
+	}
+
+	if len(filter.Names) > 0 && !containsAny(filter.Names, machine.Name, string(machine.UID)) {
+		return false
+	}
+
+	if len(filter.States) > 0 {
+		found := false
+		for _, state := range filter.States {
+			if state == machine.Status.State {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if len(filter.Platforms) > 0 && !containsAny(filter.Platforms, machine.Spec.Platform) {
+		return false
+	}
+
+	return true
+}
+
+// containsAny returns whether any of the provided values is in the list.
+func containsAny(list []string, values ...string) bool {
+	for _, item := range list {
+		for _, value := range values {
+			if item == value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
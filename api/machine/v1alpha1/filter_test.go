@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMachineFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		filters   []string
+		labels    string
+		names     []string
+		states    []MachineState
+		platforms []string
+		wantErr   bool
+	}{
+		{
+			name: "no filters",
+		},
+		{
+			name:    "label key",
+			filters: []string{"label=app"},
+			labels:  "app",
+		},
+		{
+			name:    "label key and value",
+			filters: []string{"label=app=web"},
+			labels:  "app=web",
+		},
+		{
+			name:    "label with empty value",
+			filters: []string{"label=app="},
+			labels:  "app=",
+		},
+		{
+			name:    "state",
+			filters: []string{"state=running"},
+			states:  []MachineState{MachineStateRunning},
+		},
+		{
+			name:      "plat",
+			filters:   []string{"plat=qemu"},
+			platforms: []string{"qemu"},
+		},
+		{
+			name:    "name and id",
+			filters: []string{"name=hello", "id=0b9d6f2c"},
+			names:   []string{"hello", "0b9d6f2c"},
+		},
+		{
+			name:      "combined in one flag",
+			filters:   []string{"label=tier=front,label=app,state=running,state=exited,plat=fc"},
+			labels:    "app,tier=front",
+			states:    []MachineState{MachineStateRunning, MachineStateExited},
+			platforms: []string{"fc"},
+		},
+		{
+			name:      "combined across flags",
+			filters:   []string{"label=app=web", "plat=qemu,", "state=running"},
+			labels:    "app=web",
+			states:    []MachineState{MachineStateRunning},
+			platforms: []string{"qemu"},
+		},
+		{
+			name:    "missing value",
+			filters: []string{"label"},
+			wantErr: true,
+		},
+		{
+			name:    "empty value",
+			filters: []string{"state="},
+			wantErr: true,
+		},
+		{
+			name:    "unknown kind",
+			filters: []string{"image=nginx"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid label key",
+			filters: []string{"label=-app"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid label value",
+			filters: []string{"label=app=web server"},
+			wantErr: true,
+		},
+		{
+			name:    "malformed term among valid ones",
+			filters: []string{"plat=qemu,bogus"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseMachineFilter(tt.filters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := filter.Labels.String(); got != tt.labels {
+				t.Errorf("Expected labels %q, got %q", tt.labels, got)
+			}
+
+			if !reflect.DeepEqual(filter.Names, tt.names) {
+				t.Errorf("Expected names [%s], got [%s]", strings.Join(tt.names, ", "), strings.Join(filter.Names, ", "))
+			}
+
+			if !reflect.DeepEqual(filter.States, tt.states) {
+				t.Errorf("Expected states %v, got %v", tt.states, filter.States)
+			}
+
+			if !reflect.DeepEqual(filter.Platforms, tt.platforms) {
+				t.Errorf("Expected platforms [%s], got [%s]", strings.Join(tt.platforms, ", "), strings.Join(filter.Platforms, ", "))
+			}
+		})
+	}
+}
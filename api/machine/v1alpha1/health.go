@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultHealthCheckInterval is the time between two health checks.
+	DefaultHealthCheckInterval = 5 * time.Second
+
+	// DefaultHealthCheckTimeout is how long a single health check may take
+	// before it is considered to have failed.
+	DefaultHealthCheckTimeout = 3 * time.Second
+
+	// DefaultHealthCheckRetries is the number of consecutive failed health
+	// checks after which a machine is considered unhealthy.
+	DefaultHealthCheckRetries = 3
+)
+
+// MachineHealthCheck determines how the application inside of a machine is
+// checked for whether it is serving.  Exactly one of TCP, HTTP or Log is set.
+type MachineHealthCheck struct {
+	// TCP is the [HOST:]PORT which must accept connections.  Without a host, the
+	// port is that of the machine, which is dialed via the host port it is
+	// published on or, if it is not published, on the first IP address of the
+	// machine.
+	TCP string `json:"tcp,omitempty"`
+
+	// HTTP is the URL to which a GET request must be answered with a 2xx or 3xx
+	// status code.
+	HTTP string `json:"http,omitempty"`
+
+	// Log is a regular expression which a line of the output of the machine
+	// must match.
+	Log string `json:"log,omitempty"`
+
+	// Interval is the time between two checks.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Timeout is how long a single check may take.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Retries is the number of consecutive failed checks after which the
+	// machine is considered unhealthy.
+	Retries int `json:"retries,omitempty"`
+}
+
+// ParseMachineHealthCheck parses a health check in the format tcp:[HOST:]PORT,
+// log:REGEX or an http:// or https:// URL and applies the default interval,
+// timeout and number of retries.
+func ParseMachineHealthCheck(s string) (*MachineHealthCheck, error) {
+	check := MachineHealthCheck{
+		Interval: DefaultHealthCheckInterval,
+		Timeout:  DefaultHealthCheckTimeout,
+		Retries:  DefaultHealthCheckRetries,
+	}
+
+	if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		if _, err := url.Parse(s); err != nil {
+			return nil, fmt.Errorf("invalid health check URL: %w", err)
+		}
+
+		check.HTTP = s
+		return &check, nil
+	}
+
+	kind, value, ok := strings.Cut(s, ":")
+	if !ok || value == "" {
+		return nil, fmt.Errorf("invalid health check: %s (expected tcp:[HOST:]PORT, log:REGEX or an HTTP URL)", s)
+	}
+
+	switch kind {
+	case "tcp":
+		if _, err := strconv.Atoi(value); err != nil {
+			if _, _, err := net.SplitHostPort(value); err != nil {
+				return nil, fmt.Errorf("invalid health check address: %s: %w", value, err)
+			}
+		}
+
+		check.TCP = value
+
+	case "log":
+		if _, err := regexp.Compile(value); err != nil {
+			return nil, fmt.Errorf("invalid health check expression: %w", err)
+		}
+
+		check.Log = value
+
+	default:
+		return nil, fmt.Errorf("unknown health check type: %s (choice of tcp, http or log)", kind)
+	}
+
+	return &check, nil
+}
+
+// String implements fmt.Stringer
+func (check *MachineHealthCheck) String() string {
+	switch {
+	case check.TCP != "":
+		return "tcp:" + check.TCP
+	case check.HTTP != "":
+		return check.HTTP
+	case check.Log != "":
+		return "log:" + check.Log
+	}
+
+	return ""
+}
+
+// MachineHealthStatus is the outcome of the health checks of a machine.
+type MachineHealthStatus string
+
+const (
+	// MachineHealthStatusStarting indicates that the machine has not yet passed
+	// a health check.
+	MachineHealthStatusStarting = MachineHealthStatus("starting")
+
+	// MachineHealthStatusHealthy indicates that the most recent health check of
+	// the machine passed.
+	MachineHealthStatusHealthy = MachineHealthStatus("healthy")
+
+	// MachineHealthStatusUnhealthy indicates that the machine has failed as many
+	// consecutive health checks as its health check is retried.
+	MachineHealthStatusUnhealthy = MachineHealthStatus("unhealthy")
+)
+
+// MachineHealth contains the outcome of the health checks of a machine.
+type MachineHealth struct {
+	// Status of the health of the machine.
+	Status MachineHealthStatus `json:"status,omitempty"`
+
+	// FailingStreak is the number of consecutive health checks which failed.
+	FailingStreak int `json:"failingStreak,omitempty"`
+
+	// CheckedAt is when the machine was most recently checked.
+	CheckedAt time.Time `json:"checkedAt,omitempty"`
+
+	// Error describes why the most recent health check failed.
+	Error string `json:"error,omitempty"`
+}
@@ -90,6 +90,59 @@ type MachineSpec struct {
 
 	// Emulation indicates whether to use VMM emulation.
 	Emulation bool `json:"emulation,omitempty"`
+
+	// CPUModel is the model of the virtual CPU of the machine.  The default of
+	// the platform for the architecture of the machine is used if unset.
+	CPUModel string `json:"cpuModel,omitempty"`
+
+	// GICVersion is the version of the generic interrupt controller of ARM
+	// machines, e.g. 2, 3, host or max.
+	GICVersion string `json:"gicVersion,omitempty"`
+
+	// Firmware is the path to the firmware, e.g. a BIOS or SBI implementation,
+	// which is booted before the kernel of the machine.
+	Firmware string `json:"firmware,omitempty"`
+
+	// Transport is the transport of the virtio devices of the machine.  The
+	// default of the platform is used if unset.
+	Transport MachineTransport `json:"transport,omitempty"`
+
+	// NetworkModel is the model of the network devices of the machine.  Virtio
+	// devices are used if unset.
+	NetworkModel MachineNetworkModel `json:"networkModel,omitempty"`
+
+	// MicroVM indicates whether the machine is a minimal virtual machine
+	// without a PCI bus or legacy devices, which boots faster.
+	MicroVM bool `json:"microvm,omitempty"`
+
+	// LegacyNetdevParams indicates whether the kernel of the machine only
+	// accepts the static configuration of its network interface via the legacy
+	// netdev.ipv4_* parameters rather than netdev.ip.
+	LegacyNetdevParams bool `json:"legacyNetdevParams,omitempty"`
+
+	// RestoreFrom is the path to a snapshot from which the machine is restored
+	// instead of being booted.
+	RestoreFrom string `json:"restoreFrom,omitempty"`
+
+	// StopTimeout is the duration which the machine is given to gracefully shut
+	// down when it is stopped before its process is forcibly terminated.  When
+	// unset, the default of the platform driver is used.
+	StopTimeout *time.Duration `json:"stopTimeout,omitempty"`
+
+	// RestartPolicy determines whether the machine is restarted once it exits.
+	RestartPolicy MachineRestartPolicy `json:"restartPolicy,omitempty"`
+
+	// GDBPort is the TCP port on the loopback interface of the host on which a
+	// GDB server for debugging the kernel of the machine is exposed.
+	GDBPort int `json:"gdbPort,omitempty"`
+
+	// TraceBoot indicates whether the platform records when the machine reaches
+	// each stage of its boot in its status.
+	TraceBoot bool `json:"traceBoot,omitempty"`
+
+	// HealthCheck determines how the application inside of the machine is
+	// checked for whether it is serving, if at all.
+	HealthCheck *MachineHealthCheck `json:"healthCheck,omitempty"`
 }
 
 // MachineState indicates the state of the machine.
@@ -112,6 +165,29 @@ func (ms MachineState) String() string {
 	return string(ms)
 }
 
+// MachineStopStage indicates which stage of the shutdown sequence of a machine
+// caused it to stop.
+type MachineStopStage string
+
+const (
+	// MachineStopStageShutdown indicates that the guest shut down gracefully
+	// after being requested to.
+	MachineStopStageShutdown = MachineStopStage("shutdown")
+
+	// MachineStopStageTerminate indicates that the process of the machine exited
+	// after receiving SIGTERM.
+	MachineStopStageTerminate = MachineStopStage("terminate")
+
+	// MachineStopStageKill indicates that the process of the machine had to be
+	// killed with SIGKILL.
+	MachineStopStageKill = MachineStopStage("kill")
+)
+
+// String implements fmt.Stringer
+func (mss MachineStopStage) String() string {
+	return string(mss)
+}
+
 // MachineStatus contains the complete status of the machine instance.
 type MachineStatus struct {
 	// State is the current state of the machine instance.
@@ -126,6 +202,10 @@ type MachineStatus struct {
 	// The fully-qualified path to the initramfs file of the machine instance.
 	InitrdPath string `json:"initrdPath,omitempty"`
 
+	// Cmdline is the command line with which the process of the machine was
+	// started (if applicable).
+	Cmdline string `json:"cmdline,omitempty"`
+
 	// ExitCode is the ...
 	ExitCode int `json:"exitCode,omitempty"`
 
@@ -135,17 +215,100 @@ type MachineStatus struct {
 	// ExitedAt represents when the machine fully shutdown
 	ExitedAt time.Time `json:"exitedAt,omitempty"`
 
+	// StopStage is the stage of the shutdown sequence which stopped the machine
+	// the last time it was stopped.
+	StopStage MachineStopStage `json:"stopStage,omitempty"`
+
+	// RestartCount is the number of times the machine has been restarted
+	// according to its restart policy.
+	RestartCount int `json:"restartCount,omitempty"`
+
+	// CgroupPath is the in-host path of the cgroup which constrains the
+	// process of the machine, if any.
+	CgroupPath string `json:"cgroupPath,omitempty"`
+
+	// Usage of host resources by the process of the machine as accounted for by
+	// its cgroup.
+	Usage MachineResourceUsage `json:"usage,omitempty"`
+
 	// StateDir contains the path of the state of the machine.
 	StateDir string `json:"stateDir,omitempty"`
 
 	// LogFile is the in-host path to the log file of the machine.
 	LogFile string `json:"logFile,omitempty"`
 
+	// SerialPath is the in-host path to the unix socket which is connected to
+	// the serial console of the machine, if the platform supports attaching to
+	// it.
+	SerialPath string `json:"serialPath,omitempty"`
+
+	// BootTrace contains when the machine reached each stage of its boot, if
+	// tracing was requested.
+	BootTrace MachineBootTrace `json:"bootTrace,omitempty"`
+
+	// Health is the outcome of the health checks of the machine, if it has a
+	// health check.
+	Health MachineHealth `json:"health,omitempty"`
+
+	// Snapshots which have been taken of the machine.
+	Snapshots []MachineSnapshot `json:"snapshots,omitempty"`
+
 	// PlatformConfig is platform-specific attributes which are populated by the
 	// underlying machine service implementation.
 	PlatformConfig interface{} `json:"platformConfig,omitempty"`
 }
 
+// MachineResourceUsage contains the host resources which have been consumed by
+// the process of a machine.
+type MachineResourceUsage struct {
+	// CPUTime is the total time which was spent on the CPU.
+	CPUTime time.Duration `json:"cpuTime,omitempty"`
+
+	// MemoryCurrent is the number of bytes of memory currently in use.
+	MemoryCurrent int64 `json:"memoryCurrent,omitempty"`
+
+	// MemoryPeak is the largest number of bytes of memory in use at any time.
+	MemoryPeak int64 `json:"memoryPeak,omitempty"`
+
+	// IOReadBytes is the number of bytes read from block devices.
+	IOReadBytes int64 `json:"ioReadBytes,omitempty"`
+
+	// IOWriteBytes is the number of bytes written to block devices.
+	IOWriteBytes int64 `json:"ioWriteBytes,omitempty"`
+
+	// RSS is the number of bytes of memory resident on the host which are used
+	// by the virtual machine monitor, including the memory of the guest which it
+	// has touched.
+	RSS int64 `json:"rss,omitempty"`
+
+	// GuestMemory is the number of bytes of memory which are currently available
+	// to the guest, e.g. after accounting for a memory balloon.
+	GuestMemory int64 `json:"guestMemory,omitempty"`
+
+	// Interfaces contains the traffic of each network interface of the machine
+	// from the perspective of the guest.
+	Interfaces []MachineInterfaceUsage `json:"interfaces,omitempty"`
+}
+
+// MachineInterfaceUsage contains the traffic of a network interface of a
+// machine.
+type MachineInterfaceUsage struct {
+	// IfName is the name of the interface.
+	IfName string `json:"ifName"`
+
+	// RxBytes is the number of bytes received by the guest.
+	RxBytes uint64 `json:"rxBytes"`
+
+	// RxPackets is the number of packets received by the guest.
+	RxPackets uint64 `json:"rxPackets"`
+
+	// TxBytes is the number of bytes transmitted by the guest.
+	TxBytes uint64 `json:"txBytes"`
+
+	// TxPackets is the number of packets transmitted by the guest.
+	TxPackets uint64 `json:"txPackets"`
+}
+
 // MachineService is the interface of available methods which can be performed
 // by an implementing machine platform driver.
 type MachineService interface {
@@ -159,21 +322,27 @@ type MachineService interface {
 	List(context.Context, *MachineList) (*MachineList, error)
 	Watch(context.Context, *Machine) (chan *Machine, chan error, error)
 	Logs(context.Context, *Machine) (chan string, chan error, error)
+	Snapshot(context.Context, *Machine) (*Machine, error)
+	Restore(context.Context, *Machine) (*Machine, error)
+	Stats(context.Context, *Machine) (*Machine, error)
 }
 
 // MachineServiceHandler provides a Zip API Object Framework service for the
 // machine.
 type MachineServiceHandler struct {
-	create zip.MethodStrategy[*Machine, *Machine]
-	start  zip.MethodStrategy[*Machine, *Machine]
-	pause  zip.MethodStrategy[*Machine, *Machine]
-	stop   zip.MethodStrategy[*Machine, *Machine]
-	update zip.MethodStrategy[*Machine, *Machine]
-	delete zip.MethodStrategy[*Machine, *Machine]
-	get    zip.MethodStrategy[*Machine, *Machine]
-	list   zip.MethodStrategy[*MachineList, *MachineList]
-	watch  zip.StreamStrategy[*Machine, *Machine]
-	logs   zip.StreamStrategy[*Machine, string]
+	create   zip.MethodStrategy[*Machine, *Machine]
+	start    zip.MethodStrategy[*Machine, *Machine]
+	pause    zip.MethodStrategy[*Machine, *Machine]
+	stop     zip.MethodStrategy[*Machine, *Machine]
+	update   zip.MethodStrategy[*Machine, *Machine]
+	delete   zip.MethodStrategy[*Machine, *Machine]
+	get      zip.MethodStrategy[*Machine, *Machine]
+	list     zip.MethodStrategy[*MachineList, *MachineList]
+	watch    zip.StreamStrategy[*Machine, *Machine]
+	logs     zip.StreamStrategy[*Machine, string]
+	snapshot zip.MethodStrategy[*Machine, *Machine]
+	restore  zip.MethodStrategy[*Machine, *Machine]
+	stats    zip.MethodStrategy[*Machine, *Machine]
 }
 
 // Create implements MachineService
@@ -226,6 +395,21 @@ func (client *MachineServiceHandler) Logs(ctx context.Context, req *Machine) (ch
 	return client.logs.Channel(ctx, req)
 }
 
+// Snapshot implements MachineService
+func (client *MachineServiceHandler) Snapshot(ctx context.Context, req *Machine) (*Machine, error) {
+	return client.snapshot.Do(ctx, req)
+}
+
+// Restore implements MachineService
+func (client *MachineServiceHandler) Restore(ctx context.Context, req *Machine) (*Machine, error) {
+	return client.restore.Do(ctx, req)
+}
+
+// Stats implements MachineService
+func (client *MachineServiceHandler) Stats(ctx context.Context, req *Machine) (*Machine, error) {
+	return client.stats.Do(ctx, req)
+}
+
 // NewMachineServiceHandler returns a service based on an inline API
 // client which essentially wraps the specific call, enabling pre- and post-
 // call hooks.  This is useful for wrapping the command with decorators, for
@@ -283,6 +467,21 @@ func NewMachineServiceHandler(ctx context.Context, impl MachineService, opts ...
 		return nil, err
 	}
 
+	snapshot, err := zip.NewMethodClient(ctx, impl.Snapshot, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	restore, err := zip.NewMethodClient(ctx, impl.Restore, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := zip.NewMethodClient(ctx, impl.Stats, opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	return &MachineServiceHandler{
 		create,
 		start,
@@ -294,5 +493,8 @@ func NewMachineServiceHandler(ctx context.Context, impl MachineService, opts ...
 		list,
 		watch,
 		logs,
+		snapshot,
+		restore,
+		stats,
 	}, nil
 }
@@ -0,0 +1,23 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ResourceNetworkRxBandwidth is the maximum number of bytes per second which
+	// each network interface of a machine is permitted to receive.
+	ResourceNetworkRxBandwidth corev1.ResourceName = "network.kraftkit.sh/rx-bandwidth"
+
+	// ResourceNetworkTxBandwidth is the maximum number of bytes per second which
+	// each network interface of a machine is permitted to transmit.
+	ResourceNetworkTxBandwidth corev1.ResourceName = "network.kraftkit.sh/tx-bandwidth"
+
+	// ResourceIOWeight is the relative share of block IO on the host which is
+	// given to the machine, between 1 and 10000.
+	ResourceIOWeight corev1.ResourceName = "io.kraftkit.sh/weight"
+)
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file expect in compliance with the License.
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MachineRestartPolicyName is the name of a policy which determines whether a
+// machine is restarted once it has exited.
+type MachineRestartPolicyName string
+
+const (
+	// MachineRestartPolicyNo never restarts the machine.
+	MachineRestartPolicyNo = MachineRestartPolicyName("no")
+
+	// MachineRestartPolicyOnFailure restarts the machine only if it exited
+	// unsuccessfully, optionally up to a maximum number of times.
+	MachineRestartPolicyOnFailure = MachineRestartPolicyName("on-failure")
+
+	// MachineRestartPolicyAlways restarts the machine whenever it exits.  A
+	// machine which was explicitly stopped is only restarted once its
+	// supervisor is itself restarted.
+	MachineRestartPolicyAlways = MachineRestartPolicyName("always")
+
+	// MachineRestartPolicyUnlessStopped restarts the machine whenever it exits,
+	// unless it was explicitly stopped.
+	MachineRestartPolicyUnlessStopped = MachineRestartPolicyName("unless-stopped")
+)
+
+// MachineRestartPolicy determines whether and how often a machine is
+// restarted once it has exited.
+type MachineRestartPolicy struct {
+	// Name of the policy.
+	Name MachineRestartPolicyName `json:"name,omitempty"`
+
+	// MaximumRetryCount is the number of times the machine is restarted before
+	// giving up.  It only applies to the "on-failure" policy and zero means the
+	// machine is restarted indefinitely.
+	MaximumRetryCount int `json:"maximumRetryCount,omitempty"`
+}
+
+// ParseMachineRestartPolicy parses the "docker-like" representation of a
+// restart policy, i.e. "no", "on-failure[:N]", "always" or "unless-stopped".
+func ParseMachineRestartPolicy(s string) (MachineRestartPolicy, error) {
+	name, count, hasCount := strings.Cut(s, ":")
+
+	policy := MachineRestartPolicy{
+		Name: MachineRestartPolicyName(name),
+	}
+
+	switch policy.Name {
+	case "", MachineRestartPolicyNo:
+		policy.Name = MachineRestartPolicyNo
+	case MachineRestartPolicyOnFailure,
+		MachineRestartPolicyAlways,
+		MachineRestartPolicyUnlessStopped:
+	default:
+		return policy, fmt.Errorf("unknown restart policy: %s", name)
+	}
+
+	if !hasCount {
+		return policy, nil
+	}
+
+	if policy.Name != MachineRestartPolicyOnFailure {
+		return policy, fmt.Errorf("maximum retry count can only be used with the %s restart policy", MachineRestartPolicyOnFailure)
+	}
+
+	max, err := strconv.Atoi(count)
+	if err != nil || max < 0 {
+		return policy, fmt.Errorf("invalid maximum retry count: %s", count)
+	}
+
+	policy.MaximumRetryCount = max
+
+	return policy, nil
+}
+
+// IsNone returns whether the policy never restarts the machine.
+func (policy MachineRestartPolicy) IsNone() bool {
+	return policy.Name == "" || policy.Name == MachineRestartPolicyNo
+}
+
+// String implements fmt.Stringer
+func (policy MachineRestartPolicy) String() string {
+	if policy.IsNone() {
+		return string(MachineRestartPolicyNo)
+	}
+
+	if policy.Name == MachineRestartPolicyOnFailure && policy.MaximumRetryCount > 0 {
+		return fmt.Sprintf("%s:%d", policy.Name, policy.MaximumRetryCount)
+	}
+
+	return string(policy.Name)
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestParseMachineRestartPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    MachineRestartPolicy
+		wantErr bool
+	}{
+		{
+			in:   "",
+			want: MachineRestartPolicy{Name: MachineRestartPolicyNo},
+		},
+		{
+			in:   "no",
+			want: MachineRestartPolicy{Name: MachineRestartPolicyNo},
+		},
+		{
+			in:   "always",
+			want: MachineRestartPolicy{Name: MachineRestartPolicyAlways},
+		},
+		{
+			in:   "unless-stopped",
+			want: MachineRestartPolicy{Name: MachineRestartPolicyUnlessStopped},
+		},
+		{
+			in:   "on-failure",
+			want: MachineRestartPolicy{Name: MachineRestartPolicyOnFailure},
+		},
+		{
+			in:   "on-failure:3",
+			want: MachineRestartPolicy{Name: MachineRestartPolicyOnFailure, MaximumRetryCount: 3},
+		},
+		{
+			in:   "on-failure:0",
+			want: MachineRestartPolicy{Name: MachineRestartPolicyOnFailure},
+		},
+		{
+			in:      "on-failure:",
+			wantErr: true,
+		},
+		{
+			in:      "on-failure:-1",
+			wantErr: true,
+		},
+		{
+			in:      "on-failure:three",
+			wantErr: true,
+		},
+		{
+			in:      "always:3",
+			wantErr: true,
+		},
+		{
+			in:      "no:1",
+			wantErr: true,
+		},
+		{
+			in:      "sometimes",
+			wantErr: true,
+		},
+		{
+			in:      "Always",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseMachineRestartPolicy(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+
+			// The parsed policy must survive a round trip through its string form.
+			again, err := ParseMachineRestartPolicy(got.String())
+			if err != nil {
+				t.Fatalf("Could not parse %q: %v", got.String(), err)
+			}
+
+			if again != got {
+				t.Errorf("Expected %+v after round trip, got %+v", got, again)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MachineSnapshotManifestFileName is the name of the file which describes a
+// snapshot and is located within the snapshot's directory.
+const MachineSnapshotManifestFileName = "snapshot.json"
+
+// MachineSnapshot represents the captured memory and device state of a machine
+// at a point in time from which a new machine can later be restored.
+type MachineSnapshot struct {
+	// Name of the snapshot.
+	Name string `json:"name"`
+
+	// Platform of the machine which the snapshot was taken of.  A snapshot can
+	// only be restored with the same platform.
+	Platform string `json:"plat"`
+
+	// Path is the directory containing the snapshot's files.
+	Path string `json:"path"`
+
+	// StatePath is the path to the device state of the machine.
+	StatePath string `json:"statePath,omitempty"`
+
+	// MemoryPath is the path to the memory of the machine, if it is not already
+	// part of the device state.
+	MemoryPath string `json:"memoryPath,omitempty"`
+
+	// CreatedAt represents when the snapshot was taken.
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// MachineSnapshotManifest contains everything which is necessary to restore a
+// machine from a snapshot.
+type MachineSnapshotManifest struct {
+	// Snapshot is the snapshot itself.
+	Snapshot MachineSnapshot `json:"snapshot"`
+
+	// Spec of the machine which the snapshot was taken of.
+	Spec MachineSpec `json:"spec"`
+
+	// KernelPath is the path to the kernel of the machine.
+	KernelPath string `json:"kernelPath,omitempty"`
+
+	// InitrdPath is the path to the initramfs of the machine.
+	InitrdPath string `json:"initrdPath,omitempty"`
+}
+
+// NewMachineSnapshot prepares a new snapshot of the provided machine.  Its
+// directory is placed alongside the machine's state directory, rather than
+// within it, such that the snapshot outlives the machine.
+func NewMachineSnapshot(machine *Machine) (*MachineSnapshot, error) {
+	if len(machine.Status.StateDir) == 0 {
+		return nil, fmt.Errorf("cannot snapshot machine without state directory")
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s", machine.Name, now.Format("20060102150405"))
+	path := filepath.Join(filepath.Dir(machine.Status.StateDir), "snapshots", name)
+
+	if err := os.MkdirAll(path, fs.ModeSetgid|0o775); err != nil {
+		return nil, fmt.Errorf("could not create snapshot directory: %w", err)
+	}
+
+	return &MachineSnapshot{
+		Name:      name,
+		Platform:  machine.Spec.Platform,
+		Path:      path,
+		CreatedAt: now,
+	}, nil
+}
+
+// SaveMachineSnapshotManifest writes the manifest of the snapshot of the
+// provided machine into the snapshot's directory.
+func SaveMachineSnapshotManifest(machine *Machine, snapshot *MachineSnapshot) error {
+	manifest := MachineSnapshotManifest{
+		Snapshot:   *snapshot,
+		Spec:       machine.Spec,
+		KernelPath: machine.Status.KernelPath,
+		InitrdPath: machine.Status.InitrdPath,
+	}
+
+	// A restored machine does not inherit the snapshot which the original
+	// machine was itself restored from.
+	manifest.Spec.RestoreFrom = ""
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal snapshot manifest: %w", err)
+	}
+
+	return os.WriteFile(filepath.Join(snapshot.Path, MachineSnapshotManifestFileName), b, 0o644)
+}
+
+// LoadMachineSnapshotManifest reads the manifest of a snapshot from the
+// provided path, which is either the snapshot's directory or its manifest.
+func LoadMachineSnapshotManifest(path string) (*MachineSnapshotManifest, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not stat snapshot: %w", err)
+	}
+
+	if fi.IsDir() {
+		path = filepath.Join(path, MachineSnapshotManifestFileName)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot manifest: %w", err)
+	}
+
+	var manifest MachineSnapshotManifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse snapshot manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
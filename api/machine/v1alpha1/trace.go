@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import "time"
+
+// MachineBootTrace records when a machine reached each stage of its boot.
+// Stages which were not observed are left as the zero time.
+type MachineBootTrace struct {
+	// Spawned is when the process of the virtual machine monitor was spawned.
+	Spawned time.Time `json:"spawned,omitempty"`
+
+	// ControlReady is when the control interface of the virtual machine
+	// monitor, e.g. QMP, first accepted a connection.
+	ControlReady time.Time `json:"controlReady,omitempty"`
+
+	// Continued is when the virtual machine monitor was instructed to run the
+	// virtual CPUs of the machine.
+	Continued time.Time `json:"continued,omitempty"`
+
+	// FirstOutput is when the machine first wrote to its console.
+	FirstOutput time.Time `json:"firstOutput,omitempty"`
+
+	// Ready is when the machine satisfied the condition which considers it
+	// ready, e.g. having logged a line matching a pattern or accepting TCP
+	// connections.
+	Ready time.Time `json:"ready,omitempty"`
+}
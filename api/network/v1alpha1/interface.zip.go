@@ -25,9 +25,12 @@ type NetworkInterfaceSpec struct {
 	// The name of the interface.
 	IfName string `json:"ifname,omitempty"`
 
-	// IP address of a machine interface.
+	// IPv4 address of a machine interface.
 	IP string `json:"ip,omitempty"`
 
+	// IPv6 address of a machine interface.
+	IPv6 string `json:"ipv6,omitempty"`
+
 	// Hardware address of a machine interface.
 	MacAddress string `json:"mac,omitempty"`
 }
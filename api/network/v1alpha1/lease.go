@@ -0,0 +1,23 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import "time"
+
+// NetworkLease is an IP address which has been leased to a machine on the
+// network by the DHCP server of the network.
+type NetworkLease struct {
+	// MacAddress is the hardware address of the interface which holds the lease.
+	MacAddress string `json:"mac"`
+
+	// IP is the leased address.
+	IP string `json:"ip"`
+
+	// Hostname is the name which the machine requested, if any.
+	Hostname string `json:"hostname,omitempty"`
+
+	// ExpiresAt is when the lease expires unless it is renewed.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
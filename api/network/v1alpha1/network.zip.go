@@ -27,13 +27,31 @@ type NetworkSpec struct {
 	// Interface name of this network.
 	IfName string `json:"ifName,omitempty"`
 
-	// The gateway IP address of the network.
+	// The gateway IPv4 address of the network.
 	Gateway string `json:"gateway,omitempty"`
 
-	// The network mask to apply over the gateway IP address to gather the subnet
-	// range.
+	// The network mask to apply over the gateway IPv4 address to gather the
+	// subnet range.
 	Netmask string `json:"netmask,omitempty"`
 
+	// Subnets of the network in CIDR notation, where the address is that of the
+	// gateway on the subnet, e.g. 172.100.0.1/24 or fd00:cafe::1/64.  A network
+	// has at most one IPv4 and one IPv6 subnet.  The IPv4 subnet is also
+	// reflected by Gateway and Netmask.
+	Subnets []string `json:"subnets,omitempty"`
+
+	// NAT indicates whether traffic from the network to hosts beyond the host is
+	// masqueraded behind the address of the host.
+	NAT bool `json:"nat,omitempty"`
+
+	// DHCP indicates whether a daemon on the host serves addresses to the
+	// machines on the network and resolves their names.
+	DHCP bool `json:"dhcp,omitempty"`
+
+	// Policy restricts the traffic to and from the machines on the network,
+	// which may otherwise communicate freely with each other and the host.
+	Policy *NetworkPolicy `json:"policy,omitempty"`
+
 	// Network interfaces associated with this network.
 	Interfaces []NetworkInterfaceTemplateSpec `json:"interfaces,omitempty"`
 }
@@ -92,6 +110,9 @@ type NetworkStatus struct {
 	TxPackets         uint64 `json:"txPackets"`
 	TxWindowErrors    uint64 `json:"txWindowErrors"`
 
+	// Leases which have been handed out by the DHCP server of the network.
+	Leases []NetworkLease `json:"leases,omitempty"`
+
 	// DriverConfig is driver-specific attributes which are populated by the
 	// underlying network implementation.
 	DriverConfig interface{} `json:"driverConfig,omitempty"`
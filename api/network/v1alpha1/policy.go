@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NetworkPolicyDirection indicates whether a rule applies to the traffic which
+// a machine receives or to the traffic which it sends.
+type NetworkPolicyDirection string
+
+const (
+	NetworkPolicyDirectionIngress = NetworkPolicyDirection("ingress")
+	NetworkPolicyDirectionEgress  = NetworkPolicyDirection("egress")
+)
+
+// String implements fmt.Stringer
+func (direction NetworkPolicyDirection) String() string {
+	return string(direction)
+}
+
+// NetworkPolicy restricts the traffic to and from the machines on a network.
+// A machine is isolated in a direction if the policy denies by default or if
+// any rule of that direction applies to it, in which case only the traffic
+// which is allowed by the rules that apply to it, as well as replies to such
+// traffic, may pass in that direction.  Machines which are not isolated are
+// not restricted.
+type NetworkPolicy struct {
+	// DefaultDeny indicates whether all machines on the network are isolated in
+	// both directions, regardless of the rules.
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+
+	// Rules which allow traffic to or from the machines on the network.
+	Rules []NetworkPolicyRule `json:"rules,omitempty"`
+}
+
+// NetworkPolicyRule allows traffic to (ingress) or from (egress) the machines
+// which it applies to.
+type NetworkPolicyRule struct {
+	// Direction of the traffic which is allowed.
+	Direction NetworkPolicyDirection `json:"direction"`
+
+	// Machines selects by their labels the machines which the rule applies to,
+	// e.g. tenant=acme.  The rule applies to all machines when left empty.
+	Machines string `json:"machines,omitempty"`
+
+	// Peers selects by their labels the machines on the network from which
+	// (ingress) or to which (egress) traffic is allowed.
+	Peers string `json:"peers,omitempty"`
+
+	// CIDRs are the addresses from which (ingress) or to which (egress) traffic
+	// is allowed in CIDR notation, e.g. the subnet of the host or 0.0.0.0/0.
+	// Traffic is allowed from or to any address if neither peers nor CIDRs are
+	// specified.
+	CIDRs []string `json:"cidrs,omitempty"`
+
+	// Protocol of the traffic which is allowed, i.e. tcp, udp or icmp.  Traffic
+	// of any protocol is allowed when left empty.
+	Protocol string `json:"protocol,omitempty"`
+
+	// Ports of the machine (ingress) or of the peer (egress) to which traffic
+	// is allowed, either a single port or a range in the format FROM-TO.  Only
+	// tcp and udp traffic is allowed if ports are specified.
+	Ports []string `json:"ports,omitempty"`
+}
+
+// Validate returns an error if the rule is malformed.
+func (rule NetworkPolicyRule) Validate() error {
+	switch rule.Direction {
+	case NetworkPolicyDirectionIngress, NetworkPolicyDirectionEgress:
+	default:
+		return fmt.Errorf("invalid direction: %s (choice of %s or %s)", rule.Direction, NetworkPolicyDirectionIngress, NetworkPolicyDirectionEgress)
+	}
+
+	if _, err := labels.Parse(rule.Machines); err != nil {
+		return fmt.Errorf("invalid machine selector: %w", err)
+	}
+
+	if _, err := labels.Parse(rule.Peers); err != nil {
+		return fmt.Errorf("invalid peer selector: %w", err)
+	}
+
+	for _, cidr := range rule.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR %s: %w", cidr, err)
+		}
+	}
+
+	switch rule.Protocol {
+	case "", "tcp", "udp":
+	case "icmp":
+		if len(rule.Ports) > 0 {
+			return fmt.Errorf("cannot allow ports of protocol icmp")
+		}
+	default:
+		return fmt.Errorf("invalid protocol: %s (choice of tcp, udp or icmp)", rule.Protocol)
+	}
+
+	for _, port := range rule.Ports {
+		if _, _, err := ParsePortRange(port); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AppliesTo returns whether the rule applies to the machine with the provided
+// labels.
+func (rule NetworkPolicyRule) AppliesTo(set map[string]string) bool {
+	return matchesSelector(rule.Machines, set)
+}
+
+// AllowsPeer returns whether the rule allows traffic from or to the machine
+// with the provided labels.  Peers are never allowed by a rule which does not
+// select any.
+func (rule NetworkPolicyRule) AllowsPeer(set map[string]string) bool {
+	if rule.Peers == "" {
+		return false
+	}
+
+	return matchesSelector(rule.Peers, set)
+}
+
+// matchesSelector returns whether the provided labels match the selector.  An
+// empty selector matches any labels, whereas an invalid one matches none.
+func matchesSelector(selector string, set map[string]string) bool {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return false
+	}
+
+	return parsed.Matches(labels.Set(set))
+}
+
+// ParsePortRange parses a port, e.g. 80, or a range of ports in the format
+// FROM-TO, e.g. 8000-8080.
+func ParsePortRange(port string) (uint16, uint16, error) {
+	from, to, isRange := strings.Cut(port, "-")
+	if !isRange {
+		to = from
+	}
+
+	start, err := strconv.ParseUint(from, 10, 16)
+	if err != nil || start == 0 {
+		return 0, 0, fmt.Errorf("invalid port: %s", port)
+	}
+
+	end, err := strconv.ParseUint(to, 10, 16)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid port range: %s", port)
+	}
+
+	return uint16(start), uint16(end), nil
+}
+
+// Isolated returns whether the machine with the provided labels is isolated in
+// the provided direction by the policy.
+func (policy *NetworkPolicy) Isolated(direction NetworkPolicyDirection, set map[string]string) bool {
+	if policy == nil {
+		return false
+	} else if policy.DefaultDeny {
+		return true
+	}
+
+	for _, rule := range policy.Rules {
+		if rule.Direction == direction && rule.AppliesTo(set) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate returns an error if any rule of the policy is malformed.
+func (policy *NetworkPolicy) Validate() error {
+	if policy == nil {
+		return nil
+	}
+
+	for i, rule := range policy.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("invalid rule %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ParseSubnet parses the provided subnet in CIDR notation, e.g. 172.100.0.1/24
+// or fd00:cafe::1/64.  Unlike net.ParseCIDR, the address of the returned
+// subnet is the provided one, i.e. that of the gateway, rather than the first
+// address of the subnet.
+func ParseSubnet(cidr string) (*net.IPNet, error) {
+	ip, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %s: %w", cidr, err)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	subnet.IP = ip
+
+	return subnet, nil
+}
+
+// IPv4Subnet returns the IPv4 subnet of the network, or nil if it has none.
+// Networks which only specify a gateway and netmask are also supported.
+func (spec NetworkSpec) IPv4Subnet() (*net.IPNet, error) {
+	subnet, err := spec.subnet(false)
+	if err != nil || subnet != nil || spec.Gateway == "" {
+		return subnet, err
+	}
+
+	gateway := net.ParseIP(spec.Gateway).To4()
+	if gateway == nil {
+		return nil, fmt.Errorf("invalid IPv4 gateway: %s", spec.Gateway)
+	}
+
+	var mask net.IPMask
+	if netmask := net.ParseIP(spec.Netmask).To4(); netmask != nil {
+		mask = net.IPMask(netmask)
+	} else if ones, err := strconv.Atoi(spec.Netmask); err == nil {
+		mask = net.CIDRMask(ones, 8*net.IPv4len)
+	}
+
+	if ones, bits := mask.Size(); mask == nil || (ones == 0 && bits == 0) {
+		return nil, fmt.Errorf("invalid IPv4 netmask: %s", spec.Netmask)
+	}
+
+	return &net.IPNet{IP: gateway, Mask: mask}, nil
+}
+
+// IPv6Subnet returns the IPv6 subnet of the network, or nil if it has none.
+func (spec NetworkSpec) IPv6Subnet() (*net.IPNet, error) {
+	return spec.subnet(true)
+}
+
+// subnet returns the first subnet of the provided address family.
+func (spec NetworkSpec) subnet(ipv6 bool) (*net.IPNet, error) {
+	for _, cidr := range spec.Subnets {
+		subnet, err := ParseSubnet(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		if (subnet.IP.To4() == nil) == ipv6 {
+			return subnet, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// SetSubnets sets the subnets of the network, of which there may be at most one
+// per address family, and updates its IPv4 gateway and netmask accordingly.
+// Nil subnets are ignored.
+func (spec *NetworkSpec) SetSubnets(subnets ...*net.IPNet) error {
+	var ipv4, ipv6 *net.IPNet
+
+	for _, subnet := range subnets {
+		if subnet == nil {
+			continue
+		} else if subnet.IP.To4() != nil {
+			if ipv4 != nil {
+				return fmt.Errorf("network cannot have more than one IPv4 subnet: %s and %s", ipv4, subnet)
+			}
+
+			ipv4 = &net.IPNet{IP: subnet.IP.To4(), Mask: subnet.Mask}
+		} else {
+			if ipv6 != nil {
+				return fmt.Errorf("network cannot have more than one IPv6 subnet: %s and %s", ipv6, subnet)
+			}
+
+			ipv6 = subnet
+		}
+	}
+
+	spec.Subnets = nil
+	spec.Gateway = ""
+	spec.Netmask = ""
+
+	if ipv4 != nil {
+		spec.Subnets = append(spec.Subnets, ipv4.String())
+		spec.Gateway = ipv4.IP.String()
+		spec.Netmask = net.IP(ipv4.Mask).String()
+	}
+
+	if ipv6 != nil {
+		spec.Subnets = append(spec.Subnets, ipv6.String())
+	}
+
+	return nil
+}
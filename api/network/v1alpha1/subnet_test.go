@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package v1alpha1
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseSubnet(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		ip      string
+		ones    int
+		bits    int
+		wantErr bool
+	}{
+		{cidr: "172.100.0.1/24", ip: "172.100.0.1", ones: 24, bits: 32},
+		{cidr: "10.1.2.129/26", ip: "10.1.2.129", ones: 26, bits: 32},
+		{cidr: "192.168.0.1/17", ip: "192.168.0.1", ones: 17, bits: 32},
+		{cidr: "fd00:cafe::1/64", ip: "fd00:cafe::1", ones: 64, bits: 128},
+		{cidr: "fd00:cafe::1/120", ip: "fd00:cafe::1", ones: 120, bits: 128},
+		{cidr: "172.100.0.1", wantErr: true},
+		{cidr: "172.100.0.1/33", wantErr: true},
+		{cidr: "300.100.0.1/24", wantErr: true},
+		{cidr: "fd00:cafe::1/129", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			subnet, err := ParseSubnet(tt.cidr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			// The address is that of the gateway rather than of the subnet.
+			if subnet.IP.String() != tt.ip {
+				t.Errorf("Expected address %s, got %s", tt.ip, subnet.IP)
+			}
+
+			// IPv4 addresses are in their 4-byte form.
+			if ipv4 := net.ParseIP(tt.ip).To4(); ipv4 != nil && len(subnet.IP) != net.IPv4len {
+				t.Errorf("Expected IPv4 address of %d bytes, got %d", net.IPv4len, len(subnet.IP))
+			}
+
+			if ones, bits := subnet.Mask.Size(); ones != tt.ones || bits != tt.bits {
+				t.Errorf("Expected mask /%d of %d bits, got /%d of %d bits", tt.ones, tt.bits, ones, bits)
+			}
+		})
+	}
+}
+
+func TestNetworkSpecSubnets(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    NetworkSpec
+		ipv4    string
+		ipv6    string
+		wantErr bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "IPv4 subnet",
+			spec: NetworkSpec{Subnets: []string{"172.100.0.1/24"}},
+			ipv4: "172.100.0.1/24",
+		},
+		{
+			name: "IPv6 subnet",
+			spec: NetworkSpec{Subnets: []string{"fd00:cafe::1/64"}},
+			ipv6: "fd00:cafe::1/64",
+		},
+		{
+			name: "dual-stack",
+			spec: NetworkSpec{Subnets: []string{"fd00:cafe::1/64", "10.1.2.129/26"}},
+			ipv4: "10.1.2.129/26",
+			ipv6: "fd00:cafe::1/64",
+		},
+		{
+			name: "gateway and dotted netmask",
+			spec: NetworkSpec{Gateway: "10.1.2.129", Netmask: "255.255.255.192"},
+			ipv4: "10.1.2.129/26",
+		},
+		{
+			name: "gateway and prefix length",
+			spec: NetworkSpec{Gateway: "10.1.2.129", Netmask: "26"},
+			ipv4: "10.1.2.129/26",
+		},
+		{
+			name: "IPv6 subnet with IPv4 gateway",
+			spec: NetworkSpec{Subnets: []string{"fd00:cafe::1/64"}, Gateway: "172.100.0.1", Netmask: "16"},
+			ipv4: "172.100.0.1/16",
+			ipv6: "fd00:cafe::1/64",
+		},
+		{
+			name:    "non-contiguous netmask",
+			spec:    NetworkSpec{Gateway: "10.1.2.129", Netmask: "255.0.255.0"},
+			wantErr: true,
+		},
+		{
+			name:    "missing netmask",
+			spec:    NetworkSpec{Gateway: "10.1.2.129"},
+			wantErr: true,
+		},
+		{
+			name:    "IPv6 gateway",
+			spec:    NetworkSpec{Gateway: "fd00:cafe::1", Netmask: "64"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid subnet",
+			spec:    NetworkSpec{Subnets: []string{"10.1.2.129"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipv4, err := tt.spec.IPv4Subnet()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := subnetString(ipv4); got != tt.ipv4 {
+				t.Errorf("Expected IPv4 subnet %q, got %q", tt.ipv4, got)
+			}
+
+			ipv6, err := tt.spec.IPv6Subnet()
+			if err != nil {
+				t.Fatalf("Could not get IPv6 subnet: %v", err)
+			}
+
+			if got := subnetString(ipv6); got != tt.ipv6 {
+				t.Errorf("Expected IPv6 subnet %q, got %q", tt.ipv6, got)
+			}
+		})
+	}
+}
+
+func TestNetworkSpecSetSubnets(t *testing.T) {
+	mustParse := func(cidr string) *net.IPNet {
+		subnet, err := ParseSubnet(cidr)
+		if err != nil {
+			t.Fatalf("Could not parse subnet: %v", err)
+		}
+
+		return subnet
+	}
+
+	tests := []struct {
+		name    string
+		subnets []*net.IPNet
+		want    NetworkSpec
+		wantErr bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:    "IPv4",
+			subnets: []*net.IPNet{mustParse("10.1.2.129/26")},
+			want: NetworkSpec{
+				Subnets: []string{"10.1.2.129/26"},
+				Gateway: "10.1.2.129",
+				Netmask: "255.255.255.192",
+			},
+		},
+		{
+			name:    "dual-stack",
+			subnets: []*net.IPNet{mustParse("fd00:cafe::1/64"), nil, mustParse("172.100.0.1/24")},
+			want: NetworkSpec{
+				Subnets: []string{"172.100.0.1/24", "fd00:cafe::1/64"},
+				Gateway: "172.100.0.1",
+				Netmask: "255.255.255.0",
+			},
+		},
+		{
+			name:    "IPv6",
+			subnets: []*net.IPNet{mustParse("fd00:cafe::1/64")},
+			want: NetworkSpec{
+				Subnets: []string{"fd00:cafe::1/64"},
+			},
+		},
+		{
+			name:    "two IPv4 subnets",
+			subnets: []*net.IPNet{mustParse("172.100.0.1/24"), mustParse("10.0.0.1/8")},
+			wantErr: true,
+		},
+		{
+			name:    "two IPv6 subnets",
+			subnets: []*net.IPNet{mustParse("fd00:cafe::1/64"), mustParse("fd00:beef::1/64")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Any previous subnets are replaced.
+			spec := NetworkSpec{
+				Subnets: []string{"192.168.0.1/16"},
+				Gateway: "192.168.0.1",
+				Netmask: "255.255.0.0",
+			}
+
+			err := spec.SetSubnets(tt.subnets...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(spec, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, spec)
+			}
+
+			// The subnets are read back as they were set.
+			for _, subnet := range tt.subnets {
+				if subnet == nil {
+					continue
+				}
+
+				get := spec.IPv4Subnet
+				if subnet.IP.To4() == nil {
+					get = spec.IPv6Subnet
+				}
+
+				got, err := get()
+				if err != nil || subnetString(got) != subnet.String() {
+					t.Errorf("Expected subnet %s, got %s (%v)", subnet, subnetString(got), err)
+				}
+			}
+		})
+	}
+}
+
+// subnetString returns the CIDR notation of the provided subnet or an empty
+// string if it is nil.
+func subnetString(subnet *net.IPNet) string {
+	if subnet == nil {
+		return ""
+	}
+
+	return subnet.String()
+}
@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package attach
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+// errDetached is returned once the detach key sequence has been read.
+var errDetached = errors.New("detached")
+
+type AttachOptions struct {
+	DetachKeys string `long:"detach-keys" usage:"Override the key sequence for detaching from the unikernel" default:"ctrl-p,ctrl-q"`
+	NoStdin    bool   `long:"no-stdin" usage:"Do not attach the standard input"`
+
+	detachKeys []byte
+	platform   string
+}
+
+// Attach connects the standard input and output to the serial console of a
+// local Unikraft virtual machine.
+func Attach(ctx context.Context, opts *AttachOptions, args ...string) error {
+	if opts == nil {
+		opts = &AttachOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&AttachOptions{}, cobra.Command{
+		Short: "Attach to the console of a running unikernel",
+		Use:   "attach [FLAGS] MACHINE",
+		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Attach the standard input and output of the terminal to the serial console
+			of a running unikernel.
+
+			To detach from the unikernel whilst leaving it running, type the detach key
+			sequence, which is Ctrl+P followed by Ctrl+Q by default.  The sequence can
+			be overridden with a comma-separated list of keys, each of which is either a
+			single character or ctrl-<key> where <key> is one of a-z, @, [, \, ], ^
+			or _.`),
+		Example: heredoc.Doc(`
+			# Attach to the console of a unikernel which has been run in the background
+			$ kraft attach my-machine
+
+			# Attach to the console of a unikernel and detach from it with Ctrl+X
+			$ kraft attach --detach-keys ctrl-x my-machine`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "run",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.Flags().VarP(
+		cmdfactory.NewEnumFlag[mplatform.Platform](
+			mplatform.Platforms(),
+			mplatform.Platform("auto"),
+		),
+		"plat",
+		"p",
+		"Set the platform virtual machine monitor driver.  Set to 'auto' to detect the guest's platform and 'host' to use the host platform.",
+	)
+
+	return cmd
+}
+
+func (opts *AttachOptions) Pre(cmd *cobra.Command, _ []string) error {
+	var err error
+
+	opts.detachKeys, err = parseDetachKeys(opts.DetachKeys)
+	if err != nil {
+		return err
+	}
+
+	opts.platform = cmd.Flag("plat").Value.String()
+	return nil
+}
+
+// parseDetachKeys converts the comma-separated list of keys into the sequence
+// of bytes which is typed by them.
+func parseDetachKeys(keys string) ([]byte, error) {
+	if keys == "" {
+		return nil, nil
+	}
+
+	var ret []byte
+
+	for _, key := range strings.Split(keys, ",") {
+		if ctrl, ok := strings.CutPrefix(strings.ToLower(key), "ctrl-"); ok {
+			if len(ctrl) != 1 {
+				return nil, fmt.Errorf("invalid detach key: %s", key)
+			}
+
+			switch c := ctrl[0]; {
+			case c >= 'a' && c <= 'z':
+				ret = append(ret, c-'a'+1)
+			case strings.IndexByte("@[\\]^_", c) >= 0:
+				ret = append(ret, c-'@')
+			default:
+				return nil, fmt.Errorf("invalid detach key: %s", key)
+			}
+
+			continue
+		}
+
+		if len(key) != 1 {
+			return nil, fmt.Errorf("invalid detach key: %s", key)
+		}
+
+		ret = append(ret, key[0])
+	}
+
+	return ret, nil
+}
+
+// copyInput copies the input to the console until either the input is
+// exhausted or the detach key sequence is read.  Keys which only partially
+// match the sequence are held back until the match fails.
+func copyInput(dst io.Writer, src io.Reader, keys []byte) error {
+	if len(keys) == 0 {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+
+	buf := make([]byte, 1024)
+	matched := 0
+
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			out := make([]byte, 0, n+matched)
+
+			for _, b := range buf[:n] {
+				if b == keys[matched] {
+					matched++
+					if matched == len(keys) {
+						if _, err := dst.Write(out); err != nil {
+							return err
+						}
+
+						return errDetached
+					}
+
+					continue
+				}
+
+				out = append(out, keys[:matched]...)
+				matched = 0
+
+				if b == keys[0] {
+					matched = 1
+					continue
+				}
+
+				out = append(out, b)
+			}
+
+			if len(out) > 0 {
+				if _, err := dst.Write(out); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			// Keys which were held back are no longer part of a sequence.
+			if matched > 0 {
+				if _, err := dst.Write(keys[:matched]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (opts *AttachOptions) Run(ctx context.Context, args []string) error {
+	var err error
+
+	platform := mplatform.PlatformUnknown
+	var controller machineapi.MachineService
+
+	if opts.platform == "auto" {
+		controller, err = mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	} else {
+		if opts.platform == "host" {
+			platform, _, err = mplatform.Detect(ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			var ok bool
+			platform, ok = mplatform.PlatformsByName()[opts.platform]
+			if !ok {
+				return fmt.Errorf("unknown platform driver: %s", opts.platform)
+			}
+		}
+
+		strategy, ok := mplatform.Strategies()[platform]
+		if !ok {
+			return fmt.Errorf("unsupported platform driver: %s (contributions welcome!)", platform.String())
+		}
+
+		controller, err = strategy.NewMachineV1alpha1(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	var machine *machineapi.Machine
+
+	for _, candidate := range machines.Items {
+		if args[0] == candidate.Name || string(candidate.UID) == args[0] {
+			machine = &candidate
+			break
+		}
+	}
+
+	if machine == nil {
+		return fmt.Errorf("could not find instance %s", args[0])
+	}
+
+	if machine.Status.State != machineapi.MachineStateRunning &&
+		machine.Status.State != machineapi.MachineStatePaused {
+		return fmt.Errorf("cannot attach to instance %s: it is %s", machine.Name, machine.Status.State)
+	}
+
+	if machine.Status.SerialPath == "" {
+		return fmt.Errorf("cannot attach to instance %s: its platform does not expose an interactive console", machine.Name)
+	}
+
+	conn, err := net.Dial("unix", machine.Status.SerialPath)
+	if err != nil {
+		return fmt.Errorf("could not connect to the console of %s: %v", machine.Name, err)
+	}
+
+	defer conn.Close()
+
+	stdin := iostreams.G(ctx).In
+
+	if !opts.NoStdin && iostreams.G(ctx).IsStdinTTY() {
+		if len(opts.detachKeys) > 0 {
+			fmt.Fprintf(iostreams.G(ctx).ErrOut, "attached to %s, detach with %s\r\n", machine.Name, opts.DetachKeys)
+		}
+
+		// Pass every key, including Ctrl+C, through to the unikernel.
+		state, err := term.MakeRaw(int(stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("could not set terminal to raw mode: %v", err)
+		}
+
+		defer func() {
+			_ = term.Restore(int(stdin.Fd()), state)
+		}()
+	}
+
+	output := make(chan error, 1)
+	input := make(chan error, 1)
+
+	go func() {
+		_, err := io.Copy(iostreams.G(ctx).Out, conn)
+		output <- err
+	}()
+
+	if !opts.NoStdin {
+		go func() {
+			input <- copyInput(conn, stdin, opts.detachKeys)
+		}()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case err := <-output:
+			// The console is closed once the machine exits.
+			if err != nil && !errors.Is(err, net.ErrClosed) {
+				return fmt.Errorf("could not read from the console of %s: %v", machine.Name, err)
+			}
+
+			return nil
+
+		case err := <-input:
+			if errors.Is(err, errDetached) {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("could not write to the console of %s: %v", machine.Name, err)
+			}
+
+			// The input has been exhausted, but the output of the unikernel is
+			// still of interest.
+			input = nil
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package attach
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestParseDetachKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			keys: "",
+			want: nil,
+		},
+		{
+			name: "default",
+			keys: "ctrl-p,ctrl-q",
+			want: []byte{0x10, 0x11},
+		},
+		{
+			name: "upper case",
+			keys: "CTRL-X",
+			want: []byte{0x18},
+		},
+		{
+			name: "punctuation",
+			keys: "ctrl-@,ctrl-[,ctrl-\\,ctrl-],ctrl-^,ctrl-_",
+			want: []byte{0x00, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f},
+		},
+		{
+			name: "plain characters",
+			keys: "ctrl-a,x,Y",
+			want: []byte{0x01, 'x', 'Y'},
+		},
+		{
+			name:    "unknown control key",
+			keys:    "ctrl-1",
+			wantErr: true,
+		},
+		{
+			name:    "long control key",
+			keys:    "ctrl-ab",
+			wantErr: true,
+		},
+		{
+			name:    "long key",
+			keys:    "ab",
+			wantErr: true,
+		},
+		{
+			name:    "empty key",
+			keys:    "ctrl-p,",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDetachKeys(tt.keys)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// chunkReader returns each of its chunks from a separate call to Read.
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestCopyInput(t *testing.T) {
+	keys := []byte{0x10, 0x11}
+
+	tests := []struct {
+		name     string
+		keys     []byte
+		chunks   []string
+		want     string
+		detached bool
+	}{
+		{
+			name:   "no detach keys",
+			keys:   nil,
+			chunks: []string{"ab\x10\x11cd"},
+			want:   "ab\x10\x11cd",
+		},
+		{
+			name:   "without sequence",
+			keys:   keys,
+			chunks: []string{"hello", " world"},
+			want:   "hello world",
+		},
+		{
+			name:     "sequence",
+			keys:     keys,
+			chunks:   []string{"ls\x10\x11ignored"},
+			want:     "ls",
+			detached: true,
+		},
+		{
+			name:     "sequence split across reads",
+			keys:     keys,
+			chunks:   []string{"ls\x10", "\x11ignored"},
+			want:     "ls",
+			detached: true,
+		},
+		{
+			name:   "partial sequence is passed through",
+			keys:   keys,
+			chunks: []string{"a\x10", "b"},
+			want:   "a\x10b",
+		},
+		{
+			name:     "repeated first key",
+			keys:     keys,
+			chunks:   []string{"\x10\x10\x11"},
+			want:     "\x10",
+			detached: true,
+		},
+		{
+			name:   "partial sequence at end of input",
+			keys:   keys,
+			chunks: []string{"a\x10"},
+			want:   "a\x10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst bytes.Buffer
+
+			err := copyInput(&dst, &chunkReader{chunks: tt.chunks}, tt.keys)
+			if tt.detached {
+				if !errors.Is(err, errDetached) {
+					t.Errorf("Expected %v, got %v", errDetached, err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if got := dst.String(); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -11,11 +11,14 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
 
 	machineapi "kraftkit.sh/api/machine/v1alpha1"
 	"kraftkit.sh/cmdfactory"
@@ -32,6 +35,10 @@ type EventOptions struct {
 	QuitTogether bool          `long:"quit-together" short:"q" usage:"Exit event loop when machine exits"`
 }
 
+// resyncInterval is how often machines are listed even if no change has been
+// observed, e.g. to catch machines whose platform cannot be watched exiting.
+const resyncInterval = 30 * time.Second
+
 func NewCmd() *cobra.Command {
 	cmd, err := cmdfactory.New(&EventOptions{}, cobra.Command{
 		Short:   "Follow the events of a unikernel",
@@ -40,7 +47,11 @@ func NewCmd() *cobra.Command {
 		Args:    cobra.MaximumNArgs(1),
 		Aliases: []string{"event", "e"},
 		Long: heredoc.Doc(`
-			Follow the events of a unikernel`),
+			Follow the events of a unikernel.
+
+			Whilst following events, unikernels which exit are restarted according to
+			the restart policy they were run with and the health of unikernels which
+			were run with a health check is periodically checked.`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "run",
 		},
@@ -59,6 +70,11 @@ func NewCmd() *cobra.Command {
 		"Set the platform virtual machine monitor driver.",
 	)
 
+	// Changes to machines are watched rather than polled for.
+	if err := cmd.Flags().MarkDeprecated("poll-granularity", "changes to machines are now watched"); err != nil {
+		panic(err)
+	}
+
 	return cmd
 }
 
@@ -73,30 +89,33 @@ func (opts *EventOptions) Run(ctx context.Context, args []string) error {
 	var err error
 
 	ctx, cancel := context.WithCancel(ctx)
-	platform := mplatform.PlatformUnknown
+	var controller machineapi.MachineService
 
+	// Monitor machines of all platforms unless a specific one is requested, such
+	// that a single supervisor is able to restart any machine.
 	if opts.platform == "" || opts.platform == "auto" {
-		platform, _, err = mplatform.Detect(ctx)
-		if err != nil {
-			cancel()
-			return err
-		}
+		controller, err = mplatform.NewMachineV1alpha1ServiceIterator(ctx)
 	} else {
-		var ok bool
-		platform, ok = mplatform.PlatformsByName()[opts.platform]
+		platform, ok := mplatform.PlatformsByName()[opts.platform]
 		if !ok {
 			cancel()
 			return fmt.Errorf("unknown platform driver: %s", opts.platform)
 		}
-	}
 
-	strategy, ok := mplatform.Strategies()[platform]
-	if !ok {
+		strategy, ok := mplatform.Strategies()[platform]
+		if !ok {
+			cancel()
+			return fmt.Errorf("unsupported platform driver: %s (contributions welcome!)", platform.String())
+		}
+
+		controller, err = strategy.NewMachineV1alpha1(ctx)
+	}
+	if err != nil {
 		cancel()
-		return fmt.Errorf("unsupported platform driver: %s (contributions welcome!)", platform.String())
+		return err
 	}
 
-	controller, err := strategy.NewMachineV1alpha1(ctx)
+	machineStore, err := mplatform.NewMachineV1alpha1Store(ctx)
 	if err != nil {
 		cancel()
 		return err
@@ -148,11 +167,42 @@ func (opts *EventOptions) Run(ctx context.Context, args []string) error {
 	// TODO: Should we throw an error here if a process file already exists?  We
 	// use a pid file for `kraft run` to continuously monitor running machines.
 
-	// Actively seek for machines whose events we wish to monitor.  The thread
-	// will continuously read from the machine store which can be updated
-	// elsewhere and acts as the source-of-truth for VMs which are being
-	// instantiated by KraftKit.  The thread dies if there is nothing in the store
-	// and the `--quit-together` flag is set.
+	// Subscribe to changes to the machine store, which can be updated elsewhere
+	// and acts as the source-of-truth for VMs which are being instantiated by
+	// KraftKit.
+	watcher, err := machineStore.Watch(ctx, "", storage.ListOptions{
+		Recursive: true,
+	})
+	if err != nil {
+		cancel()
+		return fmt.Errorf("could not watch machine store: %v", err)
+	}
+
+	defer watcher.Stop()
+
+	// exited receives the machines which a platform reports to have exited.
+	exited := make(chan *machineapi.Machine)
+
+	// watching contains the UIDs of the machines whose platform is being
+	// watched for status updates.
+	watching := sync.Map{}
+
+	// Seek for machines whose events we wish to monitor.  All machines are
+	// listed initially and periodically, whereas in between only the machines
+	// which have changed in the store or have exited are evaluated.  The thread
+	// dies if there is nothing in the store and the `--quit-together` flag is
+	// set.  Machines which have exited are restarted according to their restart
+	// policy.
+	initial := true
+	relist := true
+
+	// changed contains the machines which are to be evaluated.
+	var changed []machineapi.Machine
+
+	// due is when the next machine which is yet to be restarted is due to be
+	// restarted, if there is any.
+	var due time.Time
+
 seek:
 	for {
 		select {
@@ -161,13 +211,42 @@ seek:
 		default:
 		}
 
-		machines, err := controller.List(ctx, &machineapi.MachineList{})
-		if err != nil {
-			return fmt.Errorf("could not list machines: %v", err)
+		if relist {
+			machines, err := controller.List(ctx, &machineapi.MachineList{})
+			if err != nil {
+				return fmt.Errorf("could not list machines: %v", err)
+			}
+
+			changed = machines.Items
+			relist = false
+			due = time.Time{}
 		}
 
-		for _, machine := range machines.Items {
+		for _, machine := range changed {
+			machine := machine // loop closure
+
 			if len(args) == 0 || (args[0] == string(machine.UID) || args[0] == machine.Name) {
+				if shouldRestart(&machine, initial) {
+					if wait := restartDelay(machine.Status.RestartCount) - time.Since(machine.Status.ExitedAt); wait > 0 {
+						if at := time.Now().Add(wait); due.IsZero() || at.Before(due) {
+							due = at
+						}
+						continue
+					}
+
+					restarted, err := restart(ctx, controller, &machine)
+					if err != nil {
+						log.G(ctx).Errorf("could not restart machine: %v", err)
+						continue
+					}
+
+					machine = *restarted
+				}
+
+				if machine.Spec.HealthCheck != nil && machine.Status.State == machineapi.MachineStateRunning {
+					go monitorHealth(ctx, machineStore, machine)
+				}
+
 				switch machine.Status.State {
 				case machineapi.MachineStateFailed,
 					machineapi.MachineStateExited,
@@ -178,51 +257,116 @@ seek:
 				default:
 				}
 
-				observations.Add(&machine)
+				// Machines whose platform is already being watched are observed.
+				if _, ok := watching.Load(machine.UID); !ok {
+					observations.Add(&machine)
+				}
 			}
 		}
 
-		if len(observations.Items()) == 0 && opts.QuitTogether {
+		changed = nil
+
+		if len(observations.Items()) == 0 && due.IsZero() && opts.QuitTogether {
 			cancel()
 			break seek
 		}
 
+		initial = false
+
 		for _, machine := range observations.Items() {
-			machine := machine // loop closure
+			observed := machine // loop closure
+
+			if _, loaded := watching.LoadOrStore(observed.UID, struct{}{}); loaded {
+				continue
+			}
 
 			go func() {
-				events, errs, err := controller.Watch(ctx, machine)
+				defer watching.Delete(observed.UID)
+
+				events, errs, err := controller.Watch(ctx, observed)
 				if err != nil {
-					log.G(ctx).Debugf("could not listen for status updates for %s: %v", machine.Name, err)
+					log.G(ctx).Debugf("could not listen for status updates for %s: %v", observed.Name, err)
+					observations.Done(observed)
 					return
 				}
 
 				for {
 					// Wait on either channel
 					select {
-					case machine := <-events:
+					case machine, ok := <-events:
+						if !ok {
+							observations.Done(observed)
+							return
+						}
+
 						log.G(ctx).Infof("%s : %s", machine.Name, machine.Status.State.String())
 						switch machine.Status.State {
 						case machineapi.MachineStateExited, machineapi.MachineStateFailed:
-							observations.Done(machine)
+							observations.Done(observed)
+
+							// Re-evaluate the machine such that it is restarted.
+							select {
+							case exited <- observed:
+							case <-ctx.Done():
+							}
+
 							return
 						}
 
-					case err := <-errs:
-						if !errors.Is(err, qmp.ErrAcceptedNonEvent) {
+					case err, ok := <-errs:
+						if ok && !errors.Is(err, qmp.ErrAcceptedNonEvent) {
 							log.G(ctx).Errorf("%v", err)
 						}
-						observations.Done(machine)
+						observations.Done(observed)
+						return
 
 					case <-ctx.Done():
-						observations.Done(machine)
+						observations.Done(observed)
 						return
 					}
 				}
 			}()
 		}
 
-		time.Sleep(time.Second * opts.Granularity)
+		var restart <-chan time.Time
+		if !due.IsZero() {
+			restart = time.After(time.Until(due))
+		}
+
+		// Wait until either a machine has changed in the store or has exited, a
+		// machine is due to be restarted or the machines are to be resynced.
+		select {
+		case <-ctx.Done():
+			break seek
+
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return fmt.Errorf("stopped watching machine store")
+			}
+
+			// The changed machine is carried by the event, such that it does not
+			// have to be retrieved again.
+			if machine, ok := event.Object.(*machineapi.Machine); ok && event.Type != watch.Deleted {
+				changed = []machineapi.Machine{*machine}
+			}
+
+		case machine := <-exited:
+			// The state of the machine in the store is only updated once it has
+			// been retrieved from its platform.
+			updated, err := controller.Get(ctx, machine)
+			if err != nil {
+				log.G(ctx).Debugf("could not get %s: %v", machine.Name, err)
+				continue
+			}
+
+			changed = []machineapi.Machine{*updated}
+
+		case <-restart:
+			relist = true
+
+		case <-time.After(resyncInterval):
+			relist = true
+		}
 	}
 
 	observations.Wait()
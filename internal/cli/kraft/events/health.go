@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	zip "api.zip"
+	corev1 "k8s.io/api/core/v1"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/store"
+)
+
+// monitors contains the UIDs of the machines whose health is being checked.
+var monitors = sync.Map{}
+
+// healthCheckAddress returns the address which is dialed by a TCP health check
+// of the machine.  A bare port refers to a port of the machine, which is
+// reached via the host port it is published on or, if it is not published, via
+// the address of the first network interface of the machine.
+func healthCheckAddress(machine *machineapi.Machine, addr string) (string, error) {
+	port, err := strconv.Atoi(addr)
+	if err != nil {
+		return addr, nil
+	}
+
+	for _, published := range machine.Spec.Ports {
+		if int(published.MachinePort) != port || published.HostPort == 0 {
+			continue
+		}
+
+		if published.Protocol != "" && published.Protocol != corev1.ProtocolTCP {
+			continue
+		}
+
+		host := published.HostIP
+		if host == "" || net.ParseIP(host).IsUnspecified() {
+			host = "127.0.0.1"
+		}
+
+		return net.JoinHostPort(host, strconv.Itoa(int(published.HostPort))), nil
+	}
+
+	for _, network := range machine.Spec.Networks {
+		for _, iface := range network.Interfaces {
+			if iface.Spec.IP != "" {
+				return net.JoinHostPort(iface.Spec.IP, addr), nil
+			} else if iface.Spec.IPv6 != "" {
+				return net.JoinHostPort(iface.Spec.IPv6, addr), nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("port %d is neither published nor reachable via a network interface", port)
+}
+
+// logCheck follows the log of a machine for a line which matches the pattern
+// of its health check.  Only what has been appended to the log since it was
+// last checked is read and, once a line has matched, the check keeps passing.
+type logCheck struct {
+	offset  int64
+	matched bool
+}
+
+// check reads the lines which have been appended to the log at the provided
+// path and returns an error unless any line read so far matches the pattern.
+func (lc *logCheck) check(path, pattern string) error {
+	if lc.matched {
+		return nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Start over if the log has been truncated.
+	if fi.Size() < lc.offset {
+		lc.offset = 0
+	}
+
+	if _, err := f.Seek(lc.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if re.Match(bytes.TrimRight(line, "\r\n")) {
+			lc.matched = true
+			return nil
+		}
+
+		// A trailing line which is incomplete is read again once it has been
+		// completed.
+		if err == io.EOF {
+			break
+		}
+
+		lc.offset += int64(len(line))
+	}
+
+	return fmt.Errorf("no line of the log matches %q", pattern)
+}
+
+// checkHealth performs a single health check of the machine and returns why
+// it failed, if it did.  Log checks continue from the provided state.
+func checkHealth(ctx context.Context, machine *machineapi.Machine, timeout time.Duration, lc *logCheck) error {
+	check := machine.Spec.HealthCheck
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch {
+	case check.TCP != "":
+		addr, err := healthCheckAddress(machine, check.TCP)
+		if err != nil {
+			return err
+		}
+
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+
+	case check.HTTP != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.HTTP, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			return fmt.Errorf("GET %s: %s", check.HTTP, resp.Status)
+		}
+
+		return nil
+
+	case check.Log != "":
+		return lc.check(machine.Status.LogFile, check.Log)
+	}
+
+	return fmt.Errorf("health check does not specify what to check")
+}
+
+// saveHealth records the provided health on the machine as it is currently
+// saved in the machine store, leaving any other change which has been saved
+// in the meantime intact, and returns the saved machine.
+func saveHealth(ctx context.Context, machineStore zip.Store, machine *machineapi.Machine, health machineapi.MachineHealth) (*machineapi.Machine, error) {
+	return store.Update[machineapi.MachineSpec, machineapi.MachineStatus](ctx, machineStore, machine.UID, func(saved *machineapi.Machine) {
+		saved.Status.Health = health
+	})
+}
+
+// monitorHealth periodically checks the health of the provided machine and
+// saves the outcome in the machine store until it is no longer running.  Only
+// a single monitor is run for each machine.
+func monitorHealth(ctx context.Context, machineStore zip.Store, machine machineapi.Machine) {
+	if _, loaded := monitors.LoadOrStore(machine.UID, struct{}{}); loaded {
+		return
+	}
+
+	defer monitors.Delete(machine.UID)
+
+	check := machine.Spec.HealthCheck
+
+	interval := check.Interval
+	if interval <= 0 {
+		interval = machineapi.DefaultHealthCheckInterval
+	}
+
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = machineapi.DefaultHealthCheckTimeout
+	}
+
+	retries := check.Retries
+	if retries <= 0 {
+		retries = machineapi.DefaultHealthCheckRetries
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	health := machineapi.MachineHealth{
+		Status: machineapi.MachineHealthStatusStarting,
+	}
+
+	var lc logCheck
+
+	for {
+		previous := health.Status
+
+		if err := checkHealth(ctx, &machine, timeout, &lc); err != nil {
+			health.FailingStreak++
+			health.Error = err.Error()
+
+			if health.FailingStreak >= retries {
+				health.Status = machineapi.MachineHealthStatusUnhealthy
+			}
+		} else {
+			health.Status = machineapi.MachineHealthStatusHealthy
+			health.FailingStreak = 0
+			health.Error = ""
+		}
+
+		health.CheckedAt = time.Now()
+
+		if health.Status != previous {
+			log.G(ctx).Infof("%s : %s", machine.Name, health.Status)
+		}
+
+		updated, err := saveHealth(ctx, machineStore, &machine, health)
+		if err != nil {
+			log.G(ctx).Errorf("could not save health of %s: %v", machine.Name, err)
+			return
+		}
+
+		machine = *updated
+		if machine.Status.State != machineapi.MachineStateRunning {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
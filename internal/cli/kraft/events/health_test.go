@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine.log")
+
+	write := func(flag int, data string) {
+		f, err := os.OpenFile(path, flag|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatalf("Could not open log: %v", err)
+		}
+
+		defer f.Close()
+
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatalf("Could not write log: %v", err)
+		}
+	}
+
+	var lc logCheck
+
+	if err := lc.check(path, "ready"); err == nil {
+		t.Fatal("Expected error for missing log")
+	}
+
+	write(os.O_TRUNC, "booting\nloading\n")
+	if err := lc.check(path, "ready"); err == nil {
+		t.Fatal("Expected error for log without match")
+	}
+
+	if want := int64(len("booting\nloading\n")); lc.offset != want {
+		t.Errorf("Expected offset %d, got %d", want, lc.offset)
+	}
+
+	// An incomplete line is matched but not skipped over.
+	write(os.O_APPEND, "still ")
+	if err := lc.check(path, "^still$"); err == nil {
+		t.Fatal("Expected error for incomplete line without match")
+	}
+
+	if want := int64(len("booting\nloading\n")); lc.offset != want {
+		t.Errorf("Expected offset %d, got %d", want, lc.offset)
+	}
+
+	write(os.O_APPEND, "waiting\nserver ready\n")
+	if err := lc.check(path, "^server ready$"); err != nil {
+		t.Fatalf("Expected match, got %v", err)
+	}
+
+	// Once matched, the check keeps passing without reading the log.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Could not remove log: %v", err)
+	}
+
+	if err := lc.check(path, "^server ready$"); err != nil {
+		t.Errorf("Expected match to persist, got %v", err)
+	}
+}
+
+func TestLogCheckTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine.log")
+
+	if err := os.WriteFile(path, []byte("a long line which is read first\n"), 0o644); err != nil {
+		t.Fatalf("Could not write log: %v", err)
+	}
+
+	var lc logCheck
+
+	if err := lc.check(path, "ready"); err == nil {
+		t.Fatal("Expected error for log without match")
+	}
+
+	// The log is read from its start again once it has been truncated.
+	if err := os.WriteFile(path, []byte("ready\n"), 0o644); err != nil {
+		t.Fatalf("Could not write log: %v", err)
+	}
+
+	if err := lc.check(path, "ready"); err != nil {
+		t.Errorf("Expected match after truncation, got %v", err)
+	}
+}
+
+func TestLogCheckInvalidPattern(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "machine.log")
+
+	if err := os.WriteFile(path, []byte("ready\n"), 0o644); err != nil {
+		t.Fatalf("Could not write log: %v", err)
+	}
+
+	var lc logCheck
+
+	if err := lc.check(path, "("); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
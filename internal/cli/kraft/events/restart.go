@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/log"
+)
+
+const (
+	// minRestartDelay is the delay before a machine is restarted for the first
+	// time, which doubles with each subsequent restart.
+	minRestartDelay = 100 * time.Millisecond
+
+	// maxRestartDelay caps the delay between restarts of a machine.
+	maxRestartDelay = time.Minute
+)
+
+// restartDelay returns how long to wait after a machine which has previously
+// been restarted count times has exited before restarting it again.
+func restartDelay(count int) time.Duration {
+	delay := minRestartDelay
+	for i := 0; i < count && delay < maxRestartDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRestartDelay {
+		return maxRestartDelay
+	}
+
+	return delay
+}
+
+// shouldRestart returns whether the provided machine ought to be restarted
+// according to its restart policy.  A machine is considered to have been
+// explicitly stopped if it has a stop stage, which is only set when stopping
+// it, and initial indicates whether the supervisor has only just started.
+func shouldRestart(machine *machineapi.Machine, initial bool) bool {
+	switch machine.Status.State {
+	case machineapi.MachineStateExited,
+		machineapi.MachineStateFailed,
+		machineapi.MachineStateErrored:
+	default:
+		return false
+	}
+
+	policy := machine.Spec.RestartPolicy
+	stopped := len(machine.Status.StopStage) > 0
+
+	switch policy.Name {
+	case machineapi.MachineRestartPolicyOnFailure:
+		if stopped {
+			return false
+		}
+
+		if machine.Status.State == machineapi.MachineStateExited && machine.Status.ExitCode == 0 {
+			return false
+		}
+
+		return policy.MaximumRetryCount == 0 || machine.Status.RestartCount < policy.MaximumRetryCount
+
+	case machineapi.MachineRestartPolicyAlways:
+		return !stopped || initial
+
+	case machineapi.MachineRestartPolicyUnlessStopped:
+		return !stopped
+	}
+
+	return false
+}
+
+// restart recreates and starts the provided machine which has exited.
+func restart(ctx context.Context, controller machineapi.MachineService, machine *machineapi.Machine) (*machineapi.Machine, error) {
+	log.G(ctx).Infof("%s : %s", machine.Name, machineapi.MachineStateRestarting.String())
+
+	// Release any resources which are still held on behalf of the machine, e.g.
+	// if its process crashed.
+	if stopped, err := controller.Stop(ctx, machine); err != nil {
+		log.G(ctx).Debugf("could not clean up %s before restarting: %v", machine.Name, err)
+	} else if stopped != nil {
+		// Continue with the machine as it was saved such that its resource
+		// version is current.
+		machine = stopped
+	}
+
+	machine.Status.State = machineapi.MachineStateRestarting
+	machine.Status.StopStage = ""
+	machine.Status.ExitCode = 0
+	machine.Status.ExitedAt = time.Time{}
+	machine.Status.StartedAt = time.Time{}
+	machine.Status.RestartCount++
+
+	// The recreated machine has yet to pass a health check.
+	if machine.Spec.HealthCheck != nil {
+		machine.Status.Health = machineapi.MachineHealth{
+			Status: machineapi.MachineHealthStatusStarting,
+		}
+	}
+
+	machine, err := controller.Create(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not recreate %s: %v", machine.Name, err)
+	}
+
+	machine, err = controller.Start(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not start %s: %v", machine.Name, err)
+	}
+
+	return machine, nil
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package events
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+)
+
+func TestRestartDelay(t *testing.T) {
+	tests := []struct {
+		count int
+		want  time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{9, 51200 * time.Millisecond},
+		{10, time.Minute},
+		{11, time.Minute},
+		{1000, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d", tt.count), func(t *testing.T) {
+			if got := restartDelay(tt.count); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   string
+		state    machineapi.MachineState
+		exitCode int
+		stopped  bool
+		restarts int
+		initial  bool
+		want     bool
+	}{
+		{
+			name:   "no after failure",
+			policy: "no",
+			state:  machineapi.MachineStateFailed,
+		},
+		{
+			name:   "always while running",
+			policy: "always",
+			state:  machineapi.MachineStateRunning,
+		},
+		{
+			name:   "always after exit",
+			policy: "always",
+			state:  machineapi.MachineStateExited,
+			want:   true,
+		},
+		{
+			name:    "always after stop",
+			policy:  "always",
+			state:   machineapi.MachineStateExited,
+			stopped: true,
+		},
+		{
+			name:    "always after stop once the supervisor starts",
+			policy:  "always",
+			state:   machineapi.MachineStateExited,
+			stopped: true,
+			initial: true,
+			want:    true,
+		},
+		{
+			name:    "unless-stopped after stop once the supervisor starts",
+			policy:  "unless-stopped",
+			state:   machineapi.MachineStateExited,
+			stopped: true,
+			initial: true,
+		},
+		{
+			name:   "on-failure after success",
+			policy: "on-failure",
+			state:  machineapi.MachineStateExited,
+		},
+		{
+			name:     "on-failure after non-zero exit",
+			policy:   "on-failure",
+			state:    machineapi.MachineStateExited,
+			exitCode: 1,
+			want:     true,
+		},
+		{
+			name:   "on-failure after failure",
+			policy: "on-failure",
+			state:  machineapi.MachineStateFailed,
+			want:   true,
+		},
+		{
+			name:     "on-failure after stop",
+			policy:   "on-failure",
+			state:    machineapi.MachineStateExited,
+			exitCode: 1,
+			stopped:  true,
+		},
+		{
+			name:     "on-failure without maximum",
+			policy:   "on-failure",
+			state:    machineapi.MachineStateErrored,
+			restarts: 100,
+			want:     true,
+		},
+		{
+			name:     "on-failure:3 below maximum",
+			policy:   "on-failure:3",
+			state:    machineapi.MachineStateFailed,
+			restarts: 2,
+			want:     true,
+		},
+		{
+			name:     "on-failure:3 at maximum",
+			policy:   "on-failure:3",
+			state:    machineapi.MachineStateFailed,
+			restarts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := machineapi.ParseMachineRestartPolicy(tt.policy)
+			if err != nil {
+				t.Fatalf("Could not parse restart policy: %v", err)
+			}
+
+			machine := &machineapi.Machine{}
+			machine.Spec.RestartPolicy = policy
+			machine.Status.State = tt.state
+			machine.Status.ExitCode = tt.exitCode
+			machine.Status.RestartCount = tt.restarts
+
+			if tt.stopped {
+				machine.Status.StopStage = machineapi.MachineStopStageShutdown
+			}
+
+			if got := shouldRestart(machine, tt.initial); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
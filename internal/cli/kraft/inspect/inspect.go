@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package inspect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type InspectOptions struct {
+	Format   string `long:"format" short:"f" usage:"Format the output using the provided Go template"`
+	Output   string `long:"output" short:"o" usage:"Set output format (json, yaml)" default:"json"`
+	platform string
+	template *template.Template
+}
+
+// Inspect prints the complete description of local Unikraft virtual machines.
+func Inspect(ctx context.Context, opts *InspectOptions, args ...string) error {
+	if opts == nil {
+		opts = &InspectOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&InspectOptions{}, cobra.Command{
+		Short: "Display detailed information about one or more unikernels",
+		Use:   "inspect [FLAGS] MACHINE [MACHINE [...]]",
+		Args:  cobra.MinimumNArgs(1),
+		Long: heredoc.Doc(`
+			Display detailed information about one or more unikernels.
+
+			The complete machine is printed, including its specification, its status
+			and the configuration of its platform, such as the command line of its
+			virtual machine monitor.  Several unikernels are printed as a JSON array.
+			Use --format to extract individual fields with a Go template which is
+			executed on each machine.`),
+		Example: heredoc.Doc(`
+			# Print a unikernel as YAML
+			$ kraft inspect -o yaml my-machine
+
+			# Print the IP addresses of a unikernel
+			$ kraft inspect --format '{{range .Spec.Networks}}{{range .Interfaces}}{{.Spec.IP}} {{end}}{{end}}' my-machine
+
+			# Print the command line of the virtual machine monitor of a unikernel
+			$ kraft inspect --format '{{.Status.Cmdline}}' my-machine
+
+			# Print the network interfaces of a unikernel as JSON
+			$ kraft inspect --format '{{json .Spec.Networks}}' my-machine`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "run",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.Flags().VarP(
+		cmdfactory.NewEnumFlag[mplatform.Platform](
+			mplatform.Platforms(),
+			mplatform.Platform("auto"),
+		),
+		"plat",
+		"p",
+		"Set the platform virtual machine monitor driver.  Set to 'auto' to detect the guest's platform and 'host' to use the host platform.",
+	)
+
+	return cmd
+}
+
+func (opts *InspectOptions) Pre(cmd *cobra.Command, _ []string) error {
+	var err error
+
+	if opts.Format != "" {
+		opts.template, err = template.New("format").
+			Funcs(template.FuncMap{
+				"json": func(v interface{}) (string, error) {
+					b, err := json.Marshal(v)
+					return string(b), err
+				},
+			}).
+			Parse(opts.Format)
+		if err != nil {
+			return fmt.Errorf("invalid format: %v", err)
+		}
+	} else if opts.Output != "json" && opts.Output != "yaml" {
+		return fmt.Errorf("unknown output format: %s (choice of json or yaml)", opts.Output)
+	}
+
+	opts.platform = cmd.Flag("plat").Value.String()
+	return nil
+}
+
+// print writes the provided machines in the requested format.  Several
+// machines are written as a single JSON array or as consecutive YAML
+// documents, whereas a template is executed on each machine in turn.
+func (opts *InspectOptions) print(ctx context.Context, machines []machineapi.Machine) error {
+	out := iostreams.G(ctx).Out
+
+	if opts.template != nil {
+		for i := range machines {
+			machine := &machines[i]
+			if err := opts.template.Execute(out, machine); err != nil {
+				return fmt.Errorf("could not format machine %s: %v", machine.Name, err)
+			}
+
+			fmt.Fprintln(out)
+		}
+
+		return nil
+	}
+
+	if opts.Output == "yaml" {
+		for i, machine := range machines {
+			if i > 0 {
+				fmt.Fprintln(out, "---")
+			}
+
+			b, err := yaml.Marshal(machine)
+			if err != nil {
+				return fmt.Errorf("could not marshal machine %s: %v", machine.Name, err)
+			}
+
+			if _, err := out.Write(b); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var v interface{} = machines
+	if len(machines) == 1 {
+		v = machines[0]
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal machines: %v", err)
+	}
+
+	_, err = out.Write(append(b, '\n'))
+	return err
+}
+
+func (opts *InspectOptions) Run(ctx context.Context, args []string) error {
+	var err error
+
+	platform := mplatform.PlatformUnknown
+	var controller machineapi.MachineService
+
+	if opts.platform == "" || opts.platform == "auto" {
+		controller, err = mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	} else {
+		if opts.platform == "host" {
+			platform, _, err = mplatform.Detect(ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			var ok bool
+			platform, ok = mplatform.PlatformsByName()[opts.platform]
+			if !ok {
+				return fmt.Errorf("unknown platform driver: %s", opts.platform)
+			}
+		}
+
+		strategy, ok := mplatform.Strategies()[platform]
+		if !ok {
+			return fmt.Errorf("unsupported platform driver: %s (contributions welcome!)", platform.String())
+		}
+
+		controller, err = strategy.NewMachineV1alpha1(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	var inspected []machineapi.Machine
+
+	for _, arg := range args {
+		var found *machineapi.Machine
+
+		for _, machine := range machines.Items {
+			if arg == machine.Name || arg == string(machine.UID) {
+				machine := machine
+				found = &machine
+				break
+			}
+		}
+
+		if found == nil {
+			return fmt.Errorf("could not find machine %s", arg)
+		}
+
+		// Retrieve the latest status of the machine, falling back to the stored
+		// one if the platform cannot provide it.
+		current := *found
+		if latest, err := controller.Get(ctx, &current); err == nil {
+			found = latest
+		} else {
+			log.G(ctx).Debugf("could not get status of %s: %v", found.Name, err)
+		}
+
+		inspected = append(inspected, *found)
+	}
+
+	return opts.print(ctx, inspected)
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package inspect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"text/template"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/iostreams"
+)
+
+func testMachines(names ...string) []machineapi.Machine {
+	var machines []machineapi.Machine
+
+	for _, name := range names {
+		machines = append(machines, machineapi.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+			},
+		})
+	}
+
+	return machines
+}
+
+func printMachines(t *testing.T, opts *InspectOptions, machines []machineapi.Machine) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	ctx := iostreams.WithIOStreams(context.Background(), &iostreams.IOStreams{
+		Out: iostreams.NewNoTTYWriter(&out),
+	})
+
+	if err := opts.print(ctx, machines); err != nil {
+		t.Fatalf("Could not print machines: %v", err)
+	}
+
+	return out.String()
+}
+
+func TestPrintJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+	}{
+		{
+			name:  "single machine",
+			names: []string{"one"},
+		},
+		{
+			name:  "several machines",
+			names: []string{"one", "two"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := printMachines(t, &InspectOptions{Output: "json"}, testMachines(tt.names...))
+
+			var names []string
+
+			// A single machine is printed as an object, several machines as an
+			// array.
+			if len(tt.names) == 1 {
+				var machine machineapi.Machine
+				if err := json.Unmarshal([]byte(got), &machine); err != nil {
+					t.Fatalf("Expected a JSON object, got %s", got)
+				}
+
+				names = append(names, machine.Name)
+			} else {
+				var machines []machineapi.Machine
+				if err := json.Unmarshal([]byte(got), &machines); err != nil {
+					t.Fatalf("Expected a JSON array, got %s", got)
+				}
+
+				for _, machine := range machines {
+					names = append(names, machine.Name)
+				}
+			}
+
+			if len(names) != len(tt.names) {
+				t.Fatalf("Expected %v, got %v", tt.names, names)
+			}
+
+			for i := range names {
+				if names[i] != tt.names[i] {
+					t.Errorf("Expected %v, got %v", tt.names, names)
+				}
+			}
+		})
+	}
+}
+
+func TestPrintYAML(t *testing.T) {
+	got := printMachines(t, &InspectOptions{Output: "yaml"}, testMachines("one", "two"))
+
+	docs := bytes.Split([]byte(got), []byte("---\n"))
+	if len(docs) != 2 {
+		t.Fatalf("Expected 2 documents, got %d:\n%s", len(docs), got)
+	}
+
+	for i, want := range []string{"one", "two"} {
+		var machine machineapi.Machine
+		if err := yaml.Unmarshal(docs[i], &machine); err != nil {
+			t.Fatalf("Could not unmarshal document %d: %v", i, err)
+		}
+
+		if machine.Name != want {
+			t.Errorf("Expected %s, got %s", want, machine.Name)
+		}
+	}
+}
+
+func TestPrintTemplate(t *testing.T) {
+	opts := &InspectOptions{
+		template: template.Must(template.New("format").Parse("{{.Name}}")),
+	}
+
+	if got, want := printMachines(t, opts, testMachines("one", "two")), "one\ntwo\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -122,14 +122,20 @@ func (opts *LogOptions) Run(ctx context.Context, args []string) error {
 			for {
 				// Wait on either channel
 				select {
-				case status := <-events:
+				case status, ok := <-events:
+					if !ok {
+						break loop
+					}
+
 					switch status.Status.State {
 					case machineapi.MachineStateExited, machineapi.MachineStateFailed:
 						break loop
 					}
 
-				case err := <-errs:
-					log.G(ctx).Errorf("received event error: %v", err)
+				case err, ok := <-errs:
+					if ok {
+						log.G(ctx).Errorf("received event error: %v", err)
+					}
 					break loop
 
 				case <-ctx.Done():
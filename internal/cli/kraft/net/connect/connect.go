@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package connect
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type ConnectOptions struct {
+	driver     string
+	IP         string `long:"ip" usage:"Assign the provided IP address"`
+	IPv6       string `long:"ip6" usage:"Assign the provided IPv6 address"`
+	MacAddress string `long:"mac" usage:"Assign the provided MAC address"`
+}
+
+// Connect attaches a new interface on a local machine network to a machine.
+func Connect(ctx context.Context, opts *ConnectOptions, args ...string) error {
+	if opts == nil {
+		opts = &ConnectOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&ConnectOptions{}, cobra.Command{
+		Short: "Connect a unikernel to a network",
+		Use:   "connect [FLAGS] NETWORK MACHINE",
+		Args:  cobra.ExactArgs(2),
+		Long: heredoc.Doc(`
+			Connect a unikernel to a machine network.
+
+			A new interface is added to the network and attached to the unikernel.
+			Running unikernels receive the interface as a hot-plugged network device,
+			if supported by their platform.`),
+		Example: heredoc.Doc(`
+			# Connect a running unikernel to the network kraft0
+			$ kraft net connect kraft0 my-machine
+
+			# Connect a unikernel with a specific IP address
+			$ kraft net connect --ip 172.100.0.10 kraft0 my-machine`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *ConnectOptions) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+	return nil
+}
+
+func (opts *ConnectOptions) Run(ctx context.Context, args []string) error {
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %v (contributions welcome!)", opts.driver)
+	}
+
+	netcontroller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	controller, err := mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	if err != nil {
+		return err
+	}
+
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	var machine *machineapi.Machine
+	for i, candidate := range machines.Items {
+		if args[1] == candidate.Name || args[1] == string(candidate.UID) {
+			machine = &machines.Items[i]
+			break
+		}
+	}
+	if machine == nil {
+		return fmt.Errorf("machine not found: %s", args[1])
+	}
+
+	found, err := netcontroller.Get(ctx, &networkapi.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The labels of the machine are also those of its interface, such that the
+	// policy of the network can select it.
+	newIface := networkapi.NetworkInterfaceTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   machine.Name,
+			UID:    uuid.NewUUID(),
+			Labels: machine.Labels,
+		},
+		Spec: networkapi.NetworkInterfaceSpec{
+			IP:         opts.IP,
+			IPv6:       opts.IPv6,
+			MacAddress: opts.MacAddress,
+		},
+	}
+
+	found.Spec.Interfaces = append(found.Spec.Interfaces, newIface)
+
+	found, err = netcontroller.Update(ctx, found)
+	if err != nil {
+		return err
+	}
+
+	for _, iface := range found.Spec.Interfaces {
+		if iface.UID == newIface.UID {
+			newIface = iface
+			break
+		}
+	}
+
+	// Add the interface to the machine's existing attachment to the network, if
+	// any, or otherwise attach the machine to the network.
+	attached := false
+	for i, spec := range machine.Spec.Networks {
+		if spec.IfName == found.Spec.IfName {
+			machine.Spec.Networks[i].Interfaces = append(machine.Spec.Networks[i].Interfaces, newIface)
+			attached = true
+			break
+		}
+	}
+
+	if !attached {
+		spec := found.Spec
+		spec.Interfaces = []networkapi.NetworkInterfaceTemplateSpec{newIface}
+		machine.Spec.Networks = append(machine.Spec.Networks, spec)
+	}
+
+	if _, err := controller.Update(ctx, machine); err != nil {
+		// Release the interface again such that its address can be re-used.
+		for i, iface := range found.Spec.Interfaces {
+			if iface.UID == newIface.UID {
+				ret := make([]networkapi.NetworkInterfaceTemplateSpec, 0)
+				ret = append(ret, found.Spec.Interfaces[:i]...)
+				found.Spec.Interfaces = append(ret, found.Spec.Interfaces[i+1:]...)
+				break
+			}
+		}
+
+		if _, uerr := netcontroller.Update(ctx, found); uerr != nil {
+			log.G(ctx).Warnf("could not release interface on network %s: %v", found.Name, uerr)
+		}
+
+		return fmt.Errorf("could not connect machine %s to network %s: %v", machine.Name, found.Name, err)
+	}
+
+	fmt.Fprintln(iostreams.G(ctx).Out, newIface.Spec.IP)
+
+	return nil
+}
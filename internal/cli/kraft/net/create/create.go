@@ -9,19 +9,26 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
-	"github.com/vishvananda/netlink"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
 	networkapi "kraftkit.sh/api/network/v1alpha1"
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/iostreams"
 	"kraftkit.sh/machine/network"
+	"kraftkit.sh/machine/network/daemon"
 )
 
 type CreateOptions struct {
+	DHCP    bool `long:"dhcp" usage:"Serve addresses and names to the machines on the network from the host"`
 	driver  string
-	Network string `long:"network" short:"n" usage:"Set the gateway IP address and the subnet of the network in CIDR format."`
+	Labels  []string `long:"label" usage:"Set a label on the network in the format KEY[=VALUE]" split:"false"`
+	labels  map[string]string
+	NAT     bool     `long:"nat" usage:"Masquerade traffic from the network to hosts beyond the host"`
+	Network []string `long:"network" short:"n" usage:"Set the gateway IP address and the subnet of the network in CIDR format, once for IPv4 and/or once for IPv6" split:"false"`
+	subnets []*net.IPNet
 }
 
 // Create a new local machine network.
@@ -34,11 +41,42 @@ func Create(ctx context.Context, opts *CreateOptions, args ...string) error {
 }
 
 func NewCmd() *cobra.Command {
-	cmd, err := cmdfactory.New(&CreateOptions{}, cobra.Command{
+	cmd, err := cmdfactory.New(&CreateOptions{NAT: true}, cobra.Command{
 		Short:   "Create a new machine network",
 		Use:     "create [FLAGS] NETWORK",
 		Aliases: []string{"add"},
 		Args:    cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Create a new machine network.
+
+			Unless --nat=false is provided, traffic from the machines on the network to
+			hosts beyond the host is masqueraded behind the address of the host, such
+			that the machines can reach the internet.
+
+			With --dhcp, a daemon on the host hands out addresses from the subnet of
+			the network and resolves the names of its machines below the domain
+			"kraft", forwarding any other query to the name servers of the host.  The
+			daemon is stopped by 'kraft net down' and started again by 'kraft net up'.
+
+			A network may have both an IPv4 and an IPv6 subnet, in which case the
+			machines on it are assigned an address from each.  Addresses are only
+			handed out via DHCP from the IPv4 subnet.`),
+		Example: heredoc.Doc(`
+			# Create a network whose machines can reach the internet
+			$ kraft net create --network 172.100.0.1/24 kraft0
+
+			# Create a network which is isolated from the outside of the host
+			$ kraft net create --nat=false --network 172.101.0.1/24 isolated0
+
+			# Create a network whose machines are assigned addresses via DHCP and can
+			# resolve each other as <name>.kraft
+			$ kraft net create --dhcp --network 172.102.0.1/24 kraft1
+
+			# Create a dual-stack network
+			$ kraft net create --network 172.103.0.1/24 --network fd00:cafe::1/64 kraft2
+
+			# Create an IPv6-only network
+			$ kraft net create --network fd00:beef::1/64 kraft6`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "net",
 		},
@@ -47,12 +85,22 @@ func NewCmd() *cobra.Command {
 		panic(err)
 	}
 
+	// NAT is enabled unless it is explicitly disabled with --nat=false.
+	cmd.Flag("nat").DefValue = "true"
+
 	return cmd
 }
 
 func (opts *CreateOptions) Pre(cmd *cobra.Command, _ []string) error {
+	var err error
+
 	opts.driver = cmd.Flag("driver").Value.String()
 
+	opts.labels, err = machineapi.ParseLabels(opts.Labels)
+	if err != nil {
+		return err
+	}
+
 	// TODO(nderjung): A future implementation can list existing networks and
 	// generate new subnet and gateway appropriately.  Simply calculate a new
 	// subnet which is out of bounds from all existing subnets for the given
@@ -62,10 +110,25 @@ func (opts *CreateOptions) Pre(cmd *cobra.Command, _ []string) error {
 	// if opts.Subnet == "" {
 	// 	return fmt.Errorf("cannot create network without subnet")
 	// }
-	if opts.Network == "" {
+	if len(opts.Network) == 0 {
 		return fmt.Errorf("cannot create network without gateway and subnet in CIDR format")
 	}
 
+	ipv4 := false
+	for _, cidr := range opts.Network {
+		subnet, err := networkapi.ParseSubnet(cidr)
+		if err != nil {
+			return err
+		}
+
+		ipv4 = ipv4 || subnet.IP.To4() != nil
+		opts.subnets = append(opts.subnets, subnet)
+	}
+
+	if opts.DHCP && !ipv4 {
+		return fmt.Errorf("cannot serve addresses via DHCP without an IPv4 subnet")
+	}
+
 	return nil
 }
 
@@ -82,23 +145,32 @@ func (opts *CreateOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
-	addr, err := netlink.ParseAddr(opts.Network)
-	if err != nil {
+	spec := networkapi.NetworkSpec{
+		NAT:  opts.NAT,
+		DHCP: opts.DHCP,
+	}
+
+	if err := spec.SetSubnets(opts.subnets...); err != nil {
 		return err
 	}
 
 	if _, err := controller.Create(ctx, &networkapi.Network{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: args[0],
-		},
-		Spec: networkapi.NetworkSpec{
-			Gateway: addr.IP.String(),
-			Netmask: net.IP(addr.Mask).String(),
+			Name:   args[0],
+			Labels: opts.labels,
 		},
+		Spec: spec,
 	}); err != nil {
 		return err
 	}
 
+	// The bridge is already up once it has been created.
+	if opts.DHCP {
+		if err := daemon.Spawn(ctx, opts.driver, args[0]); err != nil {
+			return err
+		}
+	}
+
 	fmt.Fprintln(iostreams.G(ctx).Out, args[0])
 
 	return nil
@@ -15,6 +15,7 @@ import (
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/iostreams"
 	"kraftkit.sh/machine/network"
+	"kraftkit.sh/machine/network/daemon"
 )
 
 type DownOptions struct {
@@ -72,6 +73,10 @@ func (opts *DownOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	if err := daemon.Kill(ctx, network.Name); err != nil {
+		return err
+	}
+
 	fmt.Fprintln(iostreams.G(ctx).Out, network.Name)
 
 	return nil
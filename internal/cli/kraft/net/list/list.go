@@ -7,9 +7,10 @@ package list
 import (
 	"context"
 	"fmt"
-	"net"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
 
 	networkapi "kraftkit.sh/api/network/v1alpha1"
 	"kraftkit.sh/cmdfactory"
@@ -71,21 +72,19 @@ func (opts *ListOptions) Run(ctx context.Context, _ []string) error {
 		network string
 		driver  string
 		status  networkapi.NetworkState
+		labels  string
 	}
 
 	var items []netTable
 
 	for _, network := range networks.Items {
-		addr := &net.IPNet{
-			IP:   net.ParseIP(network.Spec.Gateway),
-			Mask: net.IPMask(net.ParseIP(network.Spec.Netmask)),
-		}
 		items = append(items, netTable{
 			id:      string(network.UID),
 			name:    network.Name,
-			network: addr.String(),
+			network: strings.Join(network.Spec.Subnets, ","),
 			driver:  opts.driver,
 			status:  network.Status.State,
+			labels:  labels.FormatLabels(network.Labels),
 		})
 
 	}
@@ -115,6 +114,9 @@ func (opts *ListOptions) Run(ctx context.Context, _ []string) error {
 	table.AddField("NETWORK", cs.Bold)
 	table.AddField("DRIVER", cs.Bold)
 	table.AddField("STATUS", cs.Bold)
+	if opts.Long {
+		table.AddField("LABELS", cs.Bold)
+	}
 	table.EndRow()
 
 	for _, item := range items {
@@ -125,6 +127,9 @@ func (opts *ListOptions) Run(ctx context.Context, _ []string) error {
 		table.AddField(item.network, nil)
 		table.AddField(item.driver, nil)
 		table.AddField(item.status.String(), nil)
+		if opts.Long {
+			table.AddField(item.labels, nil)
+		}
 		table.EndRow()
 	}
 
@@ -12,11 +12,14 @@ import (
 	"github.com/spf13/pflag"
 
 	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/cli/kraft/net/connect"
 	"kraftkit.sh/internal/cli/kraft/net/create"
 	"kraftkit.sh/internal/cli/kraft/net/down"
 	"kraftkit.sh/internal/cli/kraft/net/inspect"
 	"kraftkit.sh/internal/cli/kraft/net/list"
+	"kraftkit.sh/internal/cli/kraft/net/policy"
 	"kraftkit.sh/internal/cli/kraft/net/remove"
+	"kraftkit.sh/internal/cli/kraft/net/serve"
 	"kraftkit.sh/internal/cli/kraft/net/up"
 	"kraftkit.sh/internal/set"
 	"kraftkit.sh/machine/network"
@@ -40,11 +43,14 @@ func NewCmd() *cobra.Command {
 		panic(err)
 	}
 
+	cmd.AddCommand(connect.NewCmd())
 	cmd.AddCommand(create.NewCmd())
 	cmd.AddCommand(down.NewCmd())
 	cmd.AddCommand(inspect.NewCmd())
 	cmd.AddCommand(list.NewCmd())
+	cmd.AddCommand(policy.NewCmd())
 	cmd.AddCommand(remove.NewCmd())
+	cmd.AddCommand(serve.NewCmd())
 	cmd.AddCommand(up.NewCmd())
 
 	return cmd
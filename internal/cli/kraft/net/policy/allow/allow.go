@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package allow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/machine/network"
+)
+
+type AllowOptions struct {
+	CIDRs    []string `long:"cidr" usage:"Allow traffic from (or with --egress to) the provided addresses in CIDR notation"`
+	driver   string
+	Egress   bool     `long:"egress" usage:"Allow traffic which is sent by the machines instead of traffic which they receive"`
+	Machines string   `long:"machines" short:"m" usage:"Select the machines which the rule applies to by label, e.g. tenant=acme"`
+	Peers    string   `long:"peers" usage:"Allow traffic from (or with --egress to) the machines on the network with the provided labels"`
+	Ports    []string `long:"port" usage:"Allow traffic to the provided port or range of ports in the format FROM-TO"`
+	Protocol string   `long:"protocol" short:"p" usage:"Allow traffic of the provided protocol (tcp, udp or icmp)"`
+	rule     networkapi.NetworkPolicyRule
+}
+
+// Allow adds a rule to the policy of a local machine network.
+func Allow(ctx context.Context, opts *AllowOptions, args ...string) error {
+	if opts == nil {
+		opts = &AllowOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&AllowOptions{}, cobra.Command{
+		Short: "Allow traffic to or from the machines on a network",
+		Use:   "allow [FLAGS] NETWORK",
+		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Add a rule to the policy of a machine network which allows traffic to or
+			from the machines which it applies to.
+
+			Traffic is allowed from or to any address unless --peers or --cidr are
+			provided, and of any protocol and port unless --protocol or --port are
+			provided.  Adding a rule isolates the machines which it applies to in
+			its direction.`),
+		Example: heredoc.Doc(`
+			# Allow machines labelled tenant=acme to receive traffic from each other
+			$ kraft net policy allow --machines tenant=acme --peers tenant=acme kraft0
+
+			# Allow all machines to send DNS queries to a specific resolver
+			$ kraft net policy allow --egress --cidr 10.0.0.53/32 --port 53 kraft0`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *AllowOptions) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+
+	opts.rule = networkapi.NetworkPolicyRule{
+		Direction: networkapi.NetworkPolicyDirectionIngress,
+		Machines:  opts.Machines,
+		Peers:     opts.Peers,
+		CIDRs:     opts.CIDRs,
+		Protocol:  opts.Protocol,
+		Ports:     opts.Ports,
+	}
+
+	if opts.Egress {
+		opts.rule.Direction = networkapi.NetworkPolicyDirectionEgress
+	}
+
+	return opts.rule.Validate()
+}
+
+func (opts *AllowOptions) Run(ctx context.Context, args []string) error {
+	var err error
+
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %v (contributions welcome!)", opts.driver)
+	}
+
+	controller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	found, err := controller.Get(ctx, &networkapi.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if found.Spec.Policy == nil {
+		found.Spec.Policy = &networkapi.NetworkPolicy{}
+	}
+
+	found.Spec.Policy.Rules = append(found.Spec.Policy.Rules, opts.rule)
+
+	if _, err := controller.Update(ctx, found); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(iostreams.G(ctx).Out, len(found.Spec.Policy.Rules)-1)
+
+	return nil
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package policy
+
+import (
+	"context"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/cli/kraft/net/policy/allow"
+	"kraftkit.sh/internal/cli/kraft/net/policy/remove"
+	"kraftkit.sh/internal/cli/kraft/net/policy/setdefault"
+	"kraftkit.sh/internal/cli/kraft/net/policy/show"
+)
+
+type PolicyOptions struct{}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&PolicyOptions{}, cobra.Command{
+		Short:  "Manage the policy of a machine network",
+		Use:    "policy SUBCOMMAND",
+		Hidden: true,
+		Long: heredoc.Doc(`
+			Manage the policy of a machine network.
+
+			By default, the machines on a network can communicate freely with each
+			other, the host and, unless NAT is disabled, hosts beyond it.  A policy
+			restricts this traffic by means of rules which allow traffic that is
+			received (ingress) or sent (egress) by the machines which they apply to.
+
+			A machine is isolated in a direction if the default of the policy is to
+			deny traffic or if any rule of that direction applies to it.  Only the
+			traffic which is allowed by the rules that apply to it, as well as replies
+			to such traffic, then pass in that direction.  Machines are selected by
+			the labels which they were given by 'kraft run --label'.
+
+			The policy is enforced with nftables, which requires the nft utility and
+			Linux 5.3 or newer.  Note that published ports are subject to the ingress
+			rules of isolated machines.`),
+		Example: heredoc.Doc(`
+			# Isolate all machines on the network kraft0 from each other and the host
+			$ kraft net policy default kraft0 deny
+
+			# Allow machines of the tenant acme to communicate with each other
+			$ kraft net policy allow --machines tenant=acme --peers tenant=acme kraft0
+			$ kraft net policy allow --egress --machines tenant=acme --peers tenant=acme kraft0
+
+			# Allow any traffic to port 80 of the machines labelled role=web
+			$ kraft net policy allow --machines role=web --protocol tcp --port 80 kraft0
+
+			# Allow all machines to reach the internet
+			$ kraft net policy allow --egress --cidr 0.0.0.0/0 kraft0
+
+			# Show the policy of the network kraft0
+			$ kraft net policy show kraft0
+
+			# Remove the rule with ID 2 from the policy of the network kraft0
+			$ kraft net policy rm kraft0 2`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.AddCommand(allow.NewCmd())
+	cmd.AddCommand(remove.NewCmd())
+	cmd.AddCommand(setdefault.NewCmd())
+	cmd.AddCommand(show.NewCmd())
+
+	return cmd
+}
+
+func (opts *PolicyOptions) Run(_ context.Context, _ []string) error {
+	return pflag.ErrHelp
+}
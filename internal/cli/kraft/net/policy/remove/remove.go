@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package remove
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/machine/network"
+)
+
+type RemoveOptions struct {
+	All    bool `long:"all" usage:"Remove all rules and allow all traffic by default"`
+	driver string
+	ids    []int
+}
+
+// Remove rules from the policy of a local machine network.
+func Remove(ctx context.Context, opts *RemoveOptions, args ...string) error {
+	if opts == nil {
+		opts = &RemoveOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&RemoveOptions{}, cobra.Command{
+		Short:   "Remove rules from the policy of a network",
+		Use:     "rm [FLAGS] NETWORK [ID...]",
+		Aliases: []string{"remove", "delete", "del"},
+		Args:    cobra.MinimumNArgs(1),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *RemoveOptions) Pre(cmd *cobra.Command, args []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+
+	if opts.All && len(args) > 1 {
+		return fmt.Errorf("cannot remove specific rules with --all")
+	} else if !opts.All && len(args) < 2 {
+		return fmt.Errorf("either rule IDs or --all must be provided")
+	}
+
+	for _, arg := range args[1:] {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id < 0 {
+			return fmt.Errorf("invalid rule ID: %s", arg)
+		}
+
+		opts.ids = append(opts.ids, id)
+	}
+
+	return nil
+}
+
+func (opts *RemoveOptions) Run(ctx context.Context, args []string) error {
+	var err error
+
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %v (contributions welcome!)", opts.driver)
+	}
+
+	controller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	found, err := controller.Get(ctx, &networkapi.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if found.Spec.Policy == nil {
+		found.Spec.Policy = &networkapi.NetworkPolicy{}
+	}
+
+	policy := found.Spec.Policy
+
+	if opts.All {
+		policy.DefaultDeny = false
+		policy.Rules = nil
+	}
+
+	// Remove the rules from the last to the first such that the IDs of the
+	// remaining ones to remove do not change.
+	sort.Sort(sort.Reverse(sort.IntSlice(opts.ids)))
+
+	for i, id := range opts.ids {
+		if id >= len(policy.Rules) {
+			return fmt.Errorf("network %s has no rule with ID %d", found.Name, id)
+		} else if i > 0 && id == opts.ids[i-1] {
+			continue
+		}
+
+		policy.Rules = append(policy.Rules[:id], policy.Rules[id+1:]...)
+	}
+
+	_, err = controller.Update(ctx, found)
+	return err
+}
@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package setdefault
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/machine/network"
+)
+
+type SetDefaultOptions struct {
+	driver string
+	deny   bool
+}
+
+// SetDefault sets whether the policy of a local machine network denies traffic
+// by default.
+func SetDefault(ctx context.Context, opts *SetDefaultOptions, args ...string) error {
+	if opts == nil {
+		opts = &SetDefaultOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&SetDefaultOptions{}, cobra.Command{
+		Short:     "Set whether the policy of a network denies traffic by default",
+		Use:       "default NETWORK allow|deny",
+		Args:      cobra.ExactArgs(2),
+		ValidArgs: []string{"allow", "deny"},
+		Long: heredoc.Doc(`
+			Set whether the policy of a machine network denies traffic by default.
+
+			With deny, all machines on the network are isolated, such that they can
+			only send and receive the traffic which is allowed by the rules of the
+			policy, apart from obtaining an address and resolving names via the
+			daemon of the network.  With allow, which is the default, only machines
+			which rules apply to are isolated.`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *SetDefaultOptions) Pre(cmd *cobra.Command, args []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+
+	switch args[1] {
+	case "allow":
+		opts.deny = false
+	case "deny":
+		opts.deny = true
+	default:
+		return fmt.Errorf("invalid default: %s (choice of allow or deny)", args[1])
+	}
+
+	return nil
+}
+
+func (opts *SetDefaultOptions) Run(ctx context.Context, args []string) error {
+	var err error
+
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %v (contributions welcome!)", opts.driver)
+	}
+
+	controller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	found, err := controller.Get(ctx, &networkapi.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if found.Spec.Policy == nil {
+		found.Spec.Policy = &networkapi.NetworkPolicy{}
+	}
+
+	found.Spec.Policy.DefaultDeny = opts.deny
+
+	_, err = controller.Update(ctx, found)
+	return err
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package show
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/tableprinter"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/machine/network"
+	"kraftkit.sh/machine/network/nftables"
+)
+
+type ShowOptions struct {
+	driver string
+	Nft    bool   `long:"nft" usage:"Show the nftables ruleset which enforces the policy instead"`
+	Output string `long:"output" short:"o" usage:"Set output format" default:"table"`
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&ShowOptions{}, cobra.Command{
+		Short:   "Show the policy of a network",
+		Use:     "show [FLAGS] NETWORK",
+		Aliases: []string{"ls", "list"},
+		Args:    cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Show the rules of the policy of a machine network and their IDs.
+
+			The last row shows the default of the policy.  Unless it is deny, it only
+			applies to the machines which are not isolated by any rule.`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *ShowOptions) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+	return nil
+}
+
+func (opts *ShowOptions) Run(ctx context.Context, args []string) error {
+	var err error
+
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %v (contributions welcome!)", opts.driver)
+	}
+
+	controller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	found, err := controller.Get(ctx, &networkapi.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	policy := found.Spec.Policy
+	if policy == nil {
+		policy = &networkapi.NetworkPolicy{}
+	}
+
+	if opts.Nft {
+		if !policy.DefaultDeny && len(policy.Rules) == 0 {
+			return fmt.Errorf("network %s has no policy to enforce", found.Name)
+		}
+
+		fmt.Fprint(iostreams.G(ctx).Out, nftables.Ruleset(found))
+		return nil
+	}
+
+	cs := iostreams.G(ctx).ColorScheme()
+
+	table, err := tableprinter.NewTablePrinter(ctx,
+		tableprinter.WithMaxWidth(iostreams.G(ctx).TerminalWidth()),
+		tableprinter.WithOutputFormatFromString(opts.Output),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Header row
+	table.AddField("ID", cs.Bold)
+	table.AddField("DIRECTION", cs.Bold)
+	table.AddField("ACTION", cs.Bold)
+	table.AddField("MACHINES", cs.Bold)
+	table.AddField("PEERS", cs.Bold)
+	table.AddField("PROTOCOL", cs.Bold)
+	table.AddField("PORTS", cs.Bold)
+	table.EndRow()
+
+	for i, rule := range policy.Rules {
+		peers := rule.CIDRs
+		if rule.Peers != "" {
+			peers = append([]string{rule.Peers}, peers...)
+		}
+
+		table.AddField(strconv.Itoa(i), nil)
+		table.AddField(rule.Direction.String(), nil)
+		table.AddField("allow", nil)
+		table.AddField(orAny(rule.Machines), nil)
+		table.AddField(orAny(strings.Join(peers, " ")), nil)
+		table.AddField(orAny(rule.Protocol), nil)
+		table.AddField(orAny(strings.Join(rule.Ports, ",")), nil)
+		table.EndRow()
+	}
+
+	// The default applies to any traffic which is not allowed by a rule.
+	action := "allow"
+	if policy.DefaultDeny {
+		action = "deny"
+	}
+
+	table.AddField("-", nil)
+	table.AddField("any", nil)
+	table.AddField(action, nil)
+	table.AddField("*", nil)
+	table.AddField("*", nil)
+	table.AddField("*", nil)
+	table.AddField("*", nil)
+	table.EndRow()
+
+	return table.Render(iostreams.G(ctx).Out)
+}
+
+// orAny returns the provided value or a wildcard if it is empty.
+func orAny(value string) string {
+	if value == "" {
+		return "*"
+	}
+
+	return value
+}
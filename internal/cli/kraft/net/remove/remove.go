@@ -7,6 +7,7 @@ package remove
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -15,6 +16,7 @@ import (
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/iostreams"
 	"kraftkit.sh/machine/network"
+	"kraftkit.sh/machine/network/daemon"
 )
 
 type RemoveOptions struct {
@@ -73,6 +75,14 @@ func (opts *RemoveOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	if err := daemon.Kill(ctx, args[0]); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(daemon.StateDir(ctx, args[0])); err != nil {
+		return fmt.Errorf("could not remove state of network daemon: %v", err)
+	}
+
 	fmt.Fprintln(iostreams.G(ctx).Out, args[0])
 
 	return nil
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package serve
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkapi "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network"
+	"kraftkit.sh/machine/network/daemon"
+)
+
+// refreshInterval is how often the network is retrieved again, such that the
+// names and addresses of newly attached machines are known.
+const refreshInterval = 2 * time.Second
+
+type ServeOptions struct {
+	Domain    string        `long:"domain" usage:"Set the domain below which the names of machines are resolved" default:"kraft"`
+	LeaseTime time.Duration `long:"lease-time" usage:"Set how long addresses are leased to machines (default 1h)"`
+	Upstream  []string      `long:"upstream" usage:"Forward other DNS queries to the provided HOST:PORT instead of the name servers of the host"`
+	driver    string
+}
+
+// Serve DHCP and DNS to the machines on a local machine network.
+func Serve(ctx context.Context, opts *ServeOptions, args ...string) error {
+	if opts == nil {
+		opts = &ServeOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&ServeOptions{}, cobra.Command{
+		Short:  "Serve DHCP and DNS to the machines on a network",
+		Use:    "serve [FLAGS] NETWORK",
+		Args:   cobra.ExactArgs(1),
+		Hidden: true,
+		Long: heredoc.Doc(`
+			Serve DHCP and DNS to the machines on a network in the foreground.
+
+			This command is started in the background by 'kraft net up' for networks
+			which have been created with --dhcp.`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "net",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (opts *ServeOptions) Pre(cmd *cobra.Command, _ []string) error {
+	opts.driver = cmd.Flag("driver").Value.String()
+	return nil
+}
+
+func (opts *ServeOptions) Run(ctx context.Context, args []string) error {
+	strategy, ok := network.Strategies()[opts.driver]
+	if !ok {
+		return fmt.Errorf("unsupported network driver strategy: %v (contributions welcome!)", opts.driver)
+	}
+
+	controller, err := strategy.NewNetworkV1alpha1(ctx)
+	if err != nil {
+		return err
+	}
+
+	found, err := controller.Get(ctx, &networkapi.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: args[0],
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if pid := daemon.Running(ctx, found.Name); pid > 0 && pid != int32(os.Getpid()) {
+		return fmt.Errorf("network %s is already served by process %d", found.Name, pid)
+	}
+
+	dir := daemon.StateDir(ctx, found.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("could not create state directory: %v", err)
+	}
+
+	dopts := []daemon.DaemonOption{
+		daemon.WithPidFile(filepath.Join(dir, daemon.PidFileName)),
+		daemon.WithStateDir(dir),
+		daemon.WithDomain(opts.Domain),
+	}
+
+	if opts.LeaseTime > 0 {
+		dopts = append(dopts, daemon.WithLeaseTime(opts.LeaseTime))
+	}
+
+	if len(opts.Upstream) > 0 {
+		dopts = append(dopts, daemon.WithUpstream(opts.Upstream...))
+	}
+
+	d, err := daemon.New(found, dopts...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	go func() {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := controller.Get(ctx, &networkapi.Network{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: found.Name,
+				},
+			})
+			if err != nil {
+				log.G(ctx).Debugf("could not refresh network %s: %v", found.Name, err)
+				continue
+			}
+
+			if err := d.SetNetwork(current); err != nil {
+				log.G(ctx).Warnf("could not refresh network %s: %v", found.Name, err)
+			}
+		}
+	}()
+
+	return d.Serve(ctx)
+}
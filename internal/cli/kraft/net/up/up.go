@@ -15,6 +15,7 @@ import (
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/iostreams"
 	"kraftkit.sh/machine/network"
+	"kraftkit.sh/machine/network/daemon"
 )
 
 type UpOptions struct {
@@ -72,6 +73,12 @@ func (opts *UpOptions) Run(ctx context.Context, args []string) error {
 		return err
 	}
 
+	if network.Spec.DHCP {
+		if err := daemon.Spawn(ctx, opts.driver, network.Name); err != nil {
+			return err
+		}
+	}
+
 	fmt.Fprintln(iostreams.G(ctx).Out, network.Name)
 
 	return nil
@@ -16,14 +16,18 @@ import (
 	"kraftkit.sh/log"
 	mplatform "kraftkit.sh/machine/platform"
 
+	"github.com/MakeNowJust/heredoc"
 	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type PsOptions struct {
-	Architecture string `long:"arch" short:"m" usage:"Filter the list by architecture"`
-	Long         bool   `long:"long" short:"l" usage:"Show more information"`
+	Architecture string   `long:"arch" short:"m" usage:"Filter the list by architecture"`
+	Filter       []string `long:"filter" short:"f" usage:"Filter the list by label=KEY[=VALUE], name=NAME, id=UID, state=STATE or plat=PLATFORM"`
+	Long         bool     `long:"long" short:"l" usage:"Show more information"`
 	platform     string
+	filter       *machineapi.MachineFilter
 	Quiet        bool   `long:"quiet" short:"q" usage:"Only display machine IDs"`
 	ShowAll      bool   `long:"all" short:"a" usage:"Show all machines (default shows just running)"`
 	Output       string `long:"output" short:"o" usage:"Set output format" default:"table"`
@@ -39,6 +43,12 @@ func NewCmd() *cobra.Command {
 		Use:   "ps [FLAGS]",
 		Args:  cobra.MaximumNArgs(0),
 		Long:  "List running unikernels",
+		Example: heredoc.Doc(`
+			# List the running unikernels which were labelled by a job
+			$ kraft ps --filter label=job=1234,state=running
+
+			# List the unikernels which are run by QEMU
+			$ kraft ps --filter plat=qemu`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "run",
 		},
@@ -61,6 +71,13 @@ func NewCmd() *cobra.Command {
 }
 
 func (opts *PsOptions) Pre(cmd *cobra.Command, _ []string) error {
+	var err error
+
+	opts.filter, err = machineapi.ParseMachineFilter(opts.Filter)
+	if err != nil {
+		return err
+	}
+
 	opts.platform = cmd.Flag("plat").Value.String()
 	return nil
 }
@@ -75,11 +92,13 @@ func (opts *PsOptions) Run(ctx context.Context, _ []string) error {
 		args    string
 		created string
 		status  machineapi.MachineState
+		health  machineapi.MachineHealthStatus
 		mem     string
 		ports   string
 		arch    string
 		plat    string
 		ips     []string
+		labels  string
 	}
 
 	var items []psTable
@@ -120,23 +139,33 @@ func (opts *PsOptions) Run(ctx context.Context, _ []string) error {
 	}
 
 	for _, machine := range machines.Items {
+		if opts.filter != nil && !opts.filter.Matches(&machine) {
+			continue
+		}
+
 		entry := psTable{
 			id:      string(machine.UID),
 			name:    machine.Name,
 			args:    strings.Join(machine.Spec.ApplicationArgs, " "),
 			kernel:  machine.Spec.Kernel,
 			status:  machine.Status.State,
+			health:  machine.Status.Health.Status,
 			mem:     fmt.Sprintf("%dMiB", machine.Spec.Resources.Requests.Memory().Value()/MemoryMiB),
 			created: humanize.Time(machine.ObjectMeta.CreationTimestamp.Time),
 			ports:   machine.Spec.Ports.String(),
 			arch:    machine.Spec.Architecture,
 			plat:    machine.Spec.Platform,
 			ips:     []string{},
+			labels:  labels.FormatLabels(machine.Labels),
 		}
 
 		for _, net := range machine.Spec.Networks {
 			for _, iface := range net.Interfaces {
-				entry.ips = append(entry.ips, iface.Spec.IP)
+				for _, ip := range []string{iface.Spec.IP, iface.Spec.IPv6} {
+					if ip != "" {
+						entry.ips = append(entry.ips, ip)
+					}
+				}
 			}
 		}
 
@@ -174,6 +203,7 @@ func (opts *PsOptions) Run(ctx context.Context, _ []string) error {
 		table.AddField("PORTS", cs.Bold)
 		table.AddField("IP", cs.Bold)
 		table.AddField("ARCH", cs.Bold)
+		table.AddField("LABELS", cs.Bold)
 	}
 	table.AddField("PLAT", cs.Bold)
 	table.EndRow()
@@ -186,12 +216,17 @@ func (opts *PsOptions) Run(ctx context.Context, _ []string) error {
 		table.AddField(item.kernel, nil)
 		table.AddField(item.args, nil)
 		table.AddField(item.created, nil)
-		table.AddField(item.status.String(), nil)
+		if item.status == machineapi.MachineStateRunning && len(item.health) > 0 {
+			table.AddField(fmt.Sprintf("%s (%s)", item.status, item.health), nil)
+		} else {
+			table.AddField(item.status.String(), nil)
+		}
 		table.AddField(item.mem, nil)
 		if opts.Long {
 			table.AddField(item.ports, nil)
 			table.AddField(strings.Join(item.ips, ","), nil)
 			table.AddField(item.arch, nil)
+			table.AddField(item.labels, nil)
 			table.AddField(item.plat, nil)
 		} else {
 			table.AddField(fmt.Sprintf("%s/%s", item.plat, item.arch), nil)
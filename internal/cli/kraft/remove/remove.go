@@ -6,7 +6,9 @@ package remove
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -22,7 +24,10 @@ import (
 )
 
 type RemoveOptions struct {
-	All      bool `long:"all" usage:"Remove all machines"`
+	All      bool     `long:"all" usage:"Remove all machines"`
+	Filter   []string `long:"filter" usage:"Remove the machines selected by label=KEY[=VALUE], name=NAME, id=UID, state=STATE or plat=PLATFORM"`
+	Force    bool     `long:"force" short:"f" usage:"Stop running machines before removing them"`
+	Time     int      `long:"time" short:"t" usage:"Seconds to wait for a running unikernel to shut down gracefully before terminating it when using --force" default:"10"`
 	platform string
 }
 
@@ -42,7 +47,22 @@ func NewCmd() *cobra.Command {
 		Args:    cobra.MinimumNArgs(0),
 		Aliases: []string{"remove"},
 		Long: heredoc.Doc(`
-			Remove one or more running unikernels`),
+			Remove one or more running unikernels.
+
+			Machines which are still running or paused are only removed when --force
+			is provided, in which case they are first requested to shut down
+			gracefully before their process is terminated.  Note that, unlike earlier
+			versions, running machines are no longer stopped implicitly: any machine
+			which could not be removed is reported and causes the command to fail.`),
+		Example: heredoc.Doc(`
+			# Remove a stopped unikernel
+			$ kraft rm my-machine
+
+			# Stop and remove a unikernel, allowing it 30 seconds to shut down
+			$ kraft rm --force --time 30 my-machine
+
+			# Stop and remove every unikernel which was labelled by a job
+			$ kraft rm --force --filter label=job=1234`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "run",
 		},
@@ -65,17 +85,28 @@ func NewCmd() *cobra.Command {
 }
 
 func (opts *RemoveOptions) Pre(cmd *cobra.Command, _ []string) error {
+	if opts.Time < 0 {
+		return fmt.Errorf("the timeout must not be negative")
+	}
+
+	if cmd.Flag("time").Changed && !opts.Force {
+		return fmt.Errorf("the timeout can only be set when using --force")
+	}
+
 	opts.platform = cmd.Flag("plat").Value.String()
 	return nil
 }
 
 func (opts *RemoveOptions) Run(ctx context.Context, args []string) error {
-	var err error
-
-	if len(args) == 0 && !opts.All {
+	if len(args) == 0 && !opts.All && len(opts.Filter) == 0 {
 		return fmt.Errorf("no machine(s) specified")
 	}
 
+	filter, err := machineapi.ParseMachineFilter(opts.Filter)
+	if err != nil {
+		return err
+	}
+
 	platform := mplatform.PlatformUnknown
 	var controller machineapi.MachineService
 
@@ -114,13 +145,20 @@ func (opts *RemoveOptions) Run(ctx context.Context, args []string) error {
 	var remove []machineapi.Machine
 
 	for _, machine := range machines.Items {
-		if len(args) == 0 && opts.All {
-			remove = append(remove, machine)
+		if !filter.Matches(&machine) {
 			continue
 		}
 
-		if args[0] == machine.Name || args[0] == string(machine.UID) {
+		if len(args) == 0 {
 			remove = append(remove, machine)
+			continue
+		}
+
+		for _, arg := range args {
+			if arg == machine.Name || arg == string(machine.UID) {
+				remove = append(remove, machine)
+				break
+			}
 		}
 	}
 
@@ -129,8 +167,19 @@ func (opts *RemoveOptions) Run(ctx context.Context, args []string) error {
 	}
 
 	netcontrollers := make(map[string]networkapi.NetworkService, 0)
+	timeout := time.Duration(opts.Time) * time.Second
+
+	var errs []error
 
 	for _, machine := range remove {
+		active := machine.Status.State == machineapi.MachineStateRunning ||
+			machine.Status.State == machineapi.MachineStatePaused
+
+		if active && !opts.Force {
+			errs = append(errs, fmt.Errorf("could not remove machine %s: machine is %s, stop it first or use --force", machine.Name, machine.Status.State))
+			continue
+		}
+
 		// First remove all the associated network interfaces.
 		for _, net := range machine.Spec.Networks {
 			netcontroller, ok := netcontrollers[net.Driver]
@@ -180,19 +229,24 @@ func (opts *RemoveOptions) Run(ctx context.Context, args []string) error {
 		}
 
 		// Stop the machine before deleting it.
-		if machine.Status.State == machineapi.MachineStateRunning {
-			if _, err := controller.Stop(ctx, &machine); err != nil {
+		if active {
+			machine.Spec.StopTimeout = &timeout
+
+			stopped, err := controller.Stop(ctx, &machine)
+			if err != nil {
 				log.G(ctx).Errorf("could not stop machine %s: %v", machine.Name, err)
+			} else if len(stopped.Status.StopStage) > 0 {
+				log.G(ctx).Infof("machine %s stopped by %s", machine.Name, stopped.Status.StopStage)
 			}
 		}
 
 		// Now delete the machine.
 		if _, err := controller.Delete(ctx, &machine); err != nil {
-			log.G(ctx).Errorf("could not delete machine %s: %v", machine.Name, err)
+			errs = append(errs, fmt.Errorf("could not delete machine %s: %w", machine.Name, err))
 		} else {
 			fmt.Fprintln(iostreams.G(ctx).Out, machine.Name)
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
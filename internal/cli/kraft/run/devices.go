@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package run
+
+import (
+	"context"
+	"fmt"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/kconfig"
+	"kraftkit.sh/log"
+	"kraftkit.sh/unikraft/target"
+)
+
+// applyTargetDevices populates the devices of the machine from the project
+// target, unless they have been set via the command-line.
+func applyTargetDevices(t target.Target, machine *machineapi.Machine) error {
+	tc, ok := t.(*target.TargetConfig)
+	if !ok {
+		return nil
+	}
+
+	if len(machine.Spec.Transport) == 0 {
+		transport, err := machineapi.ParseMachineTransport(tc.Transport())
+		if err != nil {
+			return fmt.Errorf("invalid target %s: %w", t.Name(), err)
+		}
+
+		machine.Spec.Transport = transport
+	}
+
+	if len(machine.Spec.NetworkModel) == 0 {
+		nic, err := machineapi.ParseMachineNetworkModel(tc.NIC())
+		if err != nil {
+			return fmt.Errorf("invalid target %s: %w", t.Name(), err)
+		}
+
+		machine.Spec.NetworkModel = nic
+	}
+
+	if tc.MicroVM() {
+		machine.Spec.MicroVM = true
+	}
+
+	return nil
+}
+
+// kconfigEnabled returns whether the kernel to run was built with the provided
+// KConfig option.
+func (opts *RunOptions) kconfigEnabled(key string) bool {
+	value, ok := opts.kconfig.Get(key)
+	return ok && value.Value == kconfig.Yes
+}
+
+// selectTransport validates the transport of the virtio devices of the machine
+// against the KConfig of its kernel and selects MMIO if the kernel does not
+// support PCI.
+func (opts *RunOptions) selectTransport(ctx context.Context, machine *machineapi.Machine) error {
+	pci := opts.kconfigEnabled("CONFIG_VIRTIO_PCI")
+	mmio := opts.kconfigEnabled("CONFIG_VIRTIO_MMIO")
+
+	// The KConfig of the kernel is either unknown, e.g. when running a kernel
+	// binary, or the kernel does not use virtio.
+	if !pci && !mmio {
+		return nil
+	}
+
+	if len(machine.Spec.Transport) == 0 && !pci {
+		log.G(ctx).Debug("kernel only supports virtio-mmio: using MMIO transport")
+		machine.Spec.Transport = machineapi.MachineTransportMMIO
+	}
+
+	transport := machine.Spec.Transport
+	if len(transport) == 0 {
+		transport = machineapi.MachineTransportPCI
+		if machine.Spec.MicroVM {
+			transport = machineapi.MachineTransportMMIO
+		}
+	}
+
+	if transport == machineapi.MachineTransportPCI && !pci {
+		return fmt.Errorf("cannot use PCI transport: kernel was built without CONFIG_VIRTIO_PCI")
+	} else if transport == machineapi.MachineTransportMMIO && !mmio {
+		return fmt.Errorf("cannot use MMIO transport: kernel was built without CONFIG_VIRTIO_MMIO")
+	}
+
+	return nil
+}
+
+// selectNetdevParams selects the legacy netdev.ipv4_* parameters to statically
+// configure the network interface of the machine if its kernel was built with
+// lib/uknetdev but without support for the netdev.ip parameter.  Kernels whose
+// KConfig is unknown are assumed to support netdev.ip.
+func (opts *RunOptions) selectNetdevParams(ctx context.Context, machine *machineapi.Machine) {
+	if opts.kconfigEnabled("CONFIG_LIBUKNETDEV") && !opts.kconfigEnabled("CONFIG_LIBUKNETDEV_EINFO_LIBPARAM") {
+		log.G(ctx).Debug("kernel does not support netdev.ip: using legacy netdev parameters")
+		machine.Spec.LegacyNetdevParams = true
+	}
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package run
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/exec"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+)
+
+// DefaultGDBPort is the port of the GDB server when --gdb is provided without
+// a value.
+const DefaultGDBPort = 1234
+
+// gdbArgs returns the arguments to GDB which connect it to the GDB server of
+// the machine and load the symbols of its kernel.
+func (opts *RunOptions) gdbArgs(machine *machineapi.Machine) []string {
+	kernel := opts.kernelDbg
+	if _, err := os.Stat(kernel); kernel == "" || err != nil {
+		kernel = machine.Status.KernelPath
+	}
+
+	return []string{
+		"-ex", fmt.Sprintf("target remote localhost:%d", machine.Spec.GDBPort),
+		kernel,
+	}
+}
+
+// debug either prints the command with which GDB can be attached to the
+// paused machine or, with --gdb-attach, runs it in the foreground and stops
+// the machine once GDB exits.
+func (opts *RunOptions) debug(ctx context.Context, machine *machineapi.Machine) error {
+	args := opts.gdbArgs(machine)
+
+	if !opts.GDBAttach {
+		fmt.Fprintf(iostreams.G(ctx).ErrOut,
+			"%s is paused, attach to it with:\n\n  gdb -ex \"%s\" %s\n\n",
+			machine.Name,
+			args[1],
+			args[2],
+		)
+
+		// Output the name of the instance such that it can be piped
+		fmt.Fprintf(iostreams.G(ctx).Out, "%s\n", machine.Name)
+
+		return nil
+	}
+
+	process, err := exec.NewProcess("gdb", args,
+		exec.WithStdin(os.Stdin),
+		exec.WithStdout(os.Stdout),
+		exec.WithStderr(os.Stderr),
+	)
+	if err != nil {
+		return fmt.Errorf("could not prepare gdb: %v", err)
+	}
+
+	// GDB uses Ctrl+C to interrupt the unikernel, which must therefore no
+	// longer terminate kraft, such that the machine can be stopped once GDB
+	// exits.
+	signal.Ignore(os.Interrupt)
+
+	gdbErr := process.StartAndWait(ctx)
+
+	if _, err := opts.machineController.Stop(ctx, machine); err != nil {
+		log.G(ctx).Errorf("could not stop: %v", err)
+	}
+
+	if opts.Remove {
+		if _, err := opts.machineController.Delete(ctx, machine); err != nil {
+			log.G(ctx).Errorf("could not remove: %v", err)
+		}
+	}
+
+	if gdbErr != nil {
+		return fmt.Errorf("could not run gdb: %v", gdbErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package run
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/log"
+)
+
+// savedMachine returns the provided machine as it was last saved.
+func (opts *RunOptions) savedMachine(ctx context.Context, machine *machineapi.Machine) (*machineapi.Machine, error) {
+	machines, err := opts.machineController.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range machines.Items {
+		if machines.Items[i].UID == machine.UID {
+			return &machines.Items[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("machine not found")
+}
+
+// waitHealthy blocks until the supervisor has found the machine to be healthy.
+// The machine is left running if it is found to be unhealthy instead, such
+// that it can be inspected.
+func (opts *RunOptions) waitHealthy(ctx context.Context, machine *machineapi.Machine) error {
+	log.G(ctx).
+		WithField("check", machine.Spec.HealthCheck.String()).
+		Infof("waiting for %s to become healthy", machine.Name)
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		// The health is saved in the machine store by the supervisor, such that
+		// the machine is read from the store rather than from its platform.
+		current, err := opts.savedMachine(ctx, machine)
+		if err != nil {
+			return fmt.Errorf("could not get status of %s: %v", machine.Name, err)
+		}
+
+		switch current.Status.State {
+		case machineapi.MachineStateExited,
+			machineapi.MachineStateFailed,
+			machineapi.MachineStateErrored:
+			return fmt.Errorf("%s %s before becoming healthy", machine.Name, current.Status.State)
+		}
+
+		switch current.Status.Health.Status {
+		case machineapi.MachineHealthStatusHealthy:
+			return nil
+		case machineapi.MachineHealthStatusUnhealthy:
+			return fmt.Errorf("%s is unhealthy: %s", machine.Name, current.Status.Health.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +26,7 @@ import (
 	"kraftkit.sh/cmdfactory"
 	"kraftkit.sh/internal/set"
 	"kraftkit.sh/iostreams"
+	"kraftkit.sh/kconfig"
 	"kraftkit.sh/log"
 	"kraftkit.sh/machine/network"
 	mplatform "kraftkit.sh/machine/platform"
@@ -31,27 +34,62 @@ import (
 )
 
 type RunOptions struct {
-	Architecture  string   `long:"arch" short:"m" usage:"Set the architecture"`
-	Detach        bool     `long:"detach" short:"d" usage:"Run unikernel in background"`
-	DisableAccel  bool     `long:"disable-acceleration" short:"W" usage:"Disable acceleration of CPU (usually enables TCG)"`
-	InitRd        string   `long:"initrd" usage:"Use the specified initrd (readonly)" hidden:"true"`
-	IP            string   `long:"ip" usage:"Assign the provided IP address"`
-	KernelArgs    []string `long:"kernel-arg" short:"a" usage:"Set additional kernel arguments"`
-	Kraftfile     string   `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile"`
-	MacAddress    string   `long:"mac" usage:"Assign the provided MAC address"`
-	Memory        string   `long:"memory" short:"M" usage:"Assign memory to the unikernel (K/Ki, M/Mi, G/Gi)" default:"64Mi"`
-	Name          string   `long:"name" short:"n" usage:"Name of the instance"`
-	Network       string   `long:"network" usage:"Attach instance to the provided network in the format <driver>:<network>, e.g. bridge:kraft0"`
-	Ports         []string `long:"port" short:"p" usage:"Publish a machine's port(s) to the host" split:"false"`
-	Remove        bool     `long:"rm" usage:"Automatically remove the unikernel when it shutsdown"`
-	Rootfs        string   `long:"rootfs" usage:"Specify a path to use as root file system (can be volume or initramfs)"`
-	RunAs         string   `long:"as" usage:"Force a specific runner"`
-	Target        string   `long:"target" short:"t" usage:"Explicitly use the defined project target"`
-	Volumes       []string `long:"volume" short:"v" usage:"Bind a volume to the instance"`
-	WithKernelDbg bool     `long:"symbolic" usage:"Use the debuggable (symbolic) unikernel"`
+	Annotations    []string      `long:"annotation" usage:"Set an annotation on the unikernel in the format KEY=VALUE" split:"false"`
+	Architecture   string        `long:"arch" short:"m" usage:"Set the architecture"`
+	CPULimit       string        `long:"cpu-limit" usage:"Limit the host CPU time of the unikernel's VMM, e.g. 500m or 1.5"`
+	CPUModel       string        `long:"cpu-model" usage:"Set the model of the virtual CPU, e.g. cortex-a72"`
+	Detach         bool          `long:"detach" short:"d" usage:"Run unikernel in background"`
+	DisableAccel   bool          `long:"disable-acceleration" short:"W" usage:"Disable acceleration of CPU (usually enables TCG)"`
+	Firmware       string        `long:"firmware" usage:"Boot the provided firmware, e.g. a BIOS or SBI implementation, before the unikernel"`
+	FromSnapshot   string        `long:"from-snapshot" usage:"Restore the unikernel from the provided snapshot"`
+	GDB            string        `long:"gdb" usage:"Start the unikernel paused with a GDB server listening on the provided port of localhost (default 1234)"`
+	GDBAttach      bool          `long:"gdb-attach" usage:"Launch GDB in the foreground and attach it to the unikernel (implies --gdb)"`
+	GICVersion     string        `long:"gic-version" usage:"Set the version of the interrupt controller of ARM unikernels (2, 3, host, max)"`
+	HealthCheck    string        `long:"health-check" usage:"Check whether the application is serving via tcp:[HOST:]PORT, an http:// or https:// URL or log:REGEX"`
+	HealthInterval time.Duration `long:"health-interval" usage:"Time between two health checks (default 5s)"`
+	HealthRetries  int           `long:"health-retries" usage:"Consecutive failed health checks after which the unikernel is unhealthy (default 3)"`
+	HealthTimeout  time.Duration `long:"health-timeout" usage:"Time after which a single health check fails (default 3s)"`
+	InitRd         string        `long:"initrd" usage:"Use the specified initrd (readonly)" hidden:"true"`
+	IOWeight       int           `long:"io-weight" usage:"Relative share of block IO of the unikernel's VMM on the host (1-10000)"`
+	IP             string        `long:"ip" usage:"Assign the provided IP address"`
+	IPv6           string        `long:"ip6" usage:"Assign the provided IPv6 address"`
+	KernelArgs     []string      `long:"kernel-arg" short:"a" usage:"Set additional kernel arguments"`
+	Kraftfile      string        `long:"kraftfile" short:"K" usage:"Set an alternative path of the Kraftfile"`
+	Labels         []string      `long:"label" usage:"Set a label on the unikernel in the format KEY[=VALUE]" split:"false"`
+	MacAddress     string        `long:"mac" usage:"Assign the provided MAC address"`
+	Memory         string        `long:"memory" short:"M" usage:"Assign memory to the unikernel (K/Ki, M/Mi, G/Gi)" default:"64Mi"`
+	MemoryLimit    string        `long:"memory-limit" usage:"Limit the host memory of the unikernel's VMM, excluding its own overhead (K/Ki, M/Mi, G/Gi)"`
+	MicroVM        bool          `long:"microvm" usage:"Run the unikernel on a minimal virtual machine without a PCI bus"`
+	Name           string        `long:"name" short:"n" usage:"Name of the instance"`
+	Network        string        `long:"network" usage:"Attach instance to the provided network in the format <driver>:<network>, e.g. bridge:kraft0"`
+	NIC            string        `long:"nic" usage:"Set the model of the network devices (virtio, e1000, rtl8139, vmxnet3)"`
+	Ports          []string      `long:"port" short:"p" usage:"Publish a machine's port(s) to the host" split:"false"`
+	Remove         bool          `long:"rm" usage:"Automatically remove the unikernel when it shutsdown"`
+	Restart        string        `long:"restart" usage:"Restart policy to apply when the unikernel exits (no, on-failure[:N], always, unless-stopped)" default:"no"`
+	Rootfs         string        `long:"rootfs" usage:"Specify a path to use as root file system (can be volume or initramfs)"`
+	RunAs          string        `long:"as" usage:"Force a specific runner"`
+	Target         string        `long:"target" short:"t" usage:"Explicitly use the defined project target"`
+	TraceBoot      bool          `long:"trace-boot" usage:"Print how long each stage of booting the unikernel took and save it in its state directory"`
+	TraceReadyLog  string        `long:"trace-ready-log" usage:"Consider the unikernel ready once a line of its output matches the provided regular expression (implies --trace-boot)"`
+	TraceReadyPort string        `long:"trace-ready-port" usage:"Consider the unikernel ready once the provided [HOST:]PORT accepts TCP connections (implies --trace-boot)"`
+	Transport      string        `long:"transport" usage:"Set the transport of virtio devices (pci, mmio)"`
+	Volumes        []string      `long:"volume" short:"v" usage:"Bind a volume to the instance"`
+	WaitHealthy    bool          `long:"wait-healthy" usage:"Run the unikernel in the background and only return once it is healthy (requires --health-check)"`
+	WithKernelDbg  bool          `long:"symbolic" usage:"Use the debuggable (symbolic) unikernel"`
 
 	workdir           string
+	gdbPort           int
+	kernelDbg         string
+	kconfig           kconfig.KeyValueMap
+	healthCheck       *machineapi.MachineHealthCheck
+	labels            map[string]string
+	annotations       map[string]string
+	traceReadyLog     *regexp.Regexp
+	traceReadyAddr    string
+	transport         machineapi.MachineTransport
+	nic               machineapi.MachineNetworkModel
 	platform          mplatform.Platform
+	restartPolicy     machineapi.MachineRestartPolicy
 	networkDriver     string
 	networkName       string
 	networkController networkapi.NetworkService
@@ -84,6 +122,12 @@ func NewCmd() *cobra.Command {
 			Run a specific kernel binary:
 			$ kraft run --arch x86_64 --plat qemu path/to/kernel-x86_64-qemu
 
+			Run an arm64 kernel binary under emulation on an x86_64 host with a specific CPU model:
+			$ kraft run --arch arm64 --plat qemu --cpu-model cortex-a72 path/to/kernel-arm64-qemu
+
+			Run a kernel which only enables virtio-mmio on a QEMU microvm for fast boot:
+			$ kraft run --plat qemu --microvm path/to/kernel-x86_64-qemu
+
 			Run a specific kernel binary with 1000 megabytes of memory:
 			$ kraft run --arch x86_64 --plat qemu --memory 1G path/to/kernel-x86_64-qemu
 
@@ -96,6 +140,12 @@ func NewCmd() *cobra.Command {
 			Attach the unikernel to an existing network kraft0 backed by the bridge driver:
 			$ kraft run --network bridge:kraft0
 
+			Attach the unikernel to the network kraft0 and map port 8080 on the host to its port 80 on that network:
+			$ kraft run --network bridge:kraft0 -p 8080:80 unikraft.org/nginx:latest
+
+			Label a unikernel such that it can later be selected with --filter:
+			$ kraft run -d --label job=1234 --label suite=smoke unikraft.org/nginx:latest
+
 			Run a Linux userspace binary in POSIX-/binary-compatibility mode:
 			$ kraft run a.out
 
@@ -113,6 +163,24 @@ func NewCmd() *cobra.Command {
 
 			Customize the default content directory of the official Unikraft NGINX OCI-compatible unikernel and map port 8080 to localhost:
 			$ kraft run -v ./path/to/html:/nginx/html -p 8080:80 unikraft.org/nginx:latest
+
+			Restore a unikernel from a snapshot previously taken with kraft snapshot:
+			$ kraft run --from-snapshot path/to/snapshot
+
+			Run a unikernel in the background and restart it up to 3 times should it crash:
+			$ kraft run -d --restart on-failure:3 unikraft.org/nginx:latest
+
+			Start a unikernel paused with a GDB server listening on port 1234 of localhost:
+			$ kraft run --gdb --plat qemu path/to/project
+
+			Start a unikernel paused and debug it with GDB in the foreground:
+			$ kraft run --gdb-attach --plat qemu path/to/project
+
+			Run a unikernel in the background and return once it responds to HTTP requests:
+			$ kraft run -d --wait-healthy --health-check http://localhost:8080/ -p 8080:80 unikraft.org/nginx:latest
+
+			Measure how long a unikernel takes to boot until it accepts connections on port 8080:
+			$ kraft run --trace-ready-port 8080 -p 8080:80 unikraft.org/nginx:latest
 			`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "run",
@@ -131,6 +199,9 @@ func NewCmd() *cobra.Command {
 		"Set the platform virtual machine monitor driver.",
 	)
 
+	// Allow the port of the GDB server to be omitted, i.e. --gdb.
+	cmd.Flag("gdb").NoOptDefVal = strconv.Itoa(DefaultGDBPort)
+
 	return cmd
 }
 
@@ -140,8 +211,103 @@ func (opts *RunOptions) Pre(cmd *cobra.Command, _ []string) error {
 
 	opts.platform = mplatform.PlatformByName(opts.platform.String())
 
+	opts.labels, err = machineapi.ParseLabels(opts.Labels)
+	if err != nil {
+		return err
+	}
+
+	opts.annotations, err = machineapi.ParseAnnotations(opts.Annotations)
+	if err != nil {
+		return err
+	}
+
+	opts.restartPolicy, err = machineapi.ParseMachineRestartPolicy(opts.Restart)
+	if err != nil {
+		return err
+	} else if !opts.restartPolicy.IsNone() && opts.Remove {
+		return fmt.Errorf("cannot use --rm with a restart policy")
+	}
+
+	if opts.GDB != "" {
+		opts.gdbPort, err = strconv.Atoi(opts.GDB)
+		if err != nil || opts.gdbPort < 1 || opts.gdbPort > 65535 {
+			return fmt.Errorf("invalid GDB server port: %s", opts.GDB)
+		}
+	} else if opts.GDBAttach {
+		opts.gdbPort = DefaultGDBPort
+	}
+
+	if opts.gdbPort > 0 {
+		if opts.FromSnapshot != "" {
+			return fmt.Errorf("cannot debug a unikernel which is restored from a snapshot")
+		} else if !opts.restartPolicy.IsNone() {
+			return fmt.Errorf("cannot use a restart policy whilst debugging")
+		} else if opts.GDBAttach && opts.Detach {
+			return fmt.Errorf("cannot use --detach with --gdb-attach")
+		}
+	}
+
+	if opts.TraceReadyLog != "" {
+		opts.traceReadyLog, err = regexp.Compile(opts.TraceReadyLog)
+		if err != nil {
+			return fmt.Errorf("invalid --trace-ready-log expression: %v", err)
+		}
+
+		opts.TraceBoot = true
+	}
+
+	if opts.TraceReadyPort != "" {
+		opts.traceReadyAddr, err = parseReadyAddress(opts.TraceReadyPort)
+		if err != nil {
+			return fmt.Errorf("invalid --trace-ready-port: %v", err)
+		}
+
+		opts.TraceBoot = true
+	}
+
+	if opts.TraceBoot && opts.gdbPort > 0 {
+		return fmt.Errorf("cannot trace the boot of a unikernel whilst debugging")
+	}
+
+	if opts.HealthCheck != "" {
+		opts.healthCheck, err = machineapi.ParseMachineHealthCheck(opts.HealthCheck)
+		if err != nil {
+			return err
+		}
+
+		if opts.HealthInterval > 0 {
+			opts.healthCheck.Interval = opts.HealthInterval
+		}
+		if opts.HealthTimeout > 0 {
+			opts.healthCheck.Timeout = opts.HealthTimeout
+		}
+		if opts.HealthRetries > 0 {
+			opts.healthCheck.Retries = opts.HealthRetries
+		}
+	} else if opts.WaitHealthy {
+		return fmt.Errorf("cannot use --wait-healthy without --health-check")
+	}
+
+	if opts.WaitHealthy {
+		if opts.gdbPort > 0 {
+			return fmt.Errorf("cannot wait for a unikernel to become healthy whilst debugging")
+		}
+
+		opts.Detach = true
+	}
+
+	opts.transport, err = machineapi.ParseMachineTransport(opts.Transport)
+	if err != nil {
+		return err
+	}
+
+	opts.nic, err = machineapi.ParseMachineNetworkModel(opts.NIC)
+	if err != nil {
+		return err
+	}
+
 	// Discover the network controller strategy.
-	if opts.Network == "" && opts.IP != "" {
+	if opts.Network == "" && (opts.IP != "" || opts.IPv6 != "") {
 		return fmt.Errorf("cannot assign IP address without providing --network")
 	} else if opts.Network != "" && !strings.Contains(opts.Network, ":") {
 		return fmt.Errorf("specifying a network must be in the format <driver>:<network> e.g. --network=bridge:kraft0")
@@ -168,6 +334,16 @@ func (opts *RunOptions) Pre(cmd *cobra.Command, _ []string) error {
 	plat := cmd.Flag("plat").Value.String()
 	opts.platform = mplatform.PlatformUnknown
 
+	// A snapshot can only be restored with the platform it was taken with.
+	if opts.FromSnapshot != "" && (plat == "" || plat == "auto") {
+		manifest, err := machineapi.LoadMachineSnapshotManifest(opts.FromSnapshot)
+		if err != nil {
+			return err
+		}
+
+		plat = manifest.Snapshot.Platform
+	}
+
 	if plat == "" || plat == "auto" {
 		var mode mplatform.SystemMode
 		opts.platform, mode, err = mplatform.Detect(ctx)
@@ -248,91 +424,84 @@ func (opts *RunOptions) Run(ctx context.Context, args []string) error {
 			Resources: corev1.ResourceRequirements{
 				Requests: corev1.ResourceList{},
 			},
-			Emulation: opts.DisableAccel,
+			Emulation:    opts.DisableAccel,
+			CPUModel:     opts.CPUModel,
+			GICVersion:   opts.GICVersion,
+			Firmware:     opts.Firmware,
+			Transport:    opts.transport,
+			NetworkModel: opts.nic,
+			MicroVM:      opts.MicroVM,
 		},
 	}
 
-	var run runner
-	var errs []error
-	runners, err := runners()
-	if err != nil {
+	if opts.FromSnapshot != "" {
+		if err := opts.prepareFromSnapshot(ctx, machine); err != nil {
+			return err
+		}
+	} else if err := opts.prepare(ctx, machine, args...); err != nil {
+		return err
+	} else if err := opts.selectTransport(ctx, machine); err != nil {
 		return err
 	}
 
-	// Iterate through the list of built-in runners which sequentially tests and
-	// first test whether the --as flag has been set to force a specific runner or
-	// whether the current context matches the requirements for being run given
-	// its context.  The first to test positive is used to prepare the machine
-	// specification which is later passed to the controller.
-	for _, candidate := range runners {
-		if opts.RunAs != "" && candidate.String() != opts.RunAs {
-			continue
-		}
-
-		log.G(ctx).
-			WithField("runner", candidate.String()).
-			Trace("checking runnability")
-
-		capable, err := candidate.Runnable(ctx, opts, args...)
-		if capable && err == nil {
-			run = candidate
-			break
-		} else if err != nil {
-			errs = append(errs, err)
-			log.G(ctx).
-				WithField("runner", candidate.String()).
-				Debugf("cannot run because: %v", err)
-		}
-	}
-	if run == nil {
-		return fmt.Errorf("could not determine how to run provided input: %w", errors.Join(errs...))
+	if opts.FromSnapshot == "" {
+		opts.selectNetdevParams(ctx, machine)
 	}
 
-	log.G(ctx).WithField("runner", run.String()).Debug("using")
-
-	// Prepare the machine specification based on the compatible runner.
-	if err := run.Prepare(ctx, opts, machine, args...); err != nil {
-		return err
+	// The machine is managed by the selected platform even when its kernel has
+	// been built for another, compatible platform.
+	if opts.platform != mplatform.KernelPlatform(opts.platform) {
+		machine.Spec.Platform = opts.platform.String()
 	}
 
-	// Override with command-line flags
-	if len(opts.KernelArgs) > 0 {
-		machine.Spec.KernelArgs = opts.KernelArgs
+	// Labels and annotations are added to those of a machine which is restored
+	// from a snapshot.
+	for key, value := range opts.labels {
+		if machine.ObjectMeta.Labels == nil {
+			machine.ObjectMeta.Labels = make(map[string]string, len(opts.labels))
+		}
+		machine.ObjectMeta.Labels[key] = value
 	}
 
-	if len(opts.Memory) > 0 {
-		quantity, err := resource.ParseQuantity(opts.Memory)
-		if err != nil {
-			return err
+	for key, value := range opts.annotations {
+		if machine.ObjectMeta.Annotations == nil {
+			machine.ObjectMeta.Annotations = make(map[string]string, len(opts.annotations))
 		}
-
-		machine.Spec.Resources.Requests[corev1.ResourceMemory] = quantity
+		machine.ObjectMeta.Annotations[key] = value
 	}
 
-	if err := opts.parsePorts(ctx, machine); err != nil {
-		return err
-	}
+	machine.Spec.RestartPolicy = opts.restartPolicy
+	machine.Spec.GDBPort = opts.gdbPort
+	machine.Spec.TraceBoot = opts.TraceBoot
+	machine.Spec.HealthCheck = opts.healthCheck
 
-	if err := opts.parseNetworks(ctx, machine); err != nil {
-		return err
+	// The machine has yet to pass a health check.
+	if machine.Spec.HealthCheck != nil {
+		machine.Status.Health = machineapi.MachineHealth{
+			Status: machineapi.MachineHealthStatusStarting,
+		}
 	}
 
-	if err := opts.parseVolumes(ctx, machine); err != nil {
-		return err
-	}
+	// Stages of the boot are traced relative to when the machine was requested.
+	requested := time.Now()
 
-	if err := opts.assignName(ctx, machine); err != nil {
+	// Create the machine
+	machine, err = opts.machineController.Create(ctx, machine)
+	if err != nil {
 		return err
 	}
 
-	if err := opts.prepareRootfs(ctx, machine); err != nil {
-		return err
+	// The machine is not started since it remains paused until it is continued
+	// by the debugger.
+	if machine.Spec.GDBPort > 0 {
+		return opts.debug(ctx, machine)
 	}
 
-	// Create the machine
-	machine, err = opts.machineController.Create(ctx, machine)
-	if err != nil {
-		return err
+	if opts.FromSnapshot != "" {
+		machine, err = opts.machineController.Restore(ctx, machine)
+		if err != nil {
+			return err
+		}
 	}
 
 	var exitErr error
@@ -361,7 +530,12 @@ func (opts *RunOptions) Run(ctx context.Context, args []string) error {
 
 				// Wait on either channel
 				select {
-				case update := <-events:
+				case update, ok := <-events:
+					if !ok {
+						requestShutdown = true
+						continue
+					}
+
 					switch update.Status.State {
 					case machineapi.MachineStateErrored:
 						signals.RequestShutdown()
@@ -372,7 +546,12 @@ func (opts *RunOptions) Run(ctx context.Context, args []string) error {
 						requestShutdown = true
 					}
 
-				case err := <-errs:
+				case err, ok := <-errs:
+					if !ok {
+						requestShutdown = true
+						continue
+					}
+
 					log.G(ctx).Errorf("received event error: %v", err)
 					signals.RequestShutdown()
 					break loop
@@ -385,12 +564,33 @@ func (opts *RunOptions) Run(ctx context.Context, args []string) error {
 	}
 
 	// Start the machine
-	_, err = opts.machineController.Start(ctx, machine)
+	started, err := opts.machineController.Start(ctx, machine)
 	if err != nil {
 		signals.RequestShutdown()
 		return err
 	}
 
+	if !machine.Spec.RestartPolicy.IsNone() || machine.Spec.HealthCheck != nil {
+		if err := opts.ensureSupervisor(ctx); err != nil && opts.WaitHealthy {
+			return fmt.Errorf("could not supervise machine to check its health: %v", err)
+		} else if err != nil {
+			log.G(ctx).Warnf("machine will not be supervised: %v", err)
+		}
+	}
+
+	traced := make(chan struct{})
+	if opts.TraceBoot {
+		// The stages which were recorded by the platform are only known to the
+		// started machine.
+		trace := started.Status.BootTrace
+		go func() {
+			opts.traceBoot(ctx, machine, trace, requested)
+			close(traced)
+		}()
+	} else {
+		close(traced)
+	}
+
 	if !opts.Detach {
 		logs, errs, err := opts.machineController.Logs(ctx, machine)
 		if err != nil {
@@ -438,6 +638,15 @@ func (opts *RunOptions) Run(ctx context.Context, args []string) error {
 			}
 		}
 	} else {
+		// Only return once the machine has booted such that the trace is complete.
+		<-traced
+
+		if opts.WaitHealthy {
+			if err := opts.waitHealthy(ctx, machine); err != nil {
+				return err
+			}
+		}
+
 		// Output the name of the instance such that it can be piped
 		fmt.Fprintf(iostreams.G(ctx).Out, "%s\n", machine.Name)
 	}
@@ -476,3 +685,92 @@ func (opts *RunOptions) Run(ctx context.Context, args []string) error {
 
 	return exitErr
 }
+
+// prepare populates the machine specification via the first runner which is
+// capable of running the provided arguments and the command-line flags.
+func (opts *RunOptions) prepare(ctx context.Context, machine *machineapi.Machine, args ...string) error {
+	var run runner
+	var errs []error
+	runners, err := runners()
+	if err != nil {
+		return err
+	}
+
+	// Iterate through the list of built-in runners which sequentially tests and
+	// first test whether the --as flag has been set to force a specific runner or
+	// whether the current context matches the requirements for being run given
+	// its context.  The first to test positive is used to prepare the machine
+	// specification which is later passed to the controller.
+	for _, candidate := range runners {
+		if opts.RunAs != "" && candidate.String() != opts.RunAs {
+			continue
+		}
+
+		log.G(ctx).
+			WithField("runner", candidate.String()).
+			Trace("checking runnability")
+
+		capable, err := candidate.Runnable(ctx, opts, args...)
+		if capable && err == nil {
+			run = candidate
+			break
+		} else if err != nil {
+			errs = append(errs, err)
+			log.G(ctx).
+				WithField("runner", candidate.String()).
+				Debugf("cannot run because: %v", err)
+		}
+	}
+	if run == nil {
+		return fmt.Errorf("could not determine how to run provided input: %w", errors.Join(errs...))
+	}
+
+	log.G(ctx).WithField("runner", run.String()).Debug("using")
+
+	// Prepare the machine specification based on the compatible runner.
+	if err := run.Prepare(ctx, opts, machine, args...); err != nil {
+		return err
+	}
+
+	// Override with command-line flags
+	if len(opts.KernelArgs) > 0 {
+		machine.Spec.KernelArgs = opts.KernelArgs
+	}
+
+	if len(opts.Memory) > 0 {
+		quantity, err := resource.ParseQuantity(opts.Memory)
+		if err != nil {
+			return err
+		}
+
+		machine.Spec.Resources.Requests[corev1.ResourceMemory] = quantity
+	}
+
+	if err := opts.parseLimits(ctx, machine); err != nil {
+		return err
+	}
+
+	if err := opts.parsePorts(ctx, machine); err != nil {
+		return err
+	}
+
+	// The name is assigned before attaching the machine to a network such that
+	// its interface can be resolved by the name of the machine.
+	if err := opts.assignName(ctx, machine); err != nil {
+		return err
+	}
+
+	if err := opts.parseNetworks(ctx, machine); err != nil {
+		return err
+	}
+
+	if err := opts.parseVolumes(ctx, machine); err != nil {
+		return err
+	}
+
+	if err := opts.prepareRootfs(ctx, machine); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -70,6 +70,8 @@ func (runner *runnerKernel) Prepare(ctx context.Context, opts *RunOptions, machi
 			machine.Spec.Architecture = "arm"
 		case elf.EM_AARCH64:
 			machine.Spec.Architecture = "arm64"
+		case elf.EM_RISCV:
+			machine.Spec.Architecture = "riscv64"
 		default:
 			return fmt.Errorf("unsupported kernel architecture: %v", fe.Machine.String())
 		}
@@ -100,7 +100,7 @@ func (runner *runnerKraftfileRuntime) Prepare(ctx context.Context, opts *RunOpti
 		targets = target.Filter(
 			targets,
 			opts.Architecture,
-			opts.platform.String(),
+			platform.KernelPlatform(opts.platform).String(),
 			opts.Target,
 		)
 
@@ -220,6 +220,9 @@ func (runner *runnerKraftfileRuntime) Prepare(ctx context.Context, opts *RunOpti
 		machine.Spec.ApplicationArgs = runtime.Command()
 	}
 
+	opts.kernelDbg = runtime.KernelDbg()
+	opts.kconfig = runtime.KConfig()
+
 	// Use the symbolic debuggable kernel image?
 	if opts.WithKernelDbg {
 		machine.Status.KernelPath = runtime.KernelDbg()
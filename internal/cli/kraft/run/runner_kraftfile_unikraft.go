@@ -8,9 +8,12 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	machineapi "kraftkit.sh/api/machine/v1alpha1"
 	"kraftkit.sh/config"
+	"kraftkit.sh/kconfig"
+	mplatform "kraftkit.sh/machine/platform"
 	"kraftkit.sh/unikraft/app"
 	"kraftkit.sh/unikraft/target"
 )
@@ -89,7 +92,7 @@ func (runner *runnerKraftfileUnikraft) Prepare(ctx context.Context, opts *RunOpt
 	targets := target.Filter(
 		runner.project.Targets(),
 		opts.Architecture,
-		opts.platform.String(),
+		mplatform.KernelPlatform(opts.platform).String(),
 		opts.Target,
 	)
 
@@ -145,6 +148,19 @@ func (runner *runnerKraftfileUnikraft) Prepare(ctx context.Context, opts *RunOpt
 	machine.Spec.KernelArgs = kernelArgs
 	machine.Spec.ApplicationArgs = appArgs
 
+	opts.kernelDbg = t.KernelDbg()
+
+	// The KConfig of the target in the Kraftfile is incomplete, so prefer the
+	// configuration with which the kernel was built.
+	opts.kconfig, err = kconfig.NewKeyValueMapFromFile(filepath.Join(runner.workdir, t.ConfigFilename()))
+	if err != nil {
+		opts.kconfig = t.KConfig()
+	}
+
+	if err := applyTargetDevices(t, machine); err != nil {
+		return err
+	}
+
 	// Use the symbolic debuggable kernel image?
 	if opts.WithKernelDbg {
 		machine.Status.KernelPath = t.KernelDbg()
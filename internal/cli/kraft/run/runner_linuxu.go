@@ -173,6 +173,9 @@ func (runner *runnerLinuxu) Prepare(ctx context.Context, opts *RunOptions, machi
 	machine.Spec.ApplicationArgs = runner.args
 	machine.Status.InitrdPath = runner.exePath
 
+	opts.kernelDbg = loader.KernelDbg()
+	opts.kconfig = loader.KConfig()
+
 	// Use the symbolic debuggable kernel image?
 	if opts.WithKernelDbg {
 		machine.Status.KernelPath = loader.KernelDbg()
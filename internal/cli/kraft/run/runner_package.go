@@ -68,7 +68,7 @@ func (runner *runnerPackage) Runnable(ctx context.Context, opts *RunOptions, arg
 	pm, compatible, err := runner.pm.IsCompatible(ctx,
 		runner.packName,
 		packmanager.WithArchitecture(opts.Architecture),
-		packmanager.WithPlatform(opts.platform.String()),
+		packmanager.WithPlatform(platform.KernelPlatform(opts.platform).String()),
 		packmanager.WithUpdate(true),
 	)
 	if err == nil && compatible {
@@ -87,7 +87,7 @@ func (runner *runnerPackage) Prepare(ctx context.Context, opts *RunOptions, mach
 		packmanager.WithTypes(unikraft.ComponentTypeApp),
 		packmanager.WithName(runner.packName),
 		packmanager.WithArchitecture(opts.Architecture),
-		packmanager.WithPlatform(opts.platform.String()),
+		packmanager.WithPlatform(platform.KernelPlatform(opts.platform).String()),
 	}
 
 	// First try the local cache of the catalog
@@ -154,7 +154,7 @@ func (runner *runnerPackage) Prepare(ctx context.Context, opts *RunOptions, mach
 
 		for _, p := range packs {
 			pt := p.(target.Target)
-			if pt.Architecture().String() == opts.Architecture && pt.Platform().String() == opts.platform.String() {
+			if pt.Architecture().String() == opts.Architecture && pt.Platform().String() == platform.KernelPlatform(opts.platform).String() {
 				packs = []pack.Package{p}
 				break
 			}
@@ -248,6 +248,9 @@ func (runner *runnerPackage) Prepare(ctx context.Context, opts *RunOptions, mach
 		}
 	}
 
+	opts.kernelDbg = targ.KernelDbg()
+	opts.kconfig = targ.KConfig()
+
 	// Use the symbolic debuggable kernel image?
 	if opts.WithKernelDbg {
 		machine.Status.KernelPath = targ.KernelDbg()
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package run
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/internal/tableprinter"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+)
+
+const (
+	// BootTraceFileName is the name of the file in the state directory of a
+	// machine in which the trace of its boot is saved.
+	BootTraceFileName = "boot-trace.json"
+
+	// traceTimeout is how long to wait for a traced machine to become ready.
+	traceTimeout = time.Minute
+)
+
+// bootStage is a stage of the boot of a machine and when it was reached.
+type bootStage struct {
+	Name string    `json:"name"`
+	At   time.Time `json:"at"`
+
+	// Elapsed is the time since the machine was requested to be created.
+	Elapsed time.Duration `json:"elapsed"`
+
+	// Delta is the time since the previous stage was reached.
+	Delta time.Duration `json:"delta"`
+}
+
+// bootTrace is the trace of the boot of a machine as it is saved in its state
+// directory.
+type bootTrace struct {
+	Machine        string      `json:"machine"`
+	Platform       string      `json:"platform"`
+	Requested      time.Time   `json:"requested"`
+	ReadyCondition string      `json:"readyCondition,omitempty"`
+	Stages         []bootStage `json:"stages"`
+}
+
+// parseReadyAddress returns the address which is dialed to check whether the
+// machine is ready, where a port without a host refers to localhost.
+func parseReadyAddress(addr string) (string, error) {
+	if port, err := strconv.Atoi(addr); err == nil {
+		if port < 1 || port > 65535 {
+			return "", fmt.Errorf("invalid port: %s", addr)
+		}
+
+		return net.JoinHostPort("127.0.0.1", addr), nil
+	}
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", fmt.Errorf("invalid address: %s: %v", addr, err)
+	}
+
+	return addr, nil
+}
+
+// readyCondition describes the condition which considers the machine ready.
+func (opts *RunOptions) readyCondition() string {
+	if opts.traceReadyLog != nil {
+		return fmt.Sprintf("log matches %q", opts.traceReadyLog.String())
+	} else if opts.traceReadyAddr != "" {
+		return fmt.Sprintf("%s accepts connections", opts.traceReadyAddr)
+	}
+
+	return ""
+}
+
+// waitForBoot follows the console output of the machine, and probes the
+// address provided with --trace-ready-port, until it has both written to its
+// console and satisfied the ready condition, if any.
+func (opts *RunOptions) waitForBoot(ctx context.Context, machine *machineapi.Machine, trace *machineapi.MachineBootTrace) error {
+	ctx, cancel := context.WithTimeout(ctx, traceTimeout)
+	defer cancel()
+
+	f, err := os.Open(machine.Status.LogFile)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %v", err)
+	}
+
+	defer f.Close()
+
+	// Poll rather than wait for file system events such that the first write
+	// to the console is observed as soon as possible.
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+
+	buf := make([]byte, 4096)
+	var pending []byte
+
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if trace.FirstOutput.IsZero() {
+				trace.FirstOutput = time.Now()
+			}
+
+			if opts.traceReadyLog != nil && trace.Ready.IsZero() {
+				pending = append(pending, buf[:n]...)
+
+				for {
+					i := bytes.IndexByte(pending, '\n')
+					if i < 0 {
+						break
+					}
+
+					if opts.traceReadyLog.Match(pending[:i]) {
+						trace.Ready = time.Now()
+						break
+					}
+
+					pending = pending[i+1:]
+				}
+			}
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not read log file: %v", err)
+		}
+
+		if opts.traceReadyAddr != "" && trace.Ready.IsZero() {
+			conn, err := net.DialTimeout("tcp", opts.traceReadyAddr, 10*time.Millisecond)
+			if err == nil {
+				trace.Ready = time.Now()
+				conn.Close()
+			}
+		}
+
+		if !trace.FirstOutput.IsZero() && (!trace.Ready.IsZero() || opts.readyCondition() == "") {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("machine did not become ready within %s", traceTimeout)
+			}
+
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// traceBoot waits for the machine to boot before printing how long each stage
+// of its boot took and saving the trace in the state directory of the machine.
+// Stages which are recorded by the platform are read from the provided trace.
+func (opts *RunOptions) traceBoot(ctx context.Context, machine *machineapi.Machine, trace machineapi.MachineBootTrace, requested time.Time) {
+	if err := opts.waitForBoot(ctx, machine, &trace); err != nil {
+		log.G(ctx).Warnf("incomplete boot trace of %s: %v", machine.Name, err)
+	}
+
+	report := bootTrace{
+		Machine:        machine.Name,
+		Platform:       opts.platform.String(),
+		Requested:      requested,
+		ReadyCondition: opts.readyCondition(),
+		Stages:         []bootStage{},
+	}
+
+	previous := requested
+	for _, stage := range []bootStage{
+		{Name: "vmm spawned", At: trace.Spawned},
+		{Name: "control ready", At: trace.ControlReady},
+		{Name: "continued", At: trace.Continued},
+		{Name: "first output", At: trace.FirstOutput},
+		{Name: "ready", At: trace.Ready},
+	} {
+		if stage.At.IsZero() {
+			continue
+		}
+
+		stage.Elapsed = stage.At.Sub(requested)
+		stage.Delta = stage.At.Sub(previous)
+		previous = stage.At
+
+		report.Stages = append(report.Stages, stage)
+	}
+
+	if err := opts.renderBootTrace(ctx, report); err != nil {
+		log.G(ctx).Warnf("could not print boot trace: %v", err)
+	}
+
+	if machine.Status.StateDir == "" {
+		return
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.G(ctx).Warnf("could not marshal boot trace: %v", err)
+		return
+	}
+
+	path := filepath.Join(machine.Status.StateDir, BootTraceFileName)
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		log.G(ctx).Warnf("could not save boot trace: %v", err)
+		return
+	}
+
+	log.G(ctx).WithField("path", path).Info("saved boot trace")
+}
+
+// renderBootTrace prints the stages of the boot trace to the standard error,
+// such that it does not interleave with the output of the machine.
+func (opts *RunOptions) renderBootTrace(ctx context.Context, report bootTrace) error {
+	cs := iostreams.G(ctx).ColorScheme()
+
+	table, err := tableprinter.NewTablePrinter(ctx,
+		tableprinter.WithMaxWidth(iostreams.G(ctx).TerminalWidth()),
+	)
+	if err != nil {
+		return err
+	}
+
+	table.AddField("STAGE", cs.Bold)
+	table.AddField("ELAPSED", cs.Bold)
+	table.AddField("DELTA", cs.Bold)
+	table.EndRow()
+
+	for _, stage := range report.Stages {
+		name := stage.Name
+		if name == "ready" && report.ReadyCondition != "" {
+			name = fmt.Sprintf("ready (%s)", report.ReadyCondition)
+		}
+
+		table.AddField(name, nil)
+		table.AddField(stage.Elapsed.Round(time.Microsecond).String(), nil)
+		table.AddField("+"+stage.Delta.Round(time.Microsecond).String(), nil)
+		table.EndRow()
+	}
+
+	return table.Render(iostreams.G(ctx).ErrOut)
+}
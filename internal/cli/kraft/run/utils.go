@@ -5,17 +5,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/containerd/nerdctl/pkg/strutil"
 	"github.com/dustin/go-humanize"
+	goprocess "github.com/shirou/gopsutil/v3/process"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
 
 	machineapi "kraftkit.sh/api/machine/v1alpha1"
 	networkapi "kraftkit.sh/api/network/v1alpha1"
 	volumeapi "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/config"
+	"kraftkit.sh/exec"
 	"kraftkit.sh/initrd"
 	"kraftkit.sh/log"
 	machinename "kraftkit.sh/machine/name"
@@ -42,6 +47,51 @@ func (opts *RunOptions) parsePorts(_ context.Context, machine *machineapi.Machin
 	return nil
 }
 
+// Were host resource limits specified? E.g. --cpu-limit=500m --memory-limit=128Mi
+func (opts *RunOptions) parseLimits(_ context.Context, machine *machineapi.Machine) error {
+	limits := corev1.ResourceList{}
+
+	if len(opts.CPULimit) > 0 {
+		quantity, err := resource.ParseQuantity(opts.CPULimit)
+		if err != nil {
+			return fmt.Errorf("could not parse CPU limit: %v", err)
+		}
+
+		limits[corev1.ResourceCPU] = quantity
+	}
+
+	if len(opts.MemoryLimit) > 0 {
+		quantity, err := resource.ParseQuantity(opts.MemoryLimit)
+		if err != nil {
+			return fmt.Errorf("could not parse memory limit: %v", err)
+		}
+
+		limits[corev1.ResourceMemory] = quantity
+	}
+
+	if opts.IOWeight != 0 {
+		if opts.IOWeight < 1 || opts.IOWeight > 10000 {
+			return fmt.Errorf("IO weight must be between 1 and 10000")
+		}
+
+		limits[machineapi.ResourceIOWeight] = *resource.NewQuantity(int64(opts.IOWeight), resource.DecimalSI)
+	}
+
+	if len(limits) == 0 {
+		return nil
+	}
+
+	if machine.Spec.Resources.Limits == nil {
+		machine.Spec.Resources.Limits = corev1.ResourceList{}
+	}
+
+	for name, quantity := range limits {
+		machine.Spec.Resources.Limits[name] = quantity
+	}
+
+	return nil
+}
+
 // Was a network specified? E.g. --network=bridge:kraft0
 func (opts *RunOptions) parseNetworks(ctx context.Context, machine *machineapi.Machine) error {
 	if opts.Network == "" {
@@ -62,13 +112,18 @@ func (opts *RunOptions) parseNetworks(ctx context.Context, machine *machineapi.M
 	// network interface which will allow us to clean it up later. Additionally,
 	// it's OK if the IP or MAC address are empty, the network controller will
 	// populate values if they are unset and will populate with new values
-	// following the returning from the Update operation.
+	// following the returning from the Update operation.  The labels of the
+	// machine are also those of its interface, such that the policy of the
+	// network can select it.
 	newIface := networkapi.NetworkInterfaceTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
-			UID: uuid.NewUUID(),
+			Name:   machine.Name,
+			UID:    uuid.NewUUID(),
+			Labels: opts.labels,
 		},
 		Spec: networkapi.NetworkInterfaceSpec{
 			IP:         opts.IP,
+			IPv6:       opts.IPv6,
 			MacAddress: opts.MacAddress,
 		},
 	}
@@ -131,7 +186,6 @@ func (opts *RunOptions) parseVolumes(ctx context.Context, machine *machineapi.Ma
 		return nil
 	}
 
-	var err error
 	controllers := map[string]volumeapi.VolumeService{}
 	machine.Spec.Volumes = []volumeapi.Volume{}
 
@@ -145,25 +199,16 @@ func (opts *RunOptions) parseVolumes(ctx context.Context, machine *machineapi.Ma
 			return fmt.Errorf("invalid syntax for --volume=%s expected --volume=<host>:<machine>", volLine)
 		}
 
-		var driver string
-
-		for sname, strategy := range volume.Strategies() {
-			if ok, _ := strategy.IsCompatible(hostPath, nil); !ok || err != nil {
-				continue
-			}
-
-			if _, ok := controllers[sname]; !ok {
-				controllers[sname], err = strategy.NewVolumeV1alpha1(ctx)
-				if err != nil {
-					return fmt.Errorf("could not prepare %s volume service: %w", sname, err)
-				}
-			}
-
-			driver = sname
+		driver, err := volume.CompatibleDriver(hostPath, opts.platform.String(), opts.kconfig)
+		if err != nil {
+			return err
 		}
 
-		if len(driver) == 0 {
-			return fmt.Errorf("could not find compatible volume driver for %s", hostPath)
+		if _, ok := controllers[driver]; !ok {
+			controllers[driver], err = volume.Strategies()[driver].NewVolumeV1alpha1(ctx)
+			if err != nil {
+				return fmt.Errorf("could not prepare %s volume service: %w", driver, err)
+			}
 		}
 
 		vol, err := controllers[driver].Create(ctx, &volumeapi.Volume{
@@ -201,24 +246,22 @@ func (opts *RunOptions) parseKraftfileVolumes(ctx context.Context, project app.A
 		driver := volcfg.Driver()
 
 		if len(driver) == 0 {
-			for sname, strategy := range volume.Strategies() {
-				if ok, _ := strategy.IsCompatible(volcfg.Source(), nil); !ok || err != nil {
-					continue
-				}
-
-				if _, ok := controllers[sname]; !ok {
-					controllers[sname], err = strategy.NewVolumeV1alpha1(ctx)
-					if err != nil {
-						return fmt.Errorf("could not prepare %s volume service: %w", sname, err)
-					}
-				}
-
-				driver = sname
+			driver, err = volume.CompatibleDriver(volcfg.Source(), opts.platform.String(), opts.kconfig)
+			if err != nil {
+				return err
 			}
 		}
 
-		if len(driver) == 0 {
-			return fmt.Errorf("could not find compatible volume driver for %s", volcfg.Source())
+		if _, ok := controllers[driver]; !ok {
+			strategy, ok := volume.Strategies()[driver]
+			if !ok {
+				return fmt.Errorf("unknown volume driver: %s", driver)
+			}
+
+			controllers[driver], err = strategy.NewVolumeV1alpha1(ctx)
+			if err != nil {
+				return fmt.Errorf("could not prepare %s volume service: %w", driver, err)
+			}
 		}
 
 		vol, err := controllers[driver].Create(ctx, &volumeapi.Volume{
@@ -283,3 +326,86 @@ func (opts *RunOptions) prepareRootfs(ctx context.Context, machine *machineapi.M
 
 	return nil
 }
+
+// prepareFromSnapshot populates the machine specification from the manifest of
+// the snapshot provided via --from-snapshot.  Command-line flags which would
+// otherwise alter the specification are ignored, since the restored machine
+// must match the machine which the snapshot was taken of.
+func (opts *RunOptions) prepareFromSnapshot(ctx context.Context, machine *machineapi.Machine) error {
+	manifest, err := machineapi.LoadMachineSnapshotManifest(opts.FromSnapshot)
+	if err != nil {
+		return err
+	}
+
+	if manifest.Snapshot.Platform != opts.platform.String() {
+		return fmt.Errorf("cannot restore %s snapshot with %s platform", manifest.Snapshot.Platform, opts.platform.String())
+	}
+
+	// Snapshots of machines with network devices cannot be taken, but may have
+	// been by earlier versions, whose tap interfaces, addresses and published
+	// ports would collide with those of the original machine.
+	if len(manifest.Spec.Networks) > 0 || len(manifest.Spec.Ports) > 0 {
+		return fmt.Errorf("cannot restore snapshot of machine with network devices")
+	}
+
+	machine.Spec = manifest.Spec
+	machine.Spec.RestoreFrom = opts.FromSnapshot
+	machine.Status.KernelPath = manifest.KernelPath
+	machine.Status.InitrdPath = manifest.InitrdPath
+
+	log.G(ctx).
+		WithField("snapshot", manifest.Snapshot.Name).
+		Debug("restoring")
+
+	return opts.assignName(ctx, machine)
+}
+
+// ensureSupervisor starts the event monitor in the background, unless it is
+// already running, such that the machine is restarted according to its restart
+// policy and its health is checked after this command has exited.
+func (opts *RunOptions) ensureSupervisor(ctx context.Context) error {
+	pidFile := config.G[config.KraftKit](ctx).EventsPidFile
+
+	if b, err := os.ReadFile(pidFile); err == nil {
+		if pid, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 32); err == nil {
+			if exists, _ := goprocess.PidExistsWithContext(ctx, int32(pid)); exists {
+				return nil
+			}
+		}
+
+		// The event monitor only writes its pid file if it does not exist.
+		if err := os.Remove(pidFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove stale pid file: %v", err)
+		}
+	}
+
+	bin, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not determine path of executable: %v", err)
+	}
+
+	logFile, err := os.OpenFile(
+		filepath.Join(config.G[config.KraftKit](ctx).RuntimeDir, "events.log"),
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0o644,
+	)
+	if err != nil {
+		return fmt.Errorf("could not open event monitor log file: %v", err)
+	}
+
+	defer logFile.Close()
+
+	process, err := exec.NewProcess(bin, []string{"events", "--quit-together"},
+		exec.WithDetach(true),
+		exec.WithStdout(logFile),
+	)
+	if err != nil {
+		return fmt.Errorf("could not prepare event monitor: %v", err)
+	}
+
+	if err := process.Start(ctx); err != nil {
+		return fmt.Errorf("could not start event monitor: %v", err)
+	}
+
+	return process.Release()
+}
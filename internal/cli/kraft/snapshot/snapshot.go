@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package snapshot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/spf13/cobra"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/iostreams"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type SnapshotOptions struct {
+	platform string
+}
+
+// Snapshot a local Unikraft virtual machine.
+func Snapshot(ctx context.Context, opts *SnapshotOptions, args ...string) error {
+	if opts == nil {
+		opts = &SnapshotOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&SnapshotOptions{}, cobra.Command{
+		Short: "Snapshot a unikernel",
+		Use:   "snapshot [FLAGS] MACHINE",
+		Args:  cobra.ExactArgs(1),
+		Long: heredoc.Doc(`
+			Capture the memory and device state of a unikernel such that it can later
+			be restored via kraft run --from-snapshot`),
+		Example: heredoc.Doc(`
+			Snapshot a running unikernel and restore it as a new instance:
+			$ kraft snapshot my-machine
+			$ kraft run --from-snapshot /path/to/snapshot
+			`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "run",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.Flags().VarP(
+		cmdfactory.NewEnumFlag[mplatform.Platform](
+			mplatform.Platforms(),
+			mplatform.Platform("auto"),
+		),
+		"plat",
+		"p",
+		"Set the platform virtual machine monitor driver.  Set to 'auto' to detect the guest's platform and 'host' to use the host platform.",
+	)
+
+	return cmd
+}
+
+func (opts *SnapshotOptions) Pre(cmd *cobra.Command, _ []string) error {
+	opts.platform = cmd.Flag("plat").Value.String()
+	return nil
+}
+
+func (opts *SnapshotOptions) Run(ctx context.Context, args []string) error {
+	var err error
+
+	platform := mplatform.PlatformUnknown
+	var controller machineapi.MachineService
+
+	if opts.platform == "auto" {
+		controller, err = mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	} else {
+		if opts.platform == "host" {
+			platform, _, err = mplatform.Detect(ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			var ok bool
+			platform, ok = mplatform.PlatformsByName()[opts.platform]
+			if !ok {
+				return fmt.Errorf("unknown platform driver: %s", opts.platform)
+			}
+		}
+
+		strategy, ok := mplatform.Strategies()[platform]
+		if !ok {
+			return fmt.Errorf("unsupported platform driver: %s (contributions welcome!)", platform.String())
+		}
+
+		controller, err = strategy.NewMachineV1alpha1(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return err
+	}
+
+	var machine *machineapi.Machine
+
+	for _, candidate := range machines.Items {
+		if args[0] == candidate.Name || args[0] == string(candidate.UID) {
+			machine = &candidate
+			break
+		}
+	}
+
+	if machine == nil {
+		return fmt.Errorf("machine not found: %s", args[0])
+	}
+
+	switch machine.Status.State {
+	case machineapi.MachineStateRunning, machineapi.MachineStatePaused:
+	default:
+		return fmt.Errorf("cannot snapshot machine in %s state", machine.Status.State)
+	}
+
+	machine, err = controller.Snapshot(ctx, machine)
+	if err != nil {
+		return fmt.Errorf("could not snapshot machine %s: %v", args[0], err)
+	}
+
+	// Output the location of the snapshot such that it can be piped
+	snapshot := machine.Status.Snapshots[len(machine.Status.Snapshots)-1]
+	fmt.Fprintln(iostreams.G(ctx).Out, snapshot.Path)
+
+	return nil
+}
@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MakeNowJust/heredoc"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/cmdfactory"
+	"kraftkit.sh/internal/tableprinter"
+	"kraftkit.sh/iostreams"
+	"kraftkit.sh/log"
+	mplatform "kraftkit.sh/machine/platform"
+)
+
+type StatsOptions struct {
+	Interval time.Duration `long:"interval" short:"i" usage:"How often the statistics are refreshed" default:"1s"`
+	NoStream bool          `long:"no-stream" usage:"Print a single snapshot of the statistics and exit"`
+	Output   string        `long:"output" short:"o" usage:"Set output format" default:"table"`
+	platform string
+}
+
+// Stats displays the resource usage of local Unikraft virtual machines.
+func Stats(ctx context.Context, opts *StatsOptions, args ...string) error {
+	if opts == nil {
+		opts = &StatsOptions{}
+	}
+
+	return opts.Run(ctx, args)
+}
+
+func NewCmd() *cobra.Command {
+	cmd, err := cmdfactory.New(&StatsOptions{}, cobra.Command{
+		Short: "Display the resource usage of running unikernels",
+		Use:   "stats [FLAGS] [MACHINE [MACHINE [...]]]",
+		Long: heredoc.Doc(`
+			Display a live stream of the resource usage of running unikernels.
+
+			The CPU time and resident memory are those of the virtual machine monitor
+			on the host, whilst the guest memory is the memory which is currently
+			available to the unikernel.  Network traffic is from the perspective of
+			the unikernel.`),
+		Example: heredoc.Doc(`
+			# Stream the resource usage of all running unikernels
+			$ kraft stats
+
+			# Print a single snapshot of the resource usage of a unikernel as JSON
+			$ kraft stats --no-stream -o json my-machine`),
+		Annotations: map[string]string{
+			cmdfactory.AnnotationHelpGroup: "run",
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	cmd.Flags().VarP(
+		cmdfactory.NewEnumFlag[mplatform.Platform](
+			mplatform.Platforms(),
+			mplatform.Platform("auto"),
+		),
+		"plat",
+		"p",
+		"Set the platform virtual machine monitor driver.",
+	)
+
+	return cmd
+}
+
+func (opts *StatsOptions) Pre(cmd *cobra.Command, _ []string) error {
+	if opts.Interval <= 0 {
+		return fmt.Errorf("the interval must be positive")
+	}
+
+	opts.platform = cmd.Flag("plat").Value.String()
+	return nil
+}
+
+// machineStats is the resource usage of a machine at a point in time.
+type machineStats struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Platform   string  `json:"platform"`
+	CPUPercent float64 `json:"cpuPercent"`
+
+	machineapi.MachineResourceUsage
+
+	sampledAt time.Time
+}
+
+// netIO returns the total traffic of all interfaces of the machine.
+func (stats machineStats) netIO() (rx, tx uint64) {
+	for _, iface := range stats.Interfaces {
+		rx += iface.RxBytes
+		tx += iface.TxBytes
+	}
+
+	return rx, tx
+}
+
+// cpuPercent returns the share of a single CPU which the machine has spent
+// between the two provided samples of it.  Zero is returned if the CPU time
+// has been reset in the meantime, e.g. because the machine was restarted.
+func cpuPercent(previous, current machineStats) float64 {
+	elapsed := current.sampledAt.Sub(previous.sampledAt)
+	if elapsed <= 0 || current.CPUTime < previous.CPUTime {
+		return 0
+	}
+
+	return float64(current.CPUTime-previous.CPUTime) / float64(elapsed) * 100
+}
+
+// sample returns the current resource usage of each of the running machines
+// which match the provided arguments, computing their CPU usage relative to the
+// previous samples.
+func (opts *StatsOptions) sample(ctx context.Context, controller machineapi.MachineService, previous map[string]machineStats, args []string) ([]machineStats, error) {
+	machines, err := controller.List(ctx, &machineapi.MachineList{})
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []machineStats
+
+	for _, machine := range machines.Items {
+		if len(args) > 0 {
+			found := false
+			for _, arg := range args {
+				if arg == machine.Name || arg == string(machine.UID) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		if machine.Status.State != machineapi.MachineStateRunning &&
+			machine.Status.State != machineapi.MachineStatePaused {
+			continue
+		}
+
+		machine := machine
+		if _, err := controller.Stats(ctx, &machine); err != nil {
+			log.G(ctx).
+				WithField("machine", machine.Name).
+				Debugf("could not gather statistics: %v", err)
+			continue
+		}
+
+		entry := machineStats{
+			ID:                   string(machine.UID),
+			Name:                 machine.Name,
+			Platform:             machine.Spec.Platform,
+			MachineResourceUsage: machine.Status.Usage,
+			sampledAt:            time.Now(),
+		}
+
+		if prev, ok := previous[entry.ID]; ok {
+			entry.CPUPercent = cpuPercent(prev, entry)
+		}
+
+		ret = append(ret, entry)
+	}
+
+	return ret, nil
+}
+
+// render prints the provided statistics in the selected output format.
+func (opts *StatsOptions) render(ctx context.Context, items []machineStats) error {
+	cs := iostreams.G(ctx).ColorScheme()
+
+	table, err := tableprinter.NewTablePrinter(ctx,
+		tableprinter.WithMaxWidth(iostreams.G(ctx).TerminalWidth()),
+		tableprinter.WithOutputFormatFromString(opts.Output),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Header row
+	table.AddField("NAME", cs.Bold)
+	table.AddField("CPU %", cs.Bold)
+	table.AddField("CPU TIME", cs.Bold)
+	table.AddField("RSS", cs.Bold)
+	table.AddField("GUEST MEM", cs.Bold)
+	table.AddField("NET RX / TX", cs.Bold)
+	table.AddField("BLOCK R / W", cs.Bold)
+	table.AddField("PLAT", cs.Bold)
+	table.EndRow()
+
+	for _, item := range items {
+		rx, tx := item.netIO()
+
+		table.AddField(item.Name, nil)
+		table.AddField(fmt.Sprintf("%.2f%%", item.CPUPercent), nil)
+		table.AddField(item.CPUTime.Truncate(time.Millisecond).String(), nil)
+		table.AddField(humanize.IBytes(uint64(item.RSS)), nil)
+		table.AddField(humanize.IBytes(uint64(item.GuestMemory)), nil)
+		table.AddField(fmt.Sprintf("%s / %s", humanize.Bytes(rx), humanize.Bytes(tx)), nil)
+		table.AddField(fmt.Sprintf("%s / %s",
+			humanize.Bytes(uint64(item.IOReadBytes)),
+			humanize.Bytes(uint64(item.IOWriteBytes)),
+		), nil)
+		table.AddField(item.Platform, nil)
+		table.EndRow()
+	}
+
+	return table.Render(iostreams.G(ctx).Out)
+}
+
+func (opts *StatsOptions) Run(ctx context.Context, args []string) error {
+	var err error
+
+	platform := mplatform.PlatformUnknown
+	var controller machineapi.MachineService
+
+	if opts.platform == "auto" {
+		controller, err = mplatform.NewMachineV1alpha1ServiceIterator(ctx)
+	} else {
+		if opts.platform == "host" {
+			platform, _, err = mplatform.Detect(ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			var ok bool
+			platform, ok = mplatform.PlatformsByName()[opts.platform]
+			if !ok {
+				return fmt.Errorf("unknown platform driver: %s", opts.platform)
+			}
+		}
+
+		strategy, ok := mplatform.Strategies()[platform]
+		if !ok {
+			return fmt.Errorf("unsupported platform driver: %s (contributions welcome!)", platform.String())
+		}
+
+		controller, err = strategy.NewMachineV1alpha1(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	previous := map[string]machineStats{}
+
+	// The CPU usage is derived from the CPU time which elapses between two
+	// samples, so an initial sample is always taken.
+	items, err := opts.sample(ctx, controller, previous, args)
+	if err != nil {
+		return err
+	}
+
+	for {
+		for _, item := range items {
+			previous[item.ID] = item
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.Interval):
+		}
+
+		items, err = opts.sample(ctx, controller, previous, args)
+		if err != nil {
+			return err
+		}
+
+		if opts.NoStream {
+			break
+		}
+
+		iostreams.G(ctx).RefreshScreen()
+
+		if err := opts.render(ctx, items); err != nil {
+			return err
+		}
+	}
+
+	// Snapshots are intended for scripting, so the raw values are encoded
+	// rather than their human-readable representation.
+	if opts.Output == string(tableprinter.OutputFormatJSON) {
+		if items == nil {
+			items = []machineStats{}
+		}
+
+		encoder := json.NewEncoder(iostreams.G(ctx).Out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(items)
+	}
+
+	return opts.render(ctx, items)
+}
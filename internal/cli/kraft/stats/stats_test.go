@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package stats
+
+import (
+	"testing"
+	"time"
+
+	machineapi "kraftkit.sh/api/machine/v1alpha1"
+)
+
+func TestCPUPercent(t *testing.T) {
+	now := time.Now()
+
+	sample := func(at time.Duration, cpu time.Duration) machineStats {
+		return machineStats{
+			MachineResourceUsage: machineapi.MachineResourceUsage{
+				CPUTime: cpu,
+			},
+			sampledAt: now.Add(at),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		previous machineStats
+		current  machineStats
+		want     float64
+	}{
+		{
+			name:     "idle",
+			previous: sample(0, time.Second),
+			current:  sample(time.Second, time.Second),
+			want:     0,
+		},
+		{
+			name:     "half of a CPU",
+			previous: sample(0, time.Second),
+			current:  sample(2*time.Second, 2*time.Second),
+			want:     50,
+		},
+		{
+			name:     "more than one CPU",
+			previous: sample(0, 0),
+			current:  sample(time.Second, 3*time.Second),
+			want:     300,
+		},
+		{
+			name:     "CPU time reset",
+			previous: sample(0, 5*time.Second),
+			current:  sample(time.Second, time.Second),
+			want:     0,
+		},
+		{
+			name:     "no time elapsed",
+			previous: sample(0, 0),
+			current:  sample(0, time.Second),
+			want:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cpuPercent(tt.previous, tt.current); got != tt.want {
+				t.Errorf("Expected %.2f%%, got %.2f%%", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNetIO(t *testing.T) {
+	stats := machineStats{
+		MachineResourceUsage: machineapi.MachineResourceUsage{
+			Interfaces: []machineapi.MachineInterfaceUsage{
+				{IfName: "tap0", RxBytes: 100, TxBytes: 10},
+				{IfName: "tap1", RxBytes: 200, TxBytes: 20},
+			},
+		},
+	}
+
+	if rx, tx := stats.netIO(); rx != 300 || tx != 30 {
+		t.Errorf("Expected 300 / 30, got %d / %d", rx, tx)
+	}
+}
@@ -7,6 +7,7 @@ package stop
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/MakeNowJust/heredoc"
 	"github.com/spf13/cobra"
@@ -19,7 +20,9 @@ import (
 )
 
 type StopOptions struct {
-	All      bool `long:"all" usage:"Remove all machines"`
+	All      bool     `long:"all" usage:"Remove all machines"`
+	Filter   []string `long:"filter" usage:"Stop the machines selected by label=KEY[=VALUE], name=NAME, id=UID, state=STATE or plat=PLATFORM"`
+	Time     int      `long:"time" short:"t" usage:"Seconds to wait for the unikernel to shut down gracefully before terminating it" default:"10"`
 	platform string
 }
 
@@ -37,7 +40,20 @@ func NewCmd() *cobra.Command {
 		Short: "Stop one or more running unikernels",
 		Use:   "stop [FLAGS] MACHINE [MACHINE [...]]",
 		Long: heredoc.Doc(`
-			Stop one or more running unikernels`),
+			Stop one or more running unikernels.
+
+			The unikernel is first requested to shut down gracefully.  If it has not
+			done so once the timeout elapses, its process is sent SIGTERM and finally
+			SIGKILL.`),
+		Example: heredoc.Doc(`
+			# Stop a unikernel, allowing it 30 seconds to shut down gracefully
+			$ kraft stop --time 30 my-machine
+
+			# Stop all unikernels immediately
+			$ kraft stop --all --time 0
+
+			# Stop the running unikernels which were labelled by a job
+			$ kraft stop --filter label=job=1234,state=running`),
 		Annotations: map[string]string{
 			cmdfactory.AnnotationHelpGroup: "run",
 		},
@@ -60,8 +76,12 @@ func NewCmd() *cobra.Command {
 }
 
 func (opts *StopOptions) Pre(cmd *cobra.Command, args []string) error {
-	if len(args) == 0 && !opts.All {
-		return fmt.Errorf("please supply a machine ID or name or use the --all flag")
+	if len(args) == 0 && !opts.All && len(opts.Filter) == 0 {
+		return fmt.Errorf("please supply a machine ID or name or use the --all or --filter flag")
+	}
+
+	if opts.Time < 0 {
+		return fmt.Errorf("the timeout must not be negative")
 	}
 
 	opts.platform = cmd.Flag("plat").Value.String()
@@ -69,11 +89,14 @@ func (opts *StopOptions) Pre(cmd *cobra.Command, args []string) error {
 }
 
 func (opts *StopOptions) Run(ctx context.Context, args []string) error {
-	if len(args) == 0 && !opts.All {
-		return fmt.Errorf("please supply a machine ID or name or use the --all flag")
+	if len(args) == 0 && !opts.All && len(opts.Filter) == 0 {
+		return fmt.Errorf("please supply a machine ID or name or use the --all or --filter flag")
 	}
 
-	var err error
+	filter, err := machineapi.ParseMachineFilter(opts.Filter)
+	if err != nil {
+		return err
+	}
 
 	platform := mplatform.PlatformUnknown
 	var controller machineapi.MachineService
@@ -113,13 +136,20 @@ func (opts *StopOptions) Run(ctx context.Context, args []string) error {
 	var stop []machineapi.Machine
 
 	for _, machine := range machines.Items {
-		if opts.All {
-			stop = append(stop, machine)
+		if !filter.Matches(&machine) {
 			continue
 		}
 
-		if args[0] == machine.Name || args[0] == string(machine.UID) {
+		if opts.All || len(args) == 0 {
 			stop = append(stop, machine)
+			continue
+		}
+
+		for _, arg := range args {
+			if arg == machine.Name || arg == string(machine.UID) {
+				stop = append(stop, machine)
+				break
+			}
 		}
 	}
 
@@ -127,14 +157,26 @@ func (opts *StopOptions) Run(ctx context.Context, args []string) error {
 		return fmt.Errorf("machine(s) not found")
 	}
 
+	timeout := time.Duration(opts.Time) * time.Second
+
 	for _, machine := range stop {
 		if machine.Status.State == machineapi.MachineStateExited {
 			continue
-		} else if _, err := controller.Stop(ctx, &machine); err != nil {
+		}
+
+		machine.Spec.StopTimeout = &timeout
+
+		stopped, err := controller.Stop(ctx, &machine)
+		if err != nil {
 			log.G(ctx).Errorf("could not stop machine %s: %v", machine.Name, err)
-		} else {
-			fmt.Fprintln(iostreams.G(ctx).Out, machine.Name)
+			continue
 		}
+
+		if len(stopped.Status.StopStage) > 0 {
+			log.G(ctx).Infof("machine %s stopped by %s", machine.Name, stopped.Status.StopStage)
+		}
+
+		fmt.Fprintln(iostreams.G(ctx).Out, machine.Name)
 	}
 
 	return nil
@@ -0,0 +1,393 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package cgroup places the process of each machine into a dedicated cgroup
+// v2 group such that the resources it may consume on the host are constrained
+// and its usage of them can be accounted for.
+package cgroup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/log"
+)
+
+const (
+	// Root is the mount point of the unified cgroup v2 hierarchy.
+	Root = "/sys/fs/cgroup"
+
+	// Parent is the group beneath which the group of each machine is created.
+	Parent = "kraftkit.slice"
+
+	// DefaultMemoryOverhead is the memory which is accounted for the VMM itself
+	// in addition to the memory of the guest.
+	DefaultMemoryOverhead = 64 * 1024 * 1024
+
+	// cpuPeriod is the period in microseconds over which the CPU quota of a
+	// group is enforced.
+	cpuPeriod = 100000
+
+	// defaultWeight is the weight of a group which is not otherwise set and
+	// corresponds to a single requested CPU.
+	defaultWeight = 100
+)
+
+// controllers which must be enabled for the groups of machines.
+var controllers = []string{"cpu", "memory", "io"}
+
+// Limits are the constraints which are applied to a group.  Zero values leave
+// the respective constraint unset.
+type Limits struct {
+	// CPUQuota is the time in microseconds per cpuPeriod which the group may
+	// spend on the CPU.
+	CPUQuota int64
+
+	// CPUWeight is the relative share of CPU time of the group, between 1 and
+	// 10000.
+	CPUWeight uint64
+
+	// MemoryMax is the maximum memory in bytes which the group may use.
+	MemoryMax int64
+
+	// IOWeight is the relative share of block IO of the group, between 1 and
+	// 10000.
+	IOWeight uint64
+}
+
+// IsZero returns whether no constraint is set.
+func (limits Limits) IsZero() bool {
+	return limits == Limits{}
+}
+
+// clampWeight restricts the provided weight to the range which is accepted by
+// the kernel.
+func clampWeight(weight int64) uint64 {
+	if weight < 1 {
+		return 1
+	} else if weight > 10000 {
+		return 10000
+	}
+
+	return uint64(weight)
+}
+
+// LimitsFromResources derives the limits of a group from the resources of a
+// machine.  The CPU quota and memory maximum follow the respective limits,
+// with the provided overhead of the VMM added to the latter, whilst the CPU
+// weight follows the requested CPUs, or the CPU limit in their absence,
+// similar to how Kubernetes derives the shares of a container.
+func LimitsFromResources(resources corev1.ResourceRequirements, overhead int64) Limits {
+	var limits Limits
+
+	if cpu, ok := resources.Limits[corev1.ResourceCPU]; ok && cpu.MilliValue() > 0 {
+		limits.CPUQuota = cpu.MilliValue() * cpuPeriod / 1000
+	}
+
+	cpu, ok := resources.Requests[corev1.ResourceCPU]
+	if !ok {
+		cpu, ok = resources.Limits[corev1.ResourceCPU]
+	}
+	if ok && cpu.MilliValue() > 0 {
+		limits.CPUWeight = clampWeight(cpu.MilliValue() * defaultWeight / 1000)
+	}
+
+	if memory, ok := resources.Limits[corev1.ResourceMemory]; ok && memory.Value() > 0 {
+		limits.MemoryMax = memory.Value() + overhead
+	}
+
+	if weight, ok := resources.Limits[machinev1alpha1.ResourceIOWeight]; ok && weight.Value() > 0 {
+		limits.IOWeight = clampWeight(weight.Value())
+	}
+
+	return limits
+}
+
+// Available returns whether the unified cgroup v2 hierarchy is mounted.
+func Available() bool {
+	_, err := os.Stat(filepath.Join(Root, "cgroup.controllers"))
+	return err == nil
+}
+
+// Path returns the path of the group of the machine with the provided name.
+func Path(name string) string {
+	return filepath.Join(Root, Parent, name)
+}
+
+// write writes the value to the provided control file of a group.
+func write(path, file, value string) error {
+	if err := os.WriteFile(filepath.Join(path, file), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("could not set %s of %s: %v", file, path, err)
+	}
+
+	return nil
+}
+
+// enableControllers enables the controllers which are required for the groups
+// of machines in the subtree of the provided group.
+func enableControllers(path string) error {
+	available, err := os.ReadFile(filepath.Join(path, "cgroup.controllers"))
+	if err != nil {
+		return fmt.Errorf("could not read controllers of %s: %v", path, err)
+	}
+
+	var enable []string
+	for _, controller := range controllers {
+		for _, field := range strings.Fields(string(available)) {
+			if field == controller {
+				enable = append(enable, "+"+controller)
+				break
+			}
+		}
+	}
+
+	if len(enable) == 0 {
+		return nil
+	}
+
+	return write(path, "cgroup.subtree_control", strings.Join(enable, " "))
+}
+
+// Create creates the group of the machine with the provided name, applies the
+// provided limits to it and returns its path.
+func Create(name string, limits Limits) (string, error) {
+	return create(Root, name, limits)
+}
+
+// create creates the group of the machine with the provided name within the
+// hierarchy mounted at the provided root.
+func create(root, name string, limits Limits) (string, error) {
+	parent := filepath.Join(root, Parent)
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return "", fmt.Errorf("could not create cgroup %s: %v", parent, err)
+	}
+
+	if err := enableControllers(root); err != nil {
+		return "", err
+	}
+
+	if err := enableControllers(parent); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(parent, name)
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return "", fmt.Errorf("could not create cgroup %s: %v", path, err)
+	}
+
+	quota := "max"
+	if limits.CPUQuota > 0 {
+		quota = strconv.FormatInt(limits.CPUQuota, 10)
+	}
+
+	if err := write(path, "cpu.max", fmt.Sprintf("%s %d", quota, cpuPeriod)); err != nil {
+		return path, err
+	}
+
+	weight := uint64(defaultWeight)
+	if limits.CPUWeight > 0 {
+		weight = limits.CPUWeight
+	}
+
+	if err := write(path, "cpu.weight", strconv.FormatUint(weight, 10)); err != nil {
+		return path, err
+	}
+
+	memory := "max"
+	if limits.MemoryMax > 0 {
+		memory = strconv.FormatInt(limits.MemoryMax, 10)
+	}
+
+	if err := write(path, "memory.max", memory); err != nil {
+		return path, err
+	}
+
+	// The io controller is not available on all hosts, e.g. without a suitable
+	// IO scheduler, so its weight is only set when requested.
+	if limits.IOWeight > 0 {
+		if err := write(path, "io.weight", fmt.Sprintf("default %d", limits.IOWeight)); err != nil {
+			return path, err
+		}
+	}
+
+	return path, nil
+}
+
+// AddProcess moves the process with the provided pid, including all of its
+// threads, into the group at the provided path.
+func AddProcess(path string, pid int) error {
+	return write(path, "cgroup.procs", strconv.Itoa(pid))
+}
+
+// Delete removes the group at the provided path once all of its processes
+// have exited.  It is safe to call this method multiple times.
+func Delete(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not remove cgroup %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// readInt reads a control file of a group which contains a single integer.
+func readInt(path, file string) (int64, error) {
+	b, err := os.ReadFile(filepath.Join(path, file))
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// Usage returns the resources which have been consumed by the group at the
+// provided path.
+func Usage(path string) (*machinev1alpha1.MachineResourceUsage, error) {
+	usage := machinev1alpha1.MachineResourceUsage{}
+
+	cpustat, err := os.Open(filepath.Join(path, "cpu.stat"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read cpu usage of %s: %v", path, err)
+	}
+
+	defer cpustat.Close()
+
+	scanner := bufio.NewScanner(cpustat)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || key != "usage_usec" {
+			continue
+		}
+
+		usec, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse cpu usage of %s: %v", path, err)
+		}
+
+		usage.CPUTime = time.Duration(usec) * time.Microsecond
+	}
+
+	usage.MemoryCurrent, err = readInt(path, "memory.current")
+	if err != nil {
+		return nil, fmt.Errorf("could not read memory usage of %s: %v", path, err)
+	}
+
+	// Only available since Linux 5.19.
+	if peak, err := readInt(path, "memory.peak"); err == nil {
+		usage.MemoryPeak = peak
+	}
+
+	// Each line of io.stat represents a device in the form of:
+	// "MAJ:MIN rbytes=N wbytes=N rios=N wios=N dbytes=N dios=N"
+	if iostat, err := os.ReadFile(filepath.Join(path, "io.stat")); err == nil {
+		for _, line := range strings.Split(string(iostat), "\n") {
+			for _, field := range strings.Fields(line) {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					continue
+				}
+
+				n, err := strconv.ParseInt(value, 10, 64)
+				if err != nil {
+					continue
+				}
+
+				switch key {
+				case "rbytes":
+					usage.IOReadBytes += n
+				case "wbytes":
+					usage.IOWriteBytes += n
+				}
+			}
+		}
+	}
+
+	return &usage, nil
+}
+
+// Constrain places the process with the provided pid, which backs the machine,
+// into a new group named after the machine and limited according to its
+// resources, recording the path of the group in the status of the machine.
+// Failing to do so is only considered an error if the machine requests any
+// limits, since most hosts do not delegate cgroups to unprivileged users.
+func Constrain(ctx context.Context, machine *machinev1alpha1.Machine, pid int) error {
+	limits := LimitsFromResources(machine.Spec.Resources, DefaultMemoryOverhead)
+
+	constrain := func() error {
+		if !Available() {
+			return fmt.Errorf("cgroup v2 is not mounted at %s", Root)
+		}
+
+		path, err := Create(string(machine.ObjectMeta.UID), limits)
+		if err != nil {
+			if len(path) > 0 {
+				_ = Delete(path)
+			}
+			return err
+		}
+
+		if err := AddProcess(path, pid); err != nil {
+			_ = Delete(path)
+			return err
+		}
+
+		machine.Status.CgroupPath = path
+
+		return nil
+	}
+
+	if err := constrain(); err != nil {
+		if !limits.IsZero() {
+			return fmt.Errorf("could not apply resource limits: %v", err)
+		}
+
+		log.G(ctx).
+			WithField("machine", machine.Name).
+			Debugf("not placing machine in cgroup: %v", err)
+	}
+
+	return nil
+}
+
+// Release removes the group of the machine, if any.  The usage which was last
+// recorded for the machine is retained.
+func Release(machine *machinev1alpha1.Machine) error {
+	if len(machine.Status.CgroupPath) == 0 {
+		return nil
+	}
+
+	if err := Delete(machine.Status.CgroupPath); err != nil {
+		return err
+	}
+
+	machine.Status.CgroupPath = ""
+
+	return nil
+}
+
+// UpdateUsage populates the usage of the machine from its group, if any.
+func UpdateUsage(machine *machinev1alpha1.Machine) error {
+	if len(machine.Status.CgroupPath) == 0 {
+		return nil
+	}
+
+	usage, err := Usage(machine.Status.CgroupPath)
+	if err != nil {
+		return err
+	}
+
+	machine.Status.Usage = *usage
+
+	return nil
+}
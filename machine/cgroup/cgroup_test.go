@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+func TestLimitsFromResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources corev1.ResourceRequirements
+		overhead  int64
+		want      Limits
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "CPU limit",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("1500m"),
+				},
+			},
+			want: Limits{CPUQuota: 150000, CPUWeight: 150},
+		},
+		{
+			name: "CPU request",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("2"),
+				},
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("250m"),
+				},
+			},
+			want: Limits{CPUQuota: 200000, CPUWeight: 25},
+		},
+		{
+			name: "CPU weight clamped",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("1m"),
+				},
+			},
+			want: Limits{CPUWeight: 1},
+		},
+		{
+			name: "CPU weight clamped to maximum",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU: resource.MustParse("200"),
+				},
+			},
+			want: Limits{CPUWeight: 10000},
+		},
+		{
+			name: "memory limit with overhead",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				},
+			},
+			overhead: DefaultMemoryOverhead,
+			want:     Limits{MemoryMax: 192 * 1024 * 1024},
+		},
+		{
+			name: "memory request",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				},
+			},
+			overhead: DefaultMemoryOverhead,
+		},
+		{
+			name: "IO weight",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					machinev1alpha1.ResourceIOWeight: resource.MustParse("500"),
+				},
+			},
+			want: Limits{IOWeight: 500},
+		},
+		{
+			name: "IO weight clamped",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					machinev1alpha1.ResourceIOWeight: resource.MustParse("20000"),
+				},
+			},
+			want: Limits{IOWeight: 10000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LimitsFromResources(tt.resources, tt.overhead); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// newTestRoot returns a temporary directory which resembles the root of a
+// cgroup v2 hierarchy with the provided controllers.
+func newTestRoot(t *testing.T, controllers string) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	// The kernel populates the controllers of each group, which the parent
+	// group of machines is therefore given in advance.
+	for _, dir := range []string{root, filepath.Join(root, Parent)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "cgroup.controllers"), []byte(controllers+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestCreate(t *testing.T) {
+	tests := []struct {
+		name        string
+		controllers string
+		limits      Limits
+		want        map[string]string
+	}{
+		{
+			name:        "unlimited",
+			controllers: "cpuset cpu io memory pids",
+			want: map[string]string{
+				"cpu.max":    "max 100000",
+				"cpu.weight": "100",
+				"memory.max": "max",
+			},
+		},
+		{
+			name:        "limited",
+			controllers: "cpuset cpu io memory pids",
+			limits: LimitsFromResources(corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:               resource.MustParse("500m"),
+					corev1.ResourceMemory:            resource.MustParse("64Mi"),
+					machinev1alpha1.ResourceIOWeight: resource.MustParse("250"),
+				},
+			}, DefaultMemoryOverhead),
+			want: map[string]string{
+				"cpu.max":    "50000 100000",
+				"cpu.weight": "50",
+				"memory.max": "134217728",
+				"io.weight":  "default 250",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := newTestRoot(t, tt.controllers)
+
+			path, err := create(root, "machine", tt.limits)
+			if err != nil {
+				t.Fatalf("Could not create group: %v", err)
+			}
+
+			if want := filepath.Join(root, Parent, "machine"); path != want {
+				t.Errorf("Expected path %s, got %s", want, path)
+			}
+
+			for _, dir := range []string{root, filepath.Join(root, Parent)} {
+				b, err := os.ReadFile(filepath.Join(dir, "cgroup.subtree_control"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := string(b); got != "+cpu +memory +io" {
+					t.Errorf("Expected %s to enable +cpu +memory +io, got %q", dir, got)
+				}
+			}
+
+			for file, want := range tt.want {
+				b, err := os.ReadFile(filepath.Join(path, file))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got := string(b); got != want {
+					t.Errorf("Expected %s to be %q, got %q", file, want, got)
+				}
+			}
+
+			if _, ok := tt.want["io.weight"]; !ok {
+				if _, err := os.Stat(filepath.Join(path, "io.weight")); !os.IsNotExist(err) {
+					t.Errorf("Expected io.weight to be left unset")
+				}
+			}
+		})
+	}
+}
+
+func TestCreateWithoutControllers(t *testing.T) {
+	root := newTestRoot(t, "cpuset memory pids")
+
+	if _, err := create(root, "machine", Limits{}); err != nil {
+		t.Fatalf("Could not create group: %v", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(root, "cgroup.subtree_control"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the controllers which are available are enabled.
+	if got := string(b); got != "+memory" {
+		t.Errorf("Expected +memory, got %q", got)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	path := t.TempDir()
+
+	for file, content := range map[string]string{
+		"cpu.stat":       "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n",
+		"memory.current": "67108864\n",
+		"memory.peak":    "134217728\n",
+		"io.stat": strings.Join([]string{
+			"8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0",
+			"8:16 rbytes=1024 wbytes=0 rios=1 wios=0 dbytes=0 dios=0",
+			"",
+		}, "\n"),
+	} {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := Usage(path)
+	if err != nil {
+		t.Fatalf("Could not read usage: %v", err)
+	}
+
+	want := machinev1alpha1.MachineResourceUsage{
+		CPUTime:       1500 * time.Millisecond,
+		MemoryCurrent: 64 * 1024 * 1024,
+		MemoryPeak:    128 * 1024 * 1024,
+		IOReadBytes:   5120,
+		IOWriteBytes:  8192,
+	}
+
+	if !reflect.DeepEqual(*usage, want) {
+		t.Errorf("Expected %+v, got %+v", want, *usage)
+	}
+}
+
+func TestUsageWithoutOptionalFiles(t *testing.T) {
+	path := t.TempDir()
+
+	if _, err := Usage(path); err == nil {
+		t.Fatal("Expected error without cpu.stat")
+	}
+
+	for file, content := range map[string]string{
+		"cpu.stat":       "usage_usec 42\n",
+		"memory.current": "1024\n",
+	} {
+		if err := os.WriteFile(filepath.Join(path, file), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := Usage(path)
+	if err != nil {
+		t.Fatalf("Could not read usage: %v", err)
+	}
+
+	want := machinev1alpha1.MachineResourceUsage{
+		CPUTime:       42 * time.Microsecond,
+		MemoryCurrent: 1024,
+	}
+
+	if !reflect.DeepEqual(*usage, want) {
+		t.Errorf("Expected %+v, got %+v", want, *usage)
+	}
+}
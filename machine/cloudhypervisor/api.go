@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package cloudhypervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The states of a virtual machine as reported by the REST API.
+const (
+	VmStateCreated    = "Created"
+	VmStateRunning    = "Running"
+	VmStateShutdown   = "Shutdown"
+	VmStatePaused     = "Paused"
+	VmStateBreakPoint = "BreakPoint"
+)
+
+// The modes of the serial port and console devices.
+const (
+	ConsoleModeOff  = "Off"
+	ConsoleModeFile = "File"
+	ConsoleModeTty  = "Tty"
+	ConsoleModePty  = "Pty"
+)
+
+// CpusConfig represents the virtual CPUs of the virtual machine.
+type CpusConfig struct {
+	BootVcpus int64 `json:"boot_vcpus"`
+	MaxVcpus  int64 `json:"max_vcpus"`
+}
+
+// MemoryConfig represents the memory of the virtual machine.
+type MemoryConfig struct {
+	// Size of the memory in bytes.
+	Size int64 `json:"size"`
+
+	// Shared maps the memory as shared, which is necessary for vhost-user
+	// devices such as virtio-fs.
+	Shared bool `json:"shared,omitempty"`
+}
+
+// PayloadConfig represents what the virtual machine boots.
+type PayloadConfig struct {
+	Kernel    string `json:"kernel,omitempty"`
+	Cmdline   string `json:"cmdline,omitempty"`
+	Initramfs string `json:"initramfs,omitempty"`
+}
+
+// NetConfig represents a virtio-net device backed by a tap device on the
+// host.
+type NetConfig struct {
+	Id  string `json:"id,omitempty"`
+	Tap string `json:"tap,omitempty"`
+	Mac string `json:"mac,omitempty"`
+}
+
+// FsConfig represents a virtio-fs device backed by a vhost-user daemon on the
+// host.
+type FsConfig struct {
+	Id        string `json:"id,omitempty"`
+	Tag       string `json:"tag"`
+	Socket    string `json:"socket"`
+	NumQueues int64  `json:"num_queues"`
+	QueueSize int64  `json:"queue_size"`
+}
+
+// ConsoleConfig represents where the output of the serial port or console
+// device is directed to.
+type ConsoleConfig struct {
+	Mode string `json:"mode"`
+	File string `json:"file,omitempty"`
+}
+
+// VmConfig represents the configuration of the virtual machine.
+type VmConfig struct {
+	Cpus    *CpusConfig    `json:"cpus,omitempty"`
+	Memory  *MemoryConfig  `json:"memory,omitempty"`
+	Payload *PayloadConfig `json:"payload"`
+	Net     []NetConfig    `json:"net,omitempty"`
+	Fs      []FsConfig     `json:"fs,omitempty"`
+	Serial  *ConsoleConfig `json:"serial,omitempty"`
+	Console *ConsoleConfig `json:"console,omitempty"`
+}
+
+// VmInfo represents the configuration and state of the virtual machine.
+type VmInfo struct {
+	Config           VmConfig `json:"config"`
+	State            string   `json:"state"`
+	MemoryActualSize int64    `json:"memory_actual_size,omitempty"`
+}
+
+// VmSnapshotConfig represents where a snapshot of the virtual machine is
+// written to.
+type VmSnapshotConfig struct {
+	DestinationUrl string `json:"destination_url"`
+}
+
+// RestoreConfig represents where a snapshot of the virtual machine is read
+// from.
+type RestoreConfig struct {
+	SourceUrl string `json:"source_url"`
+}
+
+// apiClient is a minimal client of the Cloud Hypervisor REST API which is
+// served over a unix domain socket.
+type apiClient struct {
+	http *http.Client
+}
+
+// newAPIClient returns a client for the REST API served at the provided
+// socket.
+func newAPIClient(socketPath string, timeout time.Duration) *apiClient {
+	return &apiClient{
+		http: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// do performs a request against the provided endpoint of the REST API.  If
+// body is non-nil it is sent as JSON and if out is non-nil the response is
+// decoded into it.
+func (client *apiClient) do(ctx context.Context, method, endpoint string, body, out any) error {
+	var reader io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("could not marshal %s request: %v", endpoint, err)
+		}
+
+		reader = bytes.NewReader(b)
+	}
+
+	// The host is ignored since the connection is made to the unix socket.
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost/api/v1/"+endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("could not prepare %s request: %v", endpoint, err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not perform %s request: %v", endpoint, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s request failed with %s: %s", endpoint, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not decode %s response: %v", endpoint, err)
+	}
+
+	return nil
+}
+
+// Ping checks whether the VMM is responsive.
+func (client *apiClient) Ping(ctx context.Context) error {
+	return client.do(ctx, http.MethodGet, "vmm.ping", nil, nil)
+}
+
+// ShutdownVMM terminates the VMM process.
+func (client *apiClient) ShutdownVMM(ctx context.Context) error {
+	return client.do(ctx, http.MethodPut, "vmm.shutdown", nil, nil)
+}
+
+// CreateVM configures the virtual machine without booting it.
+func (client *apiClient) CreateVM(ctx context.Context, config *VmConfig) error {
+	return client.do(ctx, http.MethodPut, "vm.create", config, nil)
+}
+
+// BootVM boots the previously created virtual machine.
+func (client *apiClient) BootVM(ctx context.Context) error {
+	return client.do(ctx, http.MethodPut, "vm.boot", nil, nil)
+}
+
+// PauseVM pauses the virtual machine.
+func (client *apiClient) PauseVM(ctx context.Context) error {
+	return client.do(ctx, http.MethodPut, "vm.pause", nil, nil)
+}
+
+// ResumeVM resumes the paused virtual machine.
+func (client *apiClient) ResumeVM(ctx context.Context) error {
+	return client.do(ctx, http.MethodPut, "vm.resume", nil, nil)
+}
+
+// ShutdownVM shuts the virtual machine down.
+func (client *apiClient) ShutdownVM(ctx context.Context) error {
+	return client.do(ctx, http.MethodPut, "vm.shutdown", nil, nil)
+}
+
+// InfoVM returns the configuration and state of the virtual machine.
+func (client *apiClient) InfoVM(ctx context.Context) (*VmInfo, error) {
+	var info VmInfo
+	if err := client.do(ctx, http.MethodGet, "vm.info", nil, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// SnapshotVM writes a snapshot of the paused virtual machine to the provided
+// URL.
+func (client *apiClient) SnapshotVM(ctx context.Context, config *VmSnapshotConfig) error {
+	return client.do(ctx, http.MethodPut, "vm.snapshot", config, nil)
+}
+
+// RestoreVM restores the virtual machine from the snapshot at the provided
+// URL.  The VMM must not have a virtual machine created already.
+func (client *apiClient) RestoreVM(ctx context.Context, config *RestoreConfig) error {
+	return client.do(ctx, http.MethodPut, "vm.restore", config, nil)
+}
@@ -0,0 +1,24 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package cloudhypervisor
+
+// CloudHypervisorConfig represents the platform-specific state of a machine
+// which is managed by Cloud Hypervisor.
+type CloudHypervisorConfig struct {
+	// SocketPath is the path to the REST API socket of the VMM.
+	SocketPath string `json:"socket_path,omitempty"`
+
+	// LogPath is the path to the log file of the VMM itself.
+	LogPath string `json:"log_path,omitempty"`
+
+	// VirtiofsdPids are the process IDs of the virtio-fs daemons which serve
+	// the machine's volumes.
+	VirtiofsdPids []int `json:"virtiofsd_pids,omitempty"`
+
+	// PortForwardIfName is the host interface via which published ports are
+	// forwarded to PortForwardIP, the address of the machine on its network.
+	PortForwardIfName string `json:"port_forward_ifname,omitempty"`
+	PortForwardIP     string `json:"port_forward_ip,omitempty"`
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package cloudhypervisor
+
+// ExecConfig represents the command-line arguments for the Cloud Hypervisor
+// binary.  Only the arguments necessary to start the VMM are represented, the
+// virtual machine itself is configured via the REST API.
+type ExecConfig struct {
+	// Path to the unix domain socket used by the REST API.
+	ApiSocket string `flag:"--api-socket"`
+
+	// Path to the log file of the VMM.
+	LogFile string `flag:"--log-file"`
+
+	// Increase the verbosity of the VMM's log.
+	Verbose bool `flag:"-v"`
+}
+
+// VirtiofsdExecConfig represents the command-line arguments for the virtio-fs
+// daemon which serves a host directory to the virtual machine.
+type VirtiofsdExecConfig struct {
+	// Path to the vhost-user socket which the VMM connects to.
+	SocketPath string `flag:"--socket-path"`
+
+	// The directory on the host which is shared.
+	SharedDir string `flag:"--shared-dir"`
+
+	// The caching policy of the shared directory.
+	Cache string `flag:"--cache"`
+
+	// The sandboxing mechanism which the daemon uses.
+	Sandbox string `flag:"--sandbox"`
+}
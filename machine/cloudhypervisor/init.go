@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package cloudhypervisor
+
+import "encoding/gob"
+
+func init() {
+	gob.Register(CloudHypervisorConfig{})
+}
@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package cloudhypervisor
+
+import (
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/machine/network/iptables"
+)
+
+// releasePorts removes the host-side forwarding of the machine's published
+// ports, if it has any.
+func releasePorts(machine *machinev1alpha1.Machine, chcfg *CloudHypervisorConfig) error {
+	if chcfg.PortForwardIfName == "" {
+		return nil
+	}
+
+	return iptables.RemoveRules(string(machine.ObjectMeta.UID))
+}
@@ -0,0 +1,925 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package cloudhypervisor
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	zip "api.zip"
+	"github.com/acorn-io/baaah/pkg/merr"
+	"github.com/fsnotify/fsnotify"
+	goprocess "github.com/shirou/gopsutil/v3/process"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/config"
+	"kraftkit.sh/exec"
+	"kraftkit.sh/internal/logtail"
+	"kraftkit.sh/internal/run"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network/daemon"
+	"kraftkit.sh/machine/network/iptables"
+	"kraftkit.sh/machine/network/macaddr"
+	"kraftkit.sh/machine/stats"
+	"kraftkit.sh/unikraft/export/v0/ukargparse"
+	"kraftkit.sh/unikraft/export/v0/uknetdev"
+	"kraftkit.sh/unikraft/export/v0/vfscore"
+)
+
+const (
+	CloudHypervisorBin  = "cloud-hypervisor"
+	VirtiofsdBin        = "virtiofsd"
+	DefaultClientTimout = time.Second * 5
+	DefaultPollInterval = time.Millisecond * 500
+)
+
+// machineV1alpha1Service ...
+type machineV1alpha1Service struct {
+	timeout      time.Duration
+	pollInterval time.Duration
+	debug        bool
+}
+
+// NewMachineV1alpha1Service implements kraftkit.sh/machine/platform.NewStrategyConstructor
+func NewMachineV1alpha1Service(ctx context.Context, opts ...any) (machinev1alpha1.MachineService, error) {
+	service := machineV1alpha1Service{}
+
+	for _, opt := range opts {
+		qopt, ok := opt.(MachineServiceV1alpha1Option)
+		if !ok {
+			panic("cannot apply non-MachineServiceV1alpha1Option type methods")
+		}
+
+		if err := qopt(&service); err != nil {
+			return nil, err
+		}
+	}
+
+	if service.timeout == 0 {
+		service.timeout = DefaultClientTimout
+	}
+
+	if service.pollInterval == 0 {
+		service.pollInterval = DefaultPollInterval
+	}
+
+	return &service, nil
+}
+
+// getCloudHypervisorConfigFromPlatformConfig converts the provided
+// platformConfig interface into meaningful CloudHypervisorConfig.
+func getCloudHypervisorConfigFromPlatformConfig(platformConfig interface{}) (*CloudHypervisorConfig, error) {
+	chcfgptr, ok := platformConfig.(*CloudHypervisorConfig)
+	if ok {
+		return chcfgptr, nil
+	}
+
+	chcfg, ok := platformConfig.(CloudHypervisorConfig)
+	if ok {
+		return &chcfg, nil
+	}
+
+	return nil, fmt.Errorf("could not cast cloud hypervisor platform config from store")
+}
+
+// spawnAndWaitForSocket starts the provided detached process and waits until
+// it has created the socket at the provided path, which indicates that it is
+// ready to accept connections.  Waiting fails if the process exits before or
+// has not created the socket once the provided timeout elapses.
+func spawnAndWaitForSocket(ctx context.Context, process *exec.Process, socketPath string, timeout time.Duration) (int, error) {
+	// Pre-emptively prepare inotify on the directory of the socket so that its
+	// creation is not missed.
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return 0, err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(socketPath)); err != nil {
+		return 0, err
+	}
+
+	if err := process.Start(ctx); err != nil {
+		return 0, err
+	}
+
+	pid, err := process.Pid()
+	if err != nil {
+		return 0, err
+	}
+
+	// Reaping the process also reveals whether it has exited prematurely.
+	exited := make(chan error, 1)
+	go func() {
+		exited <- process.Wait()
+	}()
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return pid, fmt.Errorf("stopped watching for socket %s", socketPath)
+			}
+			if event.Name == socketPath {
+				return pid, nil
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return pid, fmt.Errorf("stopped watching for socket %s", socketPath)
+			}
+			return pid, err
+		case err := <-exited:
+			if err == nil {
+				err = fmt.Errorf("exit status 0")
+			}
+			return pid, fmt.Errorf("process exited before creating socket %s: %v", socketPath, err)
+		case <-deadline.C:
+			return pid, fmt.Errorf("timed out after %s waiting for socket %s", timeout, socketPath)
+		case <-ctx.Done():
+			return pid, ctx.Err()
+		}
+	}
+}
+
+// Create implements kraftkit.sh/api/machine/v1alpha1.MachineService.Create
+func (service *machineV1alpha1Service) Create(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	if machine.Status.KernelPath == "" {
+		return machine, fmt.Errorf("cannot create cloud hypervisor instance without kernel")
+	}
+
+	if machine.Spec.Emulation {
+		return machine, fmt.Errorf("cannot create cloud hypervisor instance with emulation")
+	}
+
+	if machine.Spec.GDBPort > 0 {
+		return machine, fmt.Errorf("cannot create cloud hypervisor instance with a GDB server: not supported")
+	}
+
+	// Cloud Hypervisor only provides virtio devices over PCI.
+	if machine.Spec.Transport == machinev1alpha1.MachineTransportMMIO || machine.Spec.MicroVM {
+		return machine, fmt.Errorf("cannot create cloud hypervisor instance without PCI transport: not supported")
+	} else if !machine.Spec.NetworkModel.IsVirtio() {
+		return machine, fmt.Errorf("cannot create cloud hypervisor instance with network model %s: not supported", machine.Spec.NetworkModel)
+	}
+
+	// Cloud Hypervisor does not offer user-mode networking, so published ports
+	// are forwarded by the host to the address of the machine on its network.
+	var portForwardIfName, portForwardIP string
+	if len(machine.Spec.Ports) > 0 {
+		portForwardIfName, portForwardIP = iptables.MachineAddress(machine)
+		if portForwardIP == "" {
+			return machine, fmt.Errorf("cannot publish ports of cloud hypervisor instance: attach it to a network with an IPv4 address instead")
+		}
+	}
+
+	if machine.ObjectMeta.UID == "" {
+		machine.ObjectMeta.UID = uuid.NewUUID()
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateUnknown
+
+	if len(machine.Status.StateDir) == 0 {
+		machine.Status.StateDir = filepath.Join(config.G[config.KraftKit](ctx).RuntimeDir, string(machine.ObjectMeta.UID))
+	}
+
+	if err := os.MkdirAll(machine.Status.StateDir, fs.ModeSetgid|0o775); err != nil {
+		return machine, err
+	}
+
+	// Set and create the log file for this machine
+	if len(machine.Status.LogFile) == 0 {
+		machine.Status.LogFile = filepath.Join(machine.Status.StateDir, "machine.log")
+	}
+
+	if machine.Spec.Resources.Requests == nil {
+		machine.Spec.Resources.Requests = make(corev1.ResourceList, 2)
+	}
+
+	if machine.Spec.Resources.Requests.Memory().Value() == 0 {
+		quantity, err := resource.ParseQuantity("64Mi")
+		if err != nil {
+			machine.Status.State = machinev1alpha1.MachineStateFailed
+			return machine, err
+		}
+
+		machine.Spec.Resources.Requests[corev1.ResourceMemory] = quantity
+	}
+
+	if machine.Spec.Resources.Requests.Cpu().Value() == 0 {
+		quantity, err := resource.ParseQuantity("1")
+		if err != nil {
+			machine.Status.State = machinev1alpha1.MachineStateFailed
+			return machine, err
+		}
+
+		machine.Spec.Resources.Requests[corev1.ResourceCPU] = quantity
+	}
+
+	chcfg := CloudHypervisorConfig{
+		SocketPath:        filepath.Join(machine.Status.StateDir, "cloudhypervisor.sock"),
+		LogPath:           filepath.Join(machine.Status.StateDir, "cloudhypervisor.log"),
+		PortForwardIfName: portForwardIfName,
+		PortForwardIP:     portForwardIP,
+	}
+
+	machine.Status.PlatformConfig = &chcfg
+
+	var err error
+
+	// Stop the VMM and any virtiofsd daemons which have been started if the
+	// machine could not be fully created, e.g. because the VMM rejected its
+	// configuration.
+	defer func() {
+		if machine.Status.State == machinev1alpha1.MachineStateCreated {
+			return
+		}
+
+		machine.Status.State = machinev1alpha1.MachineStateFailed
+
+		for _, pid := range chcfg.VirtiofsdPids {
+			if err := terminate(int32(pid)); err != nil {
+				log.G(ctx).Warnf("could not terminate virtiofsd: %v", err)
+			}
+		}
+
+		if machine.Status.Pid > 0 {
+			if err := terminate(machine.Status.Pid); err != nil {
+				log.G(ctx).Warnf("could not terminate cloud hypervisor: %v", err)
+			}
+		}
+	}()
+
+	e, err := exec.NewExecutable(CloudHypervisorBin, ExecConfig{
+		ApiSocket: chcfg.SocketPath,
+		LogFile:   chcfg.LogPath,
+		Verbose:   service.debug,
+	})
+	if err != nil {
+		return machine, fmt.Errorf("could not prepare cloud hypervisor executable: %v", err)
+	}
+
+	process, err := exec.NewProcessFromExecutable(e,
+		exec.WithDetach(true),
+	)
+	if err != nil {
+		return machine, fmt.Errorf("could not prepare cloud hypervisor process: %v", err)
+	}
+
+	machine.CreationTimestamp = metav1.NewTime(time.Now())
+
+	pid, err := spawnAndWaitForSocket(ctx, process, chcfg.SocketPath, service.timeout)
+	machine.Status.Pid = int32(pid)
+	if err != nil {
+		return machine, fmt.Errorf("could not start and wait for cloud hypervisor process: %v", err)
+	}
+
+	// A snapshot can only be restored into a VMM without a virtual machine, the
+	// configuration of the machine is instead restored from the snapshot itself,
+	// see Restore.
+	if len(machine.Spec.RestoreFrom) > 0 {
+		machine.Status.State = machinev1alpha1.MachineStateCreated
+		return machine, nil
+	}
+
+	vmcfg := VmConfig{
+		Cpus: &CpusConfig{
+			BootVcpus: machine.Spec.Resources.Requests.Cpu().Value(),
+			MaxVcpus:  machine.Spec.Resources.Requests.Cpu().Value(),
+		},
+		Memory: &MemoryConfig{
+			Size: machine.Spec.Resources.Requests.Memory().Value(),
+		},
+		Payload: &PayloadConfig{
+			Kernel:    machine.Status.KernelPath,
+			Initramfs: machine.Status.InitrdPath,
+		},
+		Serial: &ConsoleConfig{
+			Mode: ConsoleModeFile,
+			File: machine.Status.LogFile,
+		},
+		Console: &ConsoleConfig{
+			Mode: ConsoleModeOff,
+		},
+	}
+
+	kernelArgs, err := ukargparse.Parse(machine.Spec.KernelArgs...)
+	if err != nil {
+		return machine, err
+	}
+
+	if len(machine.Spec.Networks) > 0 {
+		// Start MAC addresses iteratively.  Each interface will have the last
+		// hexdecimal byte increase by 1 starting at 1, allowing for easy-to-spot
+		// interface IDs from the MAC address.  The return value below returns `:00`
+		// as the last byte.
+		startMac, err := macaddr.GenerateMacAddress(true)
+		if err != nil {
+			return machine, err
+		}
+
+		i := 0 // host network ID.
+
+		// Iterate over each interface of each network interface associated with
+		// this machine and attach it as a device.
+		for _, network := range machine.Spec.Networks {
+			for _, iface := range network.Interfaces {
+				mac := iface.Spec.MacAddress
+				if mac == "" {
+					// Increase the MAC address value by 1 such that we are able to
+					// identify interface IDs.
+					startMac = macaddr.IncrementMacAddress(startMac)
+					mac = startMac.String()
+				}
+
+				vmcfg.Net = append(vmcfg.Net, NetConfig{
+					Id:  fmt.Sprintf("hostnet%d", i),
+					Tap: iface.Spec.IfName,
+					Mac: mac,
+				})
+
+				// Assign the first interface statically via command-line arguments, also
+				// checking if the built-in arguments for
+				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && !kernelArgs.Contains(uknetdev.ParamIp) && i == 0 {
+					ipv4, err := network.IPv4Subnet()
+					if err != nil {
+						return machine, err
+					}
+
+					nameserver, domain := daemon.Resolver(network)
+
+					kernelArgs = append(kernelArgs, uknetdev.StaticParams(
+						net.ParseIP(iface.Spec.IP), ipv4,
+						nameserver, domain,
+						machine.Spec.LegacyNetdevParams,
+					)...)
+				}
+
+				// Increment the host network ID for additional interfaces.
+				i++
+			}
+		}
+	}
+
+	var fstab []string
+
+	// Cloud Hypervisor does not support 9pfs, so host directories can only be
+	// shared via virtio-fs, each served by a dedicated daemon.
+	for i, vol := range machine.Spec.Volumes {
+		switch vol.Spec.Driver {
+		case "virtiofs":
+			mounttag := fmt.Sprintf("fs%d", i+1)
+			socketPath := filepath.Join(machine.Status.StateDir, mounttag+".sock")
+
+			e, err := exec.NewExecutable(VirtiofsdBin, VirtiofsdExecConfig{
+				SocketPath: socketPath,
+				SharedDir:  vol.Spec.Source,
+				Cache:      "never",
+				Sandbox:    "none",
+			})
+			if err != nil {
+				return machine, fmt.Errorf("could not prepare virtiofsd executable: %v", err)
+			}
+
+			process, err := exec.NewProcessFromExecutable(e,
+				exec.WithDetach(true),
+			)
+			if err != nil {
+				return machine, fmt.Errorf("could not prepare virtiofsd process: %v", err)
+			}
+
+			pid, err := spawnAndWaitForSocket(ctx, process, socketPath, service.timeout)
+			if pid > 0 {
+				chcfg.VirtiofsdPids = append(chcfg.VirtiofsdPids, pid)
+			}
+			if err != nil {
+				return machine, fmt.Errorf("could not share volume %s: %v", vol.Spec.Source, err)
+			}
+
+			vmcfg.Fs = append(vmcfg.Fs, FsConfig{
+				Id:        mounttag,
+				Tag:       mounttag,
+				Socket:    socketPath,
+				NumQueues: 1,
+				QueueSize: 1024,
+			})
+
+			fstab = append(fstab, vfscore.NewFstabEntry(
+				mounttag,
+				vol.Spec.Destination,
+				"virtiofs",
+				"",
+				"",
+				// By default, create the directory if it does not exist when mounting.
+				"mkpath",
+			).String())
+
+		case "initrd":
+			fstab = append(fstab, vfscore.NewFstabEntry(
+				"initrd",
+				vol.Spec.Destination,
+				vol.Spec.Driver,
+				"",
+				"",
+				// By default, create the directory if it does not exist when mounting.
+				"mkpath",
+			).String())
+
+		default:
+			return machine, fmt.Errorf("unsupported cloud hypervisor volume driver: %v", vol.Spec.Driver)
+		}
+	}
+
+	// vhost-user devices require the memory of the guest to be shared with the
+	// daemon.
+	vmcfg.Memory.Shared = len(vmcfg.Fs) > 0
+
+	if len(fstab) > 0 {
+		kernelArgs = append(kernelArgs,
+			vfscore.ParamVfsFstab.WithValue(fstab),
+		)
+	}
+
+	// Kernel arguments are separated from the application arguments by "--", as
+	// is standard for Unikraft.
+	args := kernelArgs.Strings()
+	if len(args) > 0 {
+		args = append(args, "--")
+	}
+	args = append(args, filepath.Base(machine.Status.KernelPath))
+	args = append(args, machine.Spec.ApplicationArgs...)
+
+	vmcfg.Payload.Cmdline = run.BootArgsPrepare(args...)
+
+	if chcfg.PortForwardIP != "" {
+		// Clean up if the machine could not be fully created.
+		defer func() {
+			if err != nil {
+				if err := releasePorts(machine, &chcfg); err != nil {
+					log.G(ctx).Warnf("could not release published ports: %v", err)
+				}
+			}
+		}()
+
+		if err = iptables.AddPortForwards(
+			string(machine.ObjectMeta.UID),
+			chcfg.PortForwardIfName,
+			iptables.PortForwardsFromMachinePorts(machine.Spec.Ports, chcfg.PortForwardIP)...,
+		); err != nil {
+			return machine, fmt.Errorf("could not forward ports: %v", err)
+		}
+	}
+
+	client := newAPIClient(chcfg.SocketPath, service.timeout)
+
+	if err = client.CreateVM(ctx, &vmcfg); err != nil {
+		return machine, fmt.Errorf("could not create cloud hypervisor instance: %v", err)
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateCreated
+
+	return machine, nil
+}
+
+// Update implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Update(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	return machine, fmt.Errorf("cannot update cloud hypervisor instance: not supported")
+}
+
+// Watch implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Watch(ctx context.Context, machine *machinev1alpha1.Machine) (chan *machinev1alpha1.Machine, chan error, error) {
+	if _, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig); err != nil {
+		return nil, nil, err
+	}
+
+	events := make(chan *machinev1alpha1.Machine)
+	errs := make(chan error)
+
+	// The REST API does not emit events, so instead the state of the machine is
+	// polled and an event is sent whenever it changes.  Polling ends, closing
+	// both channels, once the machine has exited or its state can no longer be
+	// determined.
+	go func() {
+		defer close(events)
+		defer close(errs)
+
+		ticker := time.NewTicker(service.pollInterval)
+		defer ticker.Stop()
+
+		var lastState machinev1alpha1.MachineState
+
+		for {
+			machine, err := service.Get(ctx, machine)
+			if err != nil {
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
+
+				return
+			} else if machine.Status.State != lastState {
+				lastState = machine.Status.State
+
+				select {
+				case events <- machine:
+				case <-ctx.Done():
+					return
+				}
+
+				if lastState == machinev1alpha1.MachineStateExited ||
+					lastState == machinev1alpha1.MachineStateFailed {
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events, errs, nil
+}
+
+// Start implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	chcfg, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := newAPIClient(chcfg.SocketPath, service.timeout)
+
+	// A virtual machine can only be booted once, after which it can only be
+	// resumed from a paused state.
+	info, err := client.InfoVM(ctx)
+	if err != nil {
+		return machine, fmt.Errorf("could not query machine status via API socket: %v", err)
+	}
+
+	if info.State == VmStatePaused {
+		if err := client.ResumeVM(ctx); err != nil {
+			return machine, fmt.Errorf("could not resume cloud hypervisor instance: %v", err)
+		}
+	} else if err := client.BootVM(ctx); err != nil {
+		return machine, fmt.Errorf("could not boot cloud hypervisor instance: %v", err)
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateRunning
+	if machine.Status.StartedAt.IsZero() {
+		machine.Status.StartedAt = time.Now()
+	}
+
+	return machine, nil
+}
+
+// Pause implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Pause(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	chcfg, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := newAPIClient(chcfg.SocketPath, service.timeout)
+
+	if err := client.PauseVM(ctx); err != nil {
+		return machine, fmt.Errorf("could not pause cloud hypervisor instance: %v", err)
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStatePaused
+
+	return machine, nil
+}
+
+// Logs implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Logs(ctx context.Context, machine *machinev1alpha1.Machine) (chan string, chan error, error) {
+	return logtail.NewLogTail(ctx, machine.Status.LogFile)
+}
+
+// Get implements kraftkit.sh/api/machine/v1alpha1/MachineService.Get
+func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	state := machinev1alpha1.MachineStateUnknown
+	savedState := machine.Status.State
+
+	chcfg, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	// Check if the process is alive, which ultimately indicates to us whether we
+	// able to speak to the exposed API socket
+	activeProcess := false
+	if process, err := goprocess.NewProcess(machine.Status.Pid); err == nil {
+		activeProcess, err = process.IsRunning()
+		if err != nil {
+			state = machinev1alpha1.MachineStateExited
+		}
+	}
+
+	exitedAt := machine.Status.ExitedAt
+	exitCode := machine.Status.ExitCode
+
+	defer func() {
+		if exitCode >= 0 && machine.Status.ExitedAt.IsZero() {
+			exitedAt = time.Now()
+		}
+
+		// Update the machine config with the latest values if they are different from
+		// what we have on record
+		if machine.Status.ExitedAt != exitedAt || machine.Status.ExitCode != exitCode {
+			machine.Status.ExitedAt = exitedAt
+			machine.Status.ExitCode = exitCode
+		}
+
+		// Set the start time to now if it was not previously set
+		if machine.Status.StartedAt.IsZero() && state == machinev1alpha1.MachineStateRunning {
+			machine.Status.StartedAt = time.Now()
+		}
+
+		// Finally, save the state if it is different from the what we have on
+		// record
+		if state != savedState {
+			machine.Status.State = state
+		}
+	}()
+
+	if !activeProcess {
+		state = machinev1alpha1.MachineStateExited
+		if savedState == machinev1alpha1.MachineStateRunning {
+			exitCode = 1
+		}
+		return machine, nil
+	}
+
+	// A VMM which is waiting for a snapshot to be restored into it does not yet
+	// have a virtual machine to query.
+	if savedState == machinev1alpha1.MachineStateCreated && len(machine.Spec.RestoreFrom) > 0 {
+		state = savedState
+		return machine, nil
+	}
+
+	client := newAPIClient(chcfg.SocketPath, service.timeout)
+
+	// Grab the actual state of the machine by querying the API socket
+	info, err := client.InfoVM(ctx)
+	if err != nil {
+		// We cannot amend the status at this point, even if the process is
+		// alive, since it is not an indicator of the state of the VM, only of the
+		// VMM.  So we return what we already know via LookupMachineConfig.
+		state = savedState
+		return machine, fmt.Errorf("could not query machine status via API socket: %v", err)
+	}
+
+	// Map the Cloud Hypervisor state to supported machine states
+	switch info.State {
+	case VmStateCreated:
+		state = machinev1alpha1.MachineStateCreated
+		exitCode = -1
+
+	case VmStateRunning:
+		state = machinev1alpha1.MachineStateRunning
+		exitCode = -1
+
+	case VmStatePaused, VmStateBreakPoint:
+		state = machinev1alpha1.MachineStatePaused
+		exitCode = -1
+
+	case VmStateShutdown:
+		state = machinev1alpha1.MachineStateExited
+		exitCode = 0
+	}
+
+	return machine, nil
+}
+
+// List implements kraftkit.sh/api/machine/v1alpha1.MachineService.List
+func (service *machineV1alpha1Service) List(ctx context.Context, machines *machinev1alpha1.MachineList) (*machinev1alpha1.MachineList, error) {
+	cached := machines.Items
+	machines.Items = []zip.Object[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus]{}
+
+	// Iterate through each machine and grab the latest status
+	for _, machine := range cached {
+		machine, err := service.Get(ctx, &machine)
+		if err != nil {
+			machines.Items = cached
+			return machines, err
+		}
+
+		machines.Items = append(machines.Items, *machine)
+	}
+
+	return machines, nil
+}
+
+// terminate sends a termination signal to the process with the provided pid if
+// it is still running.
+func terminate(pid int32) error {
+	process, err := goprocess.NewProcess(pid)
+	if err != nil {
+		// The process has already exited.
+		return nil
+	}
+
+	if running, err := process.IsRunning(); err != nil || !running {
+		return nil
+	}
+
+	return process.Terminate()
+}
+
+// Stop implements kraftkit.sh/api/machine/v1alpha1.MachineService.Stop
+func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	chcfg, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	var errs merr.Errors
+
+	if machine.Status.State != machinev1alpha1.MachineStateExited {
+		// Ask the VMM to exit gracefully, falling back to terminating it.
+		client := newAPIClient(chcfg.SocketPath, service.timeout)
+		if err := client.ShutdownVMM(ctx); err != nil {
+			errs = append(errs, terminate(machine.Status.Pid))
+		}
+	}
+
+	for _, pid := range chcfg.VirtiofsdPids {
+		errs = append(errs, terminate(int32(pid)))
+	}
+
+	errs = append(errs, releasePorts(machine, chcfg))
+
+	if err := errs.Err(); err != nil {
+		return machine, err
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateExited
+	machine.Status.ExitedAt = time.Now()
+
+	return machine, nil
+}
+
+// Delete implements kraftkit.sh/api/machine/v1alpha1.MachineService.Delete
+func (service *machineV1alpha1Service) Delete(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	chcfg, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	var errs merr.Errors
+
+	if machine.Status.State != machinev1alpha1.MachineStateExited {
+		if _, err := service.Stop(ctx, machine); err != nil {
+			errs = append(errs, err)
+		}
+	} else {
+		errs = append(errs, releasePorts(machine, chcfg))
+	}
+
+	if err := os.RemoveAll(machine.Status.StateDir); err != nil {
+		errs = append(errs, fmt.Errorf("error deleting cloud hypervisor's state directory %s: %w", machine.Status.StateDir, err))
+	}
+
+	return nil, errs.Err()
+}
+
+// Snapshot implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Snapshot(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	// Tap devices and virtio-fs sockets are recorded by name and path in the
+	// snapshot and would therefore collide with the original machine's.
+	if len(machine.Spec.Networks) > 0 {
+		return machine, fmt.Errorf("cannot snapshot cloud hypervisor instance with network devices")
+	}
+	for _, vol := range machine.Spec.Volumes {
+		if vol.Spec.Driver != "initrd" {
+			return machine, fmt.Errorf("cannot snapshot cloud hypervisor instance with %s volume: %s", vol.Spec.Driver, vol.Spec.Source)
+		}
+	}
+
+	chcfg, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := newAPIClient(chcfg.SocketPath, service.timeout)
+
+	// Cloud Hypervisor only supports snapshotting paused instances.
+	running := machine.Status.State == machinev1alpha1.MachineStateRunning
+	if running {
+		if _, err := service.Pause(ctx, machine); err != nil {
+			return machine, err
+		}
+	}
+
+	snapshot, err := machinev1alpha1.NewMachineSnapshot(machine)
+	if err != nil {
+		return machine, err
+	}
+
+	// The state and memory are written into the snapshot's directory itself.
+	snapshot.StatePath = snapshot.Path
+
+	if err := client.SnapshotVM(ctx, &VmSnapshotConfig{
+		DestinationUrl: "file://" + snapshot.StatePath,
+	}); err != nil {
+		return machine, fmt.Errorf("could not snapshot cloud hypervisor instance: %v", err)
+	}
+
+	if running {
+		if _, err := service.Start(ctx, machine); err != nil {
+			return machine, fmt.Errorf("could not resume machine after snapshot: %v", err)
+		}
+	}
+
+	if err := machinev1alpha1.SaveMachineSnapshotManifest(machine, snapshot); err != nil {
+		return machine, err
+	}
+
+	machine.Status.Snapshots = append(machine.Status.Snapshots, *snapshot)
+
+	return machine, nil
+}
+
+// Restore implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Restore(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	if len(machine.Spec.RestoreFrom) == 0 {
+		return machine, fmt.Errorf("cannot restore machine without snapshot")
+	}
+
+	manifest, err := machinev1alpha1.LoadMachineSnapshotManifest(machine.Spec.RestoreFrom)
+	if err != nil {
+		return machine, err
+	}
+
+	if manifest.Snapshot.Platform != machine.Spec.Platform {
+		return machine, fmt.Errorf("cannot restore %s snapshot with %s platform", manifest.Snapshot.Platform, machine.Spec.Platform)
+	}
+
+	chcfg, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := newAPIClient(chcfg.SocketPath, service.timeout)
+
+	if err := client.RestoreVM(ctx, &RestoreConfig{
+		SourceUrl: "file://" + manifest.Snapshot.StatePath,
+	}); err != nil {
+		return machine, fmt.Errorf("could not restore cloud hypervisor instance: %v", err)
+	}
+
+	// The machine remains paused until it is started.
+	machine.Status.State = machinev1alpha1.MachineStatePaused
+
+	return machine, nil
+}
+
+// Stats implements kraftkit.sh/api/machine/v1alpha1.MachineService.Stats
+func (service *machineV1alpha1Service) Stats(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	chcfg, err := getCloudHypervisorConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	usage := machinev1alpha1.MachineResourceUsage{}
+
+	if err := stats.Process(ctx, machine.Status.Pid, &usage); err != nil {
+		return machine, err
+	}
+
+	client := newAPIClient(chcfg.SocketPath, service.timeout)
+
+	info, err := client.InfoVM(ctx)
+	if err != nil {
+		return machine, fmt.Errorf("could not query cloud hypervisor instance: %v", err)
+	}
+
+	if info.Config.Memory != nil {
+		usage.GuestMemory = info.Config.Memory.Size
+	}
+	if info.MemoryActualSize > 0 {
+		usage.GuestMemory = info.MemoryActualSize
+	}
+
+	usage.Interfaces = stats.Interfaces(machine)
+
+	machine.Status.Usage = usage
+
+	return machine, nil
+}
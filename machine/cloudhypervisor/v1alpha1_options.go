@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package cloudhypervisor
+
+import "time"
+
+// MachineServiceV1alpha1Option represents an option-method handler for the
+// machinev1alpha1 service.
+type MachineServiceV1alpha1Option func(*machineV1alpha1Service) error
+
+// WithTimeout sets the time out when communicating with the Cloud Hypervisor
+// REST API socket.
+func WithTimeout(timeout time.Duration) MachineServiceV1alpha1Option {
+	return func(service *machineV1alpha1Service) error {
+		service.timeout = timeout
+		return nil
+	}
+}
+
+// WithDebug increases the verbosity of Cloud Hypervisor's internal log.
+func WithDebug(debug bool) MachineServiceV1alpha1Option {
+	return func(service *machineV1alpha1Service) error {
+		service.debug = debug
+		return nil
+	}
+}
+
+// WithPollInterval sets the interval at which the state of a machine is
+// queried when it is being watched.
+func WithPollInterval(interval time.Duration) MachineServiceV1alpha1Option {
+	return func(service *machineV1alpha1Service) error {
+		service.pollInterval = interval
+		return nil
+	}
+}
@@ -11,4 +11,28 @@ type FirecrackerConfig struct {
 	SocketPath string `json:"socketPath,omitempty"`
 	BootArgs   string `json:"bootArgs,omitempty"`
 	LogPath    string `json:"logPath,omitempty"`
+
+	// MetricsPath is the named pipe to which Firecracker emits its metrics.
+	MetricsPath string `json:"metricsPath,omitempty"`
+
+	// Balloon indicates whether a memory balloon device was attached to the
+	// machine at boot, which is a prerequisite for resizing its memory later.
+	Balloon bool `json:"balloon,omitempty"`
+
+	// BalloonMib is the current target size of the memory balloon in MiB.
+	BalloonMib int64 `json:"balloonMib,omitempty"`
+
+	// RxBandwidth and TxBandwidth are the bytes-per-second rate limits which are
+	// currently applied to each of the machine's network interfaces.
+	RxBandwidth int64 `json:"rxBandwidth,omitempty"`
+	TxBandwidth int64 `json:"txBandwidth,omitempty"`
+
+	// PortForwardIfName is the host interface via which published ports are
+	// forwarded to PortForwardIP, the address of the machine.
+	PortForwardIfName string `json:"portForwardIfName,omitempty"`
+	PortForwardIP     string `json:"portForwardIP,omitempty"`
+
+	// PortForwardTap is the name of the tap device which was created solely for
+	// the purpose of forwarding ports to the machine.
+	PortForwardTap string `json:"portForwardTap,omitempty"`
 }
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package firecracker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	firecracker "github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
+	"golang.org/x/sys/unix"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+// metricsTimeout is the duration to wait for Firecracker to emit its metrics
+// after they have been flushed.
+const metricsTimeout = time.Second
+
+// firecrackerNetMetrics is the subset of the metrics of a network device which
+// Firecracker emits as "net_<iface_id>".
+type firecrackerNetMetrics struct {
+	RxBytesCount   uint64 `json:"rx_bytes_count"`
+	RxPacketsCount uint64 `json:"rx_packets_count"`
+	TxBytesCount   uint64 `json:"tx_bytes_count"`
+	TxPacketsCount uint64 `json:"tx_packets_count"`
+}
+
+// createMetricsFifo creates the named pipe to which Firecracker emits its
+// metrics.  Firecracker opens the pipe for both reading and writing, such that
+// it never blocks on it, and emits its metrics periodically as well as when
+// they are explicitly flushed.
+func createMetricsFifo(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove stale metrics pipe: %v", err)
+	}
+
+	if err := unix.Mkfifo(path, 0o600); err != nil {
+		return fmt.Errorf("could not create metrics pipe: %v", err)
+	}
+
+	return nil
+}
+
+// readMetrics flushes the metrics of the Firecracker instance and returns the
+// freshly emitted set, indexed by their top-level key.
+func readMetrics(ctx context.Context, client *firecracker.Client, path string) (map[string]json.RawMessage, error) {
+	return readFlushedMetrics(path, func() error {
+		action := models.InstanceActionInfoActionTypeFlushMetrics
+		_, err := client.CreateSyncAction(ctx, &models.InstanceActionInfo{
+			ActionType: &action,
+		})
+		return err
+	})
+}
+
+// readFlushedMetrics discards the metrics which are pending in the pipe at the
+// provided path, calls the provided function to have them emitted afresh and
+// returns the first complete set which is read thereafter.
+func readFlushedMetrics(path string, flush func() error) (map[string]json.RawMessage, error) {
+	fifo, err := os.OpenFile(path, os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("could not open metrics pipe: %v", err)
+	}
+
+	defer fifo.Close()
+
+	// Discard metrics which were periodically emitted since they were last read.
+	if err := discardPending(fifo); err != nil {
+		return nil, fmt.Errorf("could not discard pending metrics: %v", err)
+	}
+
+	reader := bufio.NewReader(fifo)
+
+	if err := flush(); err != nil {
+		return nil, fmt.Errorf("could not flush metrics: %v", err)
+	}
+
+	if err := fifo.SetReadDeadline(time.Now().Add(metricsTimeout)); err != nil {
+		return nil, fmt.Errorf("could not read metrics: %v", err)
+	}
+
+	// Any partially discarded line precedes the flushed metrics, so skip lines
+	// until a complete set has been read.
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return nil, fmt.Errorf("could not read metrics: %v", err)
+		}
+
+		metrics := map[string]json.RawMessage{}
+		if err := json.Unmarshal(line, &metrics); err == nil {
+			return metrics, nil
+		}
+	}
+}
+
+// discardPending reads and discards all data which is pending in the provided
+// pipe, which must have been opened in non-blocking mode.  A read deadline does
+// not serve this purpose, since reads fail immediately once it has passed.
+func discardPending(fifo *os.File) error {
+	rc, err := fifo.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4096)
+
+	return rc.Read(func(fd uintptr) bool {
+		for {
+			if n, err := unix.Read(int(fd), buf); n <= 0 || err != nil {
+				return true
+			}
+		}
+	})
+}
+
+// interfacesFromMetrics returns the traffic of each network interface of the
+// machine as accounted for by Firecracker, whose device metrics are from the
+// perspective of the guest.
+func interfacesFromMetrics(metrics map[string]json.RawMessage) []machinev1alpha1.MachineInterfaceUsage {
+	var ret []machinev1alpha1.MachineInterfaceUsage
+
+	for key, raw := range metrics {
+		ifname, ok := strings.CutPrefix(key, "net_")
+		if !ok {
+			continue
+		}
+
+		var net firecrackerNetMetrics
+		if err := json.Unmarshal(raw, &net); err != nil {
+			continue
+		}
+
+		ret = append(ret, machinev1alpha1.MachineInterfaceUsage{
+			IfName:    ifname,
+			RxBytes:   net.RxBytesCount,
+			RxPackets: net.RxPacketsCount,
+			TxBytes:   net.TxBytesCount,
+			TxPackets: net.TxPacketsCount,
+		})
+	}
+
+	return ret
+}
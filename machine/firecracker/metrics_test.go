@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package firecracker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+// newTestMetricsFifo creates a metrics pipe and opens it for writing in the
+// same way as Firecracker, i.e. for both reading and writing, such that data
+// written to it is retained whilst it is not being read.
+func newTestMetricsFifo(t *testing.T) (string, *os.File) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "metrics.fifo")
+	if err := createMetricsFifo(path); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Could not open metrics pipe: %v", err)
+	}
+
+	t.Cleanup(func() { writer.Close() })
+
+	return path, writer
+}
+
+func TestReadFlushedMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		pending string
+		flushed string
+		want    []string
+	}{
+		{
+			name:    "flushed metrics",
+			flushed: `{"utc_timestamp_ms":2,"net_tap0":{"rx_bytes_count":10}}` + "\n",
+			want:    []string{"net_tap0", "utc_timestamp_ms"},
+		},
+		{
+			name:    "pending metrics are discarded",
+			pending: `{"stale":1}` + "\n" + `{"stale":2}` + "\n",
+			flushed: `{"fresh":3}` + "\n",
+			want:    []string{"fresh"},
+		},
+		{
+			name:    "remainder of partially discarded line is skipped",
+			pending: `{"stale":1}` + "\n" + `{"sta`,
+			flushed: `le":2}` + "\n" + `{"fresh":3}` + "\n",
+			want:    []string{"fresh"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, writer := newTestMetricsFifo(t)
+
+			if _, err := writer.WriteString(tt.pending); err != nil {
+				t.Fatal(err)
+			}
+
+			metrics, err := readFlushedMetrics(path, func() error {
+				_, err := writer.WriteString(tt.flushed)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Could not read metrics: %v", err)
+			}
+
+			var got []string
+			for key := range metrics {
+				got = append(got, key)
+			}
+
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected metrics %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReadFlushedMetricsErrors(t *testing.T) {
+	path, writer := newTestMetricsFifo(t)
+
+	if _, err := readFlushedMetrics(path, func() error {
+		return errors.New("no socket")
+	}); err == nil {
+		t.Error("Expected error when metrics cannot be flushed")
+	}
+
+	// Nothing is emitted, so reading times out.
+	if _, err := readFlushedMetrics(path, func() error {
+		return nil
+	}); err == nil {
+		t.Error("Expected error when no metrics are emitted")
+	}
+
+	// Incomplete metrics are not returned.
+	if _, err := readFlushedMetrics(path, func() error {
+		_, err := writer.WriteString(`{"net_tap0":`)
+		return err
+	}); err == nil {
+		t.Error("Expected error when incomplete metrics are emitted")
+	}
+
+	if _, err := readFlushedMetrics(filepath.Join(t.TempDir(), "missing"), func() error {
+		return nil
+	}); err == nil {
+		t.Error("Expected error without metrics pipe")
+	}
+}
+
+func TestInterfacesFromMetrics(t *testing.T) {
+	metrics := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(`{
+		"utc_timestamp_ms": 1700000000000,
+		"net": {"rx_bytes_count": 999},
+		"net_tap0": {"rx_bytes_count": 100, "rx_packets_count": 2, "tx_bytes_count": 300, "tx_packets_count": 4, "rx_fails": 0},
+		"net_kraftfc0123": {"rx_bytes_count": 5},
+		"net_invalid": "unexpected",
+		"block_rootfs": {"read_bytes": 10}
+	}`), &metrics); err != nil {
+		t.Fatal(err)
+	}
+
+	got := interfacesFromMetrics(metrics)
+	sort.Slice(got, func(i, j int) bool {
+		return got[i].IfName < got[j].IfName
+	})
+
+	want := []machinev1alpha1.MachineInterfaceUsage{
+		{IfName: "kraftfc0123", RxBytes: 5},
+		{IfName: "tap0", RxBytes: 100, RxPackets: 2, TxBytes: 300, TxPackets: 4},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/acorn-io/baaah/pkg/merr"
+	"github.com/vishvananda/netlink"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/machine/network/bridge"
+	"kraftkit.sh/machine/network/iptables"
+)
+
+// portForwardSubnet is the range from which point-to-point subnets are
+// allocated for machines which publish ports but are not attached to a
+// network.
+var portForwardSubnet = &net.IPNet{
+	IP:   net.IPv4(172, 30, 0, 0).To4(),
+	Mask: net.CIDRMask(16, 32),
+}
+
+// portForwardMask is the mask of each point-to-point subnet, leaving exactly
+// one usable address for the host and one for the machine.
+var portForwardMask = net.CIDRMask(30, 32)
+
+// portForwardTapName returns the name of the tap device which is dedicated to
+// forwarding ports to the provided machine.
+func portForwardTapName(machine *machinev1alpha1.Machine) string {
+	uid := strings.ReplaceAll(string(machine.ObjectMeta.UID), "-", "")
+	if len(uid) > 8 {
+		uid = uid[:8]
+	}
+
+	// Interface names are limited to 15 characters.
+	return "kraftfc" + uid
+}
+
+// allocatePortForwardSubnet returns the first point-to-point subnet within
+// portForwardSubnet which does not overlap with any address on the host.
+func allocatePortForwardSubnet() (*net.IPNet, error) {
+	addrs, err := netlink.AddrList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return nil, fmt.Errorf("could not list host addresses: %v", err)
+	}
+
+	used := make([]*net.IPNet, len(addrs))
+	for i, addr := range addrs {
+		used[i] = addr.IPNet
+	}
+
+	return nextPortForwardSubnet(used)
+}
+
+// nextPortForwardSubnet returns the first point-to-point subnet within
+// portForwardSubnet which overlaps with none of the provided subnets, where
+// the address of each is one in use on the host.
+func nextPortForwardSubnet(used []*net.IPNet) (*net.IPNet, error) {
+	subnet := &net.IPNet{
+		IP:   portForwardSubnet.IP,
+		Mask: portForwardMask,
+	}
+
+search:
+	for portForwardSubnet.Contains(subnet.IP) {
+		for _, addr := range used {
+			if subnet.Contains(addr.IP) || addr.Contains(subnet.IP) {
+				subnet = &net.IPNet{
+					IP:   bridge.IncreaseIP(bridge.IncreaseIP(bridge.IncreaseIP(bridge.IncreaseIP(subnet.IP)))),
+					Mask: portForwardMask,
+				}
+				continue search
+			}
+		}
+
+		return subnet, nil
+	}
+
+	return nil, fmt.Errorf("no free subnet in %s to forward ports", portForwardSubnet.String())
+}
+
+// createPortForwardTap creates a tap device for the machine whose host side is
+// assigned the first address of a newly allocated point-to-point subnet.  The
+// address which the machine should use is returned.
+func createPortForwardTap(name string) (*net.IPNet, net.IP, error) {
+	subnet, err := allocatePortForwardSubnet()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hostIP := bridge.IncreaseIP(subnet.IP)
+	guestIP := bridge.IncreaseIP(hostIP)
+
+	tap := &netlink.Tuntap{
+		LinkAttrs: netlink.NewLinkAttrs(),
+		Mode:      netlink.TUNTAP_MODE_TAP,
+	}
+	tap.Name = name
+
+	if err := netlink.LinkAdd(tap); err != nil {
+		return nil, nil, fmt.Errorf("could not create %s link: %v", name, err)
+	}
+
+	if err := netlink.AddrAdd(tap, &netlink.Addr{
+		IPNet: &net.IPNet{
+			IP:   hostIP,
+			Mask: subnet.Mask,
+		},
+	}); err != nil {
+		return nil, nil, fmt.Errorf("could not assign address to %s: %v", name, err)
+	}
+
+	if err := netlink.LinkSetUp(tap); err != nil {
+		return nil, nil, fmt.Errorf("could not bring %s link up: %v", name, err)
+	}
+
+	return &net.IPNet{IP: hostIP, Mask: subnet.Mask}, guestIP, nil
+}
+
+// deletePortForwardTap removes the tap device with the provided name if it
+// still exists.
+func deletePortForwardTap(name string) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		if errors.As(err, &netlink.LinkNotFoundError{}) {
+			return nil
+		}
+
+		return fmt.Errorf("could not get %s link: %v", name, err)
+	}
+
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("could not delete %s link: %v", name, err)
+	}
+
+	return nil
+}
+
+// releasePorts removes the host-side forwarding of the machine's published
+// ports as well as any tap device which was dedicated to it.
+func releasePorts(machine *machinev1alpha1.Machine, fccfg *FirecrackerConfig) error {
+	if fccfg.PortForwardIfName == "" {
+		return nil
+	}
+
+	var errs merr.Errors
+
+	errs = append(errs, iptables.RemoveRules(string(machine.ObjectMeta.UID)))
+
+	if fccfg.PortForwardTap != "" {
+		errs = append(errs, deletePortForwardTap(fccfg.PortForwardTap))
+	}
+
+	return errs.Err()
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package firecracker
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNextPortForwardSubnet(t *testing.T) {
+	parse := func(cidrs ...string) []*net.IPNet {
+		var subnets []*net.IPNet
+		for _, cidr := range cidrs {
+			ip, subnet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				t.Fatalf("Could not parse subnet: %v", err)
+			}
+
+			subnet.IP = ip
+			subnets = append(subnets, subnet)
+		}
+
+		return subnets
+	}
+
+	tests := []struct {
+		name    string
+		used    []*net.IPNet
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "no addresses",
+			want: "172.30.0.0/30",
+		},
+		{
+			name: "unrelated addresses",
+			used: parse("127.0.0.1/8", "192.168.1.10/24"),
+			want: "172.30.0.0/30",
+		},
+		{
+			name: "first subnet in use",
+			used: parse("172.30.0.1/30"),
+			want: "172.30.0.4/30",
+		},
+		{
+			name: "several subnets in use",
+			used: parse("172.30.0.1/30", "172.30.0.5/30", "172.30.0.13/30"),
+			want: "172.30.0.8/30",
+		},
+		{
+			name: "address within a larger subnet",
+			used: parse("172.30.0.2/30", "172.30.0.6/29"),
+			want: "172.30.0.8/30",
+		},
+		{
+			name: "subnet within a larger host subnet",
+			used: parse("172.30.0.200/24"),
+			want: "172.30.1.0/30",
+		},
+		{
+			name:    "range in use",
+			used:    parse("172.30.0.1/16"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextPortForwardSubnet(tt.used)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
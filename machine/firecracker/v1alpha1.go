@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
 	"time"
@@ -30,21 +31,35 @@ import (
 	"kraftkit.sh/internal/logtail"
 	"kraftkit.sh/internal/run"
 	"kraftkit.sh/log"
+	"kraftkit.sh/machine/cgroup"
+	"kraftkit.sh/machine/network/daemon"
+	"kraftkit.sh/machine/network/iptables"
 	"kraftkit.sh/machine/network/macaddr"
+	"kraftkit.sh/machine/shutdown"
+	"kraftkit.sh/machine/stats"
+	"kraftkit.sh/machine/volume/block"
 	"kraftkit.sh/unikraft/export/v0/ukargparse"
 	"kraftkit.sh/unikraft/export/v0/uknetdev"
+	"kraftkit.sh/unikraft/export/v0/vfscore"
 )
 
 const (
 	FirecrackerBin         = "firecracker"
 	DefaultClientTimout    = time.Second * 5
 	FirecrackerMemoryScale = 1024 * 1024
+	DefaultPollInterval    = time.Millisecond * 500
+
+	// firecrackerRefillTime is the period in milliseconds over which a token
+	// bucket is refilled.  Using one second means that the size of the bucket is
+	// equivalent to the bytes-per-second bandwidth of the rate limiter.
+	firecrackerRefillTime = 1000
 )
 
 // machineV1alpha1Service ...
 type machineV1alpha1Service struct {
-	timeout time.Duration
-	debug   bool
+	timeout      time.Duration
+	pollInterval time.Duration
+	debug        bool
 }
 
 // NewMachineV1alpha1Service implements mdriver.NewDriverConstructor
@@ -66,16 +81,16 @@ func NewMachineV1alpha1Service(ctx context.Context, opts ...any) (machinev1alpha
 		service.timeout = DefaultClientTimout
 	}
 
+	if service.pollInterval == 0 {
+		service.pollInterval = DefaultPollInterval
+	}
+
 	return &service, nil
 }
 
 // Create implements kraftkit.sh/api/machine/v1alpha1.MachineService.Create
 func (service *machineV1alpha1Service) Create(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
 	// Start with fail-safe checks for unsupported specification declarations.
-	if len(machine.Spec.Ports) > 0 {
-		return machine, fmt.Errorf("kraftkit does not yet support port forwarding to firecracker (contributions welcome): please use a network instead")
-	}
-
 	if machine.Status.KernelPath == "" {
 		return machine, fmt.Errorf("cannot create firecracker instance without kernel")
 	}
@@ -84,6 +99,17 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, fmt.Errorf("cannot create firecracker instance with emulation")
 	}
 
+	if machine.Spec.GDBPort > 0 {
+		return machine, fmt.Errorf("cannot create firecracker instance with a GDB server: not supported by firecracker")
+	}
+
+	// Firecracker only provides virtio devices over MMIO.
+	if machine.Spec.Transport == machinev1alpha1.MachineTransportPCI {
+		return machine, fmt.Errorf("cannot create firecracker instance with PCI transport: not supported by firecracker")
+	} else if !machine.Spec.NetworkModel.IsVirtio() {
+		return machine, fmt.Errorf("cannot create firecracker instance with network model %s: not supported by firecracker", machine.Spec.NetworkModel)
+	}
+
 	if machine.ObjectMeta.UID == "" {
 		machine.ObjectMeta.UID = uuid.NewUUID()
 	}
@@ -132,12 +158,17 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 	fi.Close()
 
 	fccfg := FirecrackerConfig{
-		SocketPath: filepath.Join(machine.Status.StateDir, "firecracker.sock"),
-		LogPath:    fcLogFile,
+		SocketPath:  filepath.Join(machine.Status.StateDir, "firecracker.sock"),
+		LogPath:     fcLogFile,
+		MetricsPath: filepath.Join(machine.Status.StateDir, "metrics.fifo"),
+	}
+
+	if err := createMetricsFifo(fccfg.MetricsPath); err != nil {
+		return machine, err
 	}
 
 	defer func() {
-		if err != nil {
+		if machine.Status.State != machinev1alpha1.MachineStateCreated {
 			machine.Status.State = machinev1alpha1.MachineStateFailed
 		}
 	}()
@@ -170,6 +201,7 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, fmt.Errorf("could not prepare firecracker process: %v", err)
 	}
 
+	machine.Status.Cmdline = process.Cmdline()
 	machine.CreationTimestamp = metav1.NewTime(time.Now())
 
 	// Pre-emptively prepare inotify on the state directory so we can wait until
@@ -191,6 +223,16 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, fmt.Errorf("could not start and wait for firecracker process: %v", err)
 	}
 
+	// Kill the process should the machine not be fully created, e.g. because
+	// firecracker rejected its configuration.
+	defer func() {
+		if machine.Status.State != machinev1alpha1.MachineStateCreated {
+			if err := process.Kill(); err != nil {
+				log.G(ctx).Warnf("could not kill firecracker process: %v", err)
+			}
+		}
+	}()
+
 	// Wait for the socket file to be created
 watch:
 	for {
@@ -203,8 +245,7 @@ watch:
 				break watch
 			}
 		case <-ctx.Done():
-			err = ctx.Err()
-			break watch
+			return machine, ctx.Err()
 		}
 	}
 
@@ -213,13 +254,106 @@ watch:
 		return machine, fmt.Errorf("could not get firecracker pid: %v", err)
 	}
 
+	if err := cgroup.Constrain(ctx, machine, pid); err != nil {
+		return machine, err
+	}
+
 	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
 
+	// Metrics can only be configured before the machine is booted or restored.
+	if _, err := client.PutMetrics(ctx, &models.Metrics{
+		MetricsPath: &fccfg.MetricsPath,
+	}); err != nil {
+		return machine, fmt.Errorf("could not configure metrics: %v", err)
+	}
+
+	// A snapshot can only be loaded into a pristine instance, the configuration
+	// of the machine is instead restored from the snapshot itself, see Restore.
+	if len(machine.Spec.RestoreFrom) > 0 {
+		machine.Status.Pid = int32(pid)
+		machine.Status.State = machinev1alpha1.MachineStateCreated
+
+		return machine, nil
+	}
+
 	kernelArgs, err := ukargparse.Parse(machine.Spec.KernelArgs...)
 	if err != nil {
 		return machine, err
 	}
 
+	fccfg.RxBandwidth = machine.Spec.Resources.Limits.Name(machinev1alpha1.ResourceNetworkRxBandwidth, resource.DecimalSI).Value()
+	fccfg.TxBandwidth = machine.Spec.Resources.Limits.Name(machinev1alpha1.ResourceNetworkTxBandwidth, resource.DecimalSI).Value()
+
+	if len(machine.Spec.Ports) > 0 {
+		// Firecracker does not offer user-mode networking, so published ports are
+		// forwarded by translating traffic on the host to the address of the
+		// machine.  If the machine is attached to a network, its first interface
+		// is used, otherwise a tap device is created just for this purpose.
+		// Either is cleaned up if the machine could not be fully created.
+		defer func() {
+			if machine.Status.State != machinev1alpha1.MachineStateCreated {
+				if err := releasePorts(machine, &fccfg); err != nil {
+					log.G(ctx).Warnf("could not release published ports: %v", err)
+				}
+			}
+		}()
+
+		if ifname, ip := iptables.MachineAddress(machine); ip != "" {
+			fccfg.PortForwardIfName = ifname
+			fccfg.PortForwardIP = ip
+		} else {
+			fccfg.PortForwardTap = portForwardTapName(machine)
+			fccfg.PortForwardIfName = fccfg.PortForwardTap
+
+			hostNet, guestIP, err := createPortForwardTap(fccfg.PortForwardTap)
+			if err != nil {
+				return machine, fmt.Errorf("could not prepare port forwarding: %v", err)
+			}
+
+			fccfg.PortForwardIP = guestIP.String()
+
+			if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && !kernelArgs.Contains(uknetdev.ParamIp) {
+				kernelArgs = append(kernelArgs, uknetdev.StaticParams(
+					guestIP, hostNet,
+					nil, "",
+					machine.Spec.LegacyNetdevParams,
+				)...)
+			}
+		}
+
+		if err := iptables.AddPortForwards(
+			string(machine.ObjectMeta.UID),
+			fccfg.PortForwardIfName,
+			iptables.PortForwardsFromMachinePorts(machine.Spec.Ports, fccfg.PortForwardIP)...,
+		); err != nil {
+			return machine, fmt.Errorf("could not forward ports: %v", err)
+		}
+	}
+
+	if fccfg.PortForwardTap != "" {
+		mac, err := macaddr.GenerateMacAddress(false)
+		if err != nil {
+			return machine, err
+		}
+
+		ifaceCfg := models.NetworkInterface{
+			GuestMac:    mac.String(),
+			HostDevName: &fccfg.PortForwardTap,
+			IfaceID:     &fccfg.PortForwardTap,
+		}
+
+		if fccfg.RxBandwidth > 0 {
+			ifaceCfg.RxRateLimiter = bandwidthRateLimiter(fccfg.RxBandwidth)
+		}
+		if fccfg.TxBandwidth > 0 {
+			ifaceCfg.TxRateLimiter = bandwidthRateLimiter(fccfg.TxBandwidth)
+		}
+
+		if _, err := client.PutGuestNetworkInterfaceByID(ctx, fccfg.PortForwardTap, &ifaceCfg); err != nil {
+			return machine, err
+		}
+	}
+
 	if len(machine.Spec.Networks) > 0 {
 		// Start MAC addresses iteratively.  Each interface will have the last
 		// hexdecimal byte increase by 1 starting at 1, allowing for easy-to-spot
@@ -244,22 +378,38 @@ watch:
 					mac = startMac.String()
 				}
 
-				if _, err := client.PutGuestNetworkInterfaceByID(ctx, network.IfName, &models.NetworkInterface{
+				ifaceCfg := models.NetworkInterface{
 					GuestMac:    mac,
 					HostDevName: &iface.Spec.IfName,
 					IfaceID:     &network.IfName,
-				}); err != nil {
+				}
+
+				if fccfg.RxBandwidth > 0 {
+					ifaceCfg.RxRateLimiter = bandwidthRateLimiter(fccfg.RxBandwidth)
+				}
+				if fccfg.TxBandwidth > 0 {
+					ifaceCfg.TxRateLimiter = bandwidthRateLimiter(fccfg.TxBandwidth)
+				}
+
+				if _, err := client.PutGuestNetworkInterfaceByID(ctx, network.IfName, &ifaceCfg); err != nil {
 					return machine, err
 				}
 
 				// Assign the first interface statically via command-line arguments, also
 				// checking if the built-in arguments for
-				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && i == 0 {
-					kernelArgs = append(kernelArgs,
-						uknetdev.ParamIpv4Addr.WithValue(iface.Spec.IP),
-						uknetdev.ParamIpv4GwAddr.WithValue(network.Gateway),
-						uknetdev.ParamIpv4SubnetMask.WithValue(network.Netmask),
-					)
+				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && !kernelArgs.Contains(uknetdev.ParamIp) && i == 0 {
+					ipv4, err := network.IPv4Subnet()
+					if err != nil {
+						return machine, err
+					}
+
+					nameserver, domain := daemon.Resolver(network)
+
+					kernelArgs = append(kernelArgs, uknetdev.StaticParams(
+						net.ParseIP(iface.Spec.IP), ipv4,
+						nameserver, domain,
+						machine.Spec.LegacyNetdevParams,
+					)...)
 				}
 
 				// Increment the host network ID for additional interfaces.
@@ -268,6 +418,64 @@ watch:
 		}
 	}
 
+	var fstab []string
+
+	// Firecracker does not support 9pfs, so host directories are instead packed
+	// into a filesystem image which is attached to the machine as a drive.
+	drive := 0
+	for _, vol := range machine.Spec.Volumes {
+		switch vol.Spec.Driver {
+		case "9pfs", "block":
+			driveID := fmt.Sprintf("vol%d", drive)
+			image := filepath.Join(machine.Status.StateDir, driveID+".img")
+
+			if err := block.Pack(ctx, vol.Spec.Source, image, block.DefaultFormat); err != nil {
+				return machine, fmt.Errorf("could not prepare volume %s: %v", vol.Spec.Source, err)
+			}
+
+			if _, err := client.PutGuestDriveByID(ctx, driveID, &models.Drive{
+				DriveID:      firecracker.String(driveID),
+				PathOnHost:   firecracker.String(image),
+				IsRootDevice: firecracker.Bool(false),
+				IsReadOnly:   firecracker.Bool(vol.Spec.ReadOnly),
+			}); err != nil {
+				return machine, fmt.Errorf("could not attach volume %s: %v", vol.Spec.Source, err)
+			}
+
+			fstab = append(fstab, vfscore.NewFstabEntry(
+				fmt.Sprintf("blk%d", drive),
+				vol.Spec.Destination,
+				block.DefaultFormat.String(),
+				"",
+				"",
+				// By default, create the directory if it does not exist when mounting.
+				"mkpath",
+			).String())
+
+			drive++
+
+		case "initrd":
+			fstab = append(fstab, vfscore.NewFstabEntry(
+				"initrd",
+				vol.Spec.Destination,
+				vol.Spec.Driver,
+				"",
+				"",
+				// By default, create the directory if it does not exist when mounting.
+				"mkpath",
+			).String())
+
+		default:
+			return machine, fmt.Errorf("unsupported Firecracker volume driver: %v", vol.Spec.Driver)
+		}
+	}
+
+	if len(fstab) > 0 {
+		kernelArgs = append(kernelArgs,
+			vfscore.ParamVfsFstab.WithValue(fstab),
+		)
+	}
+
 	// TODO(nderjung): This is standard "Unikraft" positional argument syntax
 	// (kernel args and application arguments separated with "--").  The resulting
 	// string should be standardized through a central function.
@@ -286,6 +494,21 @@ watch:
 		return machine, err
 	}
 
+	// Attach a memory balloon if a memory limit has been set, since the device
+	// can only be attached before the machine boots.  Subsequent changes to the
+	// limit are then applied via Update by inflating or deflating the balloon.
+	if balloonMib, ok := balloonSizeMib(machine); ok {
+		if _, err := client.PutBalloon(ctx, &models.Balloon{
+			AmountMib:    firecracker.Int64(balloonMib),
+			DeflateOnOom: firecracker.Bool(true),
+		}); err != nil {
+			return machine, fmt.Errorf("could not attach memory balloon: %v", err)
+		}
+
+		fccfg.Balloon = true
+		fccfg.BalloonMib = balloonMib
+	}
+
 	// Set the boot source configuration.
 	if _, err := client.PutGuestBootSource(ctx, &models.BootSource{
 		KernelImagePath: &machine.Status.KernelPath,
@@ -327,48 +550,174 @@ func getFirecrackerConfigFromPlatformConfig(platformConfig interface{}) (*Firecr
 	return nil, fmt.Errorf("could not cast firecracker platform config from store")
 }
 
+// balloonSizeMib returns the size in MiB which the memory balloon must be
+// inflated to such that the machine's usable memory does not exceed its
+// limit.  The boolean return value indicates whether a memory limit was set.
+func balloonSizeMib(machine *machinev1alpha1.Machine) (int64, bool) {
+	limit, ok := machine.Spec.Resources.Limits[corev1.ResourceMemory]
+	if !ok {
+		return 0, false
+	}
+
+	requested := machine.Spec.Resources.Requests.Memory().Value()
+	if limit.Value() >= requested {
+		return 0, true
+	}
+
+	return (requested - limit.Value()) / FirecrackerMemoryScale, true
+}
+
+// bandwidthRateLimiter returns a Firecracker rate limiter which restricts the
+// throughput of an interface to the provided number of bytes per second.  A
+// non-positive bandwidth results in a zero-sized bucket which Firecracker
+// treats as disabled.
+func bandwidthRateLimiter(bandwidth int64) *models.RateLimiter {
+	if bandwidth < 0 {
+		bandwidth = 0
+	}
+
+	return &models.RateLimiter{
+		Bandwidth: &models.TokenBucket{
+			Size:       firecracker.Int64(bandwidth),
+			RefillTime: firecracker.Int64(firecrackerRefillTime),
+		},
+	}
+}
+
 // Update implements kraftkit.sh/api/machine/v1alpha1.MachineService
 func (service *machineV1alpha1Service) Update(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
-	panic("not implemented: kraftkit.sh/machine/firecracker.machineV1alpha1Service.Update")
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	// Firecracker only accepts runtime changes once the machine has booted.
+	if machine.Status.State != machinev1alpha1.MachineStateRunning &&
+		machine.Status.State != machinev1alpha1.MachineStatePaused {
+		return machine, fmt.Errorf("cannot update firecracker instance in state: %s", machine.Status.State)
+	}
+
+	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+	ctx, cancel := context.WithTimeout(ctx, service.timeout)
+	defer cancel()
+
+	if balloonMib, ok := balloonSizeMib(machine); ok && balloonMib != fccfg.BalloonMib {
+		if !fccfg.Balloon {
+			return machine, fmt.Errorf("cannot set memory limit of firecracker instance which was created without one")
+		}
+
+		if _, err := client.PatchBalloon(ctx, &models.BalloonUpdate{
+			AmountMib: firecracker.Int64(balloonMib),
+		}); err != nil {
+			return machine, fmt.Errorf("could not resize memory balloon: %v", err)
+		}
+
+		fccfg.BalloonMib = balloonMib
+	} else if !ok && fccfg.Balloon && fccfg.BalloonMib > 0 {
+		// The limit has been removed, so deflate the balloon entirely.
+		if _, err := client.PatchBalloon(ctx, &models.BalloonUpdate{
+			AmountMib: firecracker.Int64(0),
+		}); err != nil {
+			return machine, fmt.Errorf("could not deflate memory balloon: %v", err)
+		}
+
+		fccfg.BalloonMib = 0
+	}
+
+	rxBandwidth := machine.Spec.Resources.Limits.Name(machinev1alpha1.ResourceNetworkRxBandwidth, resource.DecimalSI).Value()
+	txBandwidth := machine.Spec.Resources.Limits.Name(machinev1alpha1.ResourceNetworkTxBandwidth, resource.DecimalSI).Value()
+
+	if rxBandwidth != fccfg.RxBandwidth || txBandwidth != fccfg.TxBandwidth {
+		// The tap device dedicated to forwarding ports is limited like the
+		// interfaces of the machine's networks, as it is when the machine is
+		// created.
+		var ifaceIDs []string
+		if fccfg.PortForwardTap != "" {
+			ifaceIDs = append(ifaceIDs, fccfg.PortForwardTap)
+		}
+
+		for _, network := range machine.Spec.Networks {
+			if len(network.Interfaces) > 0 {
+				ifaceIDs = append(ifaceIDs, network.IfName)
+			}
+		}
+
+		for _, ifaceID := range ifaceIDs {
+			if _, err := client.PatchGuestNetworkInterfaceByID(ctx, ifaceID, &models.PartialNetworkInterface{
+				IfaceID:       &ifaceID,
+				RxRateLimiter: bandwidthRateLimiter(rxBandwidth),
+				TxRateLimiter: bandwidthRateLimiter(txBandwidth),
+			}); err != nil {
+				return machine, fmt.Errorf("could not update rate limiters of interface %s: %v", ifaceID, err)
+			}
+		}
+
+		fccfg.RxBandwidth = rxBandwidth
+		fccfg.TxBandwidth = txBandwidth
+	}
+
+	machine.Status.PlatformConfig = fccfg
+
+	return machine, nil
 }
 
 // Watch implements kraftkit.sh/api/machine/v1alpha1.MachineService
 func (service *machineV1alpha1Service) Watch(ctx context.Context, machine *machinev1alpha1.Machine) (chan *machinev1alpha1.Machine, chan error, error) {
+	if _, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig); err != nil {
+		return nil, nil, err
+	}
+
 	events := make(chan *machinev1alpha1.Machine)
 	errs := make(chan error)
 
-	go service.watch(ctx, machine, &events, &errs)
+	// Firecracker does not emit events over its API socket, so instead the state
+	// of the machine is polled and an event is sent whenever it changes.  The
+	// first observed state is always sent so that it can be immediately acted
+	// upon.  Polling ends, closing both channels, once the machine has exited or
+	// its state can no longer be determined.
+	go func() {
+		defer close(events)
+		defer close(errs)
 
-	return events, errs, nil
-}
+		ticker := time.NewTicker(service.pollInterval)
+		defer ticker.Stop()
 
-func (service *machineV1alpha1Service) watch(ctx context.Context, machine *machinev1alpha1.Machine, events *chan *machinev1alpha1.Machine, errs *chan error) {
-	for {
-		select {
-		case <-ctx.Done():
-			log.G(ctx).Info("context cancelled (watch)")
-			*errs <- ctx.Err()
-			return
-		default:
-			process, err := os.FindProcess(int(machine.Status.Pid))
-			if err != nil {
-				return
-			}
+		var lastState machinev1alpha1.MachineState
 
-			state, err := process.Wait()
+		for {
+			machine, err := service.Get(ctx, machine)
 			if err != nil {
-				*errs <- err
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
+
 				return
-			}
+			} else if machine.Status.State != lastState {
+				lastState = machine.Status.State
 
-			if state.Exited() {
-				machine.Status.State = machinev1alpha1.MachineStateExited
-				machine.Status.ExitCode = state.ExitCode()
+				select {
+				case events <- machine:
+				case <-ctx.Done():
+					return
+				}
+
+				if lastState == machinev1alpha1.MachineStateExited ||
+					lastState == machinev1alpha1.MachineStateFailed {
+					return
+				}
 			}
 
-			*events <- machine
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
 		}
-	}
+	}()
+
+	return events, errs, nil
 }
 
 // Start implements kraftkit.sh/api/machine/v1alpha1.MachineService
@@ -379,6 +728,26 @@ func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machi
 	}
 
 	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+	// An instance can only be started once, after which it can only be resumed
+	// from a paused state.
+	instance, err := client.GetInstanceInfo(ctx)
+	if err != nil {
+		return machine, fmt.Errorf("could not query machine status via API socket: %v", err)
+	}
+
+	if instance.Payload.State != nil && *instance.Payload.State == models.InstanceInfoStatePaused {
+		if _, err := client.PatchVM(ctx, &models.VM{
+			State: firecracker.String(models.VMStateResumed),
+		}); err != nil {
+			return machine, fmt.Errorf("could not resume firecracker instance: %v", err)
+		}
+
+		machine.Status.State = machinev1alpha1.MachineStateRunning
+
+		return machine, nil
+	}
+
 	action := models.InstanceActionInfoActionTypeInstanceStart
 	info := models.InstanceActionInfo{
 		ActionType: &action,
@@ -396,7 +765,132 @@ func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machi
 
 // Pause implements kraftkit.sh/api/machine/v1alpha1.MachineService
 func (service *machineV1alpha1Service) Pause(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
-	panic("not implemented: kraftkit.sh/machine/firecracker.machineV1alpha1Service.Pause")
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+	if _, err := client.PatchVM(ctx, &models.VM{
+		State: firecracker.String(models.VMStatePaused),
+	}); err != nil {
+		return machine, fmt.Errorf("could not pause firecracker instance: %v", err)
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStatePaused
+
+	return machine, nil
+}
+
+// Snapshot implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Snapshot(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	// Host devices and tap interfaces are recorded by path and name in the
+	// snapshot and would therefore collide with the original machine's.
+	if len(machine.Spec.Ports) > 0 || len(machine.Spec.Networks) > 0 {
+		return machine, fmt.Errorf("cannot snapshot firecracker instance with network devices")
+	}
+	for _, vol := range machine.Spec.Volumes {
+		if vol.Spec.Driver != "initrd" {
+			return machine, fmt.Errorf("cannot snapshot firecracker instance with %s volume: %s", vol.Spec.Driver, vol.Spec.Source)
+		}
+	}
+
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+	// Firecracker only supports snapshotting paused instances.
+	paused := false
+	if machine.Status.State == machinev1alpha1.MachineStateRunning {
+		if _, err := service.Pause(ctx, machine); err != nil {
+			return machine, err
+		}
+
+		paused = true
+
+		// Resume the machine should the snapshot fail, such that it is not left
+		// paused.
+		defer func() {
+			if !paused {
+				return
+			}
+
+			if _, err := service.Start(ctx, machine); err != nil {
+				log.G(ctx).Warnf("could not resume machine after failed snapshot: %v", err)
+			}
+		}()
+	}
+
+	snapshot, err := machinev1alpha1.NewMachineSnapshot(machine)
+	if err != nil {
+		return machine, err
+	}
+
+	snapshot.StatePath = filepath.Join(snapshot.Path, "state")
+	snapshot.MemoryPath = filepath.Join(snapshot.Path, "memory")
+
+	if _, err := client.CreateSnapshot(ctx, &models.SnapshotCreateParams{
+		SnapshotType: models.SnapshotCreateParamsSnapshotTypeFull,
+		SnapshotPath: firecracker.String(snapshot.StatePath),
+		MemFilePath:  firecracker.String(snapshot.MemoryPath),
+	}); err != nil {
+		return machine, fmt.Errorf("could not snapshot firecracker instance: %v", err)
+	}
+
+	if paused {
+		paused = false
+		if _, err := service.Start(ctx, machine); err != nil {
+			return machine, fmt.Errorf("could not resume machine after snapshot: %v", err)
+		}
+	}
+
+	if err := machinev1alpha1.SaveMachineSnapshotManifest(machine, snapshot); err != nil {
+		return machine, err
+	}
+
+	machine.Status.Snapshots = append(machine.Status.Snapshots, *snapshot)
+
+	return machine, nil
+}
+
+// Restore implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Restore(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	if len(machine.Spec.RestoreFrom) == 0 {
+		return machine, fmt.Errorf("cannot restore machine without snapshot")
+	}
+
+	manifest, err := machinev1alpha1.LoadMachineSnapshotManifest(machine.Spec.RestoreFrom)
+	if err != nil {
+		return machine, err
+	}
+
+	if manifest.Snapshot.Platform != machine.Spec.Platform {
+		return machine, fmt.Errorf("cannot restore %s snapshot with %s platform", manifest.Snapshot.Platform, machine.Spec.Platform)
+	}
+
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+	if _, err := client.LoadSnapshot(ctx, &models.SnapshotLoadParams{
+		SnapshotPath: firecracker.String(manifest.Snapshot.StatePath),
+		MemFilePath:  firecracker.String(manifest.Snapshot.MemoryPath),
+		ResumeVM:     false,
+	}); err != nil {
+		return machine, fmt.Errorf("could not restore firecracker instance: %v", err)
+	}
+
+	// The machine remains paused until it is started.
+	machine.Status.State = machinev1alpha1.MachineStatePaused
+
+	return machine, nil
 }
 
 // Logs implements kraftkit.sh/api/machine/v1alpha1.MachineService
@@ -459,6 +953,12 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		return machine, nil
 	}
 
+	if err := cgroup.UpdateUsage(machine); err != nil {
+		log.G(ctx).
+			WithField("machine", machine.Name).
+			Debugf("could not read resource usage: %v", err)
+	}
+
 	client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
 
 	// Grab the actual state of the machine by querying the API socket
@@ -515,23 +1015,44 @@ func (service *machineV1alpha1Service) List(ctx context.Context, machines *machi
 
 // Stop implements kraftkit.sh/api/machine/v1alpha1.MachineService.Stop
 func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
 	if machine.Status.State == machinev1alpha1.MachineStateExited {
-		return machine, nil
+		if err := releasePorts(machine, fccfg); err != nil {
+			return machine, err
+		}
+
+		return machine, cgroup.Release(machine)
 	}
 
-	process, err := goprocess.NewProcess(machine.Status.Pid)
+	stage, err := shutdown.Stop(ctx, machine, machine.Status.Pid, func(ctx context.Context) error {
+		client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+		// Only supported on x86_64, in which case the process is terminated
+		// straight away.
+		action := models.InstanceActionInfoActionTypeSendCtrlAltDel
+		_, err := client.CreateSyncAction(ctx, &models.InstanceActionInfo{
+			ActionType: &action,
+		})
+
+		return err
+	})
 	if err != nil {
-		return machine, err
+		return machine, fmt.Errorf("could not stop firecracker instance: %v", err)
 	}
 
-	if err := process.Terminate(); err != nil {
+	if err := releasePorts(machine, fccfg); err != nil {
 		return machine, err
 	}
 
 	machine.Status.State = machinev1alpha1.MachineStateExited
+	machine.Status.StopStage = stage
 	machine.Status.ExitedAt = time.Now()
 
-	return machine, nil
+	return machine, cgroup.Release(machine)
 }
 
 // Delete implements kraftkit.sh/api/machine/v1alpha1.MachineService.Delete
@@ -543,9 +1064,53 @@ func (service *machineV1alpha1Service) Delete(ctx context.Context, machine *mach
 
 	var errs merr.Errors
 
+	errs = append(errs, releasePorts(machine, fccfg))
 	errs = append(errs, os.Remove(machine.Status.LogFile))
 	errs = append(errs, os.Remove(fccfg.LogPath))
 	errs = append(errs, os.RemoveAll(machine.Status.StateDir))
+	errs = append(errs, cgroup.Release(machine))
 
 	return nil, errs.Err()
 }
+
+// Stats implements kraftkit.sh/api/machine/v1alpha1.MachineService.Stats
+func (service *machineV1alpha1Service) Stats(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	fccfg, err := getFirecrackerConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	usage := machinev1alpha1.MachineResourceUsage{}
+
+	if len(machine.Status.CgroupPath) > 0 {
+		if err := cgroup.UpdateUsage(machine); err != nil {
+			return machine, err
+		}
+
+		usage = machine.Status.Usage
+	}
+
+	if err := stats.Process(ctx, machine.Status.Pid, &usage); err != nil {
+		return machine, err
+	}
+
+	usage.GuestMemory = machine.Spec.Resources.Requests.Memory().Value() - fccfg.BalloonMib*1024*1024
+
+	if len(fccfg.MetricsPath) > 0 {
+		client := firecracker.NewClient(fccfg.SocketPath, logrus.NewEntry(log.G(ctx)), false)
+
+		ctx, cancel := context.WithTimeout(ctx, service.timeout)
+		defer cancel()
+
+		metrics, err := readMetrics(ctx, client, fccfg.MetricsPath)
+		if err != nil {
+			return machine, err
+		}
+
+		usage.Interfaces = interfacesFromMetrics(metrics)
+	}
+
+	machine.Status.Usage = usage
+
+	return machine, nil
+}
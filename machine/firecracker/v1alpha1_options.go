@@ -26,3 +26,12 @@ func WithDebug(debug bool) MachineServiceV1alpha1Option {
 		return nil
 	}
 }
+
+// WithPollInterval sets the interval at which the state of a machine is
+// queried when it is being watched.
+func WithPollInterval(interval time.Duration) MachineServiceV1alpha1Option {
+	return func(service *machineV1alpha1Service) error {
+		service.pollInterval = interval
+		return nil
+	}
+}
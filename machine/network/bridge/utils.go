@@ -10,7 +10,11 @@ import (
 
 	"github.com/erikh/ping"
 	"github.com/vishvananda/netlink"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
 	"kraftkit.sh/internal/set"
+	"kraftkit.sh/machine/network/iptables"
+	"kraftkit.sh/machine/network/nftables"
 )
 
 // IpToBigInt converts a 4 bytes IP into a 128 bit integer.
@@ -49,7 +53,8 @@ func IsUnicastIP(ip net.IP, mask net.IPMask) bool {
 	return ip.IsGlobalUnicast()
 }
 
-// BridgeIPs returns all the IPs attached to the provided bridge
+// BridgeIPs returns all the IPv4 and IPv6 addresses of the neighbors of the
+// provided bridge.
 func BridgeIPs(bridge *netlink.Bridge) ([]string, error) {
 	// get the neighbors
 	var (
@@ -57,22 +62,22 @@ func BridgeIPs(bridge *netlink.Bridge) ([]string, error) {
 		err  error
 	)
 
-	list, err = netlink.NeighList(bridge.Index, netlink.FAMILY_V4)
+	list, err = netlink.NeighList(bridge.Index, netlink.FAMILY_ALL)
 	if err != nil {
-		return nil, fmt.Errorf("cannot retrieve IPv4 neighbor information for interface %s: %v", bridge.Name, err)
+		return nil, fmt.Errorf("cannot retrieve neighbor information for interface %s: %v", bridge.Name, err)
 	}
 
 	ips := make([]string, len(list))
 	for i, entry := range list {
-		ips[i] = entry.String()
+		ips[i] = entry.IP.String()
 	}
 
 	return ips, nil
 }
 
 // For a given IP network, bridge (and its interface), allocate a free IP
-// address.
-func AllocateIP(ctx context.Context, ipnet *net.IPNet, iface *net.Interface, bridge *netlink.Bridge) (net.IP, error) {
+// address other than the excluded ones.
+func AllocateIP(ctx context.Context, ipnet *net.IPNet, iface *net.Interface, bridge *netlink.Bridge, exclude ...string) (net.IP, error) {
 	bridgeAddrs, err := iface.Addrs()
 	if err != nil {
 		return nil, err
@@ -83,10 +88,32 @@ func AllocateIP(ctx context.Context, ipnet *net.IPNet, iface *net.Interface, bri
 		return nil, err
 	}
 
-	allocatedSet := set.NewStringSet(allocatedIps...)
+	allocatedSet := set.NewStringSet(append(allocatedIps, exclude...)...)
+
+	return nextFreeIP(ctx, ipnet, func(ip net.IP) bool {
+		// Skip the Bridge IP.
+		for _, addr := range bridgeAddrs {
+			itfIP, _, _ := net.ParseCIDR(addr.String())
+			if ip.Equal(itfIP) {
+				return true
+			}
+		}
+
+		// Skip allocated IP addresses.
+		if allocatedSet.Contains(ip.String()) {
+			return true
+		}
+
+		// Use ICMP to check if the IP is in use as a final sanity check.
+		return ping.Ping(&net.IPAddr{IP: ip, Zone: ""}, 150*time.Millisecond)
+	})
+}
+
+// nextFreeIP returns the first unicast address of the provided network after
+// its own address, i.e. that of its gateway, which is not in use.
+func nextFreeIP(ctx context.Context, ipnet *net.IPNet, inUse func(net.IP) bool) (net.IP, error) {
 	ip := ipnet.IP
 
-search:
 	for {
 		ip = IncreaseIP(ip)
 
@@ -102,34 +129,122 @@ search:
 		case !ipnet.Contains(ip):
 			return nil, fmt.Errorf("could not allocate IP address in %v", ipnet.String())
 
-		// Skip the Bridge IP.
-		case func() bool {
-			for _, addr := range bridgeAddrs {
-				itfIP, _, _ := net.ParseCIDR(addr.String())
-				if ip.Equal(itfIP) {
-					return true
-				}
-			}
-			return false
-		}():
-			continue
-
 		// Skip the broadcast IP address.
 		case !IsUnicastIP(ip, ipnet.Mask):
 			continue
 
-		// Skip allocated IP addresses.
-		case allocatedSet.Contains(ip.String()):
+		case inUse(ip):
 			continue
 
-		// Use ICMP to check if the IP is in use as a final sanity check.
-		case ping.Ping(&net.IPAddr{IP: ip, Zone: ""}, 150*time.Millisecond):
+		default:
+			return ip, nil
+		}
+	}
+}
+
+// Subnets returns the IPv4 and IPv6 subnets of the provided network.
+func Subnets(network *networkv1alpha1.Network) ([]*net.IPNet, error) {
+	gateways, err := gatewaySubnets(network)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets := make([]*net.IPNet, len(gateways))
+	for i, gateway := range gateways {
+		subnets[i] = &net.IPNet{
+			IP:   gateway.IP.Mask(gateway.Mask),
+			Mask: gateway.Mask,
+		}
+	}
+
+	return subnets, nil
+}
+
+// gatewaySubnets returns the IPv4 and IPv6 subnets of the provided network,
+// where the address of each subnet is that of the gateway.
+func gatewaySubnets(network *networkv1alpha1.Network) ([]*net.IPNet, error) {
+	var gateways []*net.IPNet
+
+	for _, get := range []func() (*net.IPNet, error){
+		network.Spec.IPv4Subnet,
+		network.Spec.IPv6Subnet,
+	} {
+		gateway, err := get()
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet of network %s: %v", network.Name, err)
+		} else if gateway != nil {
+			gateways = append(gateways, gateway)
+		}
+	}
+
+	return gateways, nil
+}
+
+// interfaceIP returns the IPv4 address of the provided interface, or its IPv6
+// address if it has none.
+func interfaceIP(iface networkv1alpha1.NetworkInterfaceTemplateSpec) string {
+	if iface.Spec.IP != "" {
+		return iface.Spec.IP
+	}
+
+	return iface.Spec.IPv6
+}
+
+// bridgeSubnets returns the subnets of the addresses of the provided bridge, in
+// the form of networkv1alpha1.ParseSubnet.  Link-local addresses, which the
+// kernel assigns to every interface, are omitted.
+func bridgeSubnets(bridge netlink.Link) ([]*net.IPNet, error) {
+	addrs, err := netlink.AddrList(bridge, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets := []*net.IPNet{}
+	seen := map[bool]bool{}
+
+	for _, addr := range addrs {
+		if addr.IP.IsLinkLocalUnicast() {
 			continue
+		}
 
-		default:
-			break search
+		// Only the first address of each family is considered.
+		ipv4 := addr.IP.To4() != nil
+		if seen[ipv4] {
+			continue
 		}
+
+		seen[ipv4] = true
+		subnets = append(subnets, addr.IPNet)
+	}
+
+	return subnets, nil
+}
+
+// enableNAT installs the rules which allow the machines on the provided network
+// to reach hosts beyond the host.  The rules are owned by the network, such
+// that they are removed along with it.
+func enableNAT(network *networkv1alpha1.Network) error {
+	subnets, err := Subnets(network)
+	if err != nil {
+		return err
+	}
+
+	for _, subnet := range subnets {
+		if err := iptables.AddMasquerade(network.Name, network.Name, subnet.String()); err != nil {
+			return fmt.Errorf("could not enable NAT for %s: %v", network.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// enforcePolicy installs the rules which enforce the policy of the provided
+// network, if it has one.  Networks without a policy do not require nft to be
+// available on the host.
+func enforcePolicy(ctx context.Context, network *networkv1alpha1.Network) error {
+	if network.Spec.Policy == nil {
+		return nil
 	}
 
-	return ip, nil
+	return nftables.ApplyPolicy(ctx, network)
 }
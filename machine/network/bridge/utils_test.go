@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package bridge
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+func TestNextFreeIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		subnet  string
+		inUse   []string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "IPv4 after gateway",
+			subnet: "172.100.0.1/24",
+			want:   "172.100.0.2",
+		},
+		{
+			name:   "IPv4 skips addresses in use",
+			subnet: "172.100.0.1/24",
+			inUse:  []string{"172.100.0.2", "172.100.0.3"},
+			want:   "172.100.0.4",
+		},
+		{
+			name:   "IPv4 non-classful",
+			subnet: "10.1.2.129/26",
+			inUse:  []string{"10.1.2.130"},
+			want:   "10.1.2.131",
+		},
+		{
+			name:    "IPv4 skips broadcast",
+			subnet:  "10.1.2.189/26",
+			inUse:   []string{"10.1.2.190"},
+			wantErr: true,
+		},
+		{
+			name:    "IPv4 point-to-point exhausted",
+			subnet:  "10.0.0.1/30",
+			inUse:   []string{"10.0.0.2"},
+			wantErr: true,
+		},
+		{
+			name:   "IPv6 after gateway",
+			subnet: "fd00:cafe::1/64",
+			want:   "fd00:cafe::2",
+		},
+		{
+			name:   "IPv6 skips addresses in use",
+			subnet: "fd00:cafe::1/64",
+			inUse:  []string{"fd00:cafe::2"},
+			want:   "fd00:cafe::3",
+		},
+		{
+			name:   "IPv6 last address",
+			subnet: "fd00:cafe::1/126",
+			inUse:  []string{"fd00:cafe::2"},
+			want:   "fd00:cafe::3",
+		},
+		{
+			name:    "IPv6 exhausted",
+			subnet:  "fd00:cafe::1/126",
+			inUse:   []string{"fd00:cafe::2", "fd00:cafe::3"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnet, err := networkv1alpha1.ParseSubnet(tt.subnet)
+			if err != nil {
+				t.Fatalf("Could not parse subnet: %v", err)
+			}
+
+			got, err := nextFreeIP(context.Background(), subnet, func(ip net.IP) bool {
+				for _, used := range tt.inUse {
+					if ip.Equal(net.ParseIP(used)) {
+						return true
+					}
+				}
+
+				return false
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSubnets(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    networkv1alpha1.NetworkSpec
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "IPv4 from gateway and netmask",
+			spec: networkv1alpha1.NetworkSpec{
+				Gateway: "172.100.0.1",
+				Netmask: "255.255.255.0",
+			},
+			want: []string{"172.100.0.0/24"},
+		},
+		{
+			name: "dual-stack",
+			spec: networkv1alpha1.NetworkSpec{
+				Subnets: []string{"fd00:cafe::1/64", "10.1.2.129/26"},
+			},
+			want: []string{"10.1.2.128/26", "fd00:cafe::/64"},
+		},
+		{
+			name: "IPv6 only",
+			spec: networkv1alpha1.NetworkSpec{
+				Subnets: []string{"fd00:cafe::1/64"},
+			},
+			want: []string{"fd00:cafe::/64"},
+		},
+		{
+			name: "invalid subnet",
+			spec: networkv1alpha1.NetworkSpec{
+				Subnets: []string{"fd00:cafe::1"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnets, err := Subnets(&networkv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+				},
+				Spec: tt.spec,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if len(subnets) != len(tt.want) {
+				t.Fatalf("Expected subnets %v, got %v", tt.want, subnets)
+			}
+
+			for i, subnet := range subnets {
+				if subnet.String() != tt.want[i] {
+					t.Errorf("Expected subnets %v, got %v", tt.want, subnets)
+				}
+			}
+		})
+	}
+}
@@ -15,12 +15,15 @@ import (
 
 	"github.com/erikh/ping"
 	"github.com/vishvananda/netlink"
-	"github.com/vishvananda/netlink/nl"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
 
 	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/network/daemon"
+	"kraftkit.sh/machine/network/iptables"
 	"kraftkit.sh/machine/network/macaddr"
+	"kraftkit.sh/machine/network/nftables"
 )
 
 type v1alpha1Network struct{}
@@ -47,11 +50,22 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 	network.Status.State = networkv1alpha1.NetworkStateUnknown
 
 	// Validate the options.
-	if len(network.Spec.Gateway) == 0 {
-		return network, fmt.Errorf("gateway cannot be empty")
+	gateways, err := gatewaySubnets(network)
+	if err != nil {
+		return network, err
+	}
+	if len(gateways) == 0 {
+		return network, fmt.Errorf("network must have an IPv4 or IPv6 subnet")
+	}
+
+	if err := network.Spec.Policy.Validate(); err != nil {
+		return network, fmt.Errorf("invalid policy of network %s: %v", network.Name, err)
 	}
-	if len(network.Spec.Netmask) == 0 {
-		return network, fmt.Errorf("netmask cannot be empty")
+
+	// Networks which only specify a gateway and netmask also list their subnet
+	// from here on.
+	if err := network.Spec.SetSubnets(gateways...); err != nil {
+		return network, err
 	}
 
 	bridge := &netlink.Bridge{
@@ -60,7 +74,7 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 
 	bridge.LinkAttrs.MTU = DefaultMTU
 
-	_, err := net.InterfaceByName(network.Name)
+	_, err = net.InterfaceByName(network.Name)
 	if err == nil {
 		// Bridge already exists, return early.
 		return network, fmt.Errorf("network already exists: %s", network.Name)
@@ -79,15 +93,12 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 
 	// br.Promisc = 1 // TODO(nderjung): Should the bridge be promiscuous?
 
-	// Setup IP address for bridge.
-	addr := &netlink.Addr{
-		IPNet: &net.IPNet{
-			IP:   net.ParseIP(network.Spec.Gateway),
-			Mask: net.ParseIP(network.Spec.Netmask).DefaultMask(),
-		},
-	}
-	if err := netlink.AddrAdd(br, addr); err != nil {
-		return network, fmt.Errorf("adding address %s to bridge %s failed: %v", addr.String(), network.Name, err)
+	// Setup the IP addresses for bridge.
+	for _, gateway := range gateways {
+		addr := &netlink.Addr{IPNet: gateway}
+		if err := netlink.AddrAdd(br, addr); err != nil {
+			return network, fmt.Errorf("adding address %s to bridge %s failed: %v", addr.String(), network.Name, err)
+		}
 	}
 
 	// Bring the bridge up.
@@ -95,6 +106,12 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 		return network, fmt.Errorf("bringing bridge %s up failed: %v", network.Name, err)
 	}
 
+	if network.Spec.NAT {
+		if err := enableNAT(network); err != nil {
+			return network, err
+		}
+	}
+
 	network.CreationTimestamp = metav1.Now()
 
 	link, err := netlink.LinkByName(network.Name)
@@ -150,6 +167,10 @@ func (service *v1alpha1Network) Create(ctx context.Context, network *networkv1al
 		network.Spec.Interfaces[i] = iface
 	}
 
+	if err := enforcePolicy(ctx, network); err != nil {
+		return network, err
+	}
+
 	return network, nil
 }
 
@@ -178,6 +199,18 @@ func (service *v1alpha1Network) Start(ctx context.Context, network *networkv1alp
 		return network, fmt.Errorf("could not bring %s link up: %v", network.Name, err)
 	}
 
+	// The rules may have been lost since the network was created, e.g. if the
+	// host has been rebooted.
+	if network.Spec.NAT {
+		if err := enableNAT(network); err != nil {
+			return network, err
+		}
+	}
+
+	if err := enforcePolicy(ctx, network); err != nil {
+		return network, err
+	}
+
 	network.Status.State = networkv1alpha1.NetworkStateUp
 
 	return network, nil
@@ -192,8 +225,8 @@ func (service *v1alpha1Network) Stop(ctx context.Context, network *networkv1alph
 			return network, fmt.Errorf("getting link %s failed: %v", iface.Spec.IfName, err)
 		}
 
-		if ping.Ping(&net.IPAddr{IP: net.ParseIP(iface.Spec.IP), Zone: ""}, 150*time.Millisecond) {
-			return network, fmt.Errorf("interface still in use: %s (%s, %s)", iface.Spec.IfName, iface.Spec.MacAddress, iface.Spec.IP)
+		if ip := interfaceIP(iface); ip != "" && ping.Ping(&net.IPAddr{IP: net.ParseIP(ip), Zone: ""}, 150*time.Millisecond) {
+			return network, fmt.Errorf("interface still in use: %s (%s, %s)", iface.Spec.IfName, iface.Spec.MacAddress, ip)
 		}
 
 		if err := netlink.LinkSetDown(link); err != nil {
@@ -235,9 +268,30 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 		return nil, fmt.Errorf("could not get bridge interface: %v", err)
 	}
 
-	ipnet := &net.IPNet{
-		IP:   net.ParseIP(network.Spec.Gateway),
-		Mask: net.ParseIP(network.Spec.Netmask).DefaultMask(),
+	if err := network.Spec.Policy.Validate(); err != nil {
+		return network, fmt.Errorf("invalid policy of network %s: %v", network.Name, err)
+	}
+
+	ipv4, err := network.Spec.IPv4Subnet()
+	if err != nil {
+		return network, err
+	}
+
+	ipv6, err := network.Spec.IPv6Subnet()
+	if err != nil {
+		return network, err
+	}
+
+	// Addresses of interfaces which have not been attached yet are not known to
+	// the bridge, so exclude all known ones when allocating new addresses.
+	var assigned []string
+	for _, iface := range network.Spec.Interfaces {
+		if iface.Spec.IP != "" {
+			assigned = append(assigned, iface.Spec.IP)
+		}
+		if iface.Spec.IPv6 != "" {
+			assigned = append(assigned, iface.Spec.IPv6)
+		}
 	}
 
 	// Start MAC addresses iteratively.
@@ -279,13 +333,24 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 			iface.Spec.MacAddress = mac.String()
 		}
 
-		if iface.Spec.IP == "" {
-			ip, err := AllocateIP(ctx, ipnet, bridgeface, bridge)
+		if iface.Spec.IP == "" && ipv4 != nil {
+			ip, err := AllocateIP(ctx, ipv4, bridgeface, bridge, assigned...)
 			if err != nil {
 				return network, fmt.Errorf("could not allocate interface IP for %s: %v", iface.Spec.IfName, err)
 			}
 
 			iface.Spec.IP = ip.String()
+			assigned = append(assigned, iface.Spec.IP)
+		}
+
+		if iface.Spec.IPv6 == "" && ipv6 != nil {
+			ip, err := AllocateIP(ctx, ipv6, bridgeface, bridge, assigned...)
+			if err != nil {
+				return network, fmt.Errorf("could not allocate interface IPv6 for %s: %v", iface.Spec.IfName, err)
+			}
+
+			iface.Spec.IPv6 = ip.String()
+			assigned = append(assigned, iface.Spec.IPv6)
 		}
 
 		tap := &netlink.Tuntap{
@@ -351,6 +416,12 @@ func (service *v1alpha1Network) Update(ctx context.Context, network *networkv1al
 		}
 	}
 
+	// The rules depend on the addresses of the machines on the network, which
+	// may have changed along with its interfaces.
+	if err := enforcePolicy(ctx, network); err != nil {
+		return network, err
+	}
+
 	return network, nil
 }
 
@@ -364,8 +435,8 @@ func (service *v1alpha1Network) Delete(ctx context.Context, network *networkv1al
 			return network, fmt.Errorf("could not get %s link: %v", iface.Spec.IfName, err)
 		}
 
-		if ping.Ping(&net.IPAddr{IP: net.ParseIP(iface.Spec.IP), Zone: ""}, 150*time.Millisecond) {
-			return network, fmt.Errorf("interface still in use: %s (%s, %s)", iface.Spec.IfName, iface.Spec.MacAddress, iface.Spec.IP)
+		if ip := interfaceIP(iface); ip != "" && ping.Ping(&net.IPAddr{IP: net.ParseIP(ip), Zone: ""}, 150*time.Millisecond) {
+			return network, fmt.Errorf("interface still in use: %s (%s, %s)", iface.Spec.IfName, iface.Spec.MacAddress, ip)
 		}
 
 		// Bring down the bridge link
@@ -379,6 +450,24 @@ func (service *v1alpha1Network) Delete(ctx context.Context, network *networkv1al
 		}
 	}
 
+	// Rules are removed even if the network does not indicate that it has NAT
+	// enabled in case it was not retrieved from the store.
+	if err := iptables.RemoveRules(network.Name); err != nil {
+		if network.Spec.NAT {
+			return network, fmt.Errorf("could not remove NAT rules of %s: %v", network.Name, err)
+		}
+
+		log.G(ctx).Debugf("could not remove NAT rules of %s: %v", network.Name, err)
+	}
+
+	if err := nftables.RemovePolicy(ctx, network.Name); err != nil {
+		if network.Spec.Policy != nil {
+			return network, err
+		}
+
+		log.G(ctx).Debugf("could not remove policy of %s: %v", network.Name, err)
+	}
+
 	// Get the bridge link.
 	link, err := netlink.LinkByName(network.Name)
 	if err != nil {
@@ -441,18 +530,19 @@ func (service *v1alpha1Network) Get(ctx context.Context, network *networkv1alpha
 		return network, fmt.Errorf("network link is not bridge")
 	}
 
-	addrs, err := netlink.AddrList(bridge, nl.FAMILY_V4)
+	subnets, err := bridgeSubnets(bridge)
 	if err != nil {
 		return network, err
 	}
 
-	if len(addrs) == 0 {
+	if len(subnets) == 0 {
 		return network, fmt.Errorf("bridge %s has no ip address", network.Name)
 	}
 
 	network.Spec.Driver = "bridge"
-	network.Spec.Gateway = addrs[0].IP.String()
-	network.Spec.Netmask = net.IP(addrs[0].Mask).String()
+	if err := network.Spec.SetSubnets(subnets...); err != nil {
+		return network, err
+	}
 
 	// Use the internal network bridge networking system to determine
 	// whether the identified network is online.
@@ -467,6 +557,15 @@ func (service *v1alpha1Network) Get(ctx context.Context, network *networkv1alpha
 
 	mapBridgeStatistics(network, bridge)
 
+	if network.Spec.DHCP {
+		leases, err := daemon.LoadLeases(daemon.StateDir(ctx, network.Name))
+		if err != nil {
+			log.G(ctx).Debugf("could not load leases of %s: %v", network.Name, err)
+		} else {
+			network.Status.Leases = leases
+		}
+	}
+
 	return network, nil
 }
 
@@ -512,7 +611,7 @@ func (service *v1alpha1Network) List(ctx context.Context, networks *networkv1alp
 
 	// Discover new bridges.
 	for _, bridge := range bridges {
-		addrs, err := netlink.AddrList(bridge, nl.FAMILY_V4)
+		subnets, err := bridgeSubnets(bridge)
 		if err != nil {
 			continue // TODO(nderjung): error groups
 		}
@@ -524,17 +623,12 @@ func (service *v1alpha1Network) List(ctx context.Context, networks *networkv1alp
 			},
 		}
 
-		if len(addrs) != 1 {
+		if len(subnets) == 0 || network.Spec.SetSubnets(subnets...) != nil {
 			network.Status.State = networkv1alpha1.NetworkStateDown
 			networks.Items = append(networks.Items, network)
 			continue // TODO(nderjung): error groups
 		}
 
-		network.Spec = networkv1alpha1.NetworkSpec{
-			Gateway: addrs[0].IP.String(),
-			Netmask: net.IP(addrs[0].Mask).String(),
-		}
-
 		// Use the internal network bridge networking system to determine
 		// whether the identified network is online.
 		if net.FlagUp&bridge.Flags == 1 || net.FlagRunning&bridge.Flags == 1 {
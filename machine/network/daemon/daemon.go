@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package daemon serves the machines on a network from the host: it hands out
+// addresses from the subnet of the network via DHCP and resolves the names of
+// the machines via DNS, forwarding any other query to the resolvers of the
+// host.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/log"
+)
+
+const (
+	// DefaultDomain is the domain below which the names of machines are
+	// resolved, e.g. my-machine.kraft.
+	DefaultDomain = "kraft"
+
+	// DefaultLeaseTime is how long an address is leased to a machine before it
+	// must be renewed.
+	DefaultLeaseTime = time.Hour
+)
+
+// Daemon serves DHCP and DNS to the machines on a single network.
+type Daemon struct {
+	domain    string
+	leaseTime time.Duration
+	pidFile   string
+	stateDir  string
+	upstream  []string
+
+	mu       sync.RWMutex
+	network  *networkv1alpha1.Network
+	gateway  net.IP
+	subnet   *net.IPNet
+	reserved map[string]networkv1alpha1.NetworkInterfaceTemplateSpec
+	leases   map[string]networkv1alpha1.NetworkLease
+	declined map[string]time.Time
+}
+
+// DaemonOption is a function which modifies the daemon.
+type DaemonOption func(*Daemon) error
+
+// WithDomain sets the domain below which the names of machines are resolved.
+func WithDomain(domain string) DaemonOption {
+	return func(d *Daemon) error {
+		d.domain = strings.Trim(strings.ToLower(domain), ".")
+		return nil
+	}
+}
+
+// WithLeaseTime sets how long an address is leased to a machine.
+func WithLeaseTime(leaseTime time.Duration) DaemonOption {
+	return func(d *Daemon) error {
+		if leaseTime <= 0 {
+			return fmt.Errorf("lease time must be positive")
+		}
+
+		d.leaseTime = leaseTime
+		return nil
+	}
+}
+
+// WithPidFile sets the file to which the pid of the process is written once
+// the daemon is serving, which signals that it has started successfully.
+func WithPidFile(path string) DaemonOption {
+	return func(d *Daemon) error {
+		d.pidFile = path
+		return nil
+	}
+}
+
+// WithStateDir sets the directory in which the leases are saved.
+func WithStateDir(dir string) DaemonOption {
+	return func(d *Daemon) error {
+		d.stateDir = dir
+		return nil
+	}
+}
+
+// WithUpstream sets the addresses of the DNS servers to which queries for
+// names outside of the domain are forwarded.  By default, the name servers of
+// the host are used.
+func WithUpstream(upstream ...string) DaemonOption {
+	return func(d *Daemon) error {
+		d.upstream = upstream
+		return nil
+	}
+}
+
+// New prepares a daemon for the provided network.
+func New(network *networkv1alpha1.Network, dopts ...DaemonOption) (*Daemon, error) {
+	d := Daemon{
+		domain:    DefaultDomain,
+		leaseTime: DefaultLeaseTime,
+		leases:    map[string]networkv1alpha1.NetworkLease{},
+		declined:  map[string]time.Time{},
+	}
+
+	for _, opt := range dopts {
+		if err := opt(&d); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := d.SetNetwork(network); err != nil {
+		return nil, err
+	}
+
+	if d.upstream == nil {
+		d.upstream = hostNameservers()
+	}
+
+	if d.stateDir != "" {
+		leases, err := LoadLeases(d.stateDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, lease := range leases {
+			d.leases[lease.MacAddress] = lease
+		}
+	}
+
+	return &d, nil
+}
+
+// Resolver returns the address of the name server and the domain with which
+// the machines on the provided network resolve each other's names, i.e. those
+// of the daemon of the network, or nil and an empty domain if the network is
+// not served by a daemon.
+func Resolver(network networkv1alpha1.NetworkSpec) (net.IP, string) {
+	if !network.DHCP {
+		return nil, ""
+	}
+
+	subnet, err := network.IPv4Subnet()
+	if err != nil || subnet == nil {
+		return nil, ""
+	}
+
+	return subnet.IP, DefaultDomain
+}
+
+// SetNetwork updates the network which is served, such that the addresses and
+// names of interfaces which have since been attached to it are known.
+func (d *Daemon) SetNetwork(network *networkv1alpha1.Network) error {
+	// Addresses are only handed out via DHCP, i.e. from the IPv4 subnet, whereas
+	// the IPv6 addresses of interfaces are only resolved.
+	subnet, err := network.Spec.IPv4Subnet()
+	if err != nil {
+		return fmt.Errorf("invalid subnet of network %s: %v", network.Name, err)
+	} else if subnet == nil {
+		return fmt.Errorf("network %s has no IPv4 subnet", network.Name)
+	}
+
+	reserved := make(map[string]networkv1alpha1.NetworkInterfaceTemplateSpec, len(network.Spec.Interfaces))
+	for _, iface := range network.Spec.Interfaces {
+		if iface.Spec.MacAddress == "" {
+			continue
+		}
+
+		reserved[strings.ToLower(iface.Spec.MacAddress)] = iface
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.network = network
+	d.gateway = subnet.IP
+	d.subnet = &net.IPNet{
+		IP:   subnet.IP.Mask(subnet.Mask),
+		Mask: subnet.Mask,
+	}
+	d.reserved = reserved
+
+	return nil
+}
+
+// Serve answers DHCP requests on the interface of the network and DNS queries
+// on its gateway address until the context is cancelled.
+func (d *Daemon) Serve(ctx context.Context) error {
+	d.mu.RLock()
+	ifname := d.network.Spec.IfName
+	if ifname == "" {
+		ifname = d.network.Name
+	}
+	gateway := d.gateway
+	d.mu.RUnlock()
+
+	dhcpConn, err := listenDHCP(ctx, ifname)
+	if err != nil {
+		return fmt.Errorf("could not listen for DHCP requests on %s: %v", ifname, err)
+	}
+
+	dnsConn, err := net.ListenPacket("udp4", net.JoinHostPort(gateway.String(), "53"))
+	if err != nil {
+		dhcpConn.Close()
+		return fmt.Errorf("could not listen for DNS queries on %s: %v", gateway, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		dhcpConn.Close()
+		dnsConn.Close()
+	}()
+
+	if d.pidFile != "" {
+		if err := os.WriteFile(d.pidFile, []byte(fmt.Sprintf("%d", os.Getpid())), 0o644); err != nil {
+			return fmt.Errorf("could not write pid file: %v", err)
+		}
+
+		defer func() {
+			if err := os.Remove(d.pidFile); err != nil && !os.IsNotExist(err) {
+				log.G(ctx).Errorf("could not remove pid file: %v", err)
+			}
+		}()
+	}
+
+	log.G(ctx).
+		WithField("interface", ifname).
+		WithField("domain", d.domain).
+		Info("serving DHCP and DNS")
+
+	errs := make(chan error, 2)
+
+	go func() { errs <- d.serveDHCP(ctx, dhcpConn) }()
+	go func() { errs <- d.serveDNS(ctx, dnsConn) }()
+
+	err = <-errs
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+// lookup returns the IPv4 and IPv6 addresses of the machine with the provided
+// name, either of which may be nil.  The names of the interfaces which are
+// attached to the network take precedence over the host names which machines
+// requested via DHCP.
+func (d *Daemon) lookup(name string) (net.IP, net.IP) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, iface := range d.reserved {
+		if sameHost(iface.Name, name) && (iface.Spec.IP != "" || iface.Spec.IPv6 != "") {
+			return net.ParseIP(iface.Spec.IP).To4(), net.ParseIP(iface.Spec.IPv6)
+		}
+	}
+
+	now := time.Now()
+	for _, lease := range d.leases {
+		if sameHost(lease.Hostname, name) && lease.ExpiresAt.After(now) {
+			return net.ParseIP(lease.IP).To4(), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// sameHost returns whether the provided names refer to the same host.  Since
+// the names of machines may contain underscores, which are not valid in host
+// names, they are also matched by hyphens.
+func sameHost(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+
+	return strings.EqualFold(strings.ReplaceAll(a, "_", "-"), strings.ReplaceAll(b, "_", "-"))
+}
+
+// hostname returns the name of the machine which owns the interface with the
+// provided hardware address, if it is known.
+func (d *Daemon) hostname(mac string) string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if iface, ok := d.reserved[mac]; ok {
+		return iface.Name
+	}
+
+	return ""
+}
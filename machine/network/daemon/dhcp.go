@@ -0,0 +1,372 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package daemon
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"kraftkit.sh/log"
+)
+
+// dhcpMessageType is the type of a DHCP message, see RFC 2132 section 9.6.
+type dhcpMessageType byte
+
+const (
+	dhcpDiscover = dhcpMessageType(1)
+	dhcpOffer    = dhcpMessageType(2)
+	dhcpRequest  = dhcpMessageType(3)
+	dhcpDecline  = dhcpMessageType(4)
+	dhcpAck      = dhcpMessageType(5)
+	dhcpNak      = dhcpMessageType(6)
+	dhcpRelease  = dhcpMessageType(7)
+	dhcpInform   = dhcpMessageType(8)
+)
+
+// DHCP options which are read or written by the server, see RFC 2132.
+const (
+	dhcpOptionPad         = 0
+	dhcpOptionSubnetMask  = 1
+	dhcpOptionRouter      = 3
+	dhcpOptionDNS         = 6
+	dhcpOptionHostname    = 12
+	dhcpOptionDomainName  = 15
+	dhcpOptionBroadcast   = 28
+	dhcpOptionRequestedIP = 50
+	dhcpOptionLeaseTime   = 51
+	dhcpOptionMessageType = 53
+	dhcpOptionServerID    = 54
+	dhcpOptionRenewalTime = 58
+	dhcpOptionRebindTime  = 59
+	dhcpOptionEnd         = 255
+)
+
+const (
+	dhcpServerPort = 67
+	dhcpClientPort = 68
+
+	// dhcpHeaderLen is the length of the fixed part of a DHCP message up to and
+	// including the magic cookie.
+	dhcpHeaderLen = 240
+
+	// dhcpFlagBroadcast indicates that the client cannot receive unicast
+	// replies before it has been configured.
+	dhcpFlagBroadcast = 0x8000
+)
+
+var dhcpMagicCookie = []byte{99, 130, 83, 99}
+
+// dhcpMessage is a DHCP message as defined by RFC 2131 section 2.
+type dhcpMessage struct {
+	op      byte
+	xid     [4]byte
+	flags   uint16
+	ciaddr  net.IP
+	yiaddr  net.IP
+	giaddr  net.IP
+	chaddr  net.HardwareAddr
+	options map[byte][]byte
+}
+
+// parseDHCPMessage decodes a DHCP message from the provided packet.
+func parseDHCPMessage(b []byte) (*dhcpMessage, error) {
+	if len(b) < dhcpHeaderLen {
+		return nil, fmt.Errorf("message too short: %d bytes", len(b))
+	}
+
+	if string(b[236:240]) != string(dhcpMagicCookie) {
+		return nil, fmt.Errorf("invalid magic cookie")
+	}
+
+	hlen := int(b[2])
+	if hlen > 16 {
+		return nil, fmt.Errorf("invalid hardware address length: %d", hlen)
+	}
+
+	msg := dhcpMessage{
+		op:      b[0],
+		flags:   binary.BigEndian.Uint16(b[10:12]),
+		ciaddr:  net.IP(append([]byte{}, b[12:16]...)),
+		yiaddr:  net.IP(append([]byte{}, b[16:20]...)),
+		giaddr:  net.IP(append([]byte{}, b[24:28]...)),
+		chaddr:  net.HardwareAddr(append([]byte{}, b[28:28+hlen]...)),
+		options: map[byte][]byte{},
+	}
+
+	copy(msg.xid[:], b[4:8])
+
+	for i := dhcpHeaderLen; i < len(b); {
+		code := b[i]
+		if code == dhcpOptionEnd {
+			break
+		} else if code == dhcpOptionPad {
+			i++
+			continue
+		}
+
+		if i+1 >= len(b) {
+			return nil, fmt.Errorf("truncated option %d", code)
+		}
+
+		length := int(b[i+1])
+		if i+2+length > len(b) {
+			return nil, fmt.Errorf("truncated option %d", code)
+		}
+
+		msg.options[code] = b[i+2 : i+2+length]
+		i += 2 + length
+	}
+
+	return &msg, nil
+}
+
+// messageType returns the type of the message.
+func (msg *dhcpMessage) messageType() dhcpMessageType {
+	if v := msg.options[dhcpOptionMessageType]; len(v) == 1 {
+		return dhcpMessageType(v[0])
+	}
+
+	return 0
+}
+
+// ip returns the value of an option which contains a single IPv4 address.
+func (msg *dhcpMessage) ip(code byte) net.IP {
+	if v := msg.options[code]; len(v) == net.IPv4len {
+		return net.IP(v)
+	}
+
+	return nil
+}
+
+// marshal encodes the message as a reply from the server.  Options are written
+// in the order of their codes.
+func (msg *dhcpMessage) marshal() []byte {
+	b := make([]byte, dhcpHeaderLen, 512)
+
+	b[0] = msg.op
+	b[1] = 1 // Ethernet
+	b[2] = byte(len(msg.chaddr))
+	copy(b[4:8], msg.xid[:])
+	binary.BigEndian.PutUint16(b[10:12], msg.flags)
+	copy(b[12:16], msg.ciaddr.To4())
+	copy(b[16:20], msg.yiaddr.To4())
+	copy(b[24:28], msg.giaddr.To4())
+	copy(b[28:44], msg.chaddr)
+	copy(b[236:240], dhcpMagicCookie)
+
+	for code := 1; code < dhcpOptionEnd; code++ {
+		v, ok := msg.options[byte(code)]
+		if !ok {
+			continue
+		}
+
+		b = append(b, byte(code), byte(len(v)))
+		b = append(b, v...)
+	}
+
+	b = append(b, dhcpOptionEnd)
+
+	// Some clients reject messages which are shorter than a BOOTP message.
+	for len(b) < 300 {
+		b = append(b, dhcpOptionPad)
+	}
+
+	return b
+}
+
+// serveDHCP answers the DHCP requests which are received on the provided
+// connection until it is closed.
+func (d *Daemon) serveDHCP(ctx context.Context, conn net.PacketConn) error {
+	buf := make([]byte, 1500)
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return fmt.Errorf("could not read DHCP request: %v", err)
+		}
+
+		req, err := parseDHCPMessage(buf[:n])
+		if err != nil {
+			log.G(ctx).Debugf("ignoring invalid DHCP message: %v", err)
+			continue
+		}
+
+		// Only requests from clients are answered.
+		if req.op != 1 {
+			continue
+		}
+
+		reply, err := d.handleDHCP(ctx, req)
+		if err != nil {
+			log.G(ctx).Warnf("could not answer DHCP request of %s: %v", req.chaddr, err)
+			continue
+		} else if reply == nil {
+			continue
+		}
+
+		// Clients which are not yet configured are answered via broadcast, since
+		// they cannot receive unicast replies.
+		dst := &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpClientPort}
+		if !req.ciaddr.Equal(net.IPv4zero) && req.flags&dhcpFlagBroadcast == 0 {
+			dst.IP = req.ciaddr
+		}
+
+		if _, err := conn.WriteTo(reply.marshal(), dst); err != nil {
+			log.G(ctx).Warnf("could not send DHCP reply to %s: %v", req.chaddr, err)
+		}
+	}
+}
+
+// handleDHCP returns the reply to the provided request, if any.
+func (d *Daemon) handleDHCP(ctx context.Context, req *dhcpMessage) (*dhcpMessage, error) {
+	mac := req.chaddr.String()
+
+	// Only answer requests which are addressed to this server, if they are
+	// addressed to a server at all.
+	d.mu.RLock()
+	gateway := d.gateway
+	d.mu.RUnlock()
+
+	if server := req.ip(dhcpOptionServerID); server != nil && !server.Equal(gateway) {
+		return nil, nil
+	}
+
+	switch req.messageType() {
+	case dhcpDiscover:
+		ip, err := d.address(mac, req.ip(dhcpOptionRequestedIP))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.reply(req, dhcpOffer, ip), nil
+
+	case dhcpRequest:
+		// The requested address is either provided as an option when selecting an
+		// offer or as the address of the client when renewing a lease.
+		requested := req.ip(dhcpOptionRequestedIP)
+		if requested == nil {
+			requested = req.ciaddr
+		}
+
+		ip, err := d.address(mac, requested)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ip.Equal(requested) {
+			return d.reply(req, dhcpNak, nil), nil
+		}
+
+		hostname := string(req.options[dhcpOptionHostname])
+		if hostname == "" {
+			hostname = d.hostname(mac)
+		}
+
+		if _, err := d.lease(mac, ip, hostname); err != nil {
+			log.G(ctx).Warnf("could not save lease of %s: %v", mac, err)
+		}
+
+		log.G(ctx).
+			WithField("mac", mac).
+			WithField("ip", ip.String()).
+			WithField("hostname", hostname).
+			Info("leased")
+
+		return d.reply(req, dhcpAck, ip), nil
+
+	case dhcpRelease:
+		if err := d.release(mac); err != nil {
+			log.G(ctx).Warnf("could not save release of %s: %v", mac, err)
+		}
+
+		return nil, nil
+
+	case dhcpDecline:
+		// The client has detected that the address which it was assigned is
+		// already in use on the link, such that it must not be handed out again.
+		declined := req.ip(dhcpOptionRequestedIP)
+		if declined == nil {
+			return nil, nil
+		}
+
+		log.G(ctx).
+			WithField("mac", mac).
+			WithField("ip", declined.String()).
+			Warn("address declined as already in use")
+
+		if err := d.decline(mac, declined); err != nil {
+			log.G(ctx).Warnf("could not save decline of %s: %v", mac, err)
+		}
+
+		return nil, nil
+
+	case dhcpInform:
+		// The client has configured its address itself and only requests the
+		// remaining parameters.
+		return d.reply(req, dhcpAck, nil), nil
+	}
+
+	return nil, nil
+}
+
+// reply prepares a reply of the provided type to the request which assigns the
+// provided address, if any.
+func (d *Daemon) reply(req *dhcpMessage, typ dhcpMessageType, ip net.IP) *dhcpMessage {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	reply := dhcpMessage{
+		op:     2,
+		xid:    req.xid,
+		flags:  req.flags,
+		ciaddr: req.ciaddr,
+		yiaddr: net.IPv4zero,
+		giaddr: req.giaddr,
+		chaddr: req.chaddr,
+		options: map[byte][]byte{
+			dhcpOptionMessageType: {byte(typ)},
+			dhcpOptionServerID:    d.gateway.To4(),
+		},
+	}
+
+	if ip != nil {
+		reply.yiaddr = ip
+	}
+
+	if typ == dhcpNak {
+		return &reply
+	}
+
+	broadcast := make(net.IP, net.IPv4len)
+	for i := range broadcast {
+		broadcast[i] = d.subnet.IP[i] | ^d.subnet.Mask[i]
+	}
+
+	reply.options[dhcpOptionSubnetMask] = []byte(d.subnet.Mask)
+	reply.options[dhcpOptionRouter] = d.gateway.To4()
+	reply.options[dhcpOptionDNS] = d.gateway.To4()
+	reply.options[dhcpOptionDomainName] = []byte(d.domain)
+	reply.options[dhcpOptionBroadcast] = broadcast
+
+	if typ != dhcpAck || ip != nil {
+		seconds := uint32(d.leaseTime.Seconds())
+		reply.options[dhcpOptionLeaseTime] = binary.BigEndian.AppendUint32(nil, seconds)
+		reply.options[dhcpOptionRenewalTime] = binary.BigEndian.AppendUint32(nil, seconds/2)
+		reply.options[dhcpOptionRebindTime] = binary.BigEndian.AppendUint32(nil, seconds/8*7)
+	}
+
+	if iface, ok := d.reserved[req.chaddr.String()]; ok && iface.Name != "" {
+		reply.options[dhcpOptionHostname] = []byte(iface.Name)
+	}
+
+	return &reply
+}
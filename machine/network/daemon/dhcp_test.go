@@ -0,0 +1,372 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+const (
+	testReservedMac = "02:00:00:00:00:01"
+	testClientMac   = "02:00:00:00:00:02"
+	testOtherMac    = "02:00:00:00:00:03"
+)
+
+// newTestDaemon returns a daemon for a network with the subnet 10.0.0.0/24, on
+// which the address 10.0.0.2 is reserved for the interface of the machine
+// named web.
+func newTestDaemon(t *testing.T) *Daemon {
+	t.Helper()
+
+	d, err := New(&networkv1alpha1.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Spec: networkv1alpha1.NetworkSpec{
+			Gateway: "10.0.0.1",
+			Netmask: "255.255.255.0",
+			DHCP:    true,
+			Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "web",
+					},
+					Spec: networkv1alpha1.NetworkInterfaceSpec{
+						MacAddress: testReservedMac,
+						IP:         "10.0.0.2",
+						IPv6:       "fd00::2",
+					},
+				},
+			},
+		},
+	}, WithUpstream())
+	if err != nil {
+		t.Fatalf("Could not create daemon: %v", err)
+	}
+
+	return d
+}
+
+// newDHCPPacket returns a request of a client with the provided hardware
+// address which is followed by the provided raw options.
+func newDHCPPacket(mac string, options ...byte) []byte {
+	hwaddr, _ := net.ParseMAC(mac)
+
+	b := make([]byte, dhcpHeaderLen)
+	b[0] = 1 // BOOTREQUEST
+	b[1] = 1 // Ethernet
+	b[2] = byte(len(hwaddr))
+	copy(b[4:8], []byte{0xde, 0xad, 0xbe, 0xef})
+	copy(b[28:], hwaddr)
+	copy(b[236:240], dhcpMagicCookie)
+
+	return append(b, options...)
+}
+
+// newDHCPRequest returns a request of the provided type of a client with the
+// provided hardware address and the provided requested address, if any.
+func newDHCPRequest(t *testing.T, typ dhcpMessageType, mac string, requested string) *dhcpMessage {
+	t.Helper()
+
+	options := []byte{dhcpOptionMessageType, 1, byte(typ)}
+	if requested != "" {
+		options = append(options, dhcpOptionRequestedIP, 4)
+		options = append(options, net.ParseIP(requested).To4()...)
+	}
+
+	msg, err := parseDHCPMessage(newDHCPPacket(mac, append(options, dhcpOptionEnd)...))
+	if err != nil {
+		t.Fatalf("Could not parse request: %v", err)
+	}
+
+	return msg
+}
+
+func TestParseDHCPMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		packet  []byte
+		options map[byte][]byte
+		wantErr bool
+	}{
+		{
+			name:    "without options",
+			packet:  newDHCPPacket(testClientMac),
+			options: map[byte][]byte{},
+		},
+		{
+			name: "with options",
+			packet: newDHCPPacket(testClientMac,
+				dhcpOptionMessageType, 1, byte(dhcpDiscover),
+				dhcpOptionHostname, 3, 'w', 'e', 'b',
+				dhcpOptionEnd,
+			),
+			options: map[byte][]byte{
+				dhcpOptionMessageType: {byte(dhcpDiscover)},
+				dhcpOptionHostname:    []byte("web"),
+			},
+		},
+		{
+			name: "with padding between options",
+			packet: newDHCPPacket(testClientMac,
+				dhcpOptionPad, dhcpOptionPad,
+				dhcpOptionMessageType, 1, byte(dhcpRequest),
+				dhcpOptionPad,
+				dhcpOptionRequestedIP, 4, 10, 0, 0, 3,
+				dhcpOptionEnd,
+			),
+			options: map[byte][]byte{
+				dhcpOptionMessageType: {byte(dhcpRequest)},
+				dhcpOptionRequestedIP: {10, 0, 0, 3},
+			},
+		},
+		{
+			name: "with trailing padding after end",
+			packet: newDHCPPacket(testClientMac,
+				dhcpOptionMessageType, 1, byte(dhcpRelease),
+				dhcpOptionEnd,
+				dhcpOptionPad, dhcpOptionPad, 0xff, 0x12,
+			),
+			options: map[byte][]byte{
+				dhcpOptionMessageType: {byte(dhcpRelease)},
+			},
+		},
+		{
+			name: "without end",
+			packet: newDHCPPacket(testClientMac,
+				dhcpOptionMessageType, 1, byte(dhcpInform),
+			),
+			options: map[byte][]byte{
+				dhcpOptionMessageType: {byte(dhcpInform)},
+			},
+		},
+		{
+			name:    "truncated option length",
+			packet:  newDHCPPacket(testClientMac, dhcpOptionMessageType),
+			wantErr: true,
+		},
+		{
+			name:    "truncated option value",
+			packet:  newDHCPPacket(testClientMac, dhcpOptionRequestedIP, 4, 10, 0),
+			wantErr: true,
+		},
+		{
+			name:    "too short",
+			packet:  newDHCPPacket(testClientMac)[:dhcpHeaderLen-1],
+			wantErr: true,
+		},
+		{
+			name: "invalid magic cookie",
+			packet: func() []byte {
+				b := newDHCPPacket(testClientMac, dhcpOptionEnd)
+				b[236] = 0
+				return b
+			}(),
+			wantErr: true,
+		},
+		{
+			name: "invalid hardware address length",
+			packet: func() []byte {
+				b := newDHCPPacket(testClientMac, dhcpOptionEnd)
+				b[2] = 17
+				return b
+			}(),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := parseDHCPMessage(tt.packet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if msg.op != 1 {
+				t.Errorf("Expected op 1, got %d", msg.op)
+			}
+
+			if msg.chaddr.String() != testClientMac {
+				t.Errorf("Expected hardware address %s, got %s", testClientMac, msg.chaddr)
+			}
+
+			if len(msg.options) != len(tt.options) {
+				t.Fatalf("Expected %d options, got %d", len(tt.options), len(msg.options))
+			}
+
+			for code, want := range tt.options {
+				if got := msg.options[code]; !bytes.Equal(got, want) {
+					t.Errorf("Expected option %d to be %v, got %v", code, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDHCPMessageMarshal(t *testing.T) {
+	mac, _ := net.ParseMAC(testClientMac)
+
+	msg := dhcpMessage{
+		op:     2,
+		xid:    [4]byte{1, 2, 3, 4},
+		flags:  dhcpFlagBroadcast,
+		ciaddr: net.IPv4zero,
+		yiaddr: net.ParseIP("10.0.0.3"),
+		giaddr: net.IPv4zero,
+		chaddr: mac,
+		options: map[byte][]byte{
+			dhcpOptionServerID:    {10, 0, 0, 1},
+			dhcpOptionMessageType: {byte(dhcpOffer)},
+			dhcpOptionSubnetMask:  {255, 255, 255, 0},
+		},
+	}
+
+	b := msg.marshal()
+
+	if len(b) < 300 {
+		t.Errorf("Expected message of at least 300 bytes, got %d", len(b))
+	}
+
+	if !bytes.Equal(b[236:240], dhcpMagicCookie) {
+		t.Errorf("Expected magic cookie, got %v", b[236:240])
+	}
+
+	// Options are written in the order of their codes.
+	wantOptions := []byte{
+		dhcpOptionSubnetMask, 4, 255, 255, 255, 0,
+		dhcpOptionMessageType, 1, byte(dhcpOffer),
+		dhcpOptionServerID, 4, 10, 0, 0, 1,
+		dhcpOptionEnd,
+	}
+	if got := b[dhcpHeaderLen : dhcpHeaderLen+len(wantOptions)]; !bytes.Equal(got, wantOptions) {
+		t.Errorf("Expected options %v, got %v", wantOptions, got)
+	}
+
+	parsed, err := parseDHCPMessage(b)
+	if err != nil {
+		t.Fatalf("Could not parse marshalled message: %v", err)
+	}
+
+	if parsed.op != msg.op || parsed.xid != msg.xid || parsed.flags != msg.flags {
+		t.Errorf("Expected header %d/%v/%x, got %d/%v/%x", msg.op, msg.xid, msg.flags, parsed.op, parsed.xid, parsed.flags)
+	}
+
+	if !parsed.yiaddr.Equal(msg.yiaddr) {
+		t.Errorf("Expected address %s, got %s", msg.yiaddr, parsed.yiaddr)
+	}
+
+	if parsed.chaddr.String() != testClientMac {
+		t.Errorf("Expected hardware address %s, got %s", testClientMac, parsed.chaddr)
+	}
+
+	if parsed.messageType() != dhcpOffer {
+		t.Errorf("Expected message type %d, got %d", dhcpOffer, parsed.messageType())
+	}
+}
+
+func TestHandleDHCP(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDaemon(t)
+
+	// step sends the request of the provided type and checks the type of the
+	// reply and the address which it assigns, where an empty type expects no
+	// reply.
+	step := func(name string, typ dhcpMessageType, mac, requested string, wantType dhcpMessageType, wantIP string) *dhcpMessage {
+		t.Helper()
+
+		reply, err := d.handleDHCP(ctx, newDHCPRequest(t, typ, mac, requested))
+		if err != nil {
+			t.Fatalf("%s: could not handle request: %v", name, err)
+		}
+
+		if wantType == 0 {
+			if reply != nil {
+				t.Fatalf("%s: expected no reply, got %d", name, reply.messageType())
+			}
+
+			return nil
+		}
+
+		if reply == nil {
+			t.Fatalf("%s: expected reply %d, got none", name, wantType)
+		}
+
+		if got := reply.messageType(); got != wantType {
+			t.Fatalf("%s: expected reply %d, got %d", name, wantType, got)
+		}
+
+		if got := reply.yiaddr.String(); wantIP != "" && got != wantIP {
+			t.Errorf("%s: expected address %s, got %s", name, wantIP, got)
+		}
+
+		if got := reply.ip(dhcpOptionServerID); !got.Equal(net.ParseIP("10.0.0.1")) {
+			t.Errorf("%s: expected server identifier 10.0.0.1, got %s", name, got)
+		}
+
+		return reply
+	}
+
+	// The first free address skips the gateway and the reserved address.
+	offer := step("discover", dhcpDiscover, testClientMac, "", dhcpOffer, "10.0.0.3")
+	if got := net.IP(offer.options[dhcpOptionSubnetMask]).String(); got != "255.255.255.0" {
+		t.Errorf("Expected subnet mask 255.255.255.0, got %s", got)
+	}
+	if got := offer.ip(dhcpOptionRouter); !got.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Expected router 10.0.0.1, got %s", got)
+	}
+	if got := offer.ip(dhcpOptionBroadcast); !got.Equal(net.ParseIP("10.0.0.255")) {
+		t.Errorf("Expected broadcast 10.0.0.255, got %s", got)
+	}
+
+	step("request offered address", dhcpRequest, testClientMac, "10.0.0.3", dhcpAck, "10.0.0.3")
+
+	// Addresses which are leased to or reserved for other interfaces are not
+	// handed out.
+	step("request leased address", dhcpRequest, testOtherMac, "10.0.0.3", dhcpNak, "0.0.0.0")
+	step("request reserved address", dhcpRequest, testOtherMac, "10.0.0.2", dhcpNak, "0.0.0.0")
+	step("discover with leased address", dhcpDiscover, testOtherMac, "10.0.0.3", dhcpOffer, "10.0.0.4")
+
+	// The reserved address is assigned along with the name of the machine.
+	reserved := step("discover reserved", dhcpDiscover, testReservedMac, "10.0.0.9", dhcpOffer, "10.0.0.2")
+	if got := string(reserved.options[dhcpOptionHostname]); got != "web" {
+		t.Errorf("Expected hostname web, got %q", got)
+	}
+
+	// Requests which select another server are ignored.
+	other := newDHCPRequest(t, dhcpRequest, testOtherMac, "10.0.0.4")
+	other.options[dhcpOptionServerID] = []byte{10, 0, 0, 254}
+	if reply, err := d.handleDHCP(ctx, other); err != nil || reply != nil {
+		t.Errorf("Expected request for another server to be ignored, got %v, %v", reply, err)
+	}
+
+	// A declined address is not handed out again, not even to the client which
+	// declined it.
+	step("decline", dhcpDecline, testClientMac, "10.0.0.3", 0, "")
+	step("discover after decline", dhcpDiscover, testClientMac, "10.0.0.3", dhcpOffer, "10.0.0.4")
+	step("request after decline", dhcpRequest, testOtherMac, "10.0.0.3", dhcpNak, "0.0.0.0")
+
+	// A released address is available again.
+	step("request", dhcpRequest, testClientMac, "10.0.0.4", dhcpAck, "10.0.0.4")
+	step("release", dhcpRelease, testClientMac, "", 0, "")
+	step("request after release", dhcpRequest, testOtherMac, "10.0.0.4", dhcpAck, "10.0.0.4")
+
+	// Clients which configured their address themselves only receive the
+	// remaining parameters.
+	inform := step("inform", dhcpInform, testOtherMac, "", dhcpAck, "0.0.0.0")
+	if _, ok := inform.options[dhcpOptionLeaseTime]; ok {
+		t.Errorf("Expected no lease time in reply to inform")
+	}
+}
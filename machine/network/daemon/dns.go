@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"kraftkit.sh/log"
+)
+
+const (
+	// dnsTTL is the time for which the answers about machines may be cached.
+	dnsTTL = 10
+
+	// dnsForwardTimeout is how long an upstream server is waited for.
+	dnsForwardTimeout = 2 * time.Second
+)
+
+// hostNameservers returns the addresses of the name servers which the host
+// uses, as listed in /etc/resolv.conf.
+func hostNameservers() []string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return []string{}
+	}
+
+	defer f.Close()
+
+	nameservers := []string{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+
+		nameservers = append(nameservers, net.JoinHostPort(fields[1], "53"))
+	}
+
+	return nameservers
+}
+
+// serveDNS answers the DNS queries which are received on the provided
+// connection until it is closed.
+func (d *Daemon) serveDNS(ctx context.Context, conn net.PacketConn) error {
+	for {
+		buf := make([]byte, 512)
+
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return fmt.Errorf("could not read DNS query: %v", err)
+		}
+
+		go func(query []byte, addr net.Addr) {
+			resp, err := d.handleDNS(ctx, query)
+			if err != nil {
+				log.G(ctx).Debugf("could not answer DNS query from %s: %v", addr, err)
+				return
+			}
+
+			if _, err := conn.WriteTo(resp, addr); err != nil {
+				log.G(ctx).Debugf("could not send DNS answer to %s: %v", addr, err)
+			}
+		}(buf[:n], addr)
+	}
+}
+
+// handleDNS returns the answer to the provided query.  Queries for names within
+// the domain of the daemon are answered from the machines on the network,
+// whereas any other query is forwarded upstream.
+func (d *Daemon) handleDNS(ctx context.Context, query []byte) ([]byte, error) {
+	var parser dnsmessage.Parser
+
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, err
+	}
+
+	question, err := parser.Question()
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(question.Name.String(), "."))
+
+	if name != d.domain && !strings.HasSuffix(name, "."+d.domain) {
+		resp, err := d.forward(query)
+		if err != nil {
+			log.G(ctx).Debugf("could not forward query for %s: %v", name, err)
+			return d.answer(header, question, dnsmessage.RCodeServerFailure, nil, nil)
+		}
+
+		return resp, nil
+	}
+
+	ipv4, ipv6 := d.lookup(strings.TrimSuffix(name, "."+d.domain))
+	if ipv4 == nil && ipv6 == nil {
+		return d.answer(header, question, dnsmessage.RCodeNameError, nil, nil)
+	}
+
+	return d.answer(header, question, dnsmessage.RCodeSuccess, ipv4, ipv6)
+}
+
+// answer builds the response to the provided question.  Address records are
+// only included if the question asks for them.
+func (d *Daemon) answer(query dnsmessage.Header, question dnsmessage.Question, rcode dnsmessage.RCode, ipv4, ipv6 net.IP) ([]byte, error) {
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 query.ID,
+		Response:           true,
+		Authoritative:      rcode != dnsmessage.RCodeServerFailure,
+		RecursionDesired:   query.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              rcode,
+	})
+	builder.EnableCompression()
+
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+
+	if err := builder.Question(question); err != nil {
+		return nil, err
+	}
+
+	if err := builder.StartAnswers(); err != nil {
+		return nil, err
+	}
+
+	if ipv4 != nil && (question.Type == dnsmessage.TypeA || question.Type == dnsmessage.TypeALL) {
+		var a [4]byte
+		copy(a[:], ipv4.To4())
+
+		if err := builder.AResource(dnsmessage.ResourceHeader{
+			Name:  question.Name,
+			Type:  dnsmessage.TypeA,
+			Class: dnsmessage.ClassINET,
+			TTL:   dnsTTL,
+		}, dnsmessage.AResource{A: a}); err != nil {
+			return nil, err
+		}
+	}
+
+	if ipv6 != nil && (question.Type == dnsmessage.TypeAAAA || question.Type == dnsmessage.TypeALL) {
+		var aaaa [16]byte
+		copy(aaaa[:], ipv6.To16())
+
+		if err := builder.AAAAResource(dnsmessage.ResourceHeader{
+			Name:  question.Name,
+			Type:  dnsmessage.TypeAAAA,
+			Class: dnsmessage.ClassINET,
+			TTL:   dnsTTL,
+		}, dnsmessage.AAAAResource{AAAA: aaaa}); err != nil {
+			return nil, err
+		}
+	}
+
+	return builder.Finish()
+}
+
+// forward sends the query to the upstream name servers in turn and returns the
+// first answer.
+func (d *Daemon) forward(query []byte) ([]byte, error) {
+	if len(d.upstream) == 0 {
+		return nil, fmt.Errorf("no upstream name server")
+	}
+
+	var errs []error
+
+	for _, upstream := range d.upstream {
+		resp, err := func() ([]byte, error) {
+			conn, err := net.DialTimeout("udp", upstream, dnsForwardTimeout)
+			if err != nil {
+				return nil, err
+			}
+
+			defer conn.Close()
+
+			if err := conn.SetDeadline(time.Now().Add(dnsForwardTimeout)); err != nil {
+				return nil, err
+			}
+
+			if _, err := conn.Write(query); err != nil {
+				return nil, err
+			}
+
+			buf := make([]byte, 4096)
+			n, err := conn.Read(buf)
+			if err != nil {
+				return nil, err
+			}
+
+			return buf[:n], nil
+		}()
+		if err == nil {
+			return resp, nil
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %v", upstream, err))
+	}
+
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package daemon
+
+import (
+	"context"
+	"net"
+	"sort"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestHandleDNS(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDaemon(t)
+
+	if _, err := d.lease(testClientMac, net.ParseIP("10.0.0.3"), "db_1"); err != nil {
+		t.Fatalf("Could not lease address: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		qname  string
+		qtype  dnsmessage.Type
+		rcode  dnsmessage.RCode
+		wantIP []string
+	}{
+		{
+			name:   "A of interface",
+			qname:  "web.kraft.",
+			qtype:  dnsmessage.TypeA,
+			rcode:  dnsmessage.RCodeSuccess,
+			wantIP: []string{"10.0.0.2"},
+		},
+		{
+			name:   "AAAA of interface",
+			qname:  "web.kraft.",
+			qtype:  dnsmessage.TypeAAAA,
+			rcode:  dnsmessage.RCodeSuccess,
+			wantIP: []string{"fd00::2"},
+		},
+		{
+			name:   "ANY of interface",
+			qname:  "WEB.Kraft.",
+			qtype:  dnsmessage.TypeALL,
+			rcode:  dnsmessage.RCodeSuccess,
+			wantIP: []string{"10.0.0.2", "fd00::2"},
+		},
+		{
+			name:   "A of lease with hyphenated name",
+			qname:  "db-1.kraft.",
+			qtype:  dnsmessage.TypeA,
+			rcode:  dnsmessage.RCodeSuccess,
+			wantIP: []string{"10.0.0.3"},
+		},
+		{
+			name:  "AAAA of lease without IPv6 address",
+			qname: "db-1.kraft.",
+			qtype: dnsmessage.TypeAAAA,
+			rcode: dnsmessage.RCodeSuccess,
+		},
+		{
+			name:  "MX of interface",
+			qname: "web.kraft.",
+			qtype: dnsmessage.TypeMX,
+			rcode: dnsmessage.RCodeSuccess,
+		},
+		{
+			name:  "unknown machine",
+			qname: "missing.kraft.",
+			qtype: dnsmessage.TypeA,
+			rcode: dnsmessage.RCodeNameError,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uint16(i + 1)
+			question := dnsmessage.Question{
+				Name:  dnsmessage.MustNewName(tt.qname),
+				Type:  tt.qtype,
+				Class: dnsmessage.ClassINET,
+			}
+
+			query, err := (&dnsmessage.Message{
+				Header: dnsmessage.Header{
+					ID:               id,
+					RecursionDesired: true,
+				},
+				Questions: []dnsmessage.Question{question},
+			}).Pack()
+			if err != nil {
+				t.Fatalf("Could not pack query: %v", err)
+			}
+
+			resp, err := d.handleDNS(ctx, query)
+			if err != nil {
+				t.Fatalf("Could not handle query: %v", err)
+			}
+
+			var msg dnsmessage.Message
+			if err := msg.Unpack(resp); err != nil {
+				t.Fatalf("Could not unpack answer: %v", err)
+			}
+
+			if msg.Header.ID != id || !msg.Header.Response || !msg.Header.Authoritative {
+				t.Errorf("Unexpected header: %+v", msg.Header)
+			}
+
+			if msg.Header.RCode != tt.rcode {
+				t.Errorf("Expected rcode %s, got %s", tt.rcode, msg.Header.RCode)
+			}
+
+			if len(msg.Questions) != 1 || msg.Questions[0] != question {
+				t.Errorf("Expected question %v, got %v", question, msg.Questions)
+			}
+
+			var got []string
+			for _, answer := range msg.Answers {
+				if answer.Header.Name != question.Name || answer.Header.TTL != dnsTTL {
+					t.Errorf("Unexpected answer header: %+v", answer.Header)
+				}
+
+				switch body := answer.Body.(type) {
+				case *dnsmessage.AResource:
+					got = append(got, net.IP(body.A[:]).String())
+				case *dnsmessage.AAAAResource:
+					got = append(got, net.IP(body.AAAA[:]).String())
+				default:
+					t.Errorf("Unexpected answer: %v", answer.Body)
+				}
+			}
+
+			sort.Strings(got)
+
+			if len(got) != len(tt.wantIP) {
+				t.Fatalf("Expected answers %v, got %v", tt.wantIP, got)
+			}
+
+			for i := range got {
+				if got[i] != tt.wantIP[i] {
+					t.Errorf("Expected answers %v, got %v", tt.wantIP, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package daemon
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+// LeasesFileName is the name of the file in the state directory of the daemon
+// in which the leases which it has handed out are saved.
+const LeasesFileName = "leases.json"
+
+// LoadLeases returns the leases which have been saved in the provided state
+// directory and which have not yet expired.
+func LoadLeases(dir string) ([]networkv1alpha1.NetworkLease, error) {
+	b, err := os.ReadFile(filepath.Join(dir, LeasesFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read leases: %w", err)
+	}
+
+	var leases []networkv1alpha1.NetworkLease
+	if err := json.Unmarshal(b, &leases); err != nil {
+		return nil, fmt.Errorf("could not unmarshal leases: %w", err)
+	}
+
+	now := time.Now()
+	ret := leases[:0]
+	for _, lease := range leases {
+		if lease.ExpiresAt.After(now) {
+			ret = append(ret, lease)
+		}
+	}
+
+	return ret, nil
+}
+
+// saveLeases writes the current leases into the state directory, such that
+// they are visible to other processes and survive a restart of the daemon.
+// The caller must hold the lock of the daemon.
+func (d *Daemon) saveLeases() error {
+	if d.stateDir == "" {
+		return nil
+	}
+
+	leases := make([]networkv1alpha1.NetworkLease, 0, len(d.leases))
+	for _, lease := range d.leases {
+		leases = append(leases, lease)
+	}
+
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].MacAddress < leases[j].MacAddress
+	})
+
+	b, err := json.MarshalIndent(leases, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal leases: %w", err)
+	}
+
+	if err := os.MkdirAll(d.stateDir, 0o755); err != nil {
+		return err
+	}
+
+	// Replace the file atomically since it is read by other processes.
+	tmp := filepath.Join(d.stateDir, LeasesFileName+".tmp")
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(d.stateDir, LeasesFileName))
+}
+
+// inUse returns whether the provided address is reserved for or leased to an
+// interface other than the one with the provided hardware address, or has been
+// declined by a client.  The caller must hold the lock of the daemon.
+func (d *Daemon) inUse(ip net.IP, mac string) bool {
+	for reservedMac, iface := range d.reserved {
+		if reservedMac != mac && ip.Equal(net.ParseIP(iface.Spec.IP)) {
+			return true
+		}
+	}
+
+	now := time.Now()
+	for leasedMac, lease := range d.leases {
+		if leasedMac != mac && lease.ExpiresAt.After(now) && ip.Equal(net.ParseIP(lease.IP)) {
+			return true
+		}
+	}
+
+	if until, ok := d.declined[ip.String()]; ok && until.After(now) {
+		return true
+	}
+
+	return false
+}
+
+// assignable returns whether the provided address can be handed out at all,
+// i.e. it is a host address of the subnet other than the gateway.  The caller
+// must hold the lock of the daemon.
+func (d *Daemon) assignable(ip net.IP) bool {
+	ip = ip.To4()
+	if ip == nil || !d.subnet.Contains(ip) || ip.Equal(d.gateway) {
+		return false
+	}
+
+	host := binary.BigEndian.Uint32(ip) &^ binary.BigEndian.Uint32(d.subnet.Mask)
+	hostmask := ^binary.BigEndian.Uint32(d.subnet.Mask)
+
+	return host != 0 && host != hostmask
+}
+
+// address determines the address of the interface with the provided hardware
+// address.  The address which is reserved for the interface by the network is
+// used if there is one, otherwise its previous lease or the requested address
+// is preferred over the first free address of the subnet.
+func (d *Daemon) address(mac string, requested net.IP) (net.IP, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if iface, ok := d.reserved[mac]; ok && iface.Spec.IP != "" {
+		return net.ParseIP(iface.Spec.IP).To4(), nil
+	}
+
+	if lease, ok := d.leases[mac]; ok {
+		if ip := net.ParseIP(lease.IP).To4(); d.assignable(ip) && !d.inUse(ip, mac) {
+			return ip, nil
+		}
+	}
+
+	if requested != nil && d.assignable(requested) && !d.inUse(requested, mac) {
+		return requested.To4(), nil
+	}
+
+	start := binary.BigEndian.Uint32(d.subnet.IP.To4())
+	size := ^binary.BigEndian.Uint32(d.subnet.Mask)
+
+	for offset := uint32(1); offset < size; offset++ {
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, start+offset)
+
+		if d.assignable(ip) && !d.inUse(ip, mac) {
+			return ip, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no free address in %s", d.subnet)
+}
+
+// lease records that the provided address has been leased to the interface
+// with the provided hardware address.
+func (d *Daemon) lease(mac string, ip net.IP, hostname string) (networkv1alpha1.NetworkLease, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lease := networkv1alpha1.NetworkLease{
+		MacAddress: mac,
+		IP:         ip.String(),
+		Hostname:   hostname,
+		ExpiresAt:  time.Now().Add(d.leaseTime),
+	}
+
+	d.leases[mac] = lease
+
+	return lease, d.saveLeases()
+}
+
+// release removes the lease of the interface with the provided hardware
+// address.
+func (d *Daemon) release(mac string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.leases[mac]; !ok {
+		return nil
+	}
+
+	delete(d.leases, mac)
+
+	return d.saveLeases()
+}
+
+// decline removes the lease of the interface with the provided hardware
+// address and marks the provided address, which the interface found to be
+// already in use, as unavailable for the duration of a lease.  Addresses which
+// are reserved by the network are nevertheless assigned to their interface.
+func (d *Daemon) decline(mac string, ip net.IP) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.declined[ip.To4().String()] = time.Now().Add(d.leaseTime)
+
+	if _, ok := d.leases[mac]; !ok {
+		return nil
+	}
+
+	delete(d.leases, mac)
+
+	return d.saveLeases()
+}
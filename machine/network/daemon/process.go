@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	goprocess "github.com/shirou/gopsutil/v3/process"
+
+	"kraftkit.sh/config"
+	"kraftkit.sh/exec"
+)
+
+const (
+	// PidFileName is the name of the file in the state directory of the daemon
+	// which contains the pid of its process.
+	PidFileName = "daemon.pid"
+
+	// LogFileName is the name of the file in the state directory of the daemon
+	// to which its process logs.
+	LogFileName = "daemon.log"
+
+	// spawnTimeout is the duration which a spawned daemon is given to start
+	// serving.
+	spawnTimeout = 10 * time.Second
+
+	// spawnPollInterval is how often a spawned daemon is checked for serving.
+	spawnPollInterval = 100 * time.Millisecond
+)
+
+// StateDir returns the directory in which the state of the daemon of the
+// network with the provided name is kept.
+func StateDir(ctx context.Context, name string) string {
+	return filepath.Join(config.G[config.KraftKit](ctx).RuntimeDir, "networks", name)
+}
+
+// Running returns the pid of the process of the daemon of the network with the
+// provided name, or zero if it is not running.
+func Running(ctx context.Context, name string) int32 {
+	b, err := os.ReadFile(filepath.Join(StateDir(ctx, name), PidFileName))
+	if err != nil {
+		return 0
+	}
+
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 32)
+	if err != nil {
+		return 0
+	}
+
+	if exists, _ := goprocess.PidExistsWithContext(ctx, int32(pid)); !exists {
+		return 0
+	}
+
+	return int32(pid)
+}
+
+// Spawn starts the daemon of the network with the provided driver and name in
+// the background via `kraft net serve`, unless it is already running.  It
+// returns once the daemon has written its pid file, which it only does once it
+// is serving, or with an error if the daemon exited or did not start in time.
+func Spawn(ctx context.Context, driver, name string) error {
+	if Running(ctx, name) > 0 {
+		return nil
+	}
+
+	dir := StateDir(ctx, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("could not create state directory of network daemon: %v", err)
+	}
+
+	bin, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("could not determine path of executable: %v", err)
+	}
+
+	logFile, err := os.OpenFile(
+		filepath.Join(dir, LogFileName),
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY,
+		0o644,
+	)
+	if err != nil {
+		return fmt.Errorf("could not open network daemon log file: %v", err)
+	}
+
+	defer logFile.Close()
+
+	process, err := exec.NewProcess(bin, []string{"net", "serve", "--driver", driver, name},
+		exec.WithDetach(true),
+		exec.WithStdout(logFile),
+		exec.WithStderr(logFile),
+	)
+	if err != nil {
+		return fmt.Errorf("could not prepare network daemon: %v", err)
+	}
+
+	if err := process.Start(ctx); err != nil {
+		return fmt.Errorf("could not start network daemon: %v", err)
+	}
+
+	pid, err := process.Pid()
+	if err != nil {
+		return fmt.Errorf("could not start network daemon: %v", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- process.Wait()
+	}()
+
+	timeout := time.NewTimer(spawnTimeout)
+	defer timeout.Stop()
+
+	ticker := time.NewTicker(spawnPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = fmt.Errorf("exited")
+			}
+
+			return fmt.Errorf("could not start network daemon: %v (see %s)", err, logFile.Name())
+
+		case <-timeout.C:
+			_ = process.Kill()
+			return fmt.Errorf("network daemon did not start within %s (see %s)", spawnTimeout, logFile.Name())
+
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-ticker.C:
+			if Running(ctx, name) == int32(pid) {
+				return nil
+			}
+		}
+	}
+}
+
+// Kill stops the daemon of the network with the provided name, if it is
+// running.
+func Kill(ctx context.Context, name string) error {
+	pid := Running(ctx, name)
+	if pid == 0 {
+		return nil
+	}
+
+	process, err := os.FindProcess(int(pid))
+	if err != nil {
+		return err
+	}
+
+	if err := process.Signal(syscall.SIGTERM); err != nil {
+		return fmt.Errorf("could not stop network daemon: %v", err)
+	}
+
+	return nil
+}
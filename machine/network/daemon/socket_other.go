@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// listenDHCP is not supported since bridge networks are only supported on
+// Linux.
+func listenDHCP(context.Context, string) (net.PacketConn, error) {
+	return nil, fmt.Errorf("serving DHCP is only supported on Linux")
+}
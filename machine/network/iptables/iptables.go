@@ -0,0 +1,412 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package iptables manages the host firewall rules which KraftKit needs in
+// order to route traffic to and from its machines.  All rules are placed in
+// dedicated chains and are tagged with the unique identifier of the resource
+// which owns them such that they can be removed once it no longer exists.
+// Traffic between machines on the same network is instead filtered by the
+// nftables package.
+package iptables
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	goiptables "github.com/coreos/go-iptables/iptables"
+	"github.com/google/shlex"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+const (
+	// TableNat is the name of the iptables table used for address translation.
+	TableNat = "nat"
+
+	// TableFilter is the name of the iptables table used for packet filtering.
+	TableFilter = "filter"
+
+	// ChainDNAT contains the destination address translation rules for ports
+	// which are published to machines.  It is jumped to from both the
+	// PREROUTING and OUTPUT chains so that published ports are reachable from
+	// remote hosts as well as from the host itself.
+	ChainDNAT = "KRAFTKIT-DNAT"
+
+	// ChainPostrouting contains the source address translation rules for traffic
+	// destined to or originating from machines.
+	ChainPostrouting = "KRAFTKIT-POSTROUTING"
+
+	// ChainForward contains the rules which accept forwarded traffic to and from
+	// machines.
+	ChainForward = "KRAFTKIT-FORWARD"
+
+	// commentPrefix is prepended to the owner of each rule.
+	commentPrefix = "kraftkit:"
+)
+
+// jumps represents the rules which direct traffic from the built-in chains to
+// the KraftKit-specific chains.
+var jumps = []rule{
+	{TableNat, "PREROUTING", []string{"-m", "addrtype", "--dst-type", "LOCAL", "-j", ChainDNAT}},
+	{TableNat, "OUTPUT", []string{"-m", "addrtype", "--dst-type", "LOCAL", "-j", ChainDNAT}},
+	{TableNat, "POSTROUTING", []string{"-j", ChainPostrouting}},
+	{TableFilter, "FORWARD", []string{"-j", ChainForward}},
+}
+
+// PortForward represents a port on the host which is forwarded to a port of a
+// machine.
+type PortForward struct {
+	// HostIP is the address on the host which the port is bound to.  When left
+	// empty, the port is bound to all addresses of the host.
+	HostIP string
+
+	// HostPort is the port on the host.
+	HostPort int32
+
+	// GuestIP is the address of the machine.
+	GuestIP string
+
+	// GuestPort is the port of the machine.
+	GuestPort int32
+
+	// Protocol is either "tcp" or "udp".
+	Protocol string
+}
+
+// New returns an iptables handle after ensuring that the KraftKit-specific
+// chains exist and that traffic is directed to them.
+func New() (*goiptables.IPTables, error) {
+	return NewWithProtocol(goiptables.ProtocolIPv4)
+}
+
+// NewWithProtocol returns an iptables handle for the provided protocol, i.e.
+// of ip6tables for IPv6, after ensuring that the KraftKit-specific chains exist
+// and that traffic is directed to them.
+func NewWithProtocol(proto goiptables.Protocol) (*goiptables.IPTables, error) {
+	ipt, err := goiptables.NewWithProtocol(proto)
+	if err != nil {
+		return nil, fmt.Errorf("could not initialize iptables: %v", err)
+	}
+
+	for _, chain := range []struct{ table, chain string }{
+		{TableNat, ChainDNAT},
+		{TableNat, ChainPostrouting},
+		{TableFilter, ChainForward},
+	} {
+		exists, err := ipt.ChainExists(chain.table, chain.chain)
+		if err != nil {
+			return nil, fmt.Errorf("could not check for chain %s: %v", chain.chain, err)
+		}
+
+		if exists {
+			continue
+		}
+
+		if err := ipt.NewChain(chain.table, chain.chain); err != nil {
+			return nil, fmt.Errorf("could not create chain %s: %v", chain.chain, err)
+		}
+	}
+
+	for _, jump := range jumps {
+		if err := ipt.AppendUnique(jump.table, jump.chain, jump.spec...); err != nil {
+			return nil, fmt.Errorf("could not add jump from %s to %s: %v", jump.chain, jump.spec[len(jump.spec)-1], err)
+		}
+	}
+
+	return ipt, nil
+}
+
+// comment returns the rule specification which tags a rule with its owner.
+func comment(owner string) []string {
+	return []string{"-m", "comment", "--comment", commentPrefix + owner}
+}
+
+// rule represents a rule specification which is appended to a chain.
+type rule struct {
+	table string
+	chain string
+	spec  []string
+}
+
+// appendRules appends each of the provided rules to its chain unless it already
+// exists.
+func appendRules(ipt *goiptables.IPTables, rules []rule) error {
+	for _, rule := range rules {
+		if err := ipt.AppendUnique(rule.table, rule.chain, rule.spec...); err != nil {
+			return fmt.Errorf("could not add rule '%s' to chain %s: %v", strings.Join(rule.spec, " "), rule.chain, err)
+		}
+	}
+
+	return nil
+}
+
+// AddPortForwards inserts the rules necessary to forward the provided ports
+// of the host to the machine attached to the host interface ifname.  Traffic
+// from the host itself (e.g. to localhost) is masqueraded so that the machine
+// is able to reply to it.
+func AddPortForwards(owner, ifname string, forwards ...PortForward) error {
+	ipt, err := New()
+	if err != nil {
+		return err
+	}
+
+	// Allow traffic originating from the loopback address to be routed towards
+	// the machine's interface.
+	if err := EnableRouteLocalnet(ifname); err != nil {
+		return err
+	}
+
+	if err := appendRules(ipt, portForwardRules(owner, ifname, forwards...)); err != nil {
+		return err
+	}
+
+	return EnableForwarding()
+}
+
+// portForwardRules returns the rules which AddPortForwards inserts.
+func portForwardRules(owner, ifname string, forwards ...PortForward) []rule {
+	rules := []rule{
+		{TableNat, ChainPostrouting, append([]string{
+			"-o", ifname,
+			"-m", "addrtype", "--src-type", "LOCAL",
+			"-j", "MASQUERADE",
+		}, comment(owner)...)},
+	}
+
+	for _, forward := range forwards {
+		protocol := strings.ToLower(forward.Protocol)
+		if protocol == "" {
+			protocol = "tcp"
+		}
+
+		dnat := []string{"-p", protocol}
+		if forward.HostIP != "" && forward.HostIP != "0.0.0.0" {
+			dnat = append(dnat, "-d", forward.HostIP)
+		}
+		dnat = append(dnat,
+			"--dport", fmt.Sprintf("%d", forward.HostPort),
+			"-j", "DNAT",
+			"--to-destination", fmt.Sprintf("%s:%d", forward.GuestIP, forward.GuestPort),
+		)
+
+		rules = append(rules,
+			rule{TableNat, ChainDNAT, append(dnat, comment(owner)...)},
+			rule{TableFilter, ChainForward, append([]string{
+				"-o", ifname,
+				"-p", protocol,
+				"-d", forward.GuestIP,
+				"--dport", fmt.Sprintf("%d", forward.GuestPort),
+				"-j", "ACCEPT",
+			}, comment(owner)...)},
+		)
+	}
+
+	return append(rules, rule{TableFilter, ChainForward, append([]string{
+		"-i", ifname,
+		"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED",
+		"-j", "ACCEPT",
+	}, comment(owner)...)})
+}
+
+// AddMasquerade inserts the rules necessary for machines on the provided
+// subnet, which is attached to the host interface ifname, to reach hosts beyond
+// the host.  Traffic which leaves the host via any other interface is
+// masqueraded behind the address of that interface and only replies to it are
+// accepted back into the subnet.  Both IPv4 and IPv6 subnets are supported.
+func AddMasquerade(owner, ifname, subnet string) error {
+	ip, _, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return fmt.Errorf("invalid subnet %s: %v", subnet, err)
+	}
+
+	proto := goiptables.ProtocolIPv4
+	if ip.To4() == nil {
+		proto = goiptables.ProtocolIPv6
+	}
+
+	ipt, err := NewWithProtocol(proto)
+	if err != nil {
+		return err
+	}
+
+	if err := appendRules(ipt, masqueradeRules(owner, ifname, subnet)); err != nil {
+		return err
+	}
+
+	if proto == goiptables.ProtocolIPv6 {
+		return EnableForwardingIPv6()
+	}
+
+	return EnableForwarding()
+}
+
+// masqueradeRules returns the rules which AddMasquerade inserts.
+func masqueradeRules(owner, ifname, subnet string) []rule {
+	return []rule{
+		{TableNat, ChainPostrouting, append([]string{
+			"-s", subnet,
+			"!", "-o", ifname,
+			"-j", "MASQUERADE",
+		}, comment(owner)...)},
+		{TableFilter, ChainForward, append([]string{
+			"-i", ifname,
+			"!", "-o", ifname,
+			"-j", "ACCEPT",
+		}, comment(owner)...)},
+		{TableFilter, ChainForward, append([]string{
+			"-o", ifname,
+			"-m", "conntrack", "--ctstate", "RELATED,ESTABLISHED",
+			"-j", "ACCEPT",
+		}, comment(owner)...)},
+	}
+}
+
+// RemoveRules removes all rules from the KraftKit-specific chains of both
+// iptables and ip6tables which are owned by the provided owner.  It is safe to
+// call this method multiple times.
+func RemoveRules(owner string) error {
+	ipt, err := New()
+	if err != nil {
+		return err
+	}
+
+	if err := removeRules(ipt, commentPrefix+owner); err != nil {
+		return err
+	}
+
+	// No rules can have been added if the host does not support ip6tables.
+	ipt, err = NewWithProtocol(goiptables.ProtocolIPv6)
+	if err != nil {
+		return nil
+	}
+
+	return removeRules(ipt, commentPrefix+owner)
+}
+
+// removeRules removes all rules from the KraftKit-specific chains of the
+// provided handle which are tagged with the provided tag.
+func removeRules(ipt *goiptables.IPTables, tag string) error {
+	for _, chain := range []struct{ table, chain string }{
+		{TableNat, ChainDNAT},
+		{TableNat, ChainPostrouting},
+		{TableFilter, ChainForward},
+	} {
+		rules, err := ipt.List(chain.table, chain.chain)
+		if err != nil {
+			return fmt.Errorf("could not list rules of chain %s: %v", chain.chain, err)
+		}
+
+		for _, rule := range rules {
+			if !strings.Contains(rule, tag) {
+				continue
+			}
+
+			// Rules are listed in the form of "-A CHAIN <spec...>".
+			spec, err := shlex.Split(rule)
+			if err != nil {
+				return fmt.Errorf("could not parse rule '%s': %v", rule, err)
+			}
+
+			if len(spec) < 3 || !ownedBy(spec, tag) {
+				continue
+			}
+
+			if err := ipt.DeleteIfExists(chain.table, chain.chain, spec[2:]...); err != nil {
+				return fmt.Errorf("could not delete rule '%s': %v", rule, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ownedBy returns whether the rule specification is tagged with exactly the
+// provided tag, such that an owner whose name is the prefix of another's does
+// not match the rules of the other.
+func ownedBy(spec []string, tag string) bool {
+	for i := 0; i < len(spec)-1; i++ {
+		if spec[i] == "--comment" && spec[i+1] == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EnableForwarding turns on IPv4 packet forwarding on the host.
+func EnableForwarding() error {
+	return writeSysctl("/proc/sys/net/ipv4/ip_forward", "1")
+}
+
+// EnableForwardingIPv6 turns on IPv6 packet forwarding on the host.  Note that
+// interfaces of the host then no longer accept router advertisements unless
+// their accept_ra setting is 2.
+func EnableForwardingIPv6() error {
+	return writeSysctl("/proc/sys/net/ipv6/conf/all/forwarding", "1")
+}
+
+// EnableRouteLocalnet allows packets with a loopback source or destination
+// address to be routed via the provided interface.
+func EnableRouteLocalnet(ifname string) error {
+	return writeSysctl(filepath.Join("/proc/sys/net/ipv4/conf", ifname, "route_localnet"), "1")
+}
+
+// writeSysctl writes the value to the provided procfs path if it is not
+// already set.
+func writeSysctl(path, value string) error {
+	current, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(current)) == value {
+		return nil
+	}
+
+	if err := os.WriteFile(path, []byte(value), 0o644); err != nil {
+		return fmt.Errorf("could not set %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// PortForwardsFromMachinePorts converts the ports of a machine into the list of
+// port forwards to the provided address of the machine.
+func PortForwardsFromMachinePorts(ports machinev1alpha1.MachinePorts, guestIP string) []PortForward {
+	forwards := make([]PortForward, len(ports))
+
+	for i, port := range ports {
+		hostPort := port.HostPort
+		if hostPort == 0 {
+			hostPort = port.MachinePort
+		}
+
+		forwards[i] = PortForward{
+			HostIP:    port.HostIP,
+			HostPort:  hostPort,
+			GuestIP:   guestIP,
+			GuestPort: port.MachinePort,
+			Protocol:  string(port.Protocol),
+		}
+	}
+
+	return forwards
+}
+
+// MachineAddress returns the host interface of the first network which the
+// machine is attached to and the IPv4 address of the machine on it, to which
+// its published ports can be forwarded.  Both are empty if the machine is not
+// attached to a network or has no IPv4 address on it.
+func MachineAddress(machine *machinev1alpha1.Machine) (string, string) {
+	if len(machine.Spec.Networks) == 0 || len(machine.Spec.Networks[0].Interfaces) == 0 {
+		return "", ""
+	}
+
+	network := machine.Spec.Networks[0]
+	if network.Interfaces[0].Spec.IP == "" {
+		return "", ""
+	}
+
+	return network.IfName, network.Interfaces[0].Spec.IP
+}
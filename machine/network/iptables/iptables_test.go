@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package iptables
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+// ruleStrings returns the provided rules in the form of "table chain spec...".
+func ruleStrings(rules []rule) []string {
+	ret := make([]string, len(rules))
+	for i, rule := range rules {
+		ret[i] = rule.table + " " + rule.chain + " " + strings.Join(rule.spec, " ")
+	}
+
+	return ret
+}
+
+func TestPortForwardRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		forwards []PortForward
+		want     []string
+	}{
+		{
+			name: "no ports",
+			want: []string{
+				"nat KRAFTKIT-POSTROUTING -o tap0 -m addrtype --src-type LOCAL -j MASQUERADE -m comment --comment kraftkit:owner",
+				"filter KRAFTKIT-FORWARD -i tap0 -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT -m comment --comment kraftkit:owner",
+			},
+		},
+		{
+			name: "ports on all and one host address",
+			forwards: []PortForward{
+				{HostPort: 8080, GuestIP: "172.30.0.2", GuestPort: 80},
+				{HostIP: "0.0.0.0", HostPort: 53, GuestIP: "172.30.0.2", GuestPort: 53, Protocol: "UDP"},
+				{HostIP: "127.0.0.1", HostPort: 443, GuestIP: "172.30.0.2", GuestPort: 8443, Protocol: "tcp"},
+			},
+			want: []string{
+				"nat KRAFTKIT-POSTROUTING -o tap0 -m addrtype --src-type LOCAL -j MASQUERADE -m comment --comment kraftkit:owner",
+				"nat KRAFTKIT-DNAT -p tcp --dport 8080 -j DNAT --to-destination 172.30.0.2:80 -m comment --comment kraftkit:owner",
+				"filter KRAFTKIT-FORWARD -o tap0 -p tcp -d 172.30.0.2 --dport 80 -j ACCEPT -m comment --comment kraftkit:owner",
+				"nat KRAFTKIT-DNAT -p udp --dport 53 -j DNAT --to-destination 172.30.0.2:53 -m comment --comment kraftkit:owner",
+				"filter KRAFTKIT-FORWARD -o tap0 -p udp -d 172.30.0.2 --dport 53 -j ACCEPT -m comment --comment kraftkit:owner",
+				"nat KRAFTKIT-DNAT -p tcp -d 127.0.0.1 --dport 443 -j DNAT --to-destination 172.30.0.2:8443 -m comment --comment kraftkit:owner",
+				"filter KRAFTKIT-FORWARD -o tap0 -p tcp -d 172.30.0.2 --dport 8443 -j ACCEPT -m comment --comment kraftkit:owner",
+				"filter KRAFTKIT-FORWARD -i tap0 -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT -m comment --comment kraftkit:owner",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ruleStrings(portForwardRules("owner", "tap0", tt.forwards...))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unexpected rules:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestMasqueradeRules(t *testing.T) {
+	want := []string{
+		"nat KRAFTKIT-POSTROUTING -s fd00:cafe::1/64 ! -o kraft0 -j MASQUERADE -m comment --comment kraftkit:owner",
+		"filter KRAFTKIT-FORWARD -i kraft0 ! -o kraft0 -j ACCEPT -m comment --comment kraftkit:owner",
+		"filter KRAFTKIT-FORWARD -o kraft0 -m conntrack --ctstate RELATED,ESTABLISHED -j ACCEPT -m comment --comment kraftkit:owner",
+	}
+
+	got := ruleStrings(masqueradeRules("owner", "kraft0", "fd00:cafe::1/64"))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected rules:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestOwnedBy(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want bool
+	}{
+		{
+			name: "owned",
+			rule: "-A KRAFTKIT-DNAT -p tcp -m comment --comment kraftkit:abc -j DNAT",
+			want: true,
+		},
+		{
+			name: "owner is prefix of other",
+			rule: "-A KRAFTKIT-DNAT -p tcp -m comment --comment kraftkit:abcdef -j DNAT",
+		},
+		{
+			name: "tag outside of comment",
+			rule: "-A KRAFTKIT-FORWARD -i kraftkit:abc -j ACCEPT",
+		},
+		{
+			name: "comment without value",
+			rule: "-A KRAFTKIT-FORWARD -j ACCEPT --comment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ownedBy(strings.Fields(tt.rule), "kraftkit:abc"); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPortForwardsFromMachinePorts(t *testing.T) {
+	ports := machinev1alpha1.MachinePorts{
+		{HostPort: 8080, MachinePort: 80, Protocol: corev1.ProtocolTCP},
+		{MachinePort: 53, Protocol: corev1.ProtocolUDP, HostIP: "127.0.0.1"},
+	}
+
+	want := []PortForward{
+		{HostPort: 8080, GuestIP: "10.0.0.2", GuestPort: 80, Protocol: "TCP"},
+		{HostIP: "127.0.0.1", HostPort: 53, GuestIP: "10.0.0.2", GuestPort: 53, Protocol: "UDP"},
+	}
+
+	if got := PortForwardsFromMachinePorts(ports, "10.0.0.2"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if got := PortForwardsFromMachinePorts(nil, "10.0.0.2"); len(got) != 0 {
+		t.Errorf("Expected no port forwards, got %+v", got)
+	}
+}
+
+func TestMachineAddress(t *testing.T) {
+	iface := func(ip, ipv6 string) networkv1alpha1.NetworkInterfaceTemplateSpec {
+		return networkv1alpha1.NetworkInterfaceTemplateSpec{
+			Spec: networkv1alpha1.NetworkInterfaceSpec{
+				IP:   ip,
+				IPv6: ipv6,
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		networks []networkv1alpha1.NetworkSpec
+		ifname   string
+		ip       string
+	}{
+		{
+			name: "no networks",
+		},
+		{
+			name: "no interfaces",
+			networks: []networkv1alpha1.NetworkSpec{
+				{IfName: "kraft0"},
+			},
+		},
+		{
+			name: "IPv6 only",
+			networks: []networkv1alpha1.NetworkSpec{
+				{IfName: "kraft0", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{iface("", "fd00:cafe::2")}},
+			},
+		},
+		{
+			name: "first network",
+			networks: []networkv1alpha1.NetworkSpec{
+				{IfName: "kraft0", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{iface("10.0.0.2", "fd00:cafe::2")}},
+				{IfName: "kraft1", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{iface("10.1.0.2", "")}},
+			},
+			ifname: "kraft0",
+			ip:     "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &machinev1alpha1.Machine{
+				Spec: machinev1alpha1.MachineSpec{
+					Networks: tt.networks,
+				},
+			}
+
+			ifname, ip := MachineAddress(machine)
+			if ifname != tt.ifname || ip != tt.ip {
+				t.Errorf("Expected (%q, %q), got (%q, %q)", tt.ifname, tt.ip, ifname, ip)
+			}
+		})
+	}
+}
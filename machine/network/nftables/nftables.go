@@ -0,0 +1,370 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package nftables enforces the policies of networks on the host.  The rules
+// of each network are kept in a dedicated table of the bridge family, which
+// filters the traffic of each tap interface on the bridge of the network,
+// whether it is exchanged with another machine, the host or hosts beyond it.
+// The table is replaced atomically whenever the network changes.
+//
+// Replies are recognized by connection tracking of bridged traffic, which
+// requires Linux 5.3 or newer.
+//
+// Unlike the rules of the iptables package, which translate and forward the
+// traffic that is routed by the host, these rules filter traffic between
+// machines on the same bridge.  Such traffic is never routed and hence not
+// seen by iptables unless br_netfilter is loaded, which in turn subjects the
+// traffic of every bridge on the host to the rules of iptables.  The bridge
+// family of nftables instead allows the traffic of only the bridges of
+// KraftKit to be filtered, without affecting the remainder of the host.
+package nftables
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	"kraftkit.sh/exec"
+)
+
+// TablePrefix is prepended to the name of a network to form the name of the
+// table which contains its rules.
+const TablePrefix = "kraftkit-"
+
+// ApplyPolicy replaces the rules which enforce the policy of the provided
+// network with ones that reflect its current policy and interfaces.  The rules
+// are removed if the network has no policy.
+func ApplyPolicy(ctx context.Context, network *networkv1alpha1.Network) error {
+	policy := network.Spec.Policy
+	if policy == nil || (!policy.DefaultDeny && len(policy.Rules) == 0) {
+		return RemovePolicy(ctx, network.Name)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid policy of network %s: %v", network.Name, err)
+	}
+
+	if err := run(ctx, Ruleset(network)); err != nil {
+		return fmt.Errorf("could not apply policy of network %s: %v", network.Name, err)
+	}
+
+	return nil
+}
+
+// RemovePolicy removes the rules which enforce the policy of the network with
+// the provided name.  It is safe to call this method multiple times.
+func RemovePolicy(ctx context.Context, name string) error {
+	table := TablePrefix + name
+
+	// Declaring the table first ensures that deleting it does not fail if it
+	// does not exist.
+	if err := run(ctx, fmt.Sprintf("table bridge %s\ndelete table bridge %s\n", table, table)); err != nil {
+		return fmt.Errorf("could not remove policy of network %s: %v", name, err)
+	}
+
+	return nil
+}
+
+// run passes the provided script to nft.
+func run(ctx context.Context, script string) error {
+	var stderr bytes.Buffer
+
+	process, err := exec.NewProcess("nft", []string{"-f", "-"},
+		exec.WithStdin(strings.NewReader(script)),
+		exec.WithStderr(&stderr),
+	)
+	if err != nil {
+		return fmt.Errorf("could not prepare nft: %v", err)
+	}
+
+	if err := process.StartAndWait(ctx); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// Ruleset returns the nft script which replaces the table of the provided
+// network with one that enforces its policy.
+func Ruleset(network *networkv1alpha1.Network) string {
+	var (
+		b       strings.Builder
+		table   = TablePrefix + network.Name
+		forward []string
+		input   []string
+		output  []string
+		chains  []string
+	)
+
+	for i, iface := range network.Spec.Interfaces {
+		if iface.Spec.IfName == "" {
+			continue
+		}
+
+		egress := fmt.Sprintf("egress-%d", i)
+		ingress := fmt.Sprintf("ingress-%d", i)
+
+		// Traffic which a machine sends is either bridged to another machine or
+		// received by the host, whereas traffic which a machine receives is either
+		// bridged from another machine or sent by the host.
+		forward = append(forward,
+			fmt.Sprintf("iifname %q jump %s", iface.Spec.IfName, egress),
+			fmt.Sprintf("oifname %q jump %s", iface.Spec.IfName, ingress),
+		)
+		input = append(input, fmt.Sprintf("iifname %q jump %s", iface.Spec.IfName, egress))
+		output = append(output, fmt.Sprintf("oifname %q jump %s", iface.Spec.IfName, ingress))
+
+		comment := fmt.Sprintf("# %s (%s)", iface.Spec.IfName, iface.Name)
+
+		chains = append(chains,
+			chain(egress, comment, egressRules(network, iface)),
+			chain(ingress, comment, ingressRules(network, iface)),
+		)
+	}
+
+	// Neither non-IP traffic, e.g. ARP, nor replies to traffic which has been
+	// allowed are restricted.
+	base := []string{
+		"ether type != { ip, ip6 } accept",
+		"ct state established,related accept",
+	}
+
+	fmt.Fprintf(&b, "table bridge %s\n", table)
+	fmt.Fprintf(&b, "delete table bridge %s\n", table)
+	fmt.Fprintf(&b, "table bridge %s {\n", table)
+
+	for _, hook := range []struct {
+		name  string
+		rules []string
+	}{
+		{"forward", forward},
+		{"input", input},
+		{"output", output},
+	} {
+		b.WriteString(chain(hook.name,
+			fmt.Sprintf("type filter hook %s priority filter; policy accept;", hook.name),
+			append(append([]string{}, base...), hook.rules...),
+		))
+	}
+
+	for _, c := range chains {
+		b.WriteString(c)
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// chain returns the definition of a chain with the provided header, i.e. its
+// type or a comment, and rules.
+func chain(name, header string, rules []string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\tchain %s {\n", name)
+	fmt.Fprintf(&b, "\t\t%s\n", header)
+
+	for _, rule := range rules {
+		fmt.Fprintf(&b, "\t\t%s\n", rule)
+	}
+
+	b.WriteString("\t}\n")
+
+	return b.String()
+}
+
+// egressRules returns the rules of the chain which filters the traffic sent by
+// the provided interface.  Rules which allow traffic return to the calling
+// chain, such that the traffic is subsequently subject to the ingress rules of
+// the receiving machine.
+func egressRules(network *networkv1alpha1.Network, iface networkv1alpha1.NetworkInterfaceTemplateSpec) []string {
+	var rules []string
+
+	// Since peers are recognized by their addresses, machines must not send
+	// traffic from any other than their own, apart from the unspecified and
+	// link-local addresses which are used to obtain an address.
+	if iface.Spec.IP != "" {
+		rules = append(rules, fmt.Sprintf("ip saddr != { 0.0.0.0, %s } drop", iface.Spec.IP))
+	}
+	if iface.Spec.IPv6 != "" {
+		rules = append(rules, fmt.Sprintf("ip6 saddr != { ::, fe80::/10, %s } drop", iface.Spec.IPv6))
+	}
+
+	if !network.Spec.Policy.Isolated(networkv1alpha1.NetworkPolicyDirectionEgress, iface.Labels) {
+		return rules
+	}
+
+	rules = append(rules, "icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-solicit } return")
+
+	// The daemon of the network remains reachable such that machines can still
+	// obtain an address and resolve names.
+	if network.Spec.DHCP {
+		rules = append(rules, "udp sport 68 udp dport 67 return")
+		if gateway := gatewayIP(network); gateway != "" {
+			rules = append(rules, fmt.Sprintf("ip daddr %s meta l4proto { tcp, udp } th dport 53 return", gateway))
+		}
+	}
+
+	rules = append(rules, allowRules(network, iface, networkv1alpha1.NetworkPolicyDirectionEgress)...)
+
+	return append(rules, "drop")
+}
+
+// ingressRules returns the rules of the chain which filters the traffic
+// received by the provided interface.
+func ingressRules(network *networkv1alpha1.Network, iface networkv1alpha1.NetworkInterfaceTemplateSpec) []string {
+	if !network.Spec.Policy.Isolated(networkv1alpha1.NetworkPolicyDirectionIngress, iface.Labels) {
+		return nil
+	}
+
+	rules := []string{
+		"icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-advert } return",
+	}
+
+	// Offers of the daemon of the network are not recognized as replies, since
+	// they are sent in response to broadcast requests.
+	if network.Spec.DHCP {
+		rules = append(rules, "udp sport 67 udp dport 68 return")
+	}
+
+	rules = append(rules, allowRules(network, iface, networkv1alpha1.NetworkPolicyDirectionIngress)...)
+
+	return append(rules, "drop")
+}
+
+// allowRules returns the rules which allow the traffic in the provided
+// direction that the rules of the policy of the network permit for the
+// provided interface.
+func allowRules(network *networkv1alpha1.Network, iface networkv1alpha1.NetworkInterfaceTemplateSpec, direction networkv1alpha1.NetworkPolicyDirection) []string {
+	// The address of the peer is the source of ingress and the destination of
+	// egress traffic.
+	field := "saddr"
+	if direction == networkv1alpha1.NetworkPolicyDirectionEgress {
+		field = "daddr"
+	}
+
+	var rules []string
+
+	for _, rule := range network.Spec.Policy.Rules {
+		if rule.Direction != direction || !rule.AppliesTo(iface.Labels) {
+			continue
+		}
+
+		l4 := l4Match(rule)
+
+		if rule.Peers == "" && len(rule.CIDRs) == 0 {
+			rules = append(rules, expr(l4, "return"))
+			continue
+		}
+
+		// A rule whose peers select none of the machines on the network and
+		// which has no CIDRs allows no traffic.
+		for _, addrs := range addressMatches(network, rule, field) {
+			rules = append(rules, expr(addrs, l4, "return"))
+		}
+	}
+
+	return rules
+}
+
+// expr joins the provided non-empty statements of a rule.
+func expr(statements ...string) string {
+	var ret []string
+	for _, statement := range statements {
+		if statement != "" {
+			ret = append(ret, statement)
+		}
+	}
+
+	return strings.Join(ret, " ")
+}
+
+// l4Match returns the expression which matches the protocol and ports of the
+// provided rule, or an empty string if the rule allows any traffic.
+func l4Match(rule networkv1alpha1.NetworkPolicyRule) string {
+	if len(rule.Ports) > 0 {
+		protocol := "{ tcp, udp }"
+		if rule.Protocol != "" {
+			protocol = rule.Protocol
+		}
+
+		ports := make([]string, len(rule.Ports))
+		for i, port := range rule.Ports {
+			from, to, _ := networkv1alpha1.ParsePortRange(port)
+			if from == to {
+				ports[i] = fmt.Sprintf("%d", from)
+			} else {
+				ports[i] = fmt.Sprintf("%d-%d", from, to)
+			}
+		}
+
+		return fmt.Sprintf("meta l4proto %s th dport { %s }", protocol, strings.Join(ports, ", "))
+	}
+
+	switch rule.Protocol {
+	case "icmp":
+		return "meta l4proto { icmp, ipv6-icmp }"
+	case "":
+		return ""
+	default:
+		return fmt.Sprintf("meta l4proto %s", rule.Protocol)
+	}
+}
+
+// addressMatches returns the expressions which match the peers and subnets
+// from or to which the provided rule allows traffic by the provided field of
+// the IP header.  Subnets are matched by separate expressions, since the
+// elements of a set must not overlap.
+func addressMatches(network *networkv1alpha1.Network, rule networkv1alpha1.NetworkPolicyRule, field string) []string {
+	var ipv4, ipv6, matches []string
+
+	for _, peer := range network.Spec.Interfaces {
+		if !rule.AllowsPeer(peer.Labels) {
+			continue
+		}
+
+		if peer.Spec.IP != "" {
+			ipv4 = append(ipv4, peer.Spec.IP)
+		}
+		if peer.Spec.IPv6 != "" {
+			ipv6 = append(ipv6, peer.Spec.IPv6)
+		}
+	}
+
+	if len(ipv4) > 0 {
+		matches = append(matches, fmt.Sprintf("ip %s { %s }", field, strings.Join(ipv4, ", ")))
+	}
+	if len(ipv6) > 0 {
+		matches = append(matches, fmt.Sprintf("ip6 %s { %s }", field, strings.Join(ipv6, ", ")))
+	}
+
+	for _, cidr := range rule.CIDRs {
+		ip, subnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+
+		if ip.To4() != nil {
+			matches = append(matches, fmt.Sprintf("ip %s %s", field, subnet))
+		} else {
+			matches = append(matches, fmt.Sprintf("ip6 %s %s", field, subnet))
+		}
+	}
+
+	return matches
+}
+
+// gatewayIP returns the IPv4 address of the host on the provided network.
+func gatewayIP(network *networkv1alpha1.Network) string {
+	subnet, err := network.Spec.IPv4Subnet()
+	if err != nil || subnet == nil {
+		return ""
+	}
+
+	return subnet.IP.String()
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package nftables
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+)
+
+func TestRuleset(t *testing.T) {
+	tests := []struct {
+		name    string
+		network *networkv1alpha1.Network
+		want    string
+	}{
+		{
+			name: "rules with DHCP",
+			network: &networkv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "demo",
+				},
+				Spec: networkv1alpha1.NetworkSpec{
+					Gateway: "172.100.0.1",
+					Netmask: "255.255.255.0",
+					DHCP:    true,
+					Policy: &networkv1alpha1.NetworkPolicy{
+						Rules: []networkv1alpha1.NetworkPolicyRule{
+							{
+								Direction: networkv1alpha1.NetworkPolicyDirectionIngress,
+								Machines:  "role=db",
+								Peers:     "role=web",
+								Protocol:  "tcp",
+								Ports:     []string{"5432"},
+							},
+							{
+								Direction: networkv1alpha1.NetworkPolicyDirectionEgress,
+								Machines:  "role=web",
+								CIDRs:     []string{"0.0.0.0/0"},
+								Ports:     []string{"80", "443"},
+							},
+						},
+					},
+					Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:   "web",
+								Labels: map[string]string{"role": "web"},
+							},
+							Spec: networkv1alpha1.NetworkInterfaceSpec{
+								IfName: "tap-web",
+								IP:     "172.100.0.2",
+								IPv6:   "fd00::2",
+							},
+						},
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:   "db",
+								Labels: map[string]string{"role": "db"},
+							},
+							Spec: networkv1alpha1.NetworkInterfaceSpec{
+								IfName: "tap-db",
+								IP:     "172.100.0.3",
+							},
+						},
+					},
+				},
+			},
+			want: `table bridge kraftkit-demo
+delete table bridge kraftkit-demo
+table bridge kraftkit-demo {
+	chain forward {
+		type filter hook forward priority filter; policy accept;
+		ether type != { ip, ip6 } accept
+		ct state established,related accept
+		iifname "tap-web" jump egress-0
+		oifname "tap-web" jump ingress-0
+		iifname "tap-db" jump egress-1
+		oifname "tap-db" jump ingress-1
+	}
+	chain input {
+		type filter hook input priority filter; policy accept;
+		ether type != { ip, ip6 } accept
+		ct state established,related accept
+		iifname "tap-web" jump egress-0
+		iifname "tap-db" jump egress-1
+	}
+	chain output {
+		type filter hook output priority filter; policy accept;
+		ether type != { ip, ip6 } accept
+		ct state established,related accept
+		oifname "tap-web" jump ingress-0
+		oifname "tap-db" jump ingress-1
+	}
+	chain egress-0 {
+		# tap-web (web)
+		ip saddr != { 0.0.0.0, 172.100.0.2 } drop
+		ip6 saddr != { ::, fe80::/10, fd00::2 } drop
+		icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-solicit } return
+		udp sport 68 udp dport 67 return
+		ip daddr 172.100.0.1 meta l4proto { tcp, udp } th dport 53 return
+		ip daddr 0.0.0.0/0 meta l4proto { tcp, udp } th dport { 80, 443 } return
+		drop
+	}
+	chain ingress-0 {
+		# tap-web (web)
+	}
+	chain egress-1 {
+		# tap-db (db)
+		ip saddr != { 0.0.0.0, 172.100.0.3 } drop
+	}
+	chain ingress-1 {
+		# tap-db (db)
+		icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-advert } return
+		udp sport 67 udp dport 68 return
+		ip saddr { 172.100.0.2 } meta l4proto tcp th dport { 5432 } return
+		ip6 saddr { fd00::2 } meta l4proto tcp th dport { 5432 } return
+		drop
+	}
+}
+`,
+		},
+		{
+			name: "default deny without DHCP",
+			network: &networkv1alpha1.Network{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "isolated",
+				},
+				Spec: networkv1alpha1.NetworkSpec{
+					Gateway: "10.0.0.1",
+					Netmask: "24",
+					Policy: &networkv1alpha1.NetworkPolicy{
+						DefaultDeny: true,
+					},
+					Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "app",
+							},
+							Spec: networkv1alpha1.NetworkInterfaceSpec{
+								IfName: "tap-app",
+								IP:     "10.0.0.2",
+							},
+						},
+						{
+							// Interfaces which are not attached to the host are skipped.
+							ObjectMeta: metav1.ObjectMeta{
+								Name: "pending",
+							},
+						},
+					},
+				},
+			},
+			want: `table bridge kraftkit-isolated
+delete table bridge kraftkit-isolated
+table bridge kraftkit-isolated {
+	chain forward {
+		type filter hook forward priority filter; policy accept;
+		ether type != { ip, ip6 } accept
+		ct state established,related accept
+		iifname "tap-app" jump egress-0
+		oifname "tap-app" jump ingress-0
+	}
+	chain input {
+		type filter hook input priority filter; policy accept;
+		ether type != { ip, ip6 } accept
+		ct state established,related accept
+		iifname "tap-app" jump egress-0
+	}
+	chain output {
+		type filter hook output priority filter; policy accept;
+		ether type != { ip, ip6 } accept
+		ct state established,related accept
+		oifname "tap-app" jump ingress-0
+	}
+	chain egress-0 {
+		# tap-app (app)
+		ip saddr != { 0.0.0.0, 10.0.0.2 } drop
+		icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-solicit } return
+		drop
+	}
+	chain ingress-0 {
+		# tap-app (app)
+		icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-advert } return
+		drop
+	}
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.network.Spec.Policy.Validate(); err != nil {
+				t.Fatalf("Invalid policy: %v", err)
+			}
+
+			if got := Ruleset(tt.network); got != tt.want {
+				t.Errorf("Unexpected ruleset:\n%s\nexpected:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -209,3 +209,54 @@ func (iterator *machineV1alpha1ServiceIterator) Logs(ctx context.Context, machin
 
 	return nil, nil, fmt.Errorf("all iterated platforms failed: %w", merr.NewErrors(errs...))
 }
+
+// Snapshot implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (iterator *machineV1alpha1ServiceIterator) Snapshot(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	var errs []error
+
+	for _, strategy := range iterator.strategies {
+		ret, err := strategy.Snapshot(ctx, machine)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		return ret, nil
+	}
+
+	return machine, fmt.Errorf("all iterated platforms failed: %w", merr.NewErrors(errs...))
+}
+
+// Restore implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (iterator *machineV1alpha1ServiceIterator) Restore(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	var errs []error
+
+	for _, strategy := range iterator.strategies {
+		ret, err := strategy.Restore(ctx, machine)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		return ret, nil
+	}
+
+	return machine, fmt.Errorf("all iterated platforms failed: %w", merr.NewErrors(errs...))
+}
+
+// Stats implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (iterator *machineV1alpha1ServiceIterator) Stats(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	var errs []error
+
+	for _, strategy := range iterator.strategies {
+		ret, err := strategy.Stats(ctx, machine)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		return ret, nil
+	}
+
+	return machine, fmt.Errorf("all iterated platforms failed: %w", merr.NewErrors(errs...))
+}
@@ -7,11 +7,12 @@ package platform
 type Platform string
 
 const (
-	PlatformUnknown     = Platform("unknown")
-	PlatformFirecracker = Platform("fc")
-	PlatformQEMU        = Platform("qemu")
-	PlatformKVM         = PlatformQEMU
-	PlatformXen         = Platform("xen")
+	PlatformUnknown         = Platform("unknown")
+	PlatformFirecracker     = Platform("fc")
+	PlatformQEMU            = Platform("qemu")
+	PlatformKVM             = PlatformQEMU
+	PlatformXen             = Platform("xen")
+	PlatformCloudHypervisor = Platform("cloudhypervisor")
 )
 
 // String implements fmt.Stringer
@@ -32,23 +33,38 @@ func PlatformByName(name string) Platform {
 // PlatformsByName returns the list of known platforms and their name alises.
 func PlatformsByName() map[string]Platform {
 	return map[string]Platform{
-		"fc":          PlatformFirecracker,
-		"firecracker": PlatformFirecracker,
-		"kvm":         PlatformQEMU,
-		"qemu":        PlatformQEMU,
-		"xen":         PlatformXen,
+		"ch":               PlatformCloudHypervisor,
+		"cloud-hypervisor": PlatformCloudHypervisor,
+		"cloudhypervisor":  PlatformCloudHypervisor,
+		"fc":               PlatformFirecracker,
+		"firecracker":      PlatformFirecracker,
+		"kvm":              PlatformQEMU,
+		"qemu":             PlatformQEMU,
+		"xen":              PlatformXen,
 	}
 }
 
 // Platforms returns all the unique platforms.
 func Platforms() []Platform {
 	return []Platform{
+		PlatformCloudHypervisor,
 		PlatformFirecracker,
 		PlatformQEMU,
 		PlatformXen,
 	}
 }
 
+// KernelPlatform returns the platform which a unikernel must have been built
+// for in order to be run with the provided platform.  Cloud Hypervisor does not
+// have a dedicated Unikraft platform and instead boots KVM (QEMU) unikernels.
+func KernelPlatform(platform Platform) Platform {
+	if platform == PlatformCloudHypervisor {
+		return PlatformQEMU
+	}
+
+	return platform
+}
+
 // PlatformAliases returns all the name alises for a given platform.
 func PlatformAliases() map[Platform][]string {
 	aliases := map[Platform][]string{}
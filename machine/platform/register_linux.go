@@ -6,14 +6,14 @@ package platform
 
 import (
 	"context"
-	"path/filepath"
 
 	zip "api.zip"
 	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
 	"kraftkit.sh/config"
 	"kraftkit.sh/internal/set"
+	"kraftkit.sh/machine/cloudhypervisor"
 	"kraftkit.sh/machine/firecracker"
-	"kraftkit.sh/machine/store"
+	"kraftkit.sh/machine/xen"
 )
 
 var firecrackerV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev1alpha1.MachineService, error) {
@@ -25,12 +25,7 @@ var firecrackerV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev
 		return nil, err
 	}
 
-	embeddedStore, err := store.NewEmbeddedStore[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus](
-		filepath.Join(
-			config.G[config.KraftKit](ctx).RuntimeDir,
-			"machinev1alpha1",
-		),
-	)
+	embeddedStore, err := NewMachineV1alpha1Store(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -43,13 +38,60 @@ var firecrackerV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev
 	)
 }
 
+var cloudHypervisorV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev1alpha1.MachineService, error) {
+	if set.NewStringSet("debug", "trace").Contains(config.G[config.KraftKit](ctx).Log.Level) {
+		opts = append(opts, cloudhypervisor.WithDebug(true))
+	}
+	service, err := cloudhypervisor.NewMachineV1alpha1Service(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddedStore, err := NewMachineV1alpha1Store(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return machinev1alpha1.NewMachineServiceHandler(
+		ctx,
+		service,
+		zip.WithStore[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus](embeddedStore, zip.StoreRehydrationSpecNil),
+		zip.WithBefore(storePlatformFilter(PlatformCloudHypervisor)),
+	)
+}
+
+var xenV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev1alpha1.MachineService, error) {
+	service, err := xen.NewMachineV1alpha1Service(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddedStore, err := NewMachineV1alpha1Store(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return machinev1alpha1.NewMachineServiceHandler(
+		ctx,
+		service,
+		zip.WithStore[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus](embeddedStore, zip.StoreRehydrationSpecNil),
+		zip.WithBefore(storePlatformFilter(PlatformXen)),
+	)
+}
+
 func unixVariantStrategies() map[Platform]*Strategy {
 	// TODO(jake-ciolek): The firecracker driver has a dependency on github.com/containernetworking/plugins/pkg/ns via
 	// github.com/firecracker-microvm/firecracker-go-sdk
 	// Unfortunately, it doesn't support darwin.
 	return map[Platform]*Strategy{
+		PlatformCloudHypervisor: {
+			NewMachineV1alpha1: cloudHypervisorV1alpha1Driver,
+		},
 		PlatformFirecracker: {
 			NewMachineV1alpha1: firecrackerV1alpha1Driver,
 		},
+		PlatformXen: {
+			NewMachineV1alpha1: xenV1alpha1Driver,
+		},
 	}
 }
@@ -9,14 +9,11 @@ package platform
 
 import (
 	"context"
-	"path/filepath"
 
 	zip "api.zip"
 
 	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
-	"kraftkit.sh/config"
 	"kraftkit.sh/machine/qemu"
-	"kraftkit.sh/machine/store"
 )
 
 var qemuV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev1alpha1.MachineService, error) {
@@ -25,12 +22,7 @@ var qemuV1alpha1Driver = func(ctx context.Context, opts ...any) (machinev1alpha1
 		return nil, err
 	}
 
-	embeddedStore, err := store.NewEmbeddedStore[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus](
-		filepath.Join(
-			config.G[config.KraftKit](ctx).RuntimeDir,
-			"machinev1alpha1",
-		),
-	)
+	embeddedStore, err := NewMachineV1alpha1Store(ctx)
 	if err != nil {
 		return nil, err
 	}
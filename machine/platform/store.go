@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package platform
+
+import (
+	"context"
+	"path/filepath"
+
+	zip "api.zip"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/config"
+	"kraftkit.sh/machine/store"
+)
+
+// NewMachineV1alpha1Store returns the store which is shared by the machine
+// services of all platforms.  Changes to the machines of any platform can be
+// followed via its Watch method.
+func NewMachineV1alpha1Store(ctx context.Context) (zip.Store, error) {
+	return store.NewEmbeddedStore[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus](
+		filepath.Join(
+			config.G[config.KraftKit](ctx).RuntimeDir,
+			"machinev1alpha1",
+		),
+	)
+}
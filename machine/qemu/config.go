@@ -10,6 +10,7 @@ type QemuConfig struct {
 	// Command-line arguments for qemu-system-*
 	Accel      QemuMachineAccelerator `flag:"-accel"       json:"accel,omitempty"`
 	Append     string                 `flag:"-append"      json:"append,omitempty"`
+	Bios       string                 `flag:"-bios"        json:"bios,omitempty"`
 	CharDevs   []QemuCharDev          `flag:"-chardev"     json:"chardev,omitempty"`
 	CPU        QemuCPU                `flag:"-cpu"         json:"cpu,omitempty"`
 	Daemonize  bool                   `flag:"-daemonize"   json:"daemonize,omitempty"`
@@ -17,6 +18,8 @@ type QemuConfig struct {
 	Display    QemuDisplay            `flag:"-display"     json:"display,omitempty"`
 	EnableKVM  bool                   `flag:"-enable-kvm"  json:"enable_kvm,omitempty"`
 	FsDevs     []QemuFsDev            `flag:"-fsdev"       json:"fsdev,omitempty"`
+	Gdb        QemuHostCharDev        `flag:"-gdb"         json:"gdb,omitempty"`
+	Incoming   string                 `flag:"-incoming"    json:"incoming,omitempty"`
 	InitRd     string                 `flag:"-initrd"      json:"initrd,omitempty"`
 	Kernel     string                 `flag:"-kernel"      json:"kernel,omitempty"`
 	Machine    QemuMachine            `flag:"-machine"     json:"machine,omitempty"`
@@ -42,6 +45,16 @@ type QemuConfig struct {
 
 	// Command-line arguments for qemu-system-i386 and qemu-system-x86_64 only
 	NoHPET bool `flag:"-no-hpet" json:"no_hpet,omitempty"`
+
+	// Network interfaces and volumes which are attached to the machine, used to
+	// determine which devices to hot-plug or unplug when the machine is updated.
+	Attached []QemuAttachedDevice `json:"attached,omitempty"`
+
+	// PortForwardIfName is the host interface via which published ports are
+	// forwarded to PortForwardIP, the address of the machine on its network,
+	// instead of via user-mode networking.
+	PortForwardIfName string `json:"port_forward_ifname,omitempty"`
+	PortForwardIP     string `json:"port_forward_ip,omitempty"`
 }
 
 type QemuOption func(*QemuConfig) error
@@ -72,6 +85,16 @@ func WithAppend(append ...string) QemuOption {
 	}
 }
 
+// WithBios sets the firmware which is booted before the kernel, where
+// "default" selects the firmware which QEMU ships for the machine type and
+// "none" disables it.
+func WithBios(bios string) QemuOption {
+	return func(qc *QemuConfig) error {
+		qc.Bios = bios
+		return nil
+	}
+}
+
 func WithCharDevice(chardev QemuCharDev) QemuOption {
 	return func(qc *QemuConfig) error {
 		if qc.CharDevs == nil {
@@ -84,6 +107,18 @@ func WithCharDevice(chardev QemuCharDev) QemuOption {
 	}
 }
 
+func WithAttached(attached QemuAttachedDevice) QemuOption {
+	return func(qc *QemuConfig) error {
+		if qc.Attached == nil {
+			qc.Attached = make([]QemuAttachedDevice, 0)
+		}
+
+		qc.Attached = append(qc.Attached, attached)
+
+		return nil
+	}
+}
+
 func WithCPU(cpu QemuCPU) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.CPU = cpu
@@ -136,6 +171,13 @@ func WithFsDevice(fsdev QemuFsDev) QemuOption {
 	}
 }
 
+func WithGdb(gdb QemuHostCharDev) QemuOption {
+	return func(qc *QemuConfig) error {
+		qc.Gdb = gdb
+		return nil
+	}
+}
+
 func WithInitRd(initrd string) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.InitRd = initrd
@@ -197,6 +239,15 @@ func WithNoACPI(noACPI bool) QemuOption {
 	}
 }
 
+// WithIncoming prepares the machine to receive its state via migration from
+// the provided URI, or "defer" to start the migration later via QMP.
+func WithIncoming(uri string) QemuOption {
+	return func(qc *QemuConfig) error {
+		qc.Incoming = uri
+		return nil
+	}
+}
+
 func WithNoDefaults(noDefaults bool) QemuOption {
 	return func(qc *QemuConfig) error {
 		qc.NoDefaults = noDefaults
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import (
+	"fmt"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+// transport returns the transport of the virtio devices of the machine, which
+// is PCI unless otherwise requested or the machine is a microvm, which lacks a
+// PCI bus.
+func transport(machine *machinev1alpha1.Machine) machinev1alpha1.MachineTransport {
+	if len(machine.Spec.Transport) > 0 {
+		return machine.Spec.Transport
+	} else if machine.Spec.MicroVM {
+		return machinev1alpha1.MachineTransportMMIO
+	}
+
+	return machinev1alpha1.MachineTransportPCI
+}
+
+// validateDevices returns an error if the requested machine type, transport
+// and network model cannot be combined.
+func validateDevices(machine *machinev1alpha1.Machine) error {
+	if machine.Spec.MicroVM {
+		if machine.Spec.Architecture != "x86_64" && machine.Spec.Architecture != "amd64" {
+			return fmt.Errorf("cannot create %s microvm: only supported for x86_64", machine.Spec.Architecture)
+		} else if transport(machine) == machinev1alpha1.MachineTransportPCI {
+			return fmt.Errorf("cannot create microvm with PCI transport: microvm does not have a PCI bus")
+		}
+	}
+
+	if !machine.Spec.NetworkModel.IsVirtio() && transport(machine) == machinev1alpha1.MachineTransportMMIO {
+		return fmt.Errorf("cannot use network model %s with MMIO transport: only virtio devices are memory-mapped", machine.Spec.NetworkModel)
+	}
+
+	return nil
+}
+
+// netDeviceType returns the type of the guest-facing network devices of the
+// machine.
+func netDeviceType(machine *machinev1alpha1.Machine) (QemuDeviceType, error) {
+	switch machine.Spec.NetworkModel {
+	case "", machinev1alpha1.MachineNetworkModelVirtio:
+		if transport(machine) == machinev1alpha1.MachineTransportMMIO {
+			return QemuDeviceTypeVirtioNetDevice, nil
+		}
+
+		return QemuDeviceTypeVirtioNetPci, nil
+	case machinev1alpha1.MachineNetworkModelE1000:
+		return QemuDeviceTypeE1000, nil
+	case machinev1alpha1.MachineNetworkModelRtl8139:
+		return QemuDeviceTypeRtl8139, nil
+	case machinev1alpha1.MachineNetworkModelVmxnet3:
+		return QemuDeviceTypeVmxnet3, nil
+	default:
+		return "", fmt.Errorf("unsupported network model: %s", machine.Spec.NetworkModel)
+	}
+}
+
+// netDevice returns the guest-facing network device of the machine which is
+// connected to the provided network backend.
+func netDevice(machine *machinev1alpha1.Machine, mac, netdev string) (QemuDevice, error) {
+	typ, err := netDeviceType(machine)
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case QemuDeviceTypeVirtioNetDevice:
+		return QemuDeviceVirtioNetDevice{Mac: mac, Netdev: netdev}, nil
+	case QemuDeviceTypeE1000:
+		return QemuDeviceE1000{Mac: mac, Netdev: netdev}, nil
+	case QemuDeviceTypeRtl8139:
+		return QemuDeviceRtl8139{Mac: mac, Netdev: netdev}, nil
+	case QemuDeviceTypeVmxnet3:
+		return QemuDeviceVmxnet3{Mac: mac, Netdev: netdev}, nil
+	default:
+		return QemuDeviceVirtioNetPci{Mac: mac, Netdev: netdev}, nil
+	}
+}
+
+// fsDevice returns the guest-facing 9P device of the machine which exports
+// the provided filesystem backend.
+func fsDevice(machine *machinev1alpha1.Machine, fsdev, mounttag string) QemuDevice {
+	if transport(machine) == machinev1alpha1.MachineTransportMMIO {
+		return QemuDeviceVirtio9pDevice{
+			Fsdev:    fsdev,
+			MountTag: mounttag,
+		}
+	}
+
+	return QemuDeviceVirtio9pPci{
+		Fsdev:    fsdev,
+		MountTag: mounttag,
+	}
+}
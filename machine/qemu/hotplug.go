@@ -0,0 +1,506 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+	qmpapi "kraftkit.sh/machine/qemu/qmp/v7alpha2"
+)
+
+// hotplugFsDevSlots is the number of filesystem backends which are reserved
+// when a machine is created such that 9pfs volumes can later be hot-plugged.
+// QEMU cannot add filesystem backends at runtime, but only opens the directory
+// of a backend once a device is attached to it.  The path of each reserved
+// backend is therefore a symbolic link which is pointed at the source of a
+// volume right before its device is added.
+const hotplugFsDevSlots = 4
+
+// hotplugFsDevPrefix prefixes the identifiers of the reserved filesystem
+// backends.
+const hotplugFsDevPrefix = "hplugfs"
+
+// QemuDeviceWithId assigns an identifier to a device such that it can later be
+// referenced via QMP, e.g. in order to unplug it.
+type QemuDeviceWithId struct {
+	Id     string     `json:"id"`
+	Device QemuDevice `json:"device"`
+}
+
+// String implements fmt.Stringer
+func (d QemuDeviceWithId) String() string {
+	return d.Device.String() + ",id=" + d.Id
+}
+
+// QemuAttachedDevice records a network interface or volume of the machine
+// alongside the identifiers of the QEMU devices and backends which provide it.
+type QemuAttachedDevice struct {
+	// Key uniquely identifies the network interface or volume within the
+	// specification of the machine.
+	Key string `json:"key"`
+
+	// DeviceId is the identifier of the guest-facing device.
+	DeviceId string `json:"device_id,omitempty"`
+
+	// NetdevId is the identifier of the network backend, if any.
+	NetdevId string `json:"netdev_id,omitempty"`
+
+	// FsdevId is the identifier of the filesystem backend, if any.
+	FsdevId string `json:"fsdev_id,omitempty"`
+}
+
+// networkInterfaceKey returns the key of the provided network interface as it
+// is recorded in QemuAttachedDevice.
+func networkInterfaceKey(iface networkv1alpha1.NetworkInterfaceTemplateSpec) string {
+	return "net:" + iface.Spec.IfName
+}
+
+// volumeKey returns the key of the provided volume as it is recorded in
+// QemuAttachedDevice.
+func volumeKey(vol volumev1alpha1.Volume) string {
+	return "vol:" + vol.Spec.Source + ":" + vol.Spec.Destination
+}
+
+// hotplugInterface is a network interface which is to be hot-plugged alongside
+// the network which it is attached to.
+type hotplugInterface struct {
+	network networkv1alpha1.NetworkSpec
+	iface   networkv1alpha1.NetworkInterfaceTemplateSpec
+}
+
+// deviceDiff describes how the devices which are attached to a machine differ
+// from its specification.
+type deviceDiff struct {
+	// unplug contains the attached devices which are no longer specified.
+	unplug []QemuAttachedDevice
+
+	// keep contains the attached devices which are still specified.
+	keep []QemuAttachedDevice
+
+	// interfaces contains the specified network interfaces which are not yet
+	// attached.
+	interfaces []hotplugInterface
+
+	// volumes contains the specified 9pfs volumes which are not yet attached.
+	volumes []volumev1alpha1.Volume
+}
+
+// diffDevices compares the provided devices which are attached to the machine
+// with its specification.  Devices are matched by their key, such that a
+// network interface or volume whose key has changed, e.g. a volume which is
+// mounted at a different destination, is unplugged and plugged again.
+func diffDevices(machine *machinev1alpha1.Machine, attached []QemuAttachedDevice) deviceDiff {
+	var diff deviceDiff
+
+	desired := map[string]bool{}
+	for _, network := range machine.Spec.Networks {
+		for _, iface := range network.Interfaces {
+			desired[networkInterfaceKey(iface)] = true
+		}
+	}
+	for _, vol := range machine.Spec.Volumes {
+		if vol.Spec.Driver == "9pfs" {
+			desired[volumeKey(vol)] = true
+		}
+	}
+
+	current := map[string]bool{}
+	for _, dev := range attached {
+		if !desired[dev.Key] {
+			diff.unplug = append(diff.unplug, dev)
+			continue
+		}
+
+		diff.keep = append(diff.keep, dev)
+		current[dev.Key] = true
+	}
+
+	for _, network := range machine.Spec.Networks {
+		for _, iface := range network.Interfaces {
+			if key := networkInterfaceKey(iface); !current[key] {
+				diff.interfaces = append(diff.interfaces, hotplugInterface{
+					network: network,
+					iface:   iface,
+				})
+				current[key] = true
+			}
+		}
+	}
+
+	for _, vol := range machine.Spec.Volumes {
+		if key := volumeKey(vol); vol.Spec.Driver == "9pfs" && !current[key] {
+			diff.volumes = append(diff.volumes, vol)
+			current[key] = true
+		}
+	}
+
+	return diff
+}
+
+// nextHostNetId returns the first network backend identifier which is not yet
+// in use by the machine.
+func nextHostNetId(machine *machinev1alpha1.Machine, attached []QemuAttachedDevice) string {
+	used := map[string]bool{}
+
+	// Published ports each occupy a user-mode network backend.
+	for i := range machine.Spec.Ports {
+		used[fmt.Sprintf("hostnet%d", i)] = true
+	}
+
+	for _, dev := range attached {
+		if dev.NetdevId != "" {
+			used[dev.NetdevId] = true
+		}
+	}
+
+	for i := 0; ; i++ {
+		id := fmt.Sprintf("hostnet%d", i)
+		if !used[id] {
+			return id
+		}
+	}
+}
+
+// attachedFromConfig returns the network interfaces and 9pfs volumes which the
+// machine was created with according to the tap and filesystem backends of the
+// provided configuration.  This serves as the record of machines which were
+// created before their devices were recorded in QemuConfig.Attached, such that
+// devices which are already present are not hot-plugged a second time.  The
+// identifiers of the guest-facing devices are derived from their backends in
+// the same way as they are assigned during creation.
+func attachedFromConfig(machine *machinev1alpha1.Machine, qcfg *QemuConfig) []QemuAttachedDevice {
+	attached := []QemuAttachedDevice{}
+
+	for _, netdev := range qcfg.NetDevs {
+		tap, ok := netdev.(QemuNetDevTap)
+		if !ok {
+			continue
+		}
+
+		attached = append(attached, QemuAttachedDevice{
+			Key:      "net:" + tap.Ifname,
+			DeviceId: "net" + strings.TrimPrefix(tap.Id, "hostnet"),
+			NetdevId: tap.Id,
+		})
+	}
+
+	for _, fsdev := range qcfg.FsDevs {
+		local, ok := fsdev.(QemuFsDevLocal)
+		if !ok || strings.HasPrefix(local.Id, hotplugFsDevPrefix) {
+			continue
+		}
+
+		// Without a volume of the same source, the key matches no volume of the
+		// specification and the device is unplugged.
+		key := "vol:" + local.Path + ":"
+		for _, vol := range machine.Spec.Volumes {
+			if vol.Spec.Driver == "9pfs" && vol.Spec.Source == local.Path {
+				key = volumeKey(vol)
+				break
+			}
+		}
+
+		attached = append(attached, QemuAttachedDevice{
+			Key:      key,
+			DeviceId: "virtio" + strings.TrimPrefix(local.Id, "hvirtio"),
+			FsdevId:  local.Id,
+		})
+	}
+
+	return attached
+}
+
+// forget removes the devices and backends of the provided attachment from the
+// configuration with which the machine was created, such that it is no longer
+// considered attached by attachedFromConfig once it has been unplugged.
+// Reserved filesystem backends are kept, since they can be re-used.
+func (qcfg *QemuConfig) forget(dev QemuAttachedDevice) {
+	devices := []QemuDevice{}
+	for _, device := range qcfg.Devices {
+		if withId, ok := device.(QemuDeviceWithId); ok && withId.Id == dev.DeviceId {
+			continue
+		}
+		devices = append(devices, device)
+	}
+	qcfg.Devices = devices
+
+	netdevs := []QemuNetDev{}
+	for _, netdev := range qcfg.NetDevs {
+		if tap, ok := netdev.(QemuNetDevTap); ok && tap.Id == dev.NetdevId {
+			continue
+		}
+		netdevs = append(netdevs, netdev)
+	}
+	qcfg.NetDevs = netdevs
+
+	fsdevs := []QemuFsDev{}
+	for _, fsdev := range qcfg.FsDevs {
+		if local, ok := fsdev.(QemuFsDevLocal); ok && local.Id == dev.FsdevId && !strings.HasPrefix(local.Id, hotplugFsDevPrefix) {
+			continue
+		}
+		fsdevs = append(fsdevs, fsdev)
+	}
+	qcfg.FsDevs = fsdevs
+}
+
+// reserveFsDevs prepares the filesystem backends which are reserved for
+// hot-plugging 9pfs volumes into the provided state directory of a machine.
+// Until a volume is plugged, each backend points at the same empty directory.
+func reserveFsDevs(stateDir string) ([]QemuFsDevLocal, error) {
+	dir := filepath.Join(stateDir, "hotplug")
+	empty := filepath.Join(dir, "empty")
+
+	if err := os.MkdirAll(empty, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create directory for hot-plugging volumes: %v", err)
+	}
+
+	fsdevs := make([]QemuFsDevLocal, hotplugFsDevSlots)
+
+	for i := range fsdevs {
+		id := fmt.Sprintf("%s%d", hotplugFsDevPrefix, i)
+		path := filepath.Join(dir, id)
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if err := os.Symlink(empty, path); err != nil {
+			return nil, fmt.Errorf("could not reserve filesystem backend %s: %v", id, err)
+		}
+
+		fsdevs[i] = QemuFsDevLocal{
+			SecurityModel: QemuFsDevLocalSecurityModelPassthrough,
+			Id:            id,
+			Path:          path,
+		}
+	}
+
+	return fsdevs, nil
+}
+
+// freeFsDev returns a reserved filesystem backend of the provided configuration
+// which is not used by any of the provided attachments.
+func freeFsDev(qcfg *QemuConfig, attached []QemuAttachedDevice) (QemuFsDevLocal, bool) {
+	used := map[string]bool{}
+	for _, dev := range attached {
+		if dev.FsdevId != "" {
+			used[dev.FsdevId] = true
+		}
+	}
+
+	for _, fsdev := range qcfg.FsDevs {
+		local, ok := fsdev.(QemuFsDevLocal)
+		if !ok || !strings.HasPrefix(local.Id, hotplugFsDevPrefix) || used[local.Id] {
+			continue
+		}
+
+		return local, true
+	}
+
+	return QemuFsDevLocal{}, false
+}
+
+// qmpResponseError returns the error which QEMU responded with to a command
+// whose successful response carries no value, if any.
+func qmpResponseError(res *any) error {
+	if res == nil {
+		return nil
+	}
+
+	msg, ok := (*res).(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	qerr, ok := msg["error"].(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	return fmt.Errorf("%v", qerr["desc"])
+}
+
+// plugNetworkInterface adds a network backend for the tap device of the
+// provided interface and attaches a new network device of the model of the
+// machine to it.
+func (service *machineV1alpha1Service) plugNetworkInterface(ctx context.Context, machine *machinev1alpha1.Machine, network networkv1alpha1.NetworkSpec, iface networkv1alpha1.NetworkInterfaceTemplateSpec, mac, hostnetid string) (*QemuAttachedDevice, error) {
+	driver, err := netDeviceType(machine)
+	if err != nil {
+		return nil, err
+	}
+
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return nil, fmt.Errorf("could not start qmp client: %v", err)
+	}
+
+	res, err := qmpClient.NetdevAddDevTap(qmpapi.NetdevAddDevTapRequest{
+		Arguments: qmpapi.NetdevTapOptions{
+			Id:         hostnetid,
+			Type:       qmpapi.NET_CLIENT_DRIVER_TAP,
+			Ifname:     iface.Spec.IfName,
+			Br:         network.IfName,
+			Script:     "no", // Disable execution
+			Downscript: "no", // Disable execution
+		},
+	})
+	qmpClient.Close()
+	if err == nil {
+		err = qmpResponseError(res)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not add network backend for %s: %v", iface.Spec.IfName, err)
+	}
+
+	devid := "net" + hostnetid[len("hostnet"):]
+
+	qmpClient, err = service.QMPClient(ctx, machine)
+	if err != nil {
+		return nil, fmt.Errorf("could not start qmp client: %v", err)
+	}
+
+	res, err = qmpClient.DeviceAdd(qmpapi.DeviceAddRequest{
+		Arguments: qmpapi.DeviceAddRequestArguments{
+			Driver: string(driver),
+			Id:     devid,
+			Netdev: hostnetid,
+			Mac:    mac,
+		},
+	})
+	qmpClient.Close()
+	if err == nil {
+		err = qmpResponseError(res)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not add network device for %s: %v", iface.Spec.IfName, err)
+	}
+
+	return &QemuAttachedDevice{
+		Key:      networkInterfaceKey(iface),
+		DeviceId: devid,
+		NetdevId: hostnetid,
+	}, nil
+}
+
+// plugVolume points the provided reserved filesystem backend at the source of
+// the provided volume and attaches a new 9P device to it.  The volume is
+// exported with the identifier of the backend as its mount tag.
+func (service *machineV1alpha1Service) plugVolume(ctx context.Context, machine *machinev1alpha1.Machine, vol volumev1alpha1.Volume, fsdev QemuFsDevLocal) (*QemuAttachedDevice, error) {
+	source, err := filepath.Abs(vol.Spec.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace the link atomically such that the backend never points nowhere.
+	link := fsdev.Path + ".tmp"
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err := os.Symlink(source, link); err != nil {
+		return nil, fmt.Errorf("could not link volume %s: %v", vol.Spec.Source, err)
+	}
+
+	if err := os.Rename(link, fsdev.Path); err != nil {
+		return nil, fmt.Errorf("could not link volume %s: %v", vol.Spec.Source, err)
+	}
+
+	devid := "virtio" + fsdev.Id
+
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return nil, fmt.Errorf("could not start qmp client: %v", err)
+	}
+
+	res, err := qmpClient.DeviceAdd(qmpapi.DeviceAddRequest{
+		Arguments: qmpapi.DeviceAddRequestArguments{
+			Driver:   string(QemuDeviceTypeVirtio9pPci),
+			Id:       devid,
+			Fsdev:    fsdev.Id,
+			MountTag: fsdev.Id,
+		},
+	})
+	qmpClient.Close()
+	if err == nil {
+		err = qmpResponseError(res)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not add filesystem device for %s: %v", vol.Spec.Source, err)
+	}
+
+	return &QemuAttachedDevice{
+		Key:      volumeKey(vol),
+		DeviceId: devid,
+		FsdevId:  fsdev.Id,
+	}, nil
+}
+
+// unplug removes the guest-facing device of the provided attachment followed
+// by its network backend, if any.  Filesystem backends cannot be removed at
+// runtime and remain in place until the machine is stopped.
+func (service *machineV1alpha1Service) unplug(ctx context.Context, machine *machinev1alpha1.Machine, dev QemuAttachedDevice) error {
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return fmt.Errorf("could not start qmp client: %v", err)
+	}
+
+	var res *any
+
+	if dev.DeviceId != "" {
+		res, err = qmpClient.DeviceDel(qmpapi.DeviceDelRequest{
+			Arguments: qmpapi.DeviceDelRequestArguments{
+				Id: dev.DeviceId,
+			},
+		})
+	} else if dev.NetdevId != "" {
+		// Without a device identifier, at least ensure that the guest observes
+		// the link going down before its backend is removed.
+		res, err = qmpClient.SetLink(qmpapi.SetLinkRequest{
+			Arguments: qmpapi.SetLinkRequestArguments{
+				Name: dev.NetdevId,
+				Up:   false,
+			},
+		})
+	}
+	qmpClient.Close()
+	if err == nil {
+		err = qmpResponseError(res)
+	}
+	if err != nil {
+		return fmt.Errorf("could not remove device %s: %v", dev.Key, err)
+	}
+
+	if dev.NetdevId == "" {
+		return nil
+	}
+
+	qmpClient, err = service.QMPClient(ctx, machine)
+	if err != nil {
+		return fmt.Errorf("could not start qmp client: %v", err)
+	}
+
+	defer qmpClient.Close()
+
+	res, err = qmpClient.NetdevDel(qmpapi.NetdevDelRequest{
+		Arguments: qmpapi.NetdevDelRequestArguments{
+			Id: dev.NetdevId,
+		},
+	})
+	if err == nil {
+		err = qmpResponseError(res)
+	}
+	if err != nil {
+		return fmt.Errorf("could not remove network backend %s: %v", dev.NetdevId, err)
+	}
+
+	return nil
+}
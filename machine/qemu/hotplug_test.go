@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import (
+	"reflect"
+	"testing"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+)
+
+func testInterface(ifname string) networkv1alpha1.NetworkInterfaceTemplateSpec {
+	return networkv1alpha1.NetworkInterfaceTemplateSpec{
+		Spec: networkv1alpha1.NetworkInterfaceSpec{
+			IfName: ifname,
+		},
+	}
+}
+
+func testVolume(driver, source, destination string) volumev1alpha1.Volume {
+	return volumev1alpha1.Volume{
+		Spec: volumev1alpha1.VolumeSpec{
+			Driver:      driver,
+			Source:      source,
+			Destination: destination,
+		},
+	}
+}
+
+func TestDiffDevices(t *testing.T) {
+	tests := []struct {
+		name       string
+		networks   []networkv1alpha1.NetworkSpec
+		volumes    []volumev1alpha1.Volume
+		attached   []QemuAttachedDevice
+		unplug     []string
+		keep       []string
+		interfaces []string
+		plugged    []string
+	}{
+		{
+			name: "nothing",
+		},
+		{
+			name: "unchanged",
+			networks: []networkv1alpha1.NetworkSpec{
+				{IfName: "kraft0", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{testInterface("tap0")}},
+			},
+			volumes: []volumev1alpha1.Volume{testVolume("9pfs", "/data", "/mnt")},
+			attached: []QemuAttachedDevice{
+				{Key: "net:tap0", DeviceId: "net0", NetdevId: "hostnet0"},
+				{Key: "vol:/data:/mnt", DeviceId: "virtiohplugfs0", FsdevId: "hplugfs0"},
+			},
+			keep: []string{"net:tap0", "vol:/data:/mnt"},
+		},
+		{
+			name: "added",
+			networks: []networkv1alpha1.NetworkSpec{
+				{IfName: "kraft0", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{testInterface("tap0")}},
+				{IfName: "kraft1", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{testInterface("tap1")}},
+			},
+			volumes: []volumev1alpha1.Volume{
+				testVolume("9pfs", "/data", "/mnt"),
+				testVolume("9pfs", "/logs", "/var/log"),
+			},
+			attached: []QemuAttachedDevice{
+				{Key: "net:tap0", DeviceId: "net0", NetdevId: "hostnet0"},
+			},
+			keep:       []string{"net:tap0"},
+			interfaces: []string{"kraft1/tap1"},
+			plugged:    []string{"/data:/mnt", "/logs:/var/log"},
+		},
+		{
+			name: "removed",
+			networks: []networkv1alpha1.NetworkSpec{
+				{IfName: "kraft0", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{testInterface("tap0")}},
+			},
+			attached: []QemuAttachedDevice{
+				{Key: "net:tap0", DeviceId: "net0", NetdevId: "hostnet0"},
+				{Key: "net:tap1", DeviceId: "net1", NetdevId: "hostnet1"},
+				{Key: "vol:/data:/mnt", DeviceId: "virtiohplugfs0", FsdevId: "hplugfs0"},
+			},
+			unplug: []string{"net:tap1", "vol:/data:/mnt"},
+			keep:   []string{"net:tap0"},
+		},
+		{
+			name:    "changed volume destination",
+			volumes: []volumev1alpha1.Volume{testVolume("9pfs", "/data", "/srv")},
+			attached: []QemuAttachedDevice{
+				{Key: "vol:/data:/mnt", DeviceId: "virtiohplugfs0", FsdevId: "hplugfs0"},
+			},
+			unplug:  []string{"vol:/data:/mnt"},
+			plugged: []string{"/data:/srv"},
+		},
+		{
+			name: "interface moved to other network",
+			networks: []networkv1alpha1.NetworkSpec{
+				{IfName: "kraft1", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{testInterface("tap0")}},
+			},
+			attached: []QemuAttachedDevice{
+				{Key: "net:tap0", DeviceId: "net0", NetdevId: "hostnet0"},
+			},
+			keep: []string{"net:tap0"},
+		},
+		{
+			name: "volumes of other drivers",
+			volumes: []volumev1alpha1.Volume{
+				testVolume("block", "/disk.img", "/mnt"),
+				testVolume("9pfs", "/data", "/mnt"),
+			},
+			plugged: []string{"/data:/mnt"},
+		},
+		{
+			name: "duplicates",
+			networks: []networkv1alpha1.NetworkSpec{
+				{IfName: "kraft0", Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{testInterface("tap0"), testInterface("tap0")}},
+			},
+			volumes: []volumev1alpha1.Volume{
+				testVolume("9pfs", "/data", "/mnt"),
+				testVolume("9pfs", "/data", "/mnt"),
+			},
+			interfaces: []string{"kraft0/tap0"},
+			plugged:    []string{"/data:/mnt"},
+		},
+		{
+			name: "created without record",
+			attached: []QemuAttachedDevice{
+				// As recorded by attachedFromConfig for a volume no longer specified.
+				{Key: "vol:/data:", DeviceId: "virtio0", FsdevId: "hvirtio0"},
+			},
+			unplug: []string{"vol:/data:"},
+		},
+	}
+
+	keys := func(devs []QemuAttachedDevice) []string {
+		var ret []string
+		for _, dev := range devs {
+			ret = append(ret, dev.Key)
+		}
+
+		return ret
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &machinev1alpha1.Machine{
+				Spec: machinev1alpha1.MachineSpec{
+					Networks: tt.networks,
+					Volumes:  tt.volumes,
+				},
+			}
+
+			diff := diffDevices(machine, tt.attached)
+
+			if got := keys(diff.unplug); !reflect.DeepEqual(got, tt.unplug) {
+				t.Errorf("Expected to unplug %v, got %v", tt.unplug, got)
+			}
+
+			if got := keys(diff.keep); !reflect.DeepEqual(got, tt.keep) {
+				t.Errorf("Expected to keep %v, got %v", tt.keep, got)
+			}
+
+			var interfaces []string
+			for _, plug := range diff.interfaces {
+				interfaces = append(interfaces, plug.network.IfName+"/"+plug.iface.Spec.IfName)
+			}
+
+			if !reflect.DeepEqual(interfaces, tt.interfaces) {
+				t.Errorf("Expected to plug interfaces %v, got %v", tt.interfaces, interfaces)
+			}
+
+			var plugged []string
+			for _, vol := range diff.volumes {
+				plugged = append(plugged, vol.Spec.Source+":"+vol.Spec.Destination)
+			}
+
+			if !reflect.DeepEqual(plugged, tt.plugged) {
+				t.Errorf("Expected to plug volumes %v, got %v", tt.plugged, plugged)
+			}
+		})
+	}
+}
+
+func TestFreeFsDev(t *testing.T) {
+	qcfg := &QemuConfig{
+		FsDevs: []QemuFsDev{
+			QemuFsDevLocal{Id: "hvirtio0", Path: "/data"},
+			QemuFsDevLocal{Id: "hplugfs0", Path: "/state/hotplug/hplugfs0"},
+			QemuFsDevLocal{Id: "hplugfs1", Path: "/state/hotplug/hplugfs1"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		attached []QemuAttachedDevice
+		want     string
+	}{
+		{
+			name: "first reserved",
+			attached: []QemuAttachedDevice{
+				{Key: "vol:/data:/mnt", FsdevId: "hvirtio0"},
+			},
+			want: "hplugfs0",
+		},
+		{
+			name: "skips used",
+			attached: []QemuAttachedDevice{
+				{Key: "net:tap0", NetdevId: "hostnet0"},
+				{Key: "vol:/logs:/var/log", FsdevId: "hplugfs0"},
+			},
+			want: "hplugfs1",
+		},
+		{
+			name: "all used",
+			attached: []QemuAttachedDevice{
+				{Key: "vol:/logs:/var/log", FsdevId: "hplugfs0"},
+				{Key: "vol:/srv:/srv", FsdevId: "hplugfs1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsdev, ok := freeFsDev(qcfg, tt.attached)
+			if ok != (tt.want != "") {
+				t.Fatalf("Expected free backend %v, got %v", tt.want != "", ok)
+			}
+
+			if fsdev.Id != tt.want {
+				t.Errorf("Expected backend %q, got %q", tt.want, fsdev.Id)
+			}
+		})
+	}
+}
+
+func TestNextHostNetId(t *testing.T) {
+	machine := &machinev1alpha1.Machine{
+		Spec: machinev1alpha1.MachineSpec{
+			Ports: machinev1alpha1.MachinePorts{{MachinePort: 80}},
+		},
+	}
+
+	attached := []QemuAttachedDevice{
+		{Key: "net:tap0", NetdevId: "hostnet1"},
+		{Key: "net:tap1", NetdevId: "hostnet3"},
+		{Key: "vol:/data:/mnt", FsdevId: "hplugfs0"},
+	}
+
+	if got := nextHostNetId(machine, attached); got != "hostnet2" {
+		t.Errorf("Expected hostnet2, got %s", got)
+	}
+}
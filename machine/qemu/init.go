@@ -19,7 +19,7 @@ func init() {
 	// Character devices
 	// gob.Register(QemuCharDevNull{})
 	// gob.Register(QemuCharDevSocketTCP{})
-	// gob.Register(QemuCharDevSocketUnix{})
+	gob.Register(QemuCharDevSocketUnix{})
 	// gob.Register(QemuCharDevUdp{})
 	// gob.Register(QemuCharDevVirtualConsole{})
 	// gob.Register(QemuCharDevRingBuf{})
@@ -39,13 +39,13 @@ func init() {
 	// gob.Register(QemuHostCharDevPty{})
 	gob.Register(QemuHostCharDevNone{})
 	// gob.Register(QemuHostCharDevNull{})
-	// gob.Register(QemuHostCharDevNamed{})
+	gob.Register(QemuHostCharDevNamed{})
 	// gob.Register(QemuHostCharDevTty{})
 	gob.Register(QemuHostCharDevFile{})
 	// gob.Register(QemuHostCharDevStdio{})
 	// gob.Register(QemuHostCharDevPipe{})
 	// gob.Register(QemuHostCharDevUDP{})
-	// gob.Register(QemuHostCharDevTCP{})
+	gob.Register(QemuHostCharDevTCP{})
 	// gob.Register(QemuHostCharDevTelnet{})
 	// gob.Register(QemuHostCharDevWebsocket{})
 	gob.Register(QemuHostCharDevUnix{})
@@ -302,7 +302,7 @@ func init() {
 	// gob.Register(QemuDeviceXenPlatform{})
 
 	// Network devices
-	// gob.Register(QemuDeviceE1000{})
+	gob.Register(QemuDeviceE1000{})
 	// gob.Register(QemuDeviceE100082544gc{})
 	// gob.Register(QemuDeviceE100082545em{})
 	// gob.Register(QemuDeviceE1000e{})
@@ -324,14 +324,14 @@ func init() {
 	// gob.Register(QemuDevicePcnet{})
 	// gob.Register(QemuDevicePvrdma{})
 	// gob.Register(QemuDeviceRocker{})
-	// gob.Register(QemuDeviceRtl8139{})
+	gob.Register(QemuDeviceRtl8139{})
 	// gob.Register(QemuDeviceTulip{})
 	// gob.Register(QemuDeviceUsbNet{})
-	// gob.Register(QemuDeviceVirtioNetDevice{})
+	gob.Register(QemuDeviceVirtioNetDevice{})
 	gob.Register(QemuDeviceVirtioNetPci{})
 	// gob.Register(QemuDeviceVirtioNetPciNonTransitional{})
 	// gob.Register(QemuDeviceVirtioNetPciTransitional{})
-	// gob.Register(QemuDeviceVmxnet3{})
+	gob.Register(QemuDeviceVmxnet3{})
 
 	// Sound devices
 	// gob.Register(QemuDeviceAc97{})
@@ -393,7 +393,7 @@ func init() {
 	// gob.Register(QemuDeviceVhostUserScsiPci{})
 	// gob.Register(QemuDeviceVhostUserScsiPciNonTransitional{})
 	// gob.Register(QemuDeviceVhostUserScsiPciTransitional{})
-	// gob.Register(QemuDeviceVirtio9pDevice{})
+	gob.Register(QemuDeviceVirtio9pDevice{})
 	gob.Register(QemuDeviceVirtio9pPci{})
 	// gob.Register(QemuDeviceVirtio9pPciNonTransitional{})
 	// gob.Register(QemuDeviceVirtio9pPciTransitional{})
@@ -442,10 +442,14 @@ func init() {
 	// gob.Register(QemuDeviceXenDisk{})
 	// gob.Register(QemuDeviceXenPvdevice{})
 
+	// Identified devices
+	gob.Register(QemuDeviceWithId{})
+
 	// CPUs
 	gob.Register(QemuCPU{})
 	gob.Register(QemuCPUX86(""))
 	gob.Register(QemuCPUArm(""))
+	gob.Register(QemuCPURiscv(""))
 
 	// Displays
 	// gob.Register(QemuDisplaySpiceApp{})
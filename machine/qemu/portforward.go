@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import (
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/machine/network/iptables"
+)
+
+// releasePorts removes the host-side forwarding of the machine's published
+// ports, unless they are published via user-mode networking.
+func releasePorts(machine *machinev1alpha1.Machine, qcfg *QemuConfig) error {
+	if qcfg.PortForwardIfName == "" {
+		return nil
+	}
+
+	return iptables.RemoveRules(string(machine.ObjectMeta.UID))
+}
@@ -58,6 +58,12 @@ func (arm QemuCPUArm) String() string {
 	return string(arm)
 }
 
+type QemuCPURiscv string
+
+func (riscv QemuCPURiscv) String() string {
+	return string(riscv)
+}
+
 const (
 	QemuCPUX86486                    = QemuCPUX86("486")
 	QemuCPUX86486V1                  = QemuCPUX86("486-v1")
@@ -204,6 +210,7 @@ const (
 	QemuCPUArmCortexM7  = QemuCPUArm("cortex-m7")
 	QemuCPUArmCortexR5  = QemuCPUArm("cortex-r5")
 	QemuCPUArmCortexR5f = QemuCPUArm("cortex-r5f")
+	QemuCPUArmHost      = QemuCPUArm("host")
 	QemuCPUArmMax       = QemuCPUArm("max")
 	QemuCPUArmPxa250    = QemuCPUArm("pxa250")
 	QemuCPUArmPxa255    = QemuCPUArm("pxa255")
@@ -222,6 +229,14 @@ const (
 	QemuCPUArmTi925t    = QemuCPUArm("ti925t")
 )
 
+const (
+	QemuCPURiscvHost      = QemuCPURiscv("host")
+	QemuCPURiscvMax       = QemuCPURiscv("max")
+	QemuCPURiscvRv64      = QemuCPURiscv("rv64")
+	QemuCPURiscvSifiveE51 = QemuCPURiscv("sifive-e51")
+	QemuCPURiscvSifiveU54 = QemuCPURiscv("sifive-u54")
+)
+
 const (
 	QemuCPUFeature3dnow                           = QemuCPUFeature("3dnow")
 	QemuCPUFeature3dnowext                        = QemuCPUFeature("3dnowext")
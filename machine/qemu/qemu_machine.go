@@ -120,6 +120,10 @@ type QemuMachine struct {
 	NVDIMM        bool                     `json:"nvdimm,omitempty"`
 	HMAT          bool                     `json:"hmat,omitempty"`
 
+	// GICVersion is the version of the interrupt controller of ARM virt
+	// machines, e.g. 2, 3, host or max.
+	GICVersion string `json:"gic_version,omitempty"`
+
 	// Added in QEMU 8.0.0
 	Graphics bool `json:"graphics,omitempty"`
 }
@@ -176,6 +180,10 @@ func (qm QemuMachine) String() string {
 	if qm.HMAT {
 		ret.WriteString(",hmat=on")
 	}
+	if len(qm.GICVersion) > 0 {
+		ret.WriteString(",gic-version=")
+		ret.WriteString(qm.GICVersion)
+	}
 
 	// Added in QEMU 8.0.0
 	if qm.HMAT {
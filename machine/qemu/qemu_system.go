@@ -4,8 +4,28 @@
 // You may not use this file except in compliance with the License.
 package qemu
 
+import (
+	ukarch "kraftkit.sh/unikraft/arch"
+)
+
 const (
 	QemuSystemX86     = "qemu-system-x86_64"
 	QemuSystemArm     = "qemu-system-arm"
 	QemuSystemAarch64 = "qemu-system-aarch64"
+	QemuSystemRiscv64 = "qemu-system-riscv64"
 )
+
+// isHostArchitecture returns whether the provided architecture is that of the
+// host, such that it can be hardware accelerated.
+func isHostArchitecture(arch string) bool {
+	host, err := ukarch.HostArchitecture()
+	if err != nil {
+		return false
+	}
+
+	if arch == "amd64" {
+		arch = "x86_64"
+	}
+
+	return arch == host
+}
@@ -27,3 +27,39 @@ type SystemPowerdownRequest struct {
 type SystemWakeupRequest struct {
 	Execute string `json:"execute" default:"system_Wakeup"`
 }
+
+type QueryMemorySizeSummaryRequest struct {
+	Execute string `json:"execute" default:"query-memory-size-summary"`
+}
+
+// Actual memory information in bytes.
+//
+// Since: 2.11
+type MemoryInfo struct {
+	// size of "base" memory specified with command line option -m.
+	BaseMemory int64 `json:"base-memory"`
+	// size of memory that can be hot-unplugged.  This field is omitted if target
+	// doesn't support memory hotplug (i.e. CONFIG_MEM_DEVICE not defined at
+	// build time).
+	PluggedMemory int64 `json:"plugged-memory,omitempty"`
+}
+
+type QueryMemorySizeSummaryResponse struct {
+	Return MemoryInfo `json:"return"`
+}
+
+type QueryBalloonRequest struct {
+	Execute string `json:"execute" default:"query-balloon"`
+}
+
+// Information about the guest balloon device.
+//
+// Since: 0.14
+type BalloonInfo struct {
+	// the logical size of the VM in bytes
+	Actual int64 `json:"actual"`
+}
+
+type QueryBalloonResponse struct {
+	Return BalloonInfo `json:"return"`
+}
@@ -0,0 +1,56 @@
+// Code generated by kraftkit.sh/tools/protoc-gen-go-netconn. DO NOT EDIT.
+// source: machine/qemu/qmp/v7alpha2/migration.proto
+
+package qmpv7alpha2
+
+// Migrates the current running guest to another Virtual Machine.
+//
+// Since: 0.14
+type MigrateRequest struct {
+	Execute string `json:"execute" default:"migrate"`
+
+	Arguments MigrateRequestArguments `json:"arguments"`
+}
+
+type MigrateRequestArguments struct {
+	// the Uniform Resource Identifier of the destination VM
+	Uri string `json:"uri"`
+}
+
+// Start an incoming migration, the qemu must have been started with
+// -incoming defer.
+//
+// Since: 2.3
+type MigrateIncomingRequest struct {
+	Execute string `json:"execute" default:"migrate-incoming"`
+
+	Arguments MigrateIncomingRequestArguments `json:"arguments"`
+}
+
+type MigrateIncomingRequestArguments struct {
+	// The Uniform Resource Identifier identifying the source or address to
+	// listen on
+	Uri string `json:"uri"`
+}
+
+type QueryMigrateRequest struct {
+	Execute string `json:"execute" default:"query-migrate"`
+}
+
+// Information about current migration process.
+//
+// Since: 0.14
+type MigrationInfo struct {
+	// status of migration, one of: none, setup, cancelling, cancelled, active,
+	// postcopy-active, postcopy-paused, postcopy-recover, completed, failed,
+	// colo, pre-switchover, device, wait-unplug
+	Status string `json:"status,omitempty"`
+	// total amount of milliseconds since migration started
+	TotalTime int64 `json:"total-time,omitempty"`
+	// the error description, only set if the status is failed
+	ErrorDesc string `json:"error-desc,omitempty"`
+}
+
+type QueryMigrateResponse struct {
+	Return MigrationInfo `json:"return"`
+}
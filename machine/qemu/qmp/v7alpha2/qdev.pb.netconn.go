@@ -0,0 +1,44 @@
+// Code generated by kraftkit.sh/tools/protoc-gen-go-netconn. DO NOT EDIT.
+// source: machine/qemu/qmp/v7alpha2/qdev.proto
+
+package qmpv7alpha2
+
+// Add a device.
+//
+// Since: 0.13
+type DeviceAddRequest struct {
+	Execute string `json:"execute" default:"device_add"`
+
+	Arguments DeviceAddRequestArguments `json:"arguments"`
+}
+
+type DeviceAddRequestArguments struct {
+	// the name of the new device's driver
+	Driver string `json:"driver"`
+	// the device's ID, must be unique
+	Id string `json:"id,omitempty"`
+	// the device's parent bus (device tree path)
+	Bus string `json:"bus,omitempty"`
+	// id of the network backend to connect to (network devices only)
+	Netdev string `json:"netdev,omitempty"`
+	// MAC address of the network device (network devices only)
+	Mac string `json:"mac,omitempty"`
+	// id of the filesystem backend to connect to (9p devices only)
+	Fsdev string `json:"fsdev,omitempty"`
+	// tag which the guest uses to mount the filesystem (9p devices only)
+	MountTag string `json:"mount_tag,omitempty"`
+}
+
+// Remove a device from a guest.
+//
+// Since: 0.14
+type DeviceDelRequest struct {
+	Execute string `json:"execute" default:"device_del"`
+
+	Arguments DeviceDelRequestArguments `json:"arguments"`
+}
+
+type DeviceDelRequestArguments struct {
+	// the device's ID or QOM path
+	Id string `json:"id"`
+}
@@ -31,10 +31,15 @@ import (
 	"kraftkit.sh/config"
 	"kraftkit.sh/exec"
 	"kraftkit.sh/internal/logtail"
-	"kraftkit.sh/internal/retrytimeout"
+	"kraftkit.sh/log"
+	"kraftkit.sh/machine/cgroup"
+	"kraftkit.sh/machine/network/daemon"
+	"kraftkit.sh/machine/network/iptables"
 	"kraftkit.sh/machine/network/macaddr"
 	"kraftkit.sh/machine/qemu/qmp"
 	qmpapi "kraftkit.sh/machine/qemu/qmp/v7alpha2"
+	"kraftkit.sh/machine/shutdown"
+	"kraftkit.sh/machine/stats"
 	"kraftkit.sh/unikraft/export/v0/ukargparse"
 	"kraftkit.sh/unikraft/export/v0/uknetdev"
 	"kraftkit.sh/unikraft/export/v0/vfscore"
@@ -82,10 +87,20 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		bin = QemuSystemArm
 	case "arm64":
 		bin = QemuSystemAarch64
+	case "riscv64":
+		bin = QemuSystemRiscv64
 	default:
 		return nil, fmt.Errorf("unsupported architecture: %s", machine.Spec.Architecture)
 	}
 
+	if len(machine.Spec.GICVersion) > 0 && machine.Spec.Architecture != "arm" && machine.Spec.Architecture != "arm64" {
+		return machine, fmt.Errorf("cannot set GIC version of %s machine", machine.Spec.Architecture)
+	}
+
+	if err := validateDevices(machine); err != nil {
+		return machine, err
+	}
+
 	if config.G[config.KraftKit](ctx).Qemu != "" {
 		bin = config.G[config.KraftKit](ctx).Qemu
 	}
@@ -107,6 +122,14 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, err
 	}
 
+	// Hardware acceleration is only possible for the architecture of the host.
+	if !machine.Spec.Emulation && !isHostArchitecture(machine.Spec.Architecture) {
+		log.G(ctx).
+			WithField("arch", machine.Spec.Architecture).
+			Warn("architecture differs from host: using software emulation (TCG)")
+		machine.Spec.Emulation = true
+	}
+
 	if machine.Spec.Emulation {
 		emulation := false
 		for _, accel := range qemuAccels {
@@ -152,6 +175,10 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		machine.Status.LogFile = filepath.Join(machine.Status.StateDir, "machine.log")
 	}
 
+	// The serial console is exposed over a unix socket such that it can be
+	// attached to, whilst its output is logged in parallel.
+	machine.Status.SerialPath = filepath.Join(machine.Status.StateDir, "serial.sock")
+
 	if machine.Spec.Resources.Requests == nil {
 		machine.Spec.Resources.Requests = make(corev1.ResourceList, 2)
 	}
@@ -204,9 +231,16 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 			NoWait:    true,
 			Server:    true,
 		}),
-		WithSerial(QemuHostCharDevFile{
-			Monitor:  false,
-			Filename: machine.Status.LogFile,
+		WithCharDevice(QemuCharDevSocketUnix{
+			Id:        "serial0",
+			Path:      machine.Status.SerialPath,
+			Server:    true,
+			NoWait:    true,
+			LogFile:   machine.Status.LogFile,
+			LogAppend: true,
+		}),
+		WithSerial(QemuHostCharDevNamed{
+			Id: "serial0",
 		}),
 		WithMonitor(QemuHostCharDevUnix{
 			SocketDir: machine.Status.StateDir,
@@ -227,6 +261,17 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		WithParallel(QemuHostCharDevNone{}),
 	}
 
+	// The machine is always created paused, allowing a debugger to attach
+	// before the kernel has booted.
+	if machine.Spec.GDBPort > 0 {
+		qopts = append(qopts,
+			WithGdb(QemuHostCharDevTCP{
+				Host: "127.0.0.1",
+				Port: machine.Spec.GDBPort,
+			}),
+		)
+	}
+
 	// TODO: Parse Rootfs types
 	if len(machine.Status.InitrdPath) > 0 {
 		qopts = append(qopts,
@@ -234,7 +279,19 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		)
 	}
 
-	if len(machine.Spec.Ports) > 0 {
+	// Published ports of machines which are attached to a network are forwarded
+	// to the address of the machine on it by the host, which is considerably
+	// faster than user-mode networking and does not require an additional
+	// network device.
+	var portForwardIfName, portForwardIP string
+	if len(machine.Spec.Ports) > 0 && len(machine.Spec.Networks) > 0 {
+		portForwardIfName, portForwardIP = iptables.MachineAddress(machine)
+		if portForwardIP == "" {
+			return machine, fmt.Errorf("cannot publish ports of machine without an IPv4 address on its network")
+		}
+	}
+
+	if len(machine.Spec.Ports) > 0 && portForwardIP == "" {
 		// Start MAC addresses iteratively.
 		startMac, err := macaddr.GenerateMacAddress(true)
 		if err != nil {
@@ -249,11 +306,13 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 			}
 
 			hostnetid := fmt.Sprintf("hostnet%d", i)
+			device, err := netDevice(machine, mac, hostnetid)
+			if err != nil {
+				return machine, err
+			}
+
 			qopts = append(qopts,
-				WithDevice(QemuDeviceVirtioNetPci{
-					Mac:    mac,
-					Netdev: hostnetid,
-				}),
+				WithDevice(device),
 				WithNetDevice(QemuNetDevUser{
 					Id:      hostnetid,
 					Hostfwd: fmt.Sprintf("%s::%d-:%d", port.Protocol, port.HostPort, port.MachinePort),
@@ -292,15 +351,16 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 				}
 
 				hostnetid := fmt.Sprintf("hostnet%d", i)
+				netid := fmt.Sprintf("net%d", i)
+				device, err := netDevice(machine, mac, hostnetid)
+				if err != nil {
+					return machine, err
+				}
+
 				qopts = append(qopts,
-					// TODO(nderjung): The network device should be customizable based on
-					// the network spec or machine spec.  Additional insight can be provided
-					// by inspecting the KConfig options.  Potentially the MachineSpec is
-					// updated to reflect different systems or provide access to the
-					// KConfig values.
-					WithDevice(QemuDeviceVirtioNetPci{
-						Netdev: hostnetid,
-						Mac:    mac,
+					WithDevice(QemuDeviceWithId{
+						Id:     netid,
+						Device: device,
 					}),
 					WithNetDevice(QemuNetDevTap{
 						Id:         hostnetid,
@@ -309,16 +369,28 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 						Script:     "no", // Disable execution
 						Downscript: "no", // Disable execution
 					}),
+					WithAttached(QemuAttachedDevice{
+						Key:      networkInterfaceKey(iface),
+						DeviceId: netid,
+						NetdevId: hostnetid,
+					}),
 				)
 
 				// Assign the first interface statically via command-line arguments, also
 				// checking if the built-in arguments for
-				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && i == 0 {
-					kernelArgs = append(kernelArgs,
-						uknetdev.ParamIpv4Addr.WithValue(iface.Spec.IP),
-						uknetdev.ParamIpv4GwAddr.WithValue(network.Gateway),
-						uknetdev.ParamIpv4SubnetMask.WithValue(network.Netmask),
-					)
+				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && !kernelArgs.Contains(uknetdev.ParamIp) && i == 0 {
+					ipv4, err := network.IPv4Subnet()
+					if err != nil {
+						return machine, err
+					}
+
+					nameserver, domain := daemon.Resolver(network)
+
+					kernelArgs = append(kernelArgs, uknetdev.StaticParams(
+						net.ParseIP(iface.Spec.IP), ipv4,
+						nameserver, domain,
+						machine.Spec.LegacyNetdevParams,
+					)...)
 				}
 
 				// Increment the host network ID for additional interfaces.
@@ -333,6 +405,7 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		switch vol.Spec.Driver {
 		case "9pfs":
 			hvirtioid := fmt.Sprintf("hvirtio%d", i+1)
+			virtioid := fmt.Sprintf("virtio%d", i+1)
 			mounttag := fmt.Sprintf("fs%d", i+1)
 			qopts = append(qopts,
 				WithFsDevice(QemuFsDevLocal{
@@ -340,9 +413,14 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 					Id:            hvirtioid,
 					Path:          vol.Spec.Source,
 				}),
-				WithDevice(QemuDeviceVirtio9pPci{
-					Fsdev:    hvirtioid,
-					MountTag: mounttag,
+				WithDevice(QemuDeviceWithId{
+					Id:     virtioid,
+					Device: fsDevice(machine, hvirtioid, mounttag),
+				}),
+				WithAttached(QemuAttachedDevice{
+					Key:      volumeKey(vol),
+					DeviceId: virtioid,
+					FsdevId:  hvirtioid,
 				}),
 			)
 
@@ -373,6 +451,19 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		}
 	}
 
+	// Reserve filesystem backends such that volumes can be hot-plugged later on,
+	// which is only possible for devices which are not memory-mapped.
+	if transport(machine) != machinev1alpha1.MachineTransportMMIO {
+		fsdevs, err := reserveFsDevs(machine.Status.StateDir)
+		if err != nil {
+			return machine, err
+		}
+
+		for _, fsdev := range fsdevs {
+			qopts = append(qopts, WithFsDevice(fsdev))
+		}
+	}
+
 	if len(fstab) > 0 {
 		kernelArgs = append(kernelArgs,
 			vfscore.ParamVfsFstab.WithValue(fstab),
@@ -398,53 +489,131 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 
 	switch machine.Spec.Architecture {
 	case "x86_64", "amd64":
-		qopts = append(qopts,
-			WithDevice(QemuDevicePvpanic{}),
-		)
-		if machine.Spec.Emulation {
-			qopts = append(qopts,
-				WithMachine(QemuMachine{
-					Type: QemuMachineTypePC,
-				}),
-				WithCPU(QemuCPU{
-					CPU: QemuCPUX86Qemu64,
-					On:  QemuCPUFeatures{QemuCPUFeaturePdpe1gb},
-					Off: QemuCPUFeatures{QemuCPUFeatureVmx, QemuCPUFeatureSvm},
-				}),
-			)
+		qmachine := QemuMachine{
+			Type: QemuMachineTypePC,
+		}
+
+		// The microvm machine type does not provide the legacy devices which the
+		// panic notifier and the serial graphics adapter depend on.
+		if machine.Spec.MicroVM {
+			qmachine.Type = QemuMachineTypeMicroVM
 		} else {
 			qopts = append(qopts,
-				WithEnableKVM(true),
-				WithMachine(QemuMachine{
-					Type:         QemuMachineTypePC,
-					Accelerators: []QemuMachineAccelerator{QemuMachineAccelKVM},
-				}),
-				WithCPU(QemuCPU{
-					CPU: QemuCPUX86Host,
-					On:  QemuCPUFeatures{QemuCPUFeatureX2apic},
-					Off: QemuCPUFeatures{QemuCPUFeaturePmu},
-				}),
+				WithDevice(QemuDevicePvpanic{}),
 			)
 		}
-		if qemuVersion.LessThan(QemuVersion8_0_0) {
+		qcpu := QemuCPU{
+			CPU: QemuCPUX86Qemu64,
+			On:  QemuCPUFeatures{QemuCPUFeaturePdpe1gb},
+			Off: QemuCPUFeatures{QemuCPUFeatureVmx, QemuCPUFeatureSvm},
+		}
+
+		if !machine.Spec.Emulation {
+			qopts = append(qopts, WithEnableKVM(true))
+			qmachine.Accelerators = []QemuMachineAccelerator{QemuMachineAccelKVM}
+			qcpu = QemuCPU{
+				CPU: QemuCPUX86Host,
+				On:  QemuCPUFeatures{QemuCPUFeatureX2apic},
+				Off: QemuCPUFeatures{QemuCPUFeaturePmu},
+			}
+		}
+
+		// The features of the default models do not necessarily apply to the
+		// requested model.
+		if len(machine.Spec.CPUModel) > 0 {
+			qcpu = QemuCPU{
+				CPU: QemuCPUX86(machine.Spec.CPUModel),
+			}
+		}
+
+		qopts = append(qopts,
+			WithMachine(qmachine),
+			WithCPU(qcpu),
+		)
+		if qemuVersion.LessThan(QemuVersion8_0_0) && !machine.Spec.MicroVM {
 			qopts = append(qopts,
 				WithDevice(QemuDeviceSga{}),
 			)
 		}
 	case "arm", "arm64":
+		qmachine := QemuMachine{
+			Type:       QemuMachineTypeVirt,
+			GICVersion: machine.Spec.GICVersion,
+		}
+		qcpu := QemuCPU{
+			CPU: QemuCPUArmCortexA53,
+		}
+
+		// The Cortex-A53 implements AArch64 which is not supported by
+		// qemu-system-arm.
+		if machine.Spec.Architecture == "arm" {
+			qcpu.CPU = QemuCPUArmCortexA15
+		}
+
+		if !machine.Spec.Emulation {
+			qopts = append(qopts, WithEnableKVM(true))
+			qmachine.Accelerators = []QemuMachineAccelerator{QemuMachineAccelKVM}
+			qcpu.CPU = QemuCPUArmHost
+
+			// The interrupt controller must be that of the host, such that it can
+			// be virtualized.
+			if len(qmachine.GICVersion) == 0 {
+				qmachine.GICVersion = "host"
+			}
+		}
+
+		if len(machine.Spec.CPUModel) > 0 {
+			qcpu.CPU = QemuCPUArm(machine.Spec.CPUModel)
+		}
+
 		qopts = append(qopts,
-			WithMachine(QemuMachine{
-				Type: QemuMachineTypeVirt,
-			}),
-			WithCPU(QemuCPU{
-				CPU: QemuCPUArmCortexA53,
-			}),
+			WithMachine(qmachine),
+			WithCPU(qcpu),
+		)
+
+	case "riscv64":
+		qmachine := QemuMachine{
+			Type: QemuMachineTypeVirt,
+		}
+		qcpu := QemuCPU{
+			CPU: QemuCPURiscvRv64,
+		}
+
+		if !machine.Spec.Emulation {
+			qopts = append(qopts, WithEnableKVM(true))
+			qmachine.Accelerators = []QemuMachineAccelerator{QemuMachineAccelKVM}
+			qcpu.CPU = QemuCPURiscvHost
+		}
+
+		if len(machine.Spec.CPUModel) > 0 {
+			qcpu.CPU = QemuCPURiscv(machine.Spec.CPUModel)
+		}
+
+		// The kernel is booted in supervisor mode by an SBI implementation, which
+		// is only loaded by default since QEMU 5.1.0.
+		if len(machine.Spec.Firmware) == 0 {
+			qopts = append(qopts, WithBios("default"))
+		}
+
+		qopts = append(qopts,
+			WithMachine(qmachine),
+			WithCPU(qcpu),
 		)
 
 	default:
 		return nil, fmt.Errorf("unsupported architecture: %s", machine.Spec.Architecture)
 	}
 
+	if len(machine.Spec.Firmware) > 0 {
+		qopts = append(qopts, WithBios(machine.Spec.Firmware))
+	}
+
+	if len(machine.Spec.RestoreFrom) > 0 {
+		// Defer the incoming migration such that it can be started via QMP once
+		// the process is running, see Restore.
+		qopts = append(qopts, WithIncoming("defer"))
+	}
+
 	// Create a log file just for the QEMU process which can be used to debug
 	// issues when starting the VMM.
 	qemuLogFile := filepath.Join(machine.Status.StateDir, "qemu.log")
@@ -465,6 +634,29 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, fmt.Errorf("could not generate QEMU config: %v", err)
 	}
 
+	if portForwardIP != "" {
+		qcfg.PortForwardIfName = portForwardIfName
+		qcfg.PortForwardIP = portForwardIP
+
+		// Clean up if the machine could not be fully created.
+		defer func() {
+			if machine.Status.State != machinev1alpha1.MachineStateCreated {
+				if err := releasePorts(machine, qcfg); err != nil {
+					log.G(ctx).Warnf("could not release published ports: %v", err)
+				}
+			}
+		}()
+
+		if err := iptables.AddPortForwards(
+			string(machine.ObjectMeta.UID),
+			qcfg.PortForwardIfName,
+			iptables.PortForwardsFromMachinePorts(machine.Spec.Ports, qcfg.PortForwardIP)...,
+		); err != nil {
+			machine.Status.State = machinev1alpha1.MachineStateFailed
+			return machine, fmt.Errorf("could not forward ports: %v", err)
+		}
+	}
+
 	machine.Status.PlatformConfig = *qcfg
 
 	e, err := exec.NewExecutable(bin, *qcfg)
@@ -479,8 +671,13 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, fmt.Errorf("could not prepare QEMU process: %v", err)
 	}
 
+	machine.Status.Cmdline = process.Cmdline()
 	machine.CreationTimestamp = metav1.Now()
 
+	if machine.Spec.TraceBoot {
+		machine.Status.BootTrace.Spawned = time.Now()
+	}
+
 	// Start and also wait for the process to be released, this ensures the
 	// program is actively being executed.
 	if err := process.StartAndWait(ctx); err != nil {
@@ -494,14 +691,135 @@ func (service *machineV1alpha1Service) Create(ctx context.Context, machine *mach
 		return machine, fmt.Errorf("could not start and wait for QEMU process: %v", err)
 	}
 
+	// QEMU has daemonized at this point, so its pid is only known via its pid
+	// file.
+	vmm, err := processFromPidFile(qcfg.PidFile)
+	if err != nil {
+		machine.Status.State = machinev1alpha1.MachineStateFailed
+		return machine, fmt.Errorf("could not read QEMU pid file: %v", err)
+	}
+
+	if err := cgroup.Constrain(ctx, machine, int(vmm.Pid)); err != nil {
+		machine.Status.State = machinev1alpha1.MachineStateFailed
+		_ = vmm.KillWithContext(ctx)
+		_ = os.Remove(qcfg.PidFile)
+		return machine, err
+	}
+
+	if machine.Spec.TraceBoot {
+		// The QMP server only greets its clients once QEMU has finished
+		// initializing the machine.
+		if qmpClient, err := service.QMPClient(ctx, machine); err == nil {
+			machine.Status.BootTrace.ControlReady = time.Now()
+			qmpClient.Close()
+		} else {
+			log.G(ctx).Debugf("could not trace QMP readiness: %v", err)
+		}
+	}
+
 	machine.Status.State = machinev1alpha1.MachineStateCreated
 
 	return machine, nil
 }
 
 // Update implements kraftkit.sh/api/machine/v1alpha1.MachineService
+//
+// Network interfaces and 9pfs volumes which have been removed from the
+// specification of the machine are unplugged and network interfaces which have
+// been added are hot-plugged as network devices of the model of the machine.
+// QEMU does not allow filesystem backends to be created at runtime, so newly
+// added volumes are plugged as virtio-9p devices into the backends which were
+// reserved when the machine was created, and rejected once none is free.
 func (service *machineV1alpha1Service) Update(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
-	panic("not implemented: kraftkit.sh/machine/qemu.machineV1alpha1Service.Update")
+	switch machine.Status.State {
+	case machinev1alpha1.MachineStateCreated,
+		machinev1alpha1.MachineStateRunning,
+		machinev1alpha1.MachineStatePaused:
+	default:
+		return machine, fmt.Errorf("cannot update machine in state %s", machine.Status.State)
+	}
+
+	qcfg, err := getQEMUConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, fmt.Errorf("could not read QEMU config: %v", err)
+	}
+
+	// Memory-mapped devices are fixed once the machine has been created.
+	hotplug := transport(machine) != machinev1alpha1.MachineTransportMMIO
+
+	if len(qcfg.Attached) == 0 {
+		qcfg.Attached = attachedFromConfig(machine, qcfg)
+	}
+
+	diff := diffDevices(machine, qcfg.Attached)
+
+	var errs merr.Errors
+	attached := diff.keep
+
+	// Unplug devices first such that their identifiers can be re-used.
+	for _, dev := range diff.unplug {
+		if err := service.unplug(ctx, machine, dev); err != nil {
+			errs = append(errs, err)
+			attached = append(attached, dev)
+			continue
+		}
+
+		qcfg.forget(dev)
+	}
+
+	for _, plug := range diff.interfaces {
+		if !hotplug {
+			errs = append(errs, fmt.Errorf("cannot hot-plug network interface %s: devices cannot be added over MMIO transport", plug.iface.Spec.IfName))
+			continue
+		}
+
+		mac := plug.iface.Spec.MacAddress
+		if mac == "" {
+			startMac, err := macaddr.GenerateMacAddress(true)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			mac = macaddr.IncrementMacAddress(startMac).String()
+		}
+
+		dev, err := service.plugNetworkInterface(ctx, machine, plug.network, plug.iface, mac, nextHostNetId(machine, attached))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		attached = append(attached, *dev)
+	}
+
+	for _, vol := range diff.volumes {
+		if !hotplug {
+			errs = append(errs, fmt.Errorf("cannot hot-plug volume %s: devices cannot be added over MMIO transport", vol.Spec.Source))
+			continue
+		}
+
+		// QEMU cannot add filesystem backends at runtime, such that only as many
+		// volumes can be plugged as backends were reserved on creation.
+		fsdev, ok := freeFsDev(qcfg, attached)
+		if !ok {
+			errs = append(errs, fmt.Errorf("cannot hot-plug volume %s: no filesystem backend reserved for hot-plugging is free", vol.Spec.Source))
+			continue
+		}
+
+		dev, err := service.plugVolume(ctx, machine, vol, fsdev)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		attached = append(attached, *dev)
+	}
+
+	qcfg.Attached = attached
+	machine.Status.PlatformConfig = *qcfg
+
+	return machine, errs.Err()
 }
 
 // getQEMUConfigFromPlatformConfig converts the provided platformConfig
@@ -714,6 +1032,10 @@ func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machi
 		return machine, err
 	}
 
+	if machine.Spec.TraceBoot {
+		machine.Status.BootTrace.Continued = time.Now()
+	}
+
 	qcfg, ok := machine.Status.PlatformConfig.(QemuConfig)
 	if !ok {
 		return machine, fmt.Errorf("cannot cast QEMU platform configuration from machine status")
@@ -835,6 +1157,12 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		return machine, nil
 	}
 
+	if err := cgroup.UpdateUsage(machine); err != nil {
+		log.G(ctx).
+			WithField("machine", machine.Name).
+			Debugf("could not read resource usage: %v", err)
+	}
+
 	qmpClient, err := service.QMPClient(ctx, machine)
 	if err != nil && errors.Is(err, os.ErrNotExist) {
 		state = machinev1alpha1.MachineStateExited
@@ -865,7 +1193,10 @@ func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machine
 		state = machinev1alpha1.MachineStateFailed
 		exitCode = 1
 
-	case qmpapi.RUN_STATE_PAUSED:
+	case qmpapi.RUN_STATE_PAUSED,
+		qmpapi.RUN_STATE_INMIGRATE,
+		qmpapi.RUN_STATE_FINISH_MIGRATE,
+		qmpapi.RUN_STATE_POSTMIGRATE:
 		state = machinev1alpha1.MachineStatePaused
 		exitCode = -1
 
@@ -914,35 +1245,60 @@ func (service *machineV1alpha1Service) List(ctx context.Context, machines *machi
 
 // Stop implements kraftkit.sh/api/machine/v1alpha1.MachineService.Stop
 func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
-	qmpClient, err := service.QMPClient(ctx, machine)
+	qcfg, err := getQEMUConfigFromPlatformConfig(machine.Status.PlatformConfig)
 	if err != nil {
-		return machine, fmt.Errorf("could not stop qemu instance: %v", err)
+		return machine, fmt.Errorf("cannot read QEMU platform configuration from machine status: %v", err)
 	}
 
-	defer qmpClient.Close()
-	_, err = qmpClient.Quit(qmpapi.QuitRequest{})
-	if err != nil {
-		return machine, err
+	// QEMU removes its pid file when it exits.
+	if _, err := os.Stat(qcfg.PidFile); os.IsNotExist(err) {
+		if err := releasePorts(machine, qcfg); err != nil {
+			return machine, err
+		}
+
+		machine.Status.State = machinev1alpha1.MachineStateExited
+		return machine, cgroup.Release(machine)
 	}
 
-	qcfg, ok := machine.Status.PlatformConfig.(QemuConfig)
-	if !ok {
-		return machine, fmt.Errorf("cannot read QEMU platform configuration from machine status")
+	process, err := processFromPidFile(qcfg.PidFile)
+	if err != nil {
+		return machine, fmt.Errorf("could not stop qemu instance: %v", err)
 	}
 
-	machine.Status.State = machinev1alpha1.MachineStateExited
+	stage, err := shutdown.Stop(ctx, machine, process.Pid, func(ctx context.Context) error {
+		qmpClient, err := service.QMPClient(ctx, machine)
+		if err != nil {
+			return err
+		}
 
-	if err := retrytimeout.RetryTimeout(5*time.Second, func() error {
-		if _, err := os.ReadFile(qcfg.PidFile); !os.IsNotExist(err) {
-			return fmt.Errorf("process still active")
+		defer qmpClient.Close()
+
+		res, err := qmpClient.SystemPowerdown(qmpapi.SystemPowerdownRequest{})
+		if err != nil {
+			return err
 		}
 
-		return nil
-	}); err != nil {
+		return qmpResponseError(res)
+	})
+	if err != nil {
+		return machine, fmt.Errorf("could not stop qemu instance: %v", err)
+	}
+
+	// The pid file is left behind if QEMU did not get the chance to exit
+	// cleanly.
+	if err := os.Remove(qcfg.PidFile); err != nil && !os.IsNotExist(err) {
+		return machine, fmt.Errorf("could not remove pid file: %v", err)
+	}
+
+	if err := releasePorts(machine, qcfg); err != nil {
 		return machine, err
 	}
 
-	return machine, nil
+	machine.Status.State = machinev1alpha1.MachineStateExited
+	machine.Status.StopStage = stage
+	machine.Status.ExitedAt = time.Now()
+
+	return machine, cgroup.Release(machine)
 }
 
 // Delete implements kraftkit.sh/api/machine/v1alpha1.MachineService.Delete
@@ -965,5 +1321,255 @@ func (service *machineV1alpha1Service) Delete(ctx context.Context, machine *mach
 	_ = os.Remove(qcfg.QMP[0].Resource())
 	_ = os.Remove(qcfg.QMP[1].Resource())
 
+	if err := releasePorts(machine, &qcfg); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := cgroup.Release(machine); err != nil {
+		errs = append(errs, err)
+	}
+
 	return nil, errs.Err()
 }
+
+// qemuSnapshotStateFileName is the name of the file within a snapshot's
+// directory which contains the migration stream of the machine.
+const qemuSnapshotStateFileName = "state"
+
+// shellQuote quotes the provided path such that it can be safely embedded in
+// an "exec:" migration URI, which QEMU evaluates with a shell.
+func shellQuote(path string) string {
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
+
+// waitForMigration polls the status of the ongoing migration of the machine
+// until it has either completed or failed.
+func (service *machineV1alpha1Service) waitForMigration(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		// Use a new connection for every query such that events which are
+		// emitted during the migration are not mistaken for responses.
+		qmpClient, err := service.QMPClient(ctx, machine)
+		if err != nil {
+			return fmt.Errorf("could not connect to QMP: %v", err)
+		}
+
+		info, err := qmpClient.QueryMigrate(qmpapi.QueryMigrateRequest{})
+		qmpClient.Close()
+		if err != nil {
+			return fmt.Errorf("could not query migration status: %v", err)
+		}
+
+		switch info.Return.Status {
+		case "completed":
+			return nil
+		case "failed", "cancelled":
+			return fmt.Errorf("migration %s: %s", info.Return.Status, info.Return.ErrorDesc)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Snapshot implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Snapshot(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	// Tap interfaces, their addresses and published ports are recorded in the
+	// snapshot and would therefore collide with the original machine's.
+	if len(machine.Spec.Ports) > 0 || len(machine.Spec.Networks) > 0 {
+		return machine, fmt.Errorf("cannot snapshot QEMU machine with network devices")
+	}
+	for _, vol := range machine.Spec.Volumes {
+		if vol.Spec.Driver == "9pfs" {
+			return machine, fmt.Errorf("cannot snapshot QEMU machine with 9pfs volume: %s", vol.Spec.Source)
+		}
+	}
+
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not snapshot qemu instance: %v", err)
+	}
+
+	status, err := qmpClient.QueryStatus(qmpapi.QueryStatusRequest{})
+	if err != nil {
+		qmpClient.Close()
+		return machine, fmt.Errorf("could not query machine status via QMP: %v", err)
+	}
+
+	// Pause the machine such that its state remains consistent for the whole
+	// duration of the migration.
+	paused := false
+	if status.Return.Running {
+		if _, err := qmpClient.Stop(qmpapi.StopRequest{}); err != nil {
+			qmpClient.Close()
+			return machine, fmt.Errorf("could not pause machine: %v", err)
+		}
+
+		paused = true
+
+		// Resume the machine should the snapshot fail, such that it is not left
+		// paused.
+		defer func() {
+			if !paused {
+				return
+			}
+
+			if _, err := service.Start(ctx, machine); err != nil {
+				log.G(ctx).Warnf("could not resume machine after failed snapshot: %v", err)
+			}
+		}()
+	}
+
+	snapshot, err := machinev1alpha1.NewMachineSnapshot(machine)
+	if err != nil {
+		qmpClient.Close()
+		return machine, err
+	}
+
+	snapshot.StatePath = filepath.Join(snapshot.Path, qemuSnapshotStateFileName)
+
+	_, err = qmpClient.Migrate(qmpapi.MigrateRequest{
+		Arguments: qmpapi.MigrateRequestArguments{
+			Uri: "exec:cat > " + shellQuote(snapshot.StatePath),
+		},
+	})
+	qmpClient.Close()
+	if err != nil {
+		return machine, fmt.Errorf("could not start migration: %v", err)
+	}
+
+	if err := service.waitForMigration(ctx, machine); err != nil {
+		return machine, fmt.Errorf("could not snapshot machine: %v", err)
+	}
+
+	if paused {
+		paused = false
+		if _, err := service.Start(ctx, machine); err != nil {
+			return machine, fmt.Errorf("could not resume machine after snapshot: %v", err)
+		}
+	}
+
+	if err := machinev1alpha1.SaveMachineSnapshotManifest(machine, snapshot); err != nil {
+		return machine, err
+	}
+
+	machine.Status.Snapshots = append(machine.Status.Snapshots, *snapshot)
+
+	return machine, nil
+}
+
+// Restore implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Restore(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	if len(machine.Spec.RestoreFrom) == 0 {
+		return machine, fmt.Errorf("cannot restore machine without snapshot")
+	}
+
+	manifest, err := machinev1alpha1.LoadMachineSnapshotManifest(machine.Spec.RestoreFrom)
+	if err != nil {
+		return machine, err
+	}
+
+	if manifest.Snapshot.Platform != machine.Spec.Platform {
+		return machine, fmt.Errorf("cannot restore %s snapshot with %s platform", manifest.Snapshot.Platform, machine.Spec.Platform)
+	}
+
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not restore qemu instance: %v", err)
+	}
+
+	_, err = qmpClient.MigrateIncoming(qmpapi.MigrateIncomingRequest{
+		Arguments: qmpapi.MigrateIncomingRequestArguments{
+			Uri: "exec:cat " + shellQuote(manifest.Snapshot.StatePath),
+		},
+	})
+	qmpClient.Close()
+	if err != nil {
+		return machine, fmt.Errorf("could not start incoming migration: %v", err)
+	}
+
+	if err := service.waitForMigration(ctx, machine); err != nil {
+		return machine, fmt.Errorf("could not restore machine: %v", err)
+	}
+
+	// The machine remains paused until it is started.
+	machine.Status.State = machinev1alpha1.MachineStatePaused
+
+	return machine, nil
+}
+
+// guestMemory returns the memory in bytes which is available to the guest,
+// given the base and hot-plugged memory of the machine and the actual size of
+// its memory balloon, which is zero if it has none.
+func guestMemory(base, plugged, ballooned int64) int64 {
+	if ballooned > 0 {
+		return ballooned
+	}
+
+	return base + plugged
+}
+
+// Stats implements kraftkit.sh/api/machine/v1alpha1.MachineService.Stats
+func (service *machineV1alpha1Service) Stats(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	qcfg, err := getQEMUConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, fmt.Errorf("cannot read QEMU platform configuration from machine status: %v", err)
+	}
+
+	process, err := processFromPidFile(qcfg.PidFile)
+	if err != nil {
+		return machine, fmt.Errorf("qemu instance is not running: %v", err)
+	}
+
+	usage := machinev1alpha1.MachineResourceUsage{}
+
+	if len(machine.Status.CgroupPath) > 0 {
+		if err := cgroup.UpdateUsage(machine); err != nil {
+			return machine, err
+		}
+
+		usage = machine.Status.Usage
+	}
+
+	if err := stats.Process(ctx, process.Pid, &usage); err != nil {
+		return machine, err
+	}
+
+	qmpClient, err := service.QMPClient(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not start qmp client: %v", err)
+	}
+
+	memory, err := qmpClient.QueryMemorySizeSummary(qmpapi.QueryMemorySizeSummaryRequest{})
+	qmpClient.Close()
+	if err != nil {
+		return machine, fmt.Errorf("could not query memory size via QMP: %v", err)
+	}
+
+	// The memory of the guest is reduced by the size of its balloon, if the
+	// machine has one, otherwise QEMU responds with an error.
+	qmpClient, err = service.QMPClient(ctx, machine)
+	if err != nil {
+		return machine, fmt.Errorf("could not start qmp client: %v", err)
+	}
+
+	var ballooned int64
+	balloon, err := qmpClient.QueryBalloon(qmpapi.QueryBalloonRequest{})
+	qmpClient.Close()
+	if err == nil {
+		ballooned = balloon.Return.Actual
+	}
+
+	usage.GuestMemory = guestMemory(memory.Return.BaseMemory, memory.Return.PluggedMemory, ballooned)
+
+	usage.Interfaces = stats.Interfaces(machine)
+
+	machine.Status.Usage = usage
+
+	return machine, nil
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package qemu
+
+import "testing"
+
+func TestGuestMemory(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      int64
+		plugged   int64
+		ballooned int64
+		want      int64
+	}{
+		{
+			name: "base memory",
+			base: 64 << 20,
+			want: 64 << 20,
+		},
+		{
+			name:    "hot-plugged memory",
+			base:    64 << 20,
+			plugged: 32 << 20,
+			want:    96 << 20,
+		},
+		{
+			name:      "inflated balloon",
+			base:      64 << 20,
+			ballooned: 48 << 20,
+			want:      48 << 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := guestMemory(tt.base, tt.plugged, tt.ballooned); got != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
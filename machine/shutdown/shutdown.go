@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package shutdown implements the escalating sequence with which platform
+// drivers stop the process of a machine: the guest is first asked to shut down
+// gracefully and, should it not do so in time, its process is sent SIGTERM and
+// finally SIGKILL.
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goprocess "github.com/shirou/gopsutil/v3/process"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/log"
+)
+
+const (
+	// DefaultTimeout is the duration which a machine is given to gracefully
+	// shut down when its specification does not provide one.
+	DefaultTimeout = 10 * time.Second
+
+	// signalTimeout is the duration to wait for the process to exit after it
+	// has been sent a signal.
+	signalTimeout = 5 * time.Second
+
+	// pollInterval is how often the process is checked for having exited.
+	pollInterval = 100 * time.Millisecond
+)
+
+// Timeout returns the duration which the provided machine is given to
+// gracefully shut down.
+func Timeout(machine *machinev1alpha1.Machine) time.Duration {
+	if machine.Spec.StopTimeout == nil {
+		return DefaultTimeout
+	}
+
+	return *machine.Spec.StopTimeout
+}
+
+// exited returns whether the process with the provided pid no longer runs.
+func exited(ctx context.Context, pid int32) bool {
+	exists, err := goprocess.PidExistsWithContext(ctx, pid)
+	if err != nil || !exists {
+		return true
+	}
+
+	process, err := goprocess.NewProcessWithContext(ctx, pid)
+	if err != nil {
+		return true
+	}
+
+	status, err := process.StatusWithContext(ctx)
+	if err != nil {
+		return false
+	}
+
+	for _, s := range status {
+		if s == goprocess.Zombie {
+			return true
+		}
+	}
+
+	return false
+}
+
+// waitUntil polls the provided condition until it is met or the timeout
+// elapses, returning whether it was met.  The wait is bounded only by the
+// timeout, since the provided context may already have been cancelled.
+func waitUntil(ctx context.Context, timeout time.Duration, condition func(context.Context) bool) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if condition(ctx) {
+			return true
+		}
+
+		select {
+		case <-deadline.C:
+			return condition(ctx)
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitForExit polls the process with the provided pid until it has exited or
+// the timeout elapses, returning whether it exited.
+func waitForExit(ctx context.Context, pid int32, timeout time.Duration) bool {
+	return waitUntil(ctx, timeout, func(ctx context.Context) bool {
+		return exited(ctx, pid)
+	})
+}
+
+// Shutdown asks the guest of the provided machine to shut down via the
+// provided graceful method and gives it the machine's timeout to do so, as
+// reported by the provided method.  It returns whether the machine shut down,
+// which is never the case for a zero timeout.  Platforms whose machines are not
+// backed by a process on the host use it before forcibly stopping a machine,
+// whereas Stop should be used otherwise.
+func Shutdown(ctx context.Context, machine *machinev1alpha1.Machine, graceful func(context.Context) error, stopped func(context.Context) bool) bool {
+	ctx = context.WithoutCancel(ctx)
+
+	timeout := Timeout(machine)
+	if timeout <= 0 {
+		return false
+	}
+
+	if err := graceful(ctx); err != nil {
+		log.G(ctx).
+			WithField("machine", machine.Name).
+			Debugf("could not request graceful shutdown: %v", err)
+		return false
+	}
+
+	return waitUntil(ctx, timeout, stopped)
+}
+
+// Stop stops the process with the provided pid which backs the machine.  The
+// guest is first asked to shut down via the provided graceful method and is
+// given the machine's timeout to do so, after which the process is sent
+// SIGTERM and then SIGKILL.  A zero timeout or nil graceful method skips
+// straight to SIGTERM.  The stage which stopped the machine is returned.
+//
+// Machines are often stopped because the provided context was cancelled, e.g.
+// by `kraft run --rm` after an interrupt, so the sequence is carried out
+// regardless of its cancellation and is bounded by its own timeouts instead.
+// The graceful method is passed a context which is likewise not cancelled.
+func Stop(ctx context.Context, machine *machinev1alpha1.Machine, pid int32, graceful func(context.Context) error) (machinev1alpha1.MachineStopStage, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	if graceful != nil && Shutdown(ctx, machine, graceful, func(ctx context.Context) bool {
+		return exited(ctx, pid)
+	}) {
+		return machinev1alpha1.MachineStopStageShutdown, nil
+	}
+
+	process, err := goprocess.NewProcessWithContext(ctx, pid)
+	if err != nil {
+		// The process exited in the meantime.
+		return machinev1alpha1.MachineStopStageShutdown, nil
+	}
+
+	log.G(ctx).
+		WithField("machine", machine.Name).
+		Debug("terminating process")
+
+	if err := process.TerminateWithContext(ctx); err != nil && !exited(ctx, pid) {
+		return "", fmt.Errorf("could not terminate process %d: %v", pid, err)
+	}
+
+	if waitForExit(ctx, pid, signalTimeout) {
+		return machinev1alpha1.MachineStopStageTerminate, nil
+	}
+
+	log.G(ctx).
+		WithField("machine", machine.Name).
+		Debug("killing process")
+
+	if err := process.KillWithContext(ctx); err != nil && !exited(ctx, pid) {
+		return "", fmt.Errorf("could not kill process %d: %v", pid, err)
+	}
+
+	if !waitForExit(ctx, pid, signalTimeout) {
+		return "", fmt.Errorf("process %d did not exit after being killed", pid)
+	}
+
+	return machinev1alpha1.MachineStopStageKill, nil
+}
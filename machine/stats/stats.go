@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+
+// Package stats gathers the resource usage of machines which can be observed
+// from the host, independently of the virtual machine monitor which runs them.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goprocess "github.com/shirou/gopsutil/v3/process"
+	"github.com/vishvananda/netlink"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+// Process populates the provided usage with the resident memory of the
+// process with the provided pid and, unless it has already been accounted for
+// by the cgroup of the machine, its CPU time.
+func Process(ctx context.Context, pid int32, usage *machinev1alpha1.MachineResourceUsage) error {
+	process, err := goprocess.NewProcessWithContext(ctx, pid)
+	if err != nil {
+		return fmt.Errorf("could not find process %d: %v", pid, err)
+	}
+
+	meminfo, err := process.MemoryInfoWithContext(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read memory usage of process %d: %v", pid, err)
+	}
+
+	usage.RSS = int64(meminfo.RSS)
+
+	if usage.CPUTime == 0 {
+		times, err := process.TimesWithContext(ctx)
+		if err != nil {
+			return fmt.Errorf("could not read cpu usage of process %d: %v", pid, err)
+		}
+
+		usage.CPUTime = time.Duration((times.User + times.System) * float64(time.Second))
+	}
+
+	return nil
+}
+
+// Interface returns the traffic of the guest through the host link with the
+// provided name, typically a tap device.  Traffic which is received by the
+// link on the host has been transmitted by the guest and vice versa.
+func Interface(ifname string) (*machinev1alpha1.MachineInterfaceUsage, error) {
+	link, err := netlink.LinkByName(ifname)
+	if err != nil {
+		return nil, fmt.Errorf("could not get link %s: %v", ifname, err)
+	}
+
+	usage := interfaceUsage(ifname, link.Attrs().Statistics)
+
+	return &usage, nil
+}
+
+// interfaceUsage converts the provided statistics of a host link, which may be
+// nil, into the traffic of the guest through it.
+func interfaceUsage(ifname string, statistics *netlink.LinkStatistics) machinev1alpha1.MachineInterfaceUsage {
+	usage := machinev1alpha1.MachineInterfaceUsage{
+		IfName: ifname,
+	}
+
+	if statistics != nil {
+		usage.RxBytes = statistics.TxBytes
+		usage.RxPackets = statistics.TxPackets
+		usage.TxBytes = statistics.RxBytes
+		usage.TxPackets = statistics.RxPackets
+	}
+
+	return usage
+}
+
+// Interfaces returns the traffic of each of the network interfaces of the
+// machine whose host link can be found.
+func Interfaces(machine *machinev1alpha1.Machine) []machinev1alpha1.MachineInterfaceUsage {
+	var ret []machinev1alpha1.MachineInterfaceUsage
+
+	for _, network := range machine.Spec.Networks {
+		for _, iface := range network.Interfaces {
+			usage, err := Interface(iface.Spec.IfName)
+			if err != nil {
+				continue
+			}
+
+			ret = append(ret, *usage)
+		}
+	}
+
+	return ret
+}
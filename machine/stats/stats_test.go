@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package stats
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vishvananda/netlink"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+)
+
+func TestInterfaceUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		statistics *netlink.LinkStatistics
+		want       machinev1alpha1.MachineInterfaceUsage
+	}{
+		{
+			name: "without statistics",
+			want: machinev1alpha1.MachineInterfaceUsage{IfName: "tap0"},
+		},
+		{
+			name: "traffic of the guest is the reverse of the host link",
+			statistics: &netlink.LinkStatistics{
+				RxBytes:   100,
+				RxPackets: 2,
+				TxBytes:   300,
+				TxPackets: 4,
+			},
+			want: machinev1alpha1.MachineInterfaceUsage{
+				IfName:    "tap0",
+				RxBytes:   300,
+				RxPackets: 4,
+				TxBytes:   100,
+				TxPackets: 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interfaceUsage("tap0", tt.statistics); got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	ctx := context.Background()
+	pid := int32(os.Getpid())
+
+	var usage machinev1alpha1.MachineResourceUsage
+	if err := Process(ctx, pid, &usage); err != nil {
+		t.Fatalf("Could not read usage: %v", err)
+	}
+
+	if usage.RSS <= 0 {
+		t.Errorf("Expected positive resident memory, got %d", usage.RSS)
+	}
+
+	// CPU time which has been accounted for by the cgroup of the machine is
+	// retained.
+	usage = machinev1alpha1.MachineResourceUsage{CPUTime: time.Hour}
+	if err := Process(ctx, pid, &usage); err != nil {
+		t.Fatalf("Could not read usage: %v", err)
+	}
+
+	if usage.CPUTime != time.Hour {
+		t.Errorf("Expected CPU time to be retained, got %s", usage.CPUTime)
+	}
+}
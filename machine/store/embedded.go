@@ -8,44 +8,95 @@ import (
 	"bytes"
 	"context"
 	"encoding/gob"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	zip "api.zip"
 	"github.com/dgraph-io/badger/v3"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/apiserver/pkg/storage"
 	"kraftkit.sh/internal/retrytimeout"
+	"kraftkit.sh/log"
 )
 
+// embeddedVersioner uses the commit timestamp of the entry of an object in the
+// embedded store as the resource version of the object.  Badger increments
+// the timestamp with every committed transaction, so that it is monotonic
+// across all keys of the store.
 type embeddedVersioner struct{}
 
 // UpdateObject implements storage.Versioner
 func (version *embeddedVersioner) UpdateObject(obj runtime.Object, resourceVersion uint64) error {
-	panic("not implemented: kraftkit.sh/machine/store.embeddedVersioner.UpdateObject")
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	versionString := ""
+	if resourceVersion != 0 {
+		versionString = strconv.FormatUint(resourceVersion, 10)
+	}
+
+	accessor.SetResourceVersion(versionString)
+
+	return nil
 }
 
 // UpdateList implements storage.Versioner
 func (version *embeddedVersioner) UpdateList(obj runtime.Object, resourceVersion uint64, continueValue string, remainingItemCount *int64) error {
-	panic("not implemented: kraftkit.sh/machine/store.embeddedVersioner.UpdateList")
+	if resourceVersion == 0 {
+		return fmt.Errorf("illegal resource version from storage: %d", resourceVersion)
+	}
+
+	listAccessor, err := meta.ListAccessor(obj)
+	if err != nil {
+		return err
+	}
+
+	listAccessor.SetResourceVersion(strconv.FormatUint(resourceVersion, 10))
+	listAccessor.SetContinue(continueValue)
+	listAccessor.SetRemainingItemCount(remainingItemCount)
+
+	return nil
 }
 
 // PrepareObjectForStorage implements storage.Versioner
 func (version *embeddedVersioner) PrepareObjectForStorage(obj runtime.Object) error {
-	panic("not implemented: kraftkit.sh/machine/store.embeddedVersioner.PrepareObjectForStorage")
+	// The resource version is derived from the entry in the store rather than
+	// being saved with the object.
+	return version.UpdateObject(obj, 0)
 }
 
 // ObjectResourceVersion implements storage.Versioner
 func (version *embeddedVersioner) ObjectResourceVersion(obj runtime.Object) (uint64, error) {
-	panic("not implemented: kraftkit.sh/machine/store.embeddedVersioner.ObjectResourceVersion")
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return 0, err
+	}
+
+	return version.ParseResourceVersion(accessor.GetResourceVersion())
 }
 
 // ParseResourceVersion implements storage.Versioner
 func (version *embeddedVersioner) ParseResourceVersion(resourceVersion string) (uint64, error) {
-	panic("not implemented: kraftkit.sh/machine/store.embeddedVersioner.ParseResourceVersion")
+	if resourceVersion == "" || resourceVersion == "0" {
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseUint(resourceVersion, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid resource version %s: %v", resourceVersion, err)
+	}
+
+	return parsed, nil
 }
 
 // embedded is KraftKit's default internal storage mechanism which is based on
@@ -55,6 +106,10 @@ type embedded[Spec, Status any] struct {
 	db        *badger.DB
 	bopts     badger.Options
 	timeout   time.Duration
+
+	// mu serializes access to the database, which is opened and closed for
+	// every operation, between goroutines sharing the store, e.g. watchers.
+	mu sync.Mutex
 }
 
 // NewEmbeddedStore returns a api.zip.Store-compatible storage interface based
@@ -93,8 +148,11 @@ func NewEmbeddedStore[Spec, Status any](path string) (zip.Store, error) {
 func (store *embedded[_, _]) open() error {
 	var db *badger.DB
 
+	store.mu.Lock()
+
 	db, err := badger.Open(store.bopts)
 	if err != nil && strings.Contains(err.Error(), "permission denied") {
+		store.mu.Unlock()
 		return fmt.Errorf("could not open machine store: %v", err)
 	} else if err != nil {
 		// Perform a continuous re-try to check for the dir lock on the badger
@@ -108,6 +166,7 @@ func (store *embedded[_, _]) open() error {
 
 			return nil
 		}); err != nil {
+			store.mu.Unlock()
 			return fmt.Errorf("could not open machine store: %v", err)
 		}
 	}
@@ -119,57 +178,239 @@ func (store *embedded[_, _]) open() error {
 
 // close the embedded key-value store
 func (store *embedded[_, _]) close() error {
+	defer store.mu.Unlock()
+
 	return store.db.Close()
 }
 
+// signalPath returns the path of the file which is written whenever the store
+// is changed, such that watchers in any process are notified of the change.
+func (store *embedded[_, _]) signalPath() string {
+	return strings.TrimRight(store.path, string(os.PathSeparator)) + ".changed"
+}
+
+// notify watchers that the store has been changed.
+func (store *embedded[_, _]) notify(ctx context.Context) {
+	if err := os.WriteFile(store.signalPath(), []byte(time.Now().Format(time.RFC3339Nano)), 0o644); err != nil {
+		log.G(ctx).Debugf("could not signal change of store: %v", err)
+	}
+}
+
+// decode the value of the provided item into the provided object and set the
+// resource version of the object to the version of the item.
+func (store *embedded[_, _]) decode(item *badger.Item, obj runtime.Object) error {
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return fmt.Errorf("could not copy from store for %s: %v", item.Key(), err)
+	}
+
+	if err := gob.NewDecoder(bytes.NewBuffer(val)).Decode(obj); err != nil {
+		return err
+	}
+
+	return store.versioner.UpdateObject(obj, item.Version())
+}
+
+// encode the provided object for storage.
+func (store *embedded[_, _]) encode(obj runtime.Object) ([]byte, error) {
+	if err := store.versioner.PrepareObjectForStorage(obj); err != nil {
+		return nil, err
+	}
+
+	b := bytes.Buffer{}
+	if err := gob.NewEncoder(&b).Encode(obj); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// version returns the version of the entry of the provided key.
+func (store *embedded[_, _]) version(key string) (uint64, error) {
+	var version uint64
+
+	if err := store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		version = item.Version()
+
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
 // Versioner implements storage.Interface
 func (store *embedded[_, _]) Versioner() storage.Versioner {
 	return store.versioner
 }
 
 // Create implements storage.Interface
-func (store *embedded[_, _]) Create(ctx context.Context, key string, _, out runtime.Object, ttl uint64) error {
+//
+// An existing object at the provided key is replaced, which is how the results
+// of operations on the object are saved.  If the object carries a resource
+// version, it must however be the version of the existing object, such that an
+// object which has been updated elsewhere in the meantime, e.g. by another
+// process, is not overwritten.
+func (store *embedded[Spec, Status]) Create(ctx context.Context, key string, obj, out runtime.Object, ttl uint64) error {
+	expected, err := store.versioner.ObjectResourceVersion(obj)
+	if err != nil {
+		return err
+	}
+
 	if err := store.open(); err != nil {
 		return err
 	}
 
 	defer store.close()
 
-	b := bytes.Buffer{}
-	if err := gob.NewEncoder(&b).Encode(out); err != nil {
+	b, err := store.encode(out)
+	if err != nil {
 		return fmt.Errorf("could not encode driver config for %s: %v", key, err)
 	}
 
-	txn := store.db.NewTransaction(true)
-	if err := txn.SetEntry(badger.NewEntry([]byte(key), b.Bytes())); err != nil {
-		return fmt.Errorf("could not save machine driver to store for %s: %v", key, err)
+	changed := true
+
+	if err := store.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		// Objects are saved after every operation, including those which only
+		// read them, which must neither change their version nor notify watchers.
+		if err == nil && ttl == 0 && store.unchanged(item, out) {
+			changed = false
+			return nil
+		}
+
+		if err == nil && expected > 0 && item.Version() != expected {
+			return storage.NewResourceVersionConflictsError(key, int64(expected))
+		}
+
+		entry := badger.NewEntry([]byte(key), b)
+		if ttl > 0 {
+			entry = entry.WithTTL(time.Duration(ttl) * time.Second)
+		}
+
+		return txn.SetEntry(entry)
+	}); storage.IsConflict(err) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("could not save machine driver to store for %s: %w", key, err)
+	}
+
+	if changed {
+		store.notify(ctx)
+	}
+
+	version, err := store.version(key)
+	if err != nil {
+		return fmt.Errorf("could not read version of %s: %v", key, err)
 	}
 
-	return txn.Commit()
+	return store.versioner.UpdateObject(out, version)
+}
+
+// unchanged returns whether the provided object is the same as the one saved
+// in the provided item.  Objects are compared by their JSON representation,
+// which, unlike their encoding for storage, does not depend on the order in
+// which maps are iterated.
+func (store *embedded[Spec, Status]) unchanged(item *badger.Item, obj runtime.Object) bool {
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return false
+	}
+
+	current := &zip.Object[Spec, Status]{}
+	if err := gob.NewDecoder(bytes.NewBuffer(val)).Decode(current); err != nil {
+		return false
+	}
+
+	a, err := json.Marshal(current)
+	if err != nil {
+		return false
+	}
+
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(a, b)
 }
 
 // Delete implements storage.Interface
-func (store *embedded[_, _]) Delete(ctx context.Context, key string, out runtime.Object, preconditions *storage.Preconditions, validateDeletion storage.ValidateObjectFunc, cachedExistingObject runtime.Object) error {
+func (store *embedded[Spec, Status]) Delete(ctx context.Context, key string, out runtime.Object, preconditions *storage.Preconditions, validateDeletion storage.ValidateObjectFunc, cachedExistingObject runtime.Object) error {
 	if err := store.open(); err != nil {
 		return err
 	}
 
 	defer store.close()
 
-	txn := store.db.NewTransaction(true)
+	if out == nil {
+		out = &zip.Object[Spec, Status]{}
+	}
+
+	deleted := false
 
-	if err := txn.Delete([]byte(key)); err != nil {
+	if err := store.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// Populate the output with the object as it was last saved such that it
+		// can be checked against the preconditions.
+		if err := store.decode(item, out); err != nil {
+			return fmt.Errorf("could not decode %s: %v", key, err)
+		}
+
+		if preconditions != nil {
+			if err := preconditions.Check(key, out); err != nil {
+				return err
+			}
+		}
+
+		if validateDeletion != nil {
+			if err := validateDeletion(ctx, out); err != nil {
+				return err
+			}
+		}
+
+		if err := txn.Delete([]byte(key)); err != nil {
+			return err
+		}
+
+		deleted = true
+
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	// TODO(nderjung): preconditions, validateDelete, cachedExistingObject
+	if deleted {
+		store.notify(ctx)
+	}
 
-	return txn.Commit()
+	return nil
 }
 
 // Watch implements storage.Interface
-func (store *embedded[_, _]) Watch(ctx context.Context, key string, opts storage.ListOptions) (watch.Interface, error) {
-	panic("not implemented: kraftkit.sh/machine/store.embedded.Watch")
+func (store *embedded[Spec, Status]) Watch(ctx context.Context, key string, opts storage.ListOptions) (watch.Interface, error) {
+	since, err := store.versioner.ParseResourceVersion(opts.ResourceVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEmbeddedWatcher(ctx, store, key, opts, since)
 }
 
 // Get implements storage.Interface
@@ -186,15 +427,7 @@ func (store *embedded[_, _]) Get(ctx context.Context, key string, opts storage.G
 			return fmt.Errorf("could not access store for %s: %v", key, err)
 		}
 
-		val, err := item.ValueCopy(nil)
-		if err != nil {
-			return fmt.Errorf("could not copy from store for %s: %v", key, err)
-		}
-
-		b := bytes.Buffer{}
-		b.Write(val)
-
-		return gob.NewDecoder(&b).Decode(objPtr)
+		return store.decode(item, objPtr)
 	}); err != nil {
 		return fmt.Errorf("could not read from store for %s: %v", key, err)
 	}
@@ -202,60 +435,198 @@ func (store *embedded[_, _]) Get(ctx context.Context, key string, opts storage.G
 	return nil
 }
 
-// GetList implements storage.Interface
-func (store *embedded[Spec, Status]) GetList(ctx context.Context, key string, opts storage.ListOptions, listObj runtime.Object) error {
-	if err := store.open(); err != nil {
-		return err
-	}
-
-	defer store.close()
-
-	// Re-cast the list
-	list := listObj.(*zip.ObjectList[Spec, Status])
+// embeddedEntry is an object in the store alongside its key and version.
+type embeddedEntry[Spec, Status any] struct {
+	key     string
+	version uint64
+	obj     zip.Object[Spec, Status]
+}
 
-	// Truncate the list of results as we are about to re-populate
-	list.Items = make([]zip.Object[Spec, Status], 0)
+// list returns the entries of the store whose key starts with the provided
+// prefix and whose objects match the provided predicate, as well as the largest
+// version of any entry in the store at the prefix.
+func (store *embedded[Spec, Status]) list(prefix string, pred storage.SelectionPredicate) ([]embeddedEntry[Spec, Status], uint64, error) {
+	entries := make([]embeddedEntry[Spec, Status], 0)
+	var latest uint64
 
 	if err := store.db.View(func(txn *badger.Txn) error {
 		itr := txn.NewIterator(badger.IteratorOptions{
-			Prefix:       []byte(key),
+			Prefix:       []byte(prefix),
 			PrefetchSize: 10, // TODO(nderjung): Arbitrarily picked
 		})
 
 		defer itr.Close()
 
 		for itr.Rewind(); itr.Valid(); itr.Next() {
-			val, err := itr.Item().ValueCopy(nil)
-			if err != nil {
-				return err
+			item := itr.Item()
+			if item.Version() > latest {
+				latest = item.Version()
 			}
 
-			b := bytes.Buffer{}
-			b.Write(val)
+			entry := embeddedEntry[Spec, Status]{
+				key:     string(item.KeyCopy(nil)),
+				version: item.Version(),
+			}
 
-			var obj zip.Object[Spec, Status]
+			if err := store.decode(item, &entry.obj); err != nil {
+				return err
+			}
 
-			if err := gob.NewDecoder(&b).Decode(&obj); err != nil {
+			if ok, err := matches(pred, &entry.obj); err != nil {
 				return err
+			} else if !ok {
+				continue
 			}
 
-			list.Items = append(list.Items, obj)
+			entries = append(entries, entry)
 		}
 
 		return nil
 	}); err != nil {
+		return nil, 0, err
+	}
+
+	return entries, latest, nil
+}
+
+// GetList implements storage.Interface
+func (store *embedded[Spec, Status]) GetList(ctx context.Context, key string, opts storage.ListOptions, listObj runtime.Object) error {
+	if err := store.open(); err != nil {
+		return err
+	}
+
+	defer store.close()
+
+	// Re-cast the list
+	list := listObj.(*zip.ObjectList[Spec, Status])
+
+	entries, latest, err := store.list(key, opts.Predicate)
+	if err != nil {
 		return fmt.Errorf("could not list from store at %s: %v", key, err)
 	}
 
+	// Truncate the list of results as we are about to re-populate
+	list.Items = make([]zip.Object[Spec, Status], len(entries))
+	for i, entry := range entries {
+		list.Items[i] = entry.obj
+	}
+
+	if latest > 0 {
+		return store.versioner.UpdateList(list, latest, "", nil)
+	}
+
 	return nil
 }
 
 // GuaranteedUpdate implements storage.Interface
-func (store *embedded[_, _]) GuaranteedUpdate(ctx context.Context, key string, destination runtime.Object, ignoreNotFound bool, preconditions *storage.Preconditions, tryUpdate storage.UpdateFunc, cachedExistingObject runtime.Object) error {
-	panic("not implemented: kraftkit.sh/machine/store.embedded.GuaranteedUpdate")
+//
+// The provided update is applied to the object as it is currently saved and
+// re-applied should the object be changed by another transaction whilst being
+// updated.
+func (store *embedded[Spec, Status]) GuaranteedUpdate(ctx context.Context, key string, destination runtime.Object, ignoreNotFound bool, preconditions *storage.Preconditions, tryUpdate storage.UpdateFunc, cachedExistingObject runtime.Object) error {
+	if err := store.open(); err != nil {
+		return err
+	}
+
+	defer store.close()
+
+	for {
+		var updated runtime.Object
+
+		err := store.db.Update(func(txn *badger.Txn) error {
+			current := &zip.Object[Spec, Status]{}
+			var version uint64
+
+			item, err := txn.Get([]byte(key))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				if !ignoreNotFound {
+					return storage.NewKeyNotFoundError(key, 0)
+				}
+			} else if err != nil {
+				return err
+			} else {
+				if err := store.decode(item, current); err != nil {
+					return fmt.Errorf("could not decode %s: %v", key, err)
+				}
+
+				version = item.Version()
+			}
+
+			if preconditions != nil {
+				if err := preconditions.Check(key, current); err != nil {
+					return err
+				}
+			}
+
+			updated, _, err = tryUpdate(current, storage.ResponseMeta{
+				ResourceVersion: version,
+			})
+			if err != nil {
+				return err
+			}
+
+			b, err := store.encode(updated)
+			if err != nil {
+				return fmt.Errorf("could not encode %s: %v", key, err)
+			}
+
+			return txn.SetEntry(badger.NewEntry([]byte(key), b))
+		})
+		if errors.Is(err, badger.ErrConflict) {
+			log.G(ctx).WithField("key", key).Trace("retrying conflicting update")
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		store.notify(ctx)
+
+		version, err := store.version(key)
+		if err != nil {
+			return fmt.Errorf("could not read version of %s: %v", key, err)
+		}
+
+		obj, ok := updated.(*zip.Object[Spec, Status])
+		if !ok {
+			return fmt.Errorf("unexpected type of updated object: %T", updated)
+		}
+
+		dest, ok := destination.(*zip.Object[Spec, Status])
+		if !ok {
+			return fmt.Errorf("unexpected type of destination object: %T", destination)
+		}
+
+		*dest = *obj
+
+		return store.versioner.UpdateObject(dest, version)
+	}
 }
 
 // Count implements storage.Interface
 func (store *embedded[_, _]) Count(key string) (int64, error) {
-	panic("not implemented: kraftkit.sh/machine/store.embedded.Count")
+	if err := store.open(); err != nil {
+		return 0, err
+	}
+
+	defer store.close()
+
+	var count int64
+
+	if err := store.db.View(func(txn *badger.Txn) error {
+		itr := txn.NewIterator(badger.IteratorOptions{
+			Prefix: []byte(key),
+		})
+
+		defer itr.Close()
+
+		for itr.Rewind(); itr.Valid(); itr.Next() {
+			count++
+		}
+
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("could not count entries in store at %s: %v", key, err)
+	}
+
+	return count, nil
 }
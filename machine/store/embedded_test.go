@@ -0,0 +1,392 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package store
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	zip "api.zip"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+type testSpec struct {
+	Value string
+}
+
+type testStatus struct {
+	State string
+}
+
+type (
+	testObject     = zip.Object[testSpec, testStatus]
+	testObjectList = zip.ObjectList[testSpec, testStatus]
+)
+
+func newTestStore(t *testing.T) zip.Store {
+	t.Helper()
+
+	store, err := NewEmbeddedStore[testSpec, testStatus](filepath.Join(t.TempDir(), "store"))
+	if err != nil {
+		t.Fatal("NewEmbeddedStore:", err)
+	}
+
+	return store
+}
+
+func newTestObject(name, value string, lbls map[string]string) *testObject {
+	return &testObject{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: lbls,
+		},
+		Spec: testSpec{
+			Value: value,
+		},
+	}
+}
+
+func TestEmbeddedCreateVersion(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	obj := newTestObject("a", "first", nil)
+	if err := store.Create(ctx, "a", obj, obj, 0); err != nil {
+		t.Fatal("Create:", err)
+	}
+
+	created := obj.ResourceVersion
+	if created == "" {
+		t.Fatal("Expected resource version after creation")
+	}
+
+	// Saving the object unchanged must neither bump its version nor fail.
+	if err := store.Create(ctx, "a", obj, obj, 0); err != nil {
+		t.Fatal("Create unchanged:", err)
+	}
+
+	if obj.ResourceVersion != created {
+		t.Errorf("Expected unchanged object to keep version %s, got %s", created, obj.ResourceVersion)
+	}
+
+	obj.Status.State = "running"
+	if err := store.Create(ctx, "a", obj, obj, 0); err != nil {
+		t.Fatal("Create changed:", err)
+	}
+
+	changed, err := store.Versioner().ObjectResourceVersion(obj)
+	if err != nil {
+		t.Fatal("ObjectResourceVersion:", err)
+	}
+
+	previous, err := store.Versioner().ParseResourceVersion(created)
+	if err != nil {
+		t.Fatal("ParseResourceVersion:", err)
+	}
+
+	if changed <= previous {
+		t.Errorf("Expected changed object to have version above %d, got %d", previous, changed)
+	}
+
+	got := &testObject{}
+	if err := store.Get(ctx, "a", storage.GetOptions{}, got); err != nil {
+		t.Fatal("Get:", err)
+	}
+
+	if got.ResourceVersion != obj.ResourceVersion {
+		t.Errorf("Expected saved version %s, got %s", obj.ResourceVersion, got.ResourceVersion)
+	}
+
+	if got.Status.State != "running" {
+		t.Errorf("Expected saved state running, got %q", got.Status.State)
+	}
+}
+
+func TestEmbeddedCreateConflict(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	obj := newTestObject("a", "first", nil)
+	if err := store.Create(ctx, "a", obj, obj, 0); err != nil {
+		t.Fatal("Create:", err)
+	}
+
+	// Two writers which have both read the same version of the object must not
+	// overwrite each other's changes.
+	writers := make([]*testObject, 2)
+	for i := range writers {
+		writers[i] = &testObject{}
+		if err := store.Get(ctx, "a", storage.GetOptions{}, writers[i]); err != nil {
+			t.Fatal("Get:", err)
+		}
+
+		writers[i].Spec.Value = fmt.Sprintf("writer-%d", i)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(writers))
+	start := make(chan struct{})
+
+	for i := range writers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = store.Create(ctx, "a", writers[i], writers[i], 0)
+		}(i)
+	}
+
+	close(start)
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		if err == nil {
+			if winner >= 0 {
+				t.Fatal("Expected only one writer to succeed")
+			}
+
+			winner = i
+		} else if !storage.IsConflict(err) {
+			t.Errorf("Expected conflict error, got %v", err)
+		}
+	}
+
+	if winner < 0 {
+		t.Fatal("Expected one writer to succeed")
+	}
+
+	got := &testObject{}
+	if err := store.Get(ctx, "a", storage.GetOptions{}, got); err != nil {
+		t.Fatal("Get:", err)
+	}
+
+	if want := fmt.Sprintf("writer-%d", winner); got.Spec.Value != want {
+		t.Errorf("Expected saved value %q, got %q", want, got.Spec.Value)
+	}
+
+	// The losing writer can save its change once it has re-read the object.
+	loser := writers[1-winner]
+	if err := store.Get(ctx, "a", storage.GetOptions{}, loser); err != nil {
+		t.Fatal("Get:", err)
+	}
+
+	loser.Spec.Value = "retried"
+	if err := store.Create(ctx, "a", loser, loser, 0); err != nil {
+		t.Fatal("Create after re-reading:", err)
+	}
+}
+
+func TestEmbeddedGetList(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	for _, obj := range []*testObject{
+		newTestObject("a", "first", map[string]string{"app": "web"}),
+		newTestObject("b", "second", map[string]string{"app": "db"}),
+		newTestObject("c", "third", nil),
+	} {
+		if err := store.Create(ctx, obj.Name, obj, obj, 0); err != nil {
+			t.Fatal("Create:", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		label string
+		field string
+		want  []string
+	}{
+		{
+			name: "Everything",
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name:  "Label",
+			label: "app=web",
+			want:  []string{"a"},
+		},
+		{
+			name:  "Label existence",
+			label: "app",
+			want:  []string{"a", "b"},
+		},
+		{
+			name:  "Negated label",
+			label: "app!=web",
+			want:  []string{"b", "c"},
+		},
+		{
+			name:  "Field",
+			field: "metadata.name=c",
+			want:  []string{"c"},
+		},
+		{
+			name:  "Label and field",
+			label: "app",
+			field: "metadata.name!=a",
+			want:  []string{"b"},
+		},
+		{
+			name:  "No match",
+			label: "app=cache",
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pred := storage.Everything
+
+			if tt.label != "" {
+				selector, err := labels.Parse(tt.label)
+				if err != nil {
+					t.Fatal("labels.Parse:", err)
+				}
+				pred.Label = selector
+			}
+
+			if tt.field != "" {
+				selector, err := fields.ParseSelector(tt.field)
+				if err != nil {
+					t.Fatal("fields.ParseSelector:", err)
+				}
+				pred.Field = selector
+			}
+
+			list := &testObjectList{}
+			if err := store.GetList(ctx, "", storage.ListOptions{
+				Predicate: pred,
+				Recursive: true,
+			}, list); err != nil {
+				t.Fatal("GetList:", err)
+			}
+
+			got := []string{}
+			for _, obj := range list.Items {
+				got = append(got, obj.Name)
+			}
+
+			sort.Strings(got)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// nextEvent returns the next event of the provided watcher, failing the test if
+// none is received in time.
+func nextEvent(t *testing.T, watcher watch.Interface) watch.Event {
+	t.Helper()
+
+	select {
+	case event, ok := <-watcher.ResultChan():
+		if !ok {
+			t.Fatal("Watcher stopped unexpectedly")
+		}
+
+		return event
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for event")
+	}
+
+	return watch.Event{}
+}
+
+func TestEmbeddedWatch(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	existing := newTestObject("a", "first", map[string]string{"app": "web"})
+	if err := store.Create(ctx, "a", existing, existing, 0); err != nil {
+		t.Fatal("Create:", err)
+	}
+
+	selector, err := labels.Parse("app=web")
+	if err != nil {
+		t.Fatal("labels.Parse:", err)
+	}
+
+	pred := storage.Everything
+	pred.Label = selector
+
+	watcher, err := store.Watch(ctx, "", storage.ListOptions{
+		Predicate: pred,
+		Recursive: true,
+	})
+	if err != nil {
+		t.Fatal("Watch:", err)
+	}
+
+	defer watcher.Stop()
+
+	// Objects which have not been seen since the provided version are reported
+	// as added.
+	expect := func(typ watch.EventType, name, value string) {
+		t.Helper()
+
+		event := nextEvent(t, watcher)
+		obj, ok := event.Object.(*testObject)
+		if !ok {
+			t.Fatalf("Expected object of type %T, got %T", obj, event.Object)
+		}
+
+		if event.Type != typ || obj.Name != name || obj.Spec.Value != value {
+			t.Fatalf("Expected %s event of %s with value %q, got %s event of %s with value %q", typ, name, value, event.Type, obj.Name, obj.Spec.Value)
+		}
+	}
+
+	expect(watch.Added, "a", "first")
+
+	// Objects which are not selected are not reported.
+	ignored := newTestObject("b", "ignored", map[string]string{"app": "db"})
+	if err := store.Create(ctx, "b", ignored, ignored, 0); err != nil {
+		t.Fatal("Create:", err)
+	}
+
+	added := newTestObject("c", "second", map[string]string{"app": "web"})
+	if err := store.Create(ctx, "c", added, added, 0); err != nil {
+		t.Fatal("Create:", err)
+	}
+
+	expect(watch.Added, "c", "second")
+
+	added.Spec.Value = "modified"
+	if err := store.Create(ctx, "c", added, added, 0); err != nil {
+		t.Fatal("Create:", err)
+	}
+
+	expect(watch.Modified, "c", "modified")
+
+	if err := store.Delete(ctx, "a", nil, nil, nil, nil); err != nil {
+		t.Fatal("Delete:", err)
+	}
+
+	expect(watch.Deleted, "a", "first")
+
+	watcher.Stop()
+
+	select {
+	case event, ok := <-watcher.ResultChan():
+		if ok {
+			t.Errorf("Expected no further events, got %s", event.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected result channel to be closed after stopping")
+	}
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package store
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// matches returns whether the provided object is selected by the label and
+// field selectors of the predicate.  Unless the predicate provides its own
+// attributes, objects can be selected by the fields metadata.name,
+// metadata.namespace and metadata.uid.
+func matches(pred storage.SelectionPredicate, obj runtime.Object) (bool, error) {
+	if (pred.Label == nil || pred.Label.Empty()) && (pred.Field == nil || pred.Field.Empty()) {
+		return true, nil
+	}
+
+	if pred.GetAttrs != nil {
+		return pred.Matches(obj)
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false, err
+	}
+
+	if pred.Label != nil && !pred.Label.Matches(labels.Set(accessor.GetLabels())) {
+		return false, nil
+	}
+
+	if pred.Field != nil && !pred.Field.Matches(fields.Set{
+		"metadata.name":      accessor.GetName(),
+		"metadata.namespace": accessor.GetNamespace(),
+		"metadata.uid":       string(accessor.GetUID()),
+	}) {
+		return false, nil
+	}
+
+	return true, nil
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package store
+
+import (
+	"context"
+	"fmt"
+
+	zip "api.zip"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+// Update applies the provided update to the object with the provided UID as it
+// is currently saved in the provided embedded store and returns the object as
+// it has been saved.  Unlike saving an object via its service, only the fields
+// changed by the update are written, such that changes which have been saved
+// elsewhere in the meantime are preserved.
+func Update[Spec, Status any](ctx context.Context, s zip.Store, uid types.UID, update func(*zip.Object[Spec, Status])) (*zip.Object[Spec, Status], error) {
+	store, ok := s.(*embedded[Spec, Status])
+	if !ok {
+		return nil, fmt.Errorf("unsupported store: %T", s)
+	}
+
+	if err := store.open(); err != nil {
+		return nil, err
+	}
+
+	entries, _, err := store.list("", storage.SelectionPredicate{
+		Field: fields.OneTermEqualSelector("metadata.uid", string(uid)),
+	})
+	store.close()
+
+	if err != nil {
+		return nil, fmt.Errorf("could not list from store: %v", err)
+	} else if len(entries) == 0 {
+		return nil, fmt.Errorf("object %s not found in store", uid)
+	}
+
+	updated := &zip.Object[Spec, Status]{}
+
+	if err := store.GuaranteedUpdate(ctx, entries[0].key, updated, false, storage.NewUIDPreconditions(string(uid)),
+		func(input runtime.Object, _ storage.ResponseMeta) (runtime.Object, *uint64, error) {
+			obj := input.(*zip.Object[Spec, Status])
+			update(obj)
+			return obj, nil, nil
+		}, nil,
+	); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package store
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apiserver/pkg/storage"
+)
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+
+	for _, name := range []string{"a", "b"} {
+		obj := newTestObject(name, "first", nil)
+		obj.UID = types.UID("uid-" + name)
+
+		if err := store.Create(ctx, name, obj, obj, 0); err != nil {
+			t.Fatal("Create:", err)
+		}
+	}
+
+	// A writer which has read the object before it is updated.
+	stale := &testObject{}
+	if err := store.Get(ctx, "b", storage.GetOptions{}, stale); err != nil {
+		t.Fatal("Get:", err)
+	}
+
+	// A change which is saved elsewhere in the meantime.
+	changed := &testObject{}
+	if err := store.Get(ctx, "b", storage.GetOptions{}, changed); err != nil {
+		t.Fatal("Get:", err)
+	}
+
+	changed.Spec.Value = "second"
+	if err := store.Create(ctx, "b", changed, changed, 0); err != nil {
+		t.Fatal("Create:", err)
+	}
+
+	updated, err := Update[testSpec, testStatus](ctx, store, "uid-b", func(obj *testObject) {
+		obj.Status.State = "healthy"
+	})
+	if err != nil {
+		t.Fatal("Update:", err)
+	}
+
+	if updated.Spec.Value != "second" || updated.Status.State != "healthy" {
+		t.Errorf("Expected updated object with value second and state healthy, got %+v", updated)
+	}
+
+	if updated.ResourceVersion == changed.ResourceVersion {
+		t.Errorf("Expected resource version to change from %s", changed.ResourceVersion)
+	}
+
+	for key, want := range map[string]testStatus{
+		"a": {},
+		"b": {State: "healthy"},
+	} {
+		got := &testObject{}
+		if err := store.Get(ctx, key, storage.GetOptions{}, got); err != nil {
+			t.Fatal("Get:", err)
+		}
+
+		if got.Status != want {
+			t.Errorf("Expected saved status of %s %+v, got %+v", key, want, got.Status)
+		}
+	}
+
+	// The stale writer must not overwrite the update.
+	stale.Spec.Value = "stale"
+	if err := store.Create(ctx, "b", stale, stale, 0); !storage.IsConflict(err) {
+		t.Errorf("Expected conflict error, got %v", err)
+	}
+
+	if _, err := Update[testSpec, testStatus](ctx, store, "uid-c", func(obj *testObject) {}); err == nil {
+		t.Error("Expected error updating missing object")
+	}
+}
@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apiserver/pkg/storage"
+
+	"kraftkit.sh/log"
+)
+
+// watchRetryInterval is how long a watcher waits before comparing the entries
+// of the store again if it could not do so after being notified of a change,
+// e.g. because the store was held by another process for too long.
+const watchRetryInterval = time.Second
+
+// embeddedWatcher streams the changes to the objects in the store at a key.
+// The store is shared between processes, each of which only opens the
+// database whilst accessing it, such that a watcher cannot subscribe to the
+// changes of the database directly.  Instead, every change to the store is
+// signalled by writing its signal file, upon which the watcher compares the
+// versions of the entries of the store with those it has previously seen.
+type embeddedWatcher[Spec, Status any] struct {
+	store   *embedded[Spec, Status]
+	key     string
+	opts    storage.ListOptions
+	signals *fsnotify.Watcher
+	result  chan watch.Event
+	cancel  context.CancelFunc
+}
+
+// newEmbeddedWatcher starts watching the objects in the store at the provided
+// key, or below it if the list options are recursive.  Objects which have been
+// changed since the provided resource version are reported as added, which
+// includes every object if the version is zero.
+func newEmbeddedWatcher[Spec, Status any](ctx context.Context, store *embedded[Spec, Status], key string, opts storage.ListOptions, since uint64) (*embeddedWatcher[Spec, Status], error) {
+	// The directory rather than the signal file itself is watched, since the
+	// file only exists once the store has been changed.
+	dir := filepath.Dir(store.signalPath())
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create directory of store: %v", err)
+	}
+
+	signals, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("could not watch store: %v", err)
+	}
+
+	if err := signals.Add(dir); err != nil {
+		signals.Close()
+		return nil, fmt.Errorf("could not watch store: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	watcher := &embeddedWatcher[Spec, Status]{
+		store:   store,
+		key:     key,
+		opts:    opts,
+		signals: signals,
+		result:  make(chan watch.Event),
+		cancel:  cancel,
+	}
+
+	go watcher.run(ctx, since)
+
+	return watcher, nil
+}
+
+// Stop implements watch.Interface
+func (watcher *embeddedWatcher[_, _]) Stop() {
+	watcher.cancel()
+}
+
+// ResultChan implements watch.Interface
+func (watcher *embeddedWatcher[_, _]) ResultChan() <-chan watch.Event {
+	return watcher.result
+}
+
+// snapshot returns the entries which are being watched, keyed by their key.
+// Only objects which match the predicate of the watcher are included, such that
+// objects which stop matching it are reported as deleted.
+func (watcher *embeddedWatcher[Spec, Status]) snapshot() (map[string]embeddedEntry[Spec, Status], error) {
+	if err := watcher.store.open(); err != nil {
+		return nil, err
+	}
+
+	defer watcher.store.close()
+
+	entries, _, err := watcher.store.list(watcher.key, watcher.opts.Predicate)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]embeddedEntry[Spec, Status], len(entries))
+	for _, entry := range entries {
+		if !watcher.opts.Recursive && entry.key != watcher.key {
+			continue
+		}
+
+		ret[entry.key] = entry
+	}
+
+	return ret, nil
+}
+
+// send the provided event unless the watcher has been stopped.
+func (watcher *embeddedWatcher[Spec, Status]) send(ctx context.Context, typ watch.EventType, entry embeddedEntry[Spec, Status]) bool {
+	obj := entry.obj
+
+	select {
+	case <-ctx.Done():
+		return false
+	case watcher.result <- watch.Event{Type: typ, Object: &obj}:
+		return true
+	}
+}
+
+// compare reports the differences between the current entries of the store
+// and those which were previously seen.  It returns false if the watcher has
+// been stopped.
+func (watcher *embeddedWatcher[Spec, Status]) compare(ctx context.Context, seen, current map[string]embeddedEntry[Spec, Status], since uint64) bool {
+	for key, entry := range current {
+		previous, ok := seen[key]
+		if !ok && (seen != nil || entry.version > since) {
+			if !watcher.send(ctx, watch.Added, entry) {
+				return false
+			}
+		} else if ok && previous.version != entry.version {
+			if !watcher.send(ctx, watch.Modified, entry) {
+				return false
+			}
+		}
+	}
+
+	for key, entry := range seen {
+		if _, ok := current[key]; !ok {
+			if !watcher.send(ctx, watch.Deleted, entry) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// run compares the entries of the store with those which were previously seen
+// whenever the store signals a change, until the watcher is stopped.
+func (watcher *embeddedWatcher[Spec, Status]) run(ctx context.Context, since uint64) {
+	defer close(watcher.result)
+	defer watcher.signals.Close()
+
+	signal := watcher.store.signalPath()
+
+	var (
+		seen  map[string]embeddedEntry[Spec, Status]
+		retry <-chan time.Time
+	)
+
+	for {
+		current, err := watcher.snapshot()
+		if err != nil {
+			// The store may be held by another process for longer than it can be
+			// waited for, in which case it is compared again shortly.
+			log.G(ctx).Debugf("could not watch store at %s: %v", watcher.key, err)
+			retry = time.After(watchRetryInterval)
+		} else {
+			if !watcher.compare(ctx, seen, current, since) {
+				return
+			}
+
+			seen = current
+			retry = nil
+		}
+
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-watcher.signals.Events:
+				if !ok {
+					return
+				}
+
+				if event.Name == signal && event.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+					break wait
+				}
+
+			case err, ok := <-watcher.signals.Errors:
+				if !ok {
+					return
+				}
+
+				// Signals may have been missed, so compare regardless.
+				log.G(ctx).Debugf("could not watch store at %s: %v", watcher.key, err)
+				break wait
+
+			case <-retry:
+				break wait
+			}
+		}
+	}
+}
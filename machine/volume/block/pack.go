@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package block
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"kraftkit.sh/exec"
+	"kraftkit.sh/log"
+)
+
+// Format is the filesystem format of a block device image.
+type Format string
+
+const (
+	FormatExt2  = Format("ext2")
+	FormatErofs = Format("erofs")
+
+	// DefaultFormat is the format used when packing a volume without specifying
+	// one explicitly.
+	DefaultFormat = FormatExt2
+)
+
+const (
+	// minImageSize is the smallest image which is produced, which ensures that
+	// there is sufficient space for the filesystem's metadata as well as some
+	// headroom for the machine to write to.
+	minImageSize = 8 * 1024 * 1024
+
+	// imageSizeAlignment is the size to which images are rounded up.
+	imageSizeAlignment = 1024 * 1024
+)
+
+// String implements fmt.Stringer
+func (format Format) String() string {
+	return string(format)
+}
+
+// Formats returns the list of supported block device image formats.
+func Formats() []Format {
+	return []Format{
+		FormatExt2,
+		FormatErofs,
+	}
+}
+
+// imageSize returns the size in bytes of the image which is necessary to hold
+// the contents of the provided directory.
+func imageSize(source string) (int64, error) {
+	var total int64
+
+	if err := filepath.WalkDir(source, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		// Account for the inode of each entry in addition to its contents.
+		total += info.Size() + 4096
+
+		return nil
+	}); err != nil {
+		return 0, fmt.Errorf("could not calculate size of %s: %w", source, err)
+	}
+
+	// Double the size so that the machine has room to write.
+	total *= 2
+	if total < minImageSize {
+		total = minImageSize
+	}
+
+	return (total + imageSizeAlignment - 1) / imageSizeAlignment * imageSizeAlignment, nil
+}
+
+// Pack serializes the contents of the source directory into a filesystem
+// image of the provided format at the output path, which can then be attached
+// to a machine as a block device.  Note that any changes made to the image by
+// the machine are not reflected back to the source directory.
+func Pack(ctx context.Context, source, output string, format Format) error {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("cannot stat host path volume: %w", err)
+	} else if !fi.IsDir() {
+		return fmt.Errorf("supplied path is not a directory: %s", source)
+	}
+
+	var bin string
+	var args []string
+
+	switch format {
+	case FormatExt2:
+		size, err := imageSize(source)
+		if err != nil {
+			return err
+		}
+
+		// mkfs.ext2 requires the image to already be of the desired size.
+		img, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("could not create image: %w", err)
+		}
+
+		if err := img.Truncate(size); err != nil {
+			img.Close()
+			return fmt.Errorf("could not allocate image: %w", err)
+		}
+
+		if err := img.Close(); err != nil {
+			return fmt.Errorf("could not close image: %w", err)
+		}
+
+		bin = "mkfs.ext2"
+		args = []string{"-q", "-F", "-d", source, output}
+
+	case FormatErofs:
+		bin = "mkfs.erofs"
+		args = []string{"--quiet", output, source}
+
+	default:
+		return fmt.Errorf("unsupported block volume format: %s", format)
+	}
+
+	var stderr bytes.Buffer
+
+	process, err := exec.NewProcess(bin, args,
+		exec.WithStderr(&stderr),
+	)
+	if err != nil {
+		return fmt.Errorf("could not prepare %s: %w", bin, err)
+	}
+
+	log.G(ctx).
+		WithField("source", source).
+		WithField("format", format).
+		Debug("packing volume")
+
+	if err := process.StartAndWait(ctx); err != nil {
+		return fmt.Errorf("could not pack %s into %s image: %w: %s", source, format, err, stderr.String())
+	}
+
+	return nil
+}
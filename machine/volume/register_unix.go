@@ -6,6 +6,7 @@ package volume
 
 import (
 	"context"
+	"os"
 	"path/filepath"
 
 	zip "api.zip"
@@ -13,22 +14,60 @@ import (
 	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
 	"kraftkit.sh/config"
 	"kraftkit.sh/kconfig"
+	"kraftkit.sh/machine/platform"
 	"kraftkit.sh/machine/store"
 	ninepfs "kraftkit.sh/machine/volume/9pfs"
+	"kraftkit.sh/machine/volume/block"
+	"kraftkit.sh/machine/volume/virtiofs"
 )
 
+// newEmbeddedVolumeServiceV1alpha1 wraps the provided volume service with the
+// embedded store such that volumes are persisted across invocations.
+func newEmbeddedVolumeServiceV1alpha1(ctx context.Context, service volumev1alpha1.VolumeService) (volumev1alpha1.VolumeService, error) {
+	embeddedStore, err := store.NewEmbeddedStore[volumev1alpha1.VolumeSpec, volumev1alpha1.VolumeStatus](
+		filepath.Join(
+			config.G[config.KraftKit](ctx).RuntimeDir,
+			"volumev1alpha1",
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return volumev1alpha1.NewVolumeServiceHandler(
+		ctx,
+		service,
+		zip.WithStore[volumev1alpha1.VolumeSpec, volumev1alpha1.VolumeStatus](embeddedStore, zip.StoreRehydrationSpecNil),
+	)
+}
+
 // hostSupportedStrategies returns the map of known supported drivers for the
 // given host.
 func hostSupportedStrategies() map[string]*Strategy {
 	return map[string]*Strategy{
 		"9pfs": {
-			IsCompatible: func(source string, _ kconfig.KeyValueMap) (bool, error) {
-				// TODO(nderjung): For now, it is OK to return true because this is the
-				// only supported driver.  In the future, we should a). check if the
-				// provided source is a readable directory and b). check if the supplied
-				// KConfig of the machine indicates that 9pfs is indeed part of the
-				// build configuration.
-				return true, nil
+			IsCompatible: func(source, plat string, kconf kconfig.KeyValueMap) (bool, error) {
+				// Neither Firecracker nor Cloud Hypervisor provide a 9P device.
+				switch plat {
+				case platform.PlatformFirecracker.String(),
+					platform.PlatformCloudHypervisor.String():
+					return false, nil
+				}
+
+				// Shares are only usable when the unikernel has been built with 9pfs,
+				// which is assumed if its KConfig is not known.
+				if kconf != nil {
+					if ninep, ok := kconf.Get("CONFIG_LIB9PFS"); !ok || ninep.Value != kconfig.Yes {
+						return false, nil
+					}
+				}
+
+				fi, err := os.Stat(source)
+				if err != nil {
+					return false, err
+				}
+
+				return fi.IsDir(), nil
 			},
 			NewVolumeV1alpha1: func(ctx context.Context, opts ...any) (volumev1alpha1.VolumeService, error) {
 				service, err := ninepfs.NewVolumeServiceV1alpha1(ctx, opts...)
@@ -36,21 +75,70 @@ func hostSupportedStrategies() map[string]*Strategy {
 					return nil, err
 				}
 
-				embeddedStore, err := store.NewEmbeddedStore[volumev1alpha1.VolumeSpec, volumev1alpha1.VolumeStatus](
-					filepath.Join(
-						config.G[config.KraftKit](ctx).RuntimeDir,
-						"volumev1alpha1",
-					),
-				)
+				return newEmbeddedVolumeServiceV1alpha1(ctx, service)
+			},
+		},
+		"block": {
+			IsCompatible: func(source, plat string, kconf kconfig.KeyValueMap) (bool, error) {
+				// Only Firecracker attaches directories packed into block devices.
+				if plat != platform.PlatformFirecracker.String() {
+					return false, nil
+				}
+
+				// Block volumes are only usable when the unikernel has been built with
+				// support for block devices, which is assumed if its KConfig is not
+				// known.
+				if kconf != nil {
+					if blkdev, ok := kconf.Get("CONFIG_LIBUKBLKDEV"); !ok || blkdev.Value != kconfig.Yes {
+						return false, nil
+					}
+				}
+
+				fi, err := os.Stat(source)
+				if err != nil {
+					return false, err
+				}
+
+				return fi.IsDir(), nil
+			},
+			NewVolumeV1alpha1: func(ctx context.Context, opts ...any) (volumev1alpha1.VolumeService, error) {
+				service, err := block.NewVolumeServiceV1alpha1(ctx, opts...)
+				if err != nil {
+					return nil, err
+				}
+
+				return newEmbeddedVolumeServiceV1alpha1(ctx, service)
+			},
+		},
+		"virtiofs": {
+			IsCompatible: func(source, plat string, kconf kconfig.KeyValueMap) (bool, error) {
+				// Only Cloud Hypervisor shares directories via virtio-fs.
+				if plat != platform.PlatformCloudHypervisor.String() {
+					return false, nil
+				}
+
+				// Shares are only usable when the unikernel has been built with the
+				// virtio-fs driver, which is assumed if its KConfig is not known.
+				if kconf != nil {
+					if virtiofs, ok := kconf.Get("CONFIG_VIRTIO_FS"); !ok || virtiofs.Value != kconfig.Yes {
+						return false, nil
+					}
+				}
+
+				fi, err := os.Stat(source)
+				if err != nil {
+					return false, err
+				}
+
+				return fi.IsDir(), nil
+			},
+			NewVolumeV1alpha1: func(ctx context.Context, opts ...any) (volumev1alpha1.VolumeService, error) {
+				service, err := virtiofs.NewVolumeServiceV1alpha1(ctx, opts...)
 				if err != nil {
 					return nil, err
 				}
 
-				return volumev1alpha1.NewVolumeServiceHandler(
-					ctx,
-					service,
-					zip.WithStore[volumev1alpha1.VolumeSpec, volumev1alpha1.VolumeStatus](embeddedStore, zip.StoreRehydrationSpecNil),
-				)
+				return newEmbeddedVolumeServiceV1alpha1(ctx, service)
 			},
 		},
 	}
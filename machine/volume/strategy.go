@@ -6,6 +6,8 @@ package volume
 
 import (
 	"context"
+	"fmt"
+	"sort"
 
 	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
 	"kraftkit.sh/kconfig"
@@ -22,8 +24,11 @@ var strategies = make(map[string]*Strategy)
 
 // Strategy represents canonical reference of a machine driver and their
 // platform.
+//
+// IsCompatible is provided the source of the volume, the name of the platform
+// of the machine and the KConfig of its unikernel, which is nil if unknown.
 type Strategy struct {
-	IsCompatible      func(string, kconfig.KeyValueMap) (bool, error)
+	IsCompatible      func(string, string, kconfig.KeyValueMap) (bool, error)
 	NewVolumeV1alpha1 NewStrategyConstructor[volumev1alpha1.VolumeService]
 }
 
@@ -38,12 +43,29 @@ func Strategies() map[string]*Strategy {
 }
 
 // DriverNames returns the list of registered platform driver implementation
-// names.
+// names in alphabetical order.
 func DriverNames() []string {
 	ret := []string{}
 	for plat := range Strategies() {
 		ret = append(ret, plat)
 	}
 
+	sort.Strings(ret)
+
 	return ret
 }
+
+// CompatibleDriver returns the name of the first driver which is compatible
+// with the provided source on the provided platform and, if it is known, the
+// KConfig of the unikernel.  Drivers are tried in alphabetical order.
+func CompatibleDriver(source, platform string, kconf kconfig.KeyValueMap) (string, error) {
+	strategies := Strategies()
+
+	for _, name := range DriverNames() {
+		if ok, _ := strategies[name].IsCompatible(source, platform, kconf); ok {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find compatible volume driver for %s", source)
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package volume
+
+import (
+	"path/filepath"
+	"testing"
+
+	"kraftkit.sh/kconfig"
+	"kraftkit.sh/machine/platform"
+)
+
+func TestCompatibleDriver(t *testing.T) {
+	dir := t.TempDir()
+
+	var (
+		qemu     = platform.PlatformQEMU.String()
+		fc       = platform.PlatformFirecracker.String()
+		ch       = platform.PlatformCloudHypervisor.String()
+		xen      = platform.PlatformXen.String()
+		ninep    = kconfig.KeyValueMap{}.Set("CONFIG_LIB9PFS", kconfig.Yes)
+		blkdev   = kconfig.KeyValueMap{}.Set("CONFIG_LIBUKBLKDEV", kconfig.Yes)
+		virtiofs = kconfig.KeyValueMap{}.Set("CONFIG_VIRTIO_FS", kconfig.Yes)
+	)
+
+	tests := []struct {
+		name     string
+		source   string
+		platform string
+		kconf    kconfig.KeyValueMap
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "QEMU without KConfig",
+			source:   dir,
+			platform: qemu,
+			want:     "9pfs",
+		},
+		{
+			name:     "QEMU with 9pfs",
+			source:   dir,
+			platform: qemu,
+			kconf:    ninep,
+			want:     "9pfs",
+		},
+		{
+			name:     "QEMU without 9pfs",
+			source:   dir,
+			platform: qemu,
+			kconf:    blkdev,
+			wantErr:  true,
+		},
+		{
+			name:     "Xen with 9pfs",
+			source:   dir,
+			platform: xen,
+			kconf:    ninep,
+			want:     "9pfs",
+		},
+		{
+			name:     "Firecracker without KConfig",
+			source:   dir,
+			platform: fc,
+			want:     "block",
+		},
+		{
+			name:     "Firecracker with 9pfs",
+			source:   dir,
+			platform: fc,
+			kconf:    kconfig.KeyValueMap{}.Set("CONFIG_LIB9PFS", kconfig.Yes).Set("CONFIG_LIBUKBLKDEV", kconfig.Yes),
+			want:     "block",
+		},
+		{
+			name:     "Firecracker without block devices",
+			source:   dir,
+			platform: fc,
+			kconf:    ninep,
+			wantErr:  true,
+		},
+		{
+			name:     "Cloud Hypervisor without KConfig",
+			source:   dir,
+			platform: ch,
+			want:     "virtiofs",
+		},
+		{
+			name:     "Cloud Hypervisor with virtio-fs",
+			source:   dir,
+			platform: ch,
+			kconf:    virtiofs,
+			want:     "virtiofs",
+		},
+		{
+			name:     "Cloud Hypervisor with only 9pfs",
+			source:   dir,
+			platform: ch,
+			kconf:    ninep,
+			wantErr:  true,
+		},
+		{
+			name:     "Missing source",
+			source:   filepath.Join(dir, "missing"),
+			platform: qemu,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CompatibleDriver(tt.source, tt.platform, tt.kconf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Expected driver %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package xen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// XenDomainType is the virtualization mode of a Xen domain.
+type XenDomainType string
+
+const (
+	XenDomainTypePV  = XenDomainType("pv")
+	XenDomainTypePVH = XenDomainType("pvh")
+)
+
+// XenAction is the action which is taken by the toolstack when the domain
+// shuts down, reboots or crashes.
+type XenAction string
+
+const (
+	XenActionDestroy  = XenAction("destroy")
+	XenActionPreserve = XenAction("preserve")
+)
+
+// XenVif represents a virtual network interface of the domain which is
+// attached to a bridge on the host.
+type XenVif struct {
+	// Bridge is the name of the bridge on the host.
+	Bridge string `json:"bridge,omitempty"`
+
+	// Mac is the MAC address of the interface.
+	Mac string `json:"mac,omitempty"`
+
+	// VifName is the name of the backend interface on the host.
+	VifName string `json:"vifname,omitempty"`
+}
+
+// String implements fmt.Stringer and returns the vif specification string.
+func (vif XenVif) String() string {
+	var ret []string
+
+	if vif.Bridge != "" {
+		ret = append(ret, "bridge="+vif.Bridge)
+	}
+	if vif.Mac != "" {
+		ret = append(ret, "mac="+vif.Mac)
+	}
+	if vif.VifName != "" {
+		ret = append(ret, "vifname="+vif.VifName)
+	}
+
+	return strings.Join(ret, ",")
+}
+
+// XenP9 represents a host directory which is shared with the domain via the
+// 9pfs protocol.
+type XenP9 struct {
+	// Tag is the mount tag which is used by the domain to identify the share.
+	Tag string `json:"tag,omitempty"`
+
+	// Path is the directory on the host.
+	Path string `json:"path,omitempty"`
+
+	// SecurityModel determines how file permissions are mapped to the host.
+	SecurityModel string `json:"security_model,omitempty"`
+}
+
+// String implements fmt.Stringer and returns the p9 specification string.
+func (p9 XenP9) String() string {
+	securityModel := p9.SecurityModel
+	if securityModel == "" {
+		securityModel = "none"
+	}
+
+	return fmt.Sprintf("tag=%s,security_model=%s,path=%s", p9.Tag, securityModel, p9.Path)
+}
+
+// XenConfig represents the xl domain configuration of a machine alongside the
+// locations of the files which are used to manage it.
+type XenConfig struct {
+	// Name of the domain.
+	Name string `json:"name,omitempty"`
+
+	// Type is the virtualization mode of the domain.
+	Type XenDomainType `json:"type,omitempty"`
+
+	// Kernel is the path to the kernel image.
+	Kernel string `json:"kernel,omitempty"`
+
+	// Ramdisk is the path to the initial ramdisk.
+	Ramdisk string `json:"ramdisk,omitempty"`
+
+	// Cmdline is the command-line passed to the kernel.
+	Cmdline string `json:"cmdline,omitempty"`
+
+	// Memory is the amount of memory of the domain in MiB.
+	Memory int64 `json:"memory,omitempty"`
+
+	// Vcpus is the number of virtual CPUs of the domain.
+	Vcpus int64 `json:"vcpus,omitempty"`
+
+	// Vifs are the network interfaces of the domain.
+	Vifs []XenVif `json:"vif,omitempty"`
+
+	// P9 are the host directories which are shared with the domain.
+	P9 []XenP9 `json:"p9,omitempty"`
+
+	// OnPoweroff is the action taken when the domain shuts down.
+	OnPoweroff XenAction `json:"on_poweroff,omitempty"`
+
+	// OnReboot is the action taken when the domain reboots.
+	OnReboot XenAction `json:"on_reboot,omitempty"`
+
+	// OnCrash is the action taken when the domain crashes.
+	OnCrash XenAction `json:"on_crash,omitempty"`
+
+	// ConfigFile is the path to the file containing the rendered configuration.
+	ConfigFile string `json:"config_file,omitempty"`
+
+	// ConsolePid is the process ID of the process which captures the console
+	// of the domain into the machine's log file.
+	ConsolePid int `json:"console_pid,omitempty"`
+}
+
+// quote returns the provided value as a string literal in the xl
+// configuration syntax.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// String implements fmt.Stringer and renders the configuration in the syntax
+// understood by xl, see xl.cfg(5).
+func (cfg XenConfig) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "name = %s\n", quote(cfg.Name))
+	fmt.Fprintf(&b, "type = %s\n", quote(string(cfg.Type)))
+	fmt.Fprintf(&b, "kernel = %s\n", quote(cfg.Kernel))
+
+	if cfg.Ramdisk != "" {
+		fmt.Fprintf(&b, "ramdisk = %s\n", quote(cfg.Ramdisk))
+	}
+	if cfg.Cmdline != "" {
+		fmt.Fprintf(&b, "cmdline = %s\n", quote(cfg.Cmdline))
+	}
+
+	fmt.Fprintf(&b, "memory = %d\n", cfg.Memory)
+	fmt.Fprintf(&b, "vcpus = %d\n", cfg.Vcpus)
+
+	if len(cfg.Vifs) > 0 {
+		vifs := make([]string, len(cfg.Vifs))
+		for i, vif := range cfg.Vifs {
+			vifs[i] = quote(vif.String())
+		}
+
+		fmt.Fprintf(&b, "vif = [ %s ]\n", strings.Join(vifs, ", "))
+	}
+
+	if len(cfg.P9) > 0 {
+		p9s := make([]string, len(cfg.P9))
+		for i, p9 := range cfg.P9 {
+			p9s[i] = quote(p9.String())
+		}
+
+		fmt.Fprintf(&b, "p9 = [ %s ]\n", strings.Join(p9s, ", "))
+	}
+
+	if cfg.OnPoweroff != "" {
+		fmt.Fprintf(&b, "on_poweroff = %s\n", quote(string(cfg.OnPoweroff)))
+	}
+	if cfg.OnReboot != "" {
+		fmt.Fprintf(&b, "on_reboot = %s\n", quote(string(cfg.OnReboot)))
+	}
+	if cfg.OnCrash != "" {
+		fmt.Fprintf(&b, "on_crash = %s\n", quote(string(cfg.OnCrash)))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,782 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package xen
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	zip "api.zip"
+	"github.com/acorn-io/baaah/pkg/merr"
+	goprocess "github.com/shirou/gopsutil/v3/process"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	"kraftkit.sh/config"
+	"kraftkit.sh/exec"
+	"kraftkit.sh/internal/logtail"
+	"kraftkit.sh/internal/run"
+	"kraftkit.sh/machine/network/daemon"
+	"kraftkit.sh/machine/network/macaddr"
+	"kraftkit.sh/machine/shutdown"
+	"kraftkit.sh/machine/stats"
+	"kraftkit.sh/unikraft/export/v0/ukargparse"
+	"kraftkit.sh/unikraft/export/v0/uknetdev"
+	"kraftkit.sh/unikraft/export/v0/vfscore"
+)
+
+const (
+	XenMemoryScale      = 1024 * 1024
+	DefaultPollInterval = time.Millisecond * 500
+)
+
+// machineV1alpha1Service ...
+type machineV1alpha1Service struct {
+	xlBin        string
+	eopts        []exec.ExecOption
+	pollInterval time.Duration
+}
+
+// NewMachineV1alpha1Service implements kraftkit.sh/machine/platform.NewStrategyConstructor
+func NewMachineV1alpha1Service(ctx context.Context, opts ...any) (machinev1alpha1.MachineService, error) {
+	service := machineV1alpha1Service{}
+
+	for _, opt := range opts {
+		qopt, ok := opt.(MachineServiceV1alpha1Option)
+		if !ok {
+			panic("cannot apply non-MachineServiceV1alpha1Option type methods")
+		}
+
+		if err := qopt(&service); err != nil {
+			return nil, err
+		}
+	}
+
+	if service.xlBin == "" {
+		service.xlBin = XlBin
+	}
+
+	if service.pollInterval == 0 {
+		service.pollInterval = DefaultPollInterval
+	}
+
+	return &service, nil
+}
+
+// getXenConfigFromPlatformConfig converts the provided platformConfig
+// interface into meaningful XenConfig.
+func getXenConfigFromPlatformConfig(platformConfig interface{}) (*XenConfig, error) {
+	xcfgptr, ok := platformConfig.(*XenConfig)
+	if ok {
+		return xcfgptr, nil
+	}
+
+	xcfg, ok := platformConfig.(XenConfig)
+	if ok {
+		return &xcfg, nil
+	}
+
+	return nil, fmt.Errorf("could not cast Xen platform config from store")
+}
+
+// Create implements kraftkit.sh/api/machine/v1alpha1.MachineService.Create
+func (service *machineV1alpha1Service) Create(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	if machine.Status.KernelPath == "" {
+		return machine, fmt.Errorf("cannot create Xen domain without kernel")
+	}
+
+	if _, err := os.Stat(machine.Status.KernelPath); err != nil && os.IsNotExist(err) {
+		return machine, fmt.Errorf("supplied kernel path does not exist: %s", machine.Status.KernelPath)
+	}
+
+	if machine.Spec.Emulation {
+		return machine, fmt.Errorf("cannot create Xen domain with emulation")
+	}
+
+	if machine.Spec.GDBPort > 0 {
+		return machine, fmt.Errorf("cannot create Xen domain with a GDB server: not supported")
+	}
+
+	// Xen domains use paravirtualized devices rather than virtio.
+	if len(machine.Spec.Transport) > 0 || len(machine.Spec.NetworkModel) > 0 || machine.Spec.MicroVM {
+		return machine, fmt.Errorf("cannot create Xen domain with virtio device options: not supported")
+	}
+
+	if len(machine.Spec.Ports) > 0 {
+		return machine, fmt.Errorf("cannot publish ports of Xen domain: attach it to a network instead")
+	}
+
+	xcfg := XenConfig{
+		OnPoweroff: XenActionDestroy,
+		OnReboot:   XenActionDestroy,
+		OnCrash:    XenActionPreserve,
+	}
+
+	// Unikraft is built as a paravirtualized guest on x86_64, whereas Xen on ARM
+	// only supports PVH guests.
+	switch machine.Spec.Architecture {
+	case "x86_64", "amd64":
+		xcfg.Type = XenDomainTypePV
+	case "arm64":
+		xcfg.Type = XenDomainTypePVH
+	default:
+		return machine, fmt.Errorf("unsupported architecture: %s", machine.Spec.Architecture)
+	}
+
+	if machine.ObjectMeta.UID == "" {
+		machine.ObjectMeta.UID = uuid.NewUUID()
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateUnknown
+
+	if len(machine.Status.StateDir) == 0 {
+		machine.Status.StateDir = filepath.Join(config.G[config.KraftKit](ctx).RuntimeDir, string(machine.ObjectMeta.UID))
+	}
+
+	if err := os.MkdirAll(machine.Status.StateDir, fs.ModeSetgid|0o775); err != nil {
+		return machine, err
+	}
+
+	// Set and create the log file for this machine
+	if len(machine.Status.LogFile) == 0 {
+		machine.Status.LogFile = filepath.Join(machine.Status.StateDir, "machine.log")
+	}
+
+	if machine.Spec.Resources.Requests == nil {
+		machine.Spec.Resources.Requests = make(corev1.ResourceList, 2)
+	}
+
+	if machine.Spec.Resources.Requests.Memory().Value() == 0 {
+		quantity, err := resource.ParseQuantity("64Mi")
+		if err != nil {
+			machine.Status.State = machinev1alpha1.MachineStateFailed
+			return machine, err
+		}
+
+		machine.Spec.Resources.Requests[corev1.ResourceMemory] = quantity
+	}
+
+	if machine.Spec.Resources.Requests.Cpu().Value() == 0 {
+		quantity, err := resource.ParseQuantity("1")
+		if err != nil {
+			machine.Status.State = machinev1alpha1.MachineStateFailed
+			return machine, err
+		}
+
+		machine.Spec.Resources.Requests[corev1.ResourceCPU] = quantity
+	}
+
+	xcfg.Name = string(machine.ObjectMeta.UID)
+	xcfg.Kernel = machine.Status.KernelPath
+	xcfg.Ramdisk = machine.Status.InitrdPath
+	xcfg.Memory = machine.Spec.Resources.Requests.Memory().Value() / XenMemoryScale
+	xcfg.Vcpus = machine.Spec.Resources.Requests.Cpu().Value()
+	xcfg.ConfigFile = filepath.Join(machine.Status.StateDir, "xen.cfg")
+
+	kernelArgs, err := ukargparse.Parse(machine.Spec.KernelArgs...)
+	if err != nil {
+		return machine, err
+	}
+
+	if len(machine.Spec.Networks) > 0 {
+		// Start MAC addresses iteratively.  Each interface will have the last
+		// hexdecimal byte increase by 1 starting at 1, allowing for easy-to-spot
+		// interface IDs from the MAC address.  The return value below returns `:00`
+		// as the last byte.
+		startMac, err := macaddr.GenerateMacAddress(true)
+		if err != nil {
+			return machine, err
+		}
+
+		i := 0 // host network ID.
+
+		// Iterate over each interface of each network interface associated with
+		// this machine and attach it as a vif on the network's bridge.
+		for _, network := range machine.Spec.Networks {
+			for _, iface := range network.Interfaces {
+				mac := iface.Spec.MacAddress
+				if mac == "" {
+					// Increase the MAC address value by 1 such that we are able to
+					// identify interface IDs.
+					startMac = macaddr.IncrementMacAddress(startMac)
+					mac = startMac.String()
+				}
+
+				// The backend interface is created by the toolstack itself, so the
+				// interface's tap device which was allocated by the network is not
+				// re-used as it would otherwise collide.
+				xcfg.Vifs = append(xcfg.Vifs, XenVif{
+					Bridge: network.IfName,
+					Mac:    mac,
+				})
+
+				// Assign the first interface statically via command-line arguments, also
+				// checking if the built-in arguments for
+				if !kernelArgs.Contains(uknetdev.ParamIpv4Addr) && !kernelArgs.Contains(uknetdev.ParamIp) && i == 0 {
+					ipv4, err := network.IPv4Subnet()
+					if err != nil {
+						return machine, err
+					}
+
+					nameserver, domain := daemon.Resolver(network)
+
+					kernelArgs = append(kernelArgs, uknetdev.StaticParams(
+						net.ParseIP(iface.Spec.IP), ipv4,
+						nameserver, domain,
+						machine.Spec.LegacyNetdevParams,
+					)...)
+				}
+
+				// Increment the host network ID for additional interfaces.
+				i++
+			}
+		}
+	}
+
+	var fstab []string
+
+	for i, vol := range machine.Spec.Volumes {
+		switch vol.Spec.Driver {
+		case "9pfs":
+			mounttag := fmt.Sprintf("fs%d", i+1)
+			xcfg.P9 = append(xcfg.P9, XenP9{
+				Tag:  mounttag,
+				Path: vol.Spec.Source,
+			})
+
+			fstab = append(fstab, vfscore.NewFstabEntry(
+				mounttag,
+				vol.Spec.Destination,
+				vol.Spec.Driver,
+				"",
+				"",
+				// By default, create the directory if it does not exist when mounting.
+				"mkpath",
+			).String())
+
+		case "initrd":
+			fstab = append(fstab, vfscore.NewFstabEntry(
+				"initrd",
+				vol.Spec.Destination,
+				vol.Spec.Driver,
+				"",
+				"",
+				// By default, create the directory if it does not exist when mounting.
+				"mkpath",
+			).String())
+
+		default:
+			return machine, fmt.Errorf("unsupported Xen volume driver: %v", vol.Spec.Driver)
+		}
+	}
+
+	if len(fstab) > 0 {
+		kernelArgs = append(kernelArgs,
+			vfscore.ParamVfsFstab.WithValue(fstab),
+		)
+	}
+
+	// Kernel arguments are separated from the application arguments by "--", as
+	// is standard for Unikraft.
+	args := kernelArgs.Strings()
+	if len(args) > 0 {
+		args = append(args, "--")
+	}
+
+	// The program name is provided by Unikraft's Xen platform itself and does not
+	// need to be prepended.
+	args = append(args, machine.Spec.ApplicationArgs...)
+	xcfg.Cmdline = run.BootArgsPrepare(args...)
+
+	if err := os.WriteFile(xcfg.ConfigFile, []byte(xcfg.String()), 0o644); err != nil {
+		machine.Status.State = machinev1alpha1.MachineStateFailed
+		return machine, fmt.Errorf("could not write Xen domain configuration: %v", err)
+	}
+
+	machine.Status.PlatformConfig = xcfg
+	machine.CreationTimestamp = metav1.Now()
+
+	// The domain is instead created from the snapshot's saved state, see
+	// Restore.
+	if len(machine.Spec.RestoreFrom) > 0 {
+		machine.Status.State = machinev1alpha1.MachineStateCreated
+		return machine, nil
+	}
+
+	// Create the domain in a paused state such that it is only executed once the
+	// machine is started.
+	if _, err := service.xl(ctx, "create", "-p", xcfg.ConfigFile); err != nil {
+		machine.Status.State = machinev1alpha1.MachineStateFailed
+		return machine, err
+	}
+
+	if err := service.attachConsole(ctx, machine); err != nil {
+		machine.Status.State = machinev1alpha1.MachineStateFailed
+		return machine, err
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateCreated
+
+	return machine, nil
+}
+
+// attachConsole spawns a process which captures the console of the machine's
+// domain into the machine's log file.  The process exits by itself once the
+// domain has been destroyed.
+func (service *machineV1alpha1Service) attachConsole(ctx context.Context, machine *machinev1alpha1.Machine) error {
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(machine.Status.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	defer logFile.Close()
+
+	process, err := exec.NewProcess(service.xlBin, []string{"console", xcfg.Name},
+		exec.WithStdout(logFile),
+		exec.WithDetach(true),
+	)
+	if err != nil {
+		return fmt.Errorf("could not prepare console process: %v", err)
+	}
+
+	if err := process.Start(ctx); err != nil {
+		return fmt.Errorf("could not attach to console of domain: %v", err)
+	}
+
+	xcfg.ConsolePid, err = process.Pid()
+	if err != nil {
+		return err
+	}
+
+	if err := process.Release(); err != nil {
+		return err
+	}
+
+	machine.Status.PlatformConfig = *xcfg
+
+	return nil
+}
+
+// Update implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Update(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	if machine.Status.State != machinev1alpha1.MachineStateRunning && machine.Status.State != machinev1alpha1.MachineStatePaused {
+		return machine, fmt.Errorf("cannot update machine in %s state", machine.Status.State)
+	}
+
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	// The memory of a domain can only be adjusted up to the amount which it was
+	// created with.
+	memory := machine.Spec.Resources.Requests.Memory().Value() / XenMemoryScale
+	if memory > xcfg.Memory {
+		return machine, fmt.Errorf("cannot increase memory of Xen domain beyond %dMiB", xcfg.Memory)
+	}
+
+	domain, err := service.domain(ctx, xcfg.Name)
+	if err != nil {
+		return machine, fmt.Errorf("could not query Xen domain: %v", err)
+	} else if domain == nil {
+		return machine, fmt.Errorf("could not find Xen domain %s", xcfg.Name)
+	}
+
+	if domain.Memory != memory {
+		if _, err := service.xl(ctx, "mem-set", xcfg.Name, fmt.Sprintf("%dm", memory)); err != nil {
+			return machine, err
+		}
+	}
+
+	return machine, nil
+}
+
+// Watch implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Watch(ctx context.Context, machine *machinev1alpha1.Machine) (chan *machinev1alpha1.Machine, chan error, error) {
+	if _, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig); err != nil {
+		return nil, nil, err
+	}
+
+	events := make(chan *machinev1alpha1.Machine)
+	errs := make(chan error)
+
+	// The toolstack does not provide a means of subscribing to changes of a
+	// domain, so its state is polled and an event is sent whenever it changes.
+	go func() {
+		ticker := time.NewTicker(service.pollInterval)
+		defer ticker.Stop()
+
+		var lastState machinev1alpha1.MachineState
+
+		for {
+			machine, err := service.Get(ctx, machine)
+			if err != nil {
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+					return
+				}
+			} else if machine.Status.State != lastState {
+				lastState = machine.Status.State
+
+				select {
+				case events <- machine:
+				case <-ctx.Done():
+					return
+				}
+
+				if lastState == machinev1alpha1.MachineStateExited {
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events, errs, nil
+}
+
+// Start implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Start(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	// Domains are always created paused, so starting and resuming are the same.
+	if _, err := service.xl(ctx, "unpause", xcfg.Name); err != nil {
+		return machine, fmt.Errorf("could not start Xen domain: %v", err)
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateRunning
+	if machine.Status.StartedAt.IsZero() {
+		machine.Status.StartedAt = time.Now()
+	}
+
+	return machine, nil
+}
+
+// Pause implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Pause(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	if _, err := service.xl(ctx, "pause", xcfg.Name); err != nil {
+		return machine, fmt.Errorf("could not pause Xen domain: %v", err)
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStatePaused
+
+	return machine, nil
+}
+
+// Logs implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Logs(ctx context.Context, machine *machinev1alpha1.Machine) (chan string, chan error, error) {
+	return logtail.NewLogTail(ctx, machine.Status.LogFile)
+}
+
+// Get implements kraftkit.sh/api/machine/v1alpha1/MachineService.Get
+func (service *machineV1alpha1Service) Get(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	// A machine which is about to be restored does not yet have a domain.
+	if machine.Status.State == machinev1alpha1.MachineStateCreated && len(machine.Spec.RestoreFrom) > 0 {
+		return machine, nil
+	}
+
+	domain, err := service.domain(ctx, xcfg.Name)
+	if err != nil {
+		return machine, fmt.Errorf("could not query Xen domain: %v", err)
+	}
+
+	savedState := machine.Status.State
+	state := machinev1alpha1.MachineStateUnknown
+
+	// Domains are destroyed once they power off, see XenConfig.OnPoweroff.
+	switch {
+	case domain == nil || domain.Shutdown():
+		state = machinev1alpha1.MachineStateExited
+		if savedState == machinev1alpha1.MachineStateExited {
+			break
+		}
+
+		machine.Status.ExitCode = 0
+		if machine.Status.ExitedAt.IsZero() {
+			machine.Status.ExitedAt = time.Now()
+		}
+
+	case domain.Crashed():
+		state = machinev1alpha1.MachineStateErrored
+		machine.Status.ExitCode = 1
+		if machine.Status.ExitedAt.IsZero() {
+			machine.Status.ExitedAt = time.Now()
+		}
+
+	case domain.Paused():
+		// Freshly created domains are paused until they are started.
+		if savedState == machinev1alpha1.MachineStateCreated {
+			state = machinev1alpha1.MachineStateCreated
+		} else {
+			state = machinev1alpha1.MachineStatePaused
+		}
+
+	case domain.Running():
+		state = machinev1alpha1.MachineStateRunning
+		if machine.Status.StartedAt.IsZero() {
+			machine.Status.StartedAt = time.Now()
+		}
+	}
+
+	machine.Status.State = state
+
+	return machine, nil
+}
+
+// List implements kraftkit.sh/api/machine/v1alpha1.MachineService.List
+func (service *machineV1alpha1Service) List(ctx context.Context, machines *machinev1alpha1.MachineList) (*machinev1alpha1.MachineList, error) {
+	cached := machines.Items
+	machines.Items = make([]zip.Object[machinev1alpha1.MachineSpec, machinev1alpha1.MachineStatus], len(cached))
+
+	// Iterate through each machine and grab the latest status
+	for i, machine := range cached {
+		machine, err := service.Get(ctx, &machine)
+		if err != nil {
+			machines.Items = cached
+			return machines, err
+		}
+
+		machines.Items[i] = *machine
+	}
+
+	return machines, nil
+}
+
+// Stop implements kraftkit.sh/api/machine/v1alpha1.MachineService.Stop
+func (service *machineV1alpha1Service) Stop(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	domain, err := service.domain(ctx, xcfg.Name)
+	if err != nil {
+		return machine, fmt.Errorf("could not query Xen domain: %v", err)
+	}
+
+	machine.Status.StopStage = machinev1alpha1.MachineStopStageShutdown
+
+	if domain != nil {
+		// The guest is first asked to shut down gracefully, unless it is paused
+		// and therefore cannot respond to the request, before its domain is
+		// destroyed, which is the equivalent of killing its process.
+		stopped := !domain.Paused() && shutdown.Shutdown(ctx, machine,
+			func(ctx context.Context) error {
+				_, err := service.xl(ctx, "shutdown", xcfg.Name)
+				return err
+			},
+			func(ctx context.Context) bool {
+				domain, err := service.domain(ctx, xcfg.Name)
+				return err == nil && domain == nil
+			},
+		)
+
+		if !stopped {
+			if _, err := service.xl(ctx, "destroy", xcfg.Name); err != nil {
+				return machine, fmt.Errorf("could not stop Xen domain: %v", err)
+			}
+
+			machine.Status.StopStage = machinev1alpha1.MachineStopStageKill
+		}
+	}
+
+	if err := stopConsole(xcfg); err != nil {
+		return machine, err
+	}
+
+	machine.Status.State = machinev1alpha1.MachineStateExited
+	machine.Status.ExitedAt = time.Now()
+
+	return machine, nil
+}
+
+// stopConsole terminates the process which captures the console of the
+// domain, if it is still running.
+func stopConsole(xcfg *XenConfig) error {
+	if xcfg.ConsolePid == 0 {
+		return nil
+	}
+
+	process, err := goprocess.NewProcess(int32(xcfg.ConsolePid))
+	if err != nil {
+		// The process has already exited.
+		return nil
+	}
+
+	if running, err := process.IsRunning(); err != nil || !running {
+		return nil
+	}
+
+	if err := process.Terminate(); err != nil {
+		return fmt.Errorf("could not terminate console process %d: %v", xcfg.ConsolePid, err)
+	}
+
+	return nil
+}
+
+// Delete implements kraftkit.sh/api/machine/v1alpha1.MachineService.Delete
+func (service *machineV1alpha1Service) Delete(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	var errs merr.Errors
+
+	if machine.Status.State != machinev1alpha1.MachineStateExited {
+		if _, err := service.Stop(ctx, machine); err != nil {
+			errs = append(errs, err)
+		}
+	} else {
+		errs = append(errs, stopConsole(xcfg))
+	}
+
+	if err := os.RemoveAll(machine.Status.StateDir); err != nil {
+		errs = append(errs, fmt.Errorf("error deleting Xen domain's state directory %s: %w", machine.Status.StateDir, err))
+	}
+
+	return nil, errs.Err()
+}
+
+// Snapshot implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Snapshot(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	snapshot, err := machinev1alpha1.NewMachineSnapshot(machine)
+	if err != nil {
+		return machine, err
+	}
+
+	snapshot.StatePath = filepath.Join(snapshot.Path, "state")
+
+	// Checkpoint the domain such that it continues to execute once it has been
+	// saved.  Paused domains remain paused.
+	args := []string{"save", "-c"}
+	if machine.Status.State == machinev1alpha1.MachineStatePaused {
+		args = append(args, "-p")
+	}
+
+	if _, err := service.xl(ctx, append(args, xcfg.Name, snapshot.StatePath)...); err != nil {
+		return machine, fmt.Errorf("could not snapshot Xen domain: %v", err)
+	}
+
+	if err := machinev1alpha1.SaveMachineSnapshotManifest(machine, snapshot); err != nil {
+		return machine, err
+	}
+
+	machine.Status.Snapshots = append(machine.Status.Snapshots, *snapshot)
+
+	return machine, nil
+}
+
+// Restore implements kraftkit.sh/api/machine/v1alpha1.MachineService
+func (service *machineV1alpha1Service) Restore(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	if len(machine.Spec.RestoreFrom) == 0 {
+		return machine, fmt.Errorf("cannot restore machine without snapshot")
+	}
+
+	manifest, err := machinev1alpha1.LoadMachineSnapshotManifest(machine.Spec.RestoreFrom)
+	if err != nil {
+		return machine, err
+	}
+
+	if manifest.Snapshot.Platform != machine.Spec.Platform {
+		return machine, fmt.Errorf("cannot restore %s snapshot with %s platform", manifest.Snapshot.Platform, machine.Spec.Platform)
+	}
+
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	// The configuration embedded in the saved state is overridden such that the
+	// restored domain is given the name of this machine.
+	if _, err := service.xl(ctx, "restore", "-p", xcfg.ConfigFile, manifest.Snapshot.StatePath); err != nil {
+		return machine, fmt.Errorf("could not restore Xen domain: %v", err)
+	}
+
+	if err := service.attachConsole(ctx, machine); err != nil {
+		return machine, err
+	}
+
+	// The machine remains paused until it is started.
+	machine.Status.State = machinev1alpha1.MachineStatePaused
+
+	return machine, nil
+}
+
+// Stats implements kraftkit.sh/api/machine/v1alpha1.MachineService.Stats
+//
+// Domains are not backed by a process on the host, so only the CPU time and
+// memory which Xen reports for the domain and the traffic of its backend
+// interfaces are available.
+func (service *machineV1alpha1Service) Stats(ctx context.Context, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+	xcfg, err := getXenConfigFromPlatformConfig(machine.Status.PlatformConfig)
+	if err != nil {
+		return machine, err
+	}
+
+	domain, err := service.domain(ctx, xcfg.Name)
+	if err != nil {
+		return machine, fmt.Errorf("could not query Xen domain: %v", err)
+	} else if domain == nil {
+		return machine, fmt.Errorf("Xen domain %s does not exist", xcfg.Name)
+	}
+
+	usage := machinev1alpha1.MachineResourceUsage{
+		CPUTime:     domain.CPUTime,
+		GuestMemory: domain.Memory * XenMemoryScale,
+	}
+
+	// The toolstack names the backend interface of each vif after the domain
+	// and the index of the vif.
+	for i := range xcfg.Vifs {
+		iface, err := stats.Interface(fmt.Sprintf("vif%d.%d", domain.ID, i))
+		if err != nil {
+			continue
+		}
+
+		usage.Interfaces = append(usage.Interfaces, *iface)
+	}
+
+	machine.Status.Usage = usage
+
+	return machine, nil
+}
@@ -0,0 +1,428 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package xen_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	machinev1alpha1 "kraftkit.sh/api/machine/v1alpha1"
+	networkv1alpha1 "kraftkit.sh/api/network/v1alpha1"
+	volumev1alpha1 "kraftkit.sh/api/volume/v1alpha1"
+	"kraftkit.sh/machine/xen"
+)
+
+const testDomain = "kraftkit-test"
+
+// stubXl emulates the subset of the Xen toolstack which is used by the
+// service.  Each invocation other than console and list is recorded in the
+// calls file of the directory in $XL_STATE and the configuration which a domain
+// is created from is copied to the config file, whereas the state and memory of
+// the single domain are kept in the state and mem files.  Requests to shut
+// down are ignored if the ignore-shutdown file exists.
+const stubXl = `#!/bin/sh
+case "$1" in
+console)
+	exit 0
+	;;
+list)
+	echo "Name                                        ID   Mem VCPUs      State   Time(s)"
+	echo "Domain-0                                     0  2048     4     r-----     112.5"
+	if [ -e "$XL_STATE/state" ]; then
+		echo "` + testDomain + `                                 1 $(cat "$XL_STATE/mem")     1     $(cat "$XL_STATE/state")-----       0.2"
+	fi
+	exit 0
+	;;
+esac
+
+echo "$*" >> "$XL_STATE/calls"
+
+case "$1" in
+create)
+	cp "$3" "$XL_STATE/config"
+	echo p > "$XL_STATE/state"
+	echo 64 > "$XL_STATE/mem"
+	;;
+unpause)
+	echo r > "$XL_STATE/state"
+	;;
+pause)
+	echo p > "$XL_STATE/state"
+	;;
+mem-set)
+	echo "${3%m}" > "$XL_STATE/mem"
+	;;
+shutdown)
+	[ -e "$XL_STATE/ignore-shutdown" ] || rm -f "$XL_STATE/state"
+	;;
+destroy)
+	rm -f "$XL_STATE/state"
+	;;
+esac
+`
+
+// newStubXl writes the stub xl into the provided directory, pointing it at a
+// new state directory, and returns the paths of both.
+func newStubXl(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	xl := filepath.Join(dir, "xl")
+	if err := os.WriteFile(xl, []byte(stubXl), 0o755); err != nil {
+		t.Fatal("Failed to write stub xl:", err)
+	}
+
+	state := filepath.Join(dir, "state")
+	if err := os.Mkdir(state, 0o755); err != nil {
+		t.Fatal("Failed to create stub state directory:", err)
+	}
+
+	t.Setenv("XL_STATE", state)
+
+	return xl, state
+}
+
+func TestXenConfigString(t *testing.T) {
+	cfg := xen.XenConfig{
+		Name:    "test",
+		Type:    xen.XenDomainTypePVH,
+		Kernel:  "/path/to/kernel",
+		Ramdisk: "/path/to/initrd",
+		Cmdline: `'a="b"' 'c\d'`,
+		Memory:  32,
+		Vcpus:   2,
+		Vifs: []xen.XenVif{
+			{Bridge: "xenbr0", Mac: "02:00:00:00:00:01"},
+			{Bridge: "xenbr1", VifName: "vif-test"},
+		},
+		P9: []xen.XenP9{
+			{Tag: "fs1", Path: "/data"},
+			{Tag: "fs2", Path: "/logs", SecurityModel: "mapped-xattr"},
+		},
+		OnPoweroff: xen.XenActionDestroy,
+		OnCrash:    xen.XenActionPreserve,
+	}
+
+	want := `name = "test"
+type = "pvh"
+kernel = "/path/to/kernel"
+ramdisk = "/path/to/initrd"
+cmdline = "'a=\"b\"' 'c\\d'"
+memory = 32
+vcpus = 2
+vif = [ "bridge=xenbr0,mac=02:00:00:00:00:01", "bridge=xenbr1,vifname=vif-test" ]
+p9 = [ "tag=fs1,security_model=none,path=/data", "tag=fs2,security_model=mapped-xattr,path=/logs" ]
+on_poweroff = "destroy"
+on_crash = "preserve"
+`
+
+	if got := cfg.String(); got != want {
+		t.Errorf("Expected config:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestMachineV1alpha1ServiceConfig(t *testing.T) {
+	tests := []struct {
+		arch   string
+		memory string
+		cpus   string
+		want   string
+	}{
+		{
+			arch:   "x86_64",
+			memory: "64Mi",
+			cpus:   "1",
+			want: `name = "{domain}"
+type = "pv"
+kernel = "{kernel}"
+cmdline = "'netdev.ip=10.0.0.2/24:10.0.0.1' 'vfs.fstab=[ \"fs1:/data:9pfs:::mkpath\" ]' '--' 'hello' "
+memory = 64
+vcpus = 1
+vif = [ "bridge=xenbr0,mac=02:00:00:00:00:01" ]
+p9 = [ "tag=fs1,security_model=none,path={share}" ]
+on_poweroff = "destroy"
+on_reboot = "destroy"
+on_crash = "preserve"
+`,
+		},
+		{
+			arch:   "arm64",
+			memory: "128Mi",
+			cpus:   "2",
+			want: `name = "{domain}"
+type = "pvh"
+kernel = "{kernel}"
+cmdline = "'netdev.ip=10.0.0.2/24:10.0.0.1' 'vfs.fstab=[ \"fs1:/data:9pfs:::mkpath\" ]' '--' 'hello' "
+memory = 128
+vcpus = 2
+vif = [ "bridge=xenbr0,mac=02:00:00:00:00:01" ]
+p9 = [ "tag=fs1,security_model=none,path={share}" ]
+on_poweroff = "destroy"
+on_reboot = "destroy"
+on_crash = "preserve"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			xl, state := newStubXl(t, dir)
+
+			kernel := filepath.Join(dir, "kernel")
+			if err := os.WriteFile(kernel, nil, 0o644); err != nil {
+				t.Fatal("Failed to write kernel:", err)
+			}
+
+			share := filepath.Join(dir, "share")
+
+			service, err := xen.NewMachineV1alpha1Service(ctx, xen.WithXlBin(xl))
+			if err != nil {
+				t.Fatal("NewMachineV1alpha1Service:", err)
+			}
+
+			machine := &machinev1alpha1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+					UID:  testDomain,
+				},
+				Spec: machinev1alpha1.MachineSpec{
+					Architecture:    tt.arch,
+					KernelArgs:      []string{"netdev.ip=10.0.0.2/24:10.0.0.1"},
+					ApplicationArgs: []string{"hello"},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceMemory: resource.MustParse(tt.memory),
+							corev1.ResourceCPU:    resource.MustParse(tt.cpus),
+						},
+					},
+					Networks: []networkv1alpha1.NetworkSpec{{
+						IfName: "xenbr0",
+						Interfaces: []networkv1alpha1.NetworkInterfaceTemplateSpec{{
+							Spec: networkv1alpha1.NetworkInterfaceSpec{
+								IP:         "10.0.0.2",
+								MacAddress: "02:00:00:00:00:01",
+							},
+						}},
+					}},
+					Volumes: []volumev1alpha1.Volume{{
+						Spec: volumev1alpha1.VolumeSpec{
+							Driver:      "9pfs",
+							Source:      share,
+							Destination: "/data",
+						},
+					}},
+				},
+				Status: machinev1alpha1.MachineStatus{
+					KernelPath: kernel,
+					StateDir:   filepath.Join(dir, "machine"),
+				},
+			}
+
+			if _, err := service.Create(ctx, machine); err != nil {
+				t.Fatal("Create:", err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(state, "config"))
+			if err != nil {
+				t.Fatal("Failed to read config recorded by stub xl:", err)
+			}
+
+			want := strings.NewReplacer(
+				"{domain}", testDomain,
+				"{kernel}", kernel,
+				"{share}", share,
+			).Replace(tt.want)
+
+			if got := string(b); got != want {
+				t.Errorf("Expected config:\n%s\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestMachineV1alpha1Service(t *testing.T) {
+	type op func(context.Context, machinev1alpha1.MachineService, *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error)
+
+	var (
+		start = func(ctx context.Context, service machinev1alpha1.MachineService, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+			return service.Start(ctx, machine)
+		}
+		stop = func(ctx context.Context, service machinev1alpha1.MachineService, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+			return service.Stop(ctx, machine)
+		}
+		update = func(memory string) op {
+			return func(ctx context.Context, service machinev1alpha1.MachineService, machine *machinev1alpha1.Machine) (*machinev1alpha1.Machine, error) {
+				machine.Spec.Resources.Requests[corev1.ResourceMemory] = resource.MustParse(memory)
+				return service.Update(ctx, machine)
+			}
+		}
+	)
+
+	tests := []struct {
+		name           string
+		ignoreShutdown bool
+		ops            []op
+		wantCalls      []string
+		wantState      machinev1alpha1.MachineState
+		wantStopStage  machinev1alpha1.MachineStopStage
+		wantErr        bool
+	}{
+		{
+			name:      "Create",
+			wantCalls: []string{"create -p {config}"},
+			wantState: machinev1alpha1.MachineStateCreated,
+		},
+		{
+			name:      "Start",
+			ops:       []op{start},
+			wantCalls: []string{"create -p {config}", "unpause {domain}"},
+			wantState: machinev1alpha1.MachineStateRunning,
+		},
+		{
+			name:          "Stop gracefully",
+			ops:           []op{start, stop},
+			wantCalls:     []string{"create -p {config}", "unpause {domain}", "shutdown {domain}"},
+			wantState:     machinev1alpha1.MachineStateExited,
+			wantStopStage: machinev1alpha1.MachineStopStageShutdown,
+		},
+		{
+			name:           "Stop after ignored shutdown",
+			ignoreShutdown: true,
+			ops:            []op{start, stop},
+			wantCalls:      []string{"create -p {config}", "unpause {domain}", "shutdown {domain}", "destroy {domain}"},
+			wantState:      machinev1alpha1.MachineStateExited,
+			wantStopStage:  machinev1alpha1.MachineStopStageKill,
+		},
+		{
+			name:          "Stop paused",
+			ops:           []op{stop},
+			wantCalls:     []string{"create -p {config}", "destroy {domain}"},
+			wantState:     machinev1alpha1.MachineStateExited,
+			wantStopStage: machinev1alpha1.MachineStopStageKill,
+		},
+		{
+			name:      "Update memory",
+			ops:       []op{start, update("32Mi")},
+			wantCalls: []string{"create -p {config}", "unpause {domain}", "mem-set {domain} 32m"},
+			wantState: machinev1alpha1.MachineStateRunning,
+		},
+		{
+			name:      "Update unchanged memory",
+			ops:       []op{start, update("64Mi")},
+			wantCalls: []string{"create -p {config}", "unpause {domain}"},
+			wantState: machinev1alpha1.MachineStateRunning,
+		},
+		{
+			name:      "Update memory beyond creation",
+			ops:       []op{start, update("128Mi")},
+			wantCalls: []string{"create -p {config}", "unpause {domain}"},
+			wantState: machinev1alpha1.MachineStateRunning,
+			wantErr:   true,
+		},
+		{
+			name:      "Update created",
+			ops:       []op{update("32Mi")},
+			wantCalls: []string{"create -p {config}"},
+			wantState: machinev1alpha1.MachineStateCreated,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			xl, state := newStubXl(t, dir)
+
+			if tt.ignoreShutdown {
+				if err := os.WriteFile(filepath.Join(state, "ignore-shutdown"), nil, 0o644); err != nil {
+					t.Fatal("Failed to configure stub xl:", err)
+				}
+			}
+
+			kernel := filepath.Join(dir, "kernel")
+			if err := os.WriteFile(kernel, nil, 0o644); err != nil {
+				t.Fatal("Failed to write kernel:", err)
+			}
+
+			service, err := xen.NewMachineV1alpha1Service(ctx, xen.WithXlBin(xl))
+			if err != nil {
+				t.Fatal("NewMachineV1alpha1Service:", err)
+			}
+
+			stopTimeout := time.Second
+
+			machine := &machinev1alpha1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test",
+					UID:  testDomain,
+				},
+				Spec: machinev1alpha1.MachineSpec{
+					Architecture: "x86_64",
+					StopTimeout:  &stopTimeout,
+				},
+				Status: machinev1alpha1.MachineStatus{
+					KernelPath: kernel,
+					StateDir:   filepath.Join(dir, "machine"),
+				},
+			}
+
+			machine, err = service.Create(ctx, machine)
+			if err != nil {
+				t.Fatal("Create:", err)
+			}
+
+			for _, op := range tt.ops {
+				machine, err = op(ctx, service, machine)
+				if err != nil {
+					break
+				}
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+
+			b, err := os.ReadFile(filepath.Join(state, "calls"))
+			if err != nil {
+				t.Fatal("Failed to read calls of stub xl:", err)
+			}
+
+			replacer := strings.NewReplacer(
+				"{config}", filepath.Join(machine.Status.StateDir, "xen.cfg"),
+				"{domain}", testDomain,
+			)
+
+			wantCalls := make([]string, len(tt.wantCalls))
+			for i, call := range tt.wantCalls {
+				wantCalls[i] = replacer.Replace(call)
+			}
+
+			if gotCalls := strings.Split(strings.TrimSpace(string(b)), "\n"); !reflect.DeepEqual(gotCalls, wantCalls) {
+				t.Errorf("Expected calls %q, got %q", wantCalls, gotCalls)
+			}
+
+			if machine.Status.State != tt.wantState {
+				t.Errorf("Expected state %s, got %s", tt.wantState, machine.Status.State)
+			}
+
+			if machine.Status.StopStage != tt.wantStopStage {
+				t.Errorf("Expected stop stage %q, got %q", tt.wantStopStage, machine.Status.StopStage)
+			}
+		})
+	}
+}
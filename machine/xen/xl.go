@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package xen
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"kraftkit.sh/exec"
+)
+
+// XlBin is the default name of the binary of the Xen toolstack.
+const XlBin = "xl"
+
+// xlDomain represents an entry of the output of `xl list`.
+type xlDomain struct {
+	Name   string
+	ID     int
+	Memory int64
+	Vcpus  int64
+	State  string
+
+	// CPUTime is the total time which the vCPUs of the domain have spent on
+	// the CPU.
+	CPUTime time.Duration
+}
+
+// Running indicates whether the domain is executing or waiting to execute.
+func (domain xlDomain) Running() bool {
+	return !strings.ContainsAny(domain.State, "pscd")
+}
+
+// Paused indicates whether the domain has been paused.
+func (domain xlDomain) Paused() bool {
+	return strings.Contains(domain.State, "p")
+}
+
+// Shutdown indicates whether the domain has shut down or is in the process of
+// being destroyed.
+func (domain xlDomain) Shutdown() bool {
+	return strings.ContainsAny(domain.State, "sd")
+}
+
+// Crashed indicates whether the domain has crashed.
+func (domain xlDomain) Crashed() bool {
+	return strings.Contains(domain.State, "c")
+}
+
+// xl runs the Xen toolstack with the provided arguments and returns its
+// standard output.
+func (service *machineV1alpha1Service) xl(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	eopts := make([]exec.ExecOption, 0, len(service.eopts)+2)
+	eopts = append(eopts, service.eopts...)
+	eopts = append(eopts,
+		exec.WithStdout(&stdout),
+		exec.WithStderr(&stderr),
+	)
+
+	process, err := exec.NewProcess(service.xlBin, args, eopts...)
+	if err != nil {
+		return "", fmt.Errorf("could not prepare xl: %v", err)
+	}
+
+	if err := process.StartAndWait(ctx); err != nil {
+		return "", fmt.Errorf("could not %s: %v: %s", strings.Join(append([]string{service.xlBin}, args...), " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.String(), nil
+}
+
+// domain returns the domain with the provided name or nil if it does not
+// exist.
+func (service *machineV1alpha1Service) domain(ctx context.Context, name string) (*xlDomain, error) {
+	out, err := service.xl(ctx, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	domains, err := parseXlList(out)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, domain := range domains {
+		if domain.Name == name {
+			return &domain, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// parseXlList parses the tabular output of `xl list`, e.g.:
+//
+//	Name                                        ID   Mem VCPUs      State   Time(s)
+//	Domain-0                                     0  2048     4     r-----     112.5
+//	d0e5e9f4-6a1b-4c3e-9d4a-8a3c2b1f0e7d         3    64     1     -b----       0.2
+func parseXlList(out string) ([]xlDomain, error) {
+	var domains []xlDomain
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] == "Name" {
+			continue
+		}
+
+		id, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse domain ID '%s': %v", fields[1], err)
+		}
+
+		memory, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse domain memory '%s': %v", fields[2], err)
+		}
+
+		vcpus, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse domain vCPUs '%s': %v", fields[3], err)
+		}
+
+		var cputime time.Duration
+		if len(fields) > 5 {
+			seconds, err := strconv.ParseFloat(fields[5], 64)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse domain time '%s': %v", fields[5], err)
+			}
+
+			cputime = time.Duration(seconds * float64(time.Second))
+		}
+
+		domains = append(domains, xlDomain{
+			Name:    fields[0],
+			ID:      id,
+			Memory:  memory,
+			Vcpus:   vcpus,
+			State:   strings.ReplaceAll(fields[4], "-", ""),
+			CPUTime: cputime,
+		})
+	}
+
+	return domains, scanner.Err()
+}
@@ -104,6 +104,8 @@ func (ac ArchitectureConfig) KConfig() kconfig.KeyValueMap {
 		arch.WriteString("ARCH_ARM_32")
 	case "arm64":
 		arch.WriteString("ARCH_ARM_64")
+	case "riscv64":
+		arch.WriteString("ARCH_RISCV_64")
 	}
 
 	values.Set(arch.String(), kconfig.Yes)
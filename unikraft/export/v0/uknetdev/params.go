@@ -5,18 +5,98 @@
 package uknetdev
 
 import (
+	"fmt"
+	"net"
+	"strings"
+
 	"kraftkit.sh/unikraft/export/v0/ukargparse"
 )
 
+// The legacy netdev.ipv4_* parameters are read by lib-lwip in Unikraft
+// releases before v0.14, which replaced them with netdev.ip, read by
+// lib/uknetdev when it is built with CONFIG_LIBUKNETDEV_EINFO_LIBPARAM.
+// Neither accepts a static IPv6 configuration.
 var (
 	ParamIpv4Addr       = ukargparse.ParamStr("netdev", "ipv4_addr", nil)
 	ParamIpv4SubnetMask = ukargparse.ParamStr("netdev", "ipv4_subnet_mask", nil)
 	ParamIpv4GwAddr     = ukargparse.ParamStr("netdev", "ipv4_gw_addr", nil)
+	ParamIp             = ukargparse.ParamStr("netdev", "ip", nil)
 )
 
 // ExportedParams returns the parameters available by this exported library.
 func ExportedParams() []ukargparse.Param {
 	return []ukargparse.Param{
 		ParamIpv4Addr,
+		ParamIp,
+	}
+}
+
+// NetdevIp is the static IPv4 configuration of an interface as accepted by the
+// netdev.ip parameter.
+type NetdevIp struct {
+	cidr     string
+	gateway  string
+	dns0     string
+	dns1     string
+	hostname string
+	domain   string
+}
+
+// NewNetdevIp generates the static IPv4 configuration of an interface with the
+// provided address and gateway, where the mask of the subnet is that of the
+// provided subnet.
+func NewNetdevIp(ip net.IP, subnet *net.IPNet, gateway net.IP, dns0, dns1, hostname, domain string) NetdevIp {
+	ones, _ := subnet.Mask.Size()
+
+	return NetdevIp{
+		cidr:     fmt.Sprintf("%s/%d", ip, ones),
+		gateway:  gateway.String(),
+		dns0:     dns0,
+		dns1:     dns1,
+		hostname: hostname,
+		domain:   domain,
+	}
+}
+
+// String implements fmt.Stringer and returns a valid netdev.ip-formatted
+// value, i.e. <ip>/<prefix>:<gateway>:<dns0>:<dns1>:<hostname>:<domain>,
+// without any trailing empty fields.
+func (nip NetdevIp) String() string {
+	return strings.TrimRight(strings.Join([]string{
+		nip.cidr,
+		nip.gateway,
+		nip.dns0,
+		nip.dns1,
+		nip.hostname,
+		nip.domain,
+	}, ":"), ":")
+}
+
+// StaticParams returns the parameters which statically configure an interface
+// with the provided IPv4 address, where the address of the provided subnet is
+// that of its gateway.  The configuration is passed via netdev.ip, including
+// the name server and domain, which may be nil and empty respectively, unless
+// legacy is set, in which case it is passed via the netdev.ipv4_* parameters
+// which older kernels expect and which cannot hold the name server or domain.
+func StaticParams(ip net.IP, subnet *net.IPNet, nameserver net.IP, domain string, legacy bool) []ukargparse.Param {
+	if ip == nil || subnet == nil {
+		return nil
+	}
+
+	if legacy {
+		return []ukargparse.Param{
+			ParamIpv4Addr.WithValue(ip.String()),
+			ParamIpv4GwAddr.WithValue(subnet.IP.String()),
+			ParamIpv4SubnetMask.WithValue(net.IP(subnet.Mask).String()),
+		}
+	}
+
+	dns0 := ""
+	if nameserver != nil {
+		dns0 = nameserver.String()
+	}
+
+	return []ukargparse.Param{
+		ParamIp.WithValue(NewNetdevIp(ip, subnet, subnet.IP, dns0, "", "", domain).String()),
 	}
 }
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: BSD-3-Clause
+// Copyright (c) 2022, Unikraft GmbH and The KraftKit Authors.
+// Licensed under the BSD-3-Clause License (the "License").
+// You may not use this file except in compliance with the License.
+package uknetdev
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestStaticParams(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.1.2.128/26")
+	subnet.IP = net.ParseIP("10.1.2.129").To4()
+
+	tests := []struct {
+		name       string
+		ip         net.IP
+		subnet     *net.IPNet
+		nameserver net.IP
+		domain     string
+		legacy     bool
+		want       []string
+	}{
+		{
+			name:   "netdev.ip",
+			ip:     net.ParseIP("10.1.2.130"),
+			subnet: subnet,
+			want:   []string{"netdev.ip=10.1.2.130/26:10.1.2.129"},
+		},
+		{
+			name:       "netdev.ip with name server and domain",
+			ip:         net.ParseIP("10.1.2.130"),
+			subnet:     subnet,
+			nameserver: net.ParseIP("10.1.2.129"),
+			domain:     "kraft",
+			want:       []string{"netdev.ip=10.1.2.130/26:10.1.2.129:10.1.2.129:::kraft"},
+		},
+		{
+			name:       "legacy",
+			ip:         net.ParseIP("10.1.2.130"),
+			subnet:     subnet,
+			nameserver: net.ParseIP("10.1.2.129"),
+			domain:     "kraft",
+			legacy:     true,
+			want: []string{
+				"netdev.ipv4_addr=10.1.2.130",
+				"netdev.ipv4_gw_addr=10.1.2.129",
+				"netdev.ipv4_subnet_mask=255.255.255.192",
+			},
+		},
+		{
+			name:   "without address",
+			subnet: subnet,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, param := range StaticParams(tt.ip, tt.subnet, tt.nameserver, tt.domain, tt.legacy) {
+				got = append(got, param.String())
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
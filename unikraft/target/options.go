@@ -77,3 +77,27 @@ func WithCommand(command []string) TargetOption {
 		return nil
 	}
 }
+
+// WithTransport sets the transport of the virtio devices of the target.
+func WithTransport(transport string) TargetOption {
+	return func(tc *TargetConfig) error {
+		tc.transport = transport
+		return nil
+	}
+}
+
+// WithNIC sets the model of the network devices of the target.
+func WithNIC(nic string) TargetOption {
+	return func(tc *TargetConfig) error {
+		tc.nic = nic
+		return nil
+	}
+}
+
+// WithMicroVM sets whether the target is run on a minimal virtual machine.
+func WithMicroVM(microVM bool) TargetOption {
+	return func(tc *TargetConfig) error {
+		tc.microVM = microVM
+		return nil
+	}
+}
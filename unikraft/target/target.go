@@ -68,6 +68,18 @@ type TargetConfig struct {
 
 	// command is the command-line arguments set for this target.
 	command []string
+
+	// transport is the transport of the virtio devices of the machine which
+	// runs this target, e.g. pci or mmio.
+	transport string
+
+	// nic is the model of the network devices of the machine which runs this
+	// target.
+	nic string
+
+	// microVM indicates whether this target is run on a minimal virtual
+	// machine.
+	microVM bool
 }
 
 // NewTargetFromOptions is a constructor for TargetConfig.
@@ -131,6 +143,23 @@ func (tc *TargetConfig) Command() []string {
 	return tc.command
 }
 
+// Transport is the transport of the virtio devices of the machine which runs
+// this target.
+func (tc *TargetConfig) Transport() string {
+	return tc.transport
+}
+
+// NIC is the model of the network devices of the machine which runs this
+// target.
+func (tc *TargetConfig) NIC() string {
+	return tc.nic
+}
+
+// MicroVM returns whether this target is run on a minimal virtual machine.
+func (tc *TargetConfig) MicroVM() bool {
+	return tc.microVM
+}
+
 func (tc *TargetConfig) IsUnpacked() bool {
 	return false
 }
@@ -84,6 +84,20 @@ func TransformFromSchema(ctx context.Context, data interface{}) (interface{}, er
 			case "kernel":
 				t.name = prop.(string)
 
+			case "transport":
+				t.transport = fmt.Sprint(prop)
+
+			case "nic":
+				t.nic = fmt.Sprint(prop)
+
+			case "microvm":
+				microVM, ok := prop.(bool)
+				if !ok {
+					return nil, fmt.Errorf("invalid type %T for microvm", prop)
+				}
+
+				t.microVM = microVM
+
 			case "kconfig":
 				switch tprop := prop.(type) {
 				case map[string]interface{}: